@@ -0,0 +1,354 @@
+// ./cmd/clinicctl/accounts.go
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"onlineClinic/models"
+	"onlineClinic/utils"
+	"onlineClinic/validation"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// account is what clinicctl shows of a doctor or patient. It leaves out the
+// password hash and the profile details.
+type account struct {
+	ID            int        `json:"id"`
+	Role          string     `json:"role"`
+	FirstName     string     `json:"firstName"`
+	LastName      string     `json:"lastName"`
+	PhoneNumber   string     `json:"phoneNumber"`
+	NationalCode  string     `json:"nationalCode,omitempty"` // Not kept for staff
+	Active        bool       `json:"active"`
+	DeactivatedAt *time.Time `json:"deactivatedAt,omitempty"`
+}
+
+func (a account) matches(term string) bool {
+	term = strings.ToLower(term)
+	name := strings.ToLower(a.FirstName + " " + a.LastName)
+	return strings.Contains(name, term) ||
+		strings.Contains(a.PhoneNumber, term) ||
+		strings.Contains(a.NationalCode, term)
+}
+
+func listDoctors(store *models.Store, out *output, args []string) error {
+	return listAccounts(store, out, "doctor", args)
+}
+
+func listPatients(store *models.Store, out *output, args []string) error {
+	return listAccounts(store, out, "patient", args)
+}
+
+func listStaff(store *models.Store, out *output, args []string) error {
+	return listAccounts(store, out, "staff", args)
+}
+
+func listAccounts(store *models.Store, out *output, role string, args []string) error {
+	name := role + "s"
+	if role == "staff" {
+		name = role
+	}
+	args, err := parseArgs(flag.NewFlagSet(name, flag.ContinueOnError), out, args)
+	if err != nil {
+		return err
+	}
+	var term string
+	switch {
+	case len(args) == 0:
+	case len(args) == 2 && args[0] == "find":
+		term = args[1]
+	default:
+		return errUsage
+	}
+
+	accounts, err := loadAccounts(store, role)
+	if err != nil {
+		return err
+	}
+	found := []account{}
+	for _, a := range accounts {
+		if term == "" || a.matches(term) {
+			found = append(found, a)
+		}
+	}
+
+	return out.print(found, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tROLE\tNAME\tPHONE\tNATIONAL CODE\tSTATUS")
+		for _, a := range found {
+			status := "active"
+			if !a.Active {
+				status = "deactivated " + a.DeactivatedAt.Format("2006-01-02")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s %s\t%s\t%s\t%s\n", a.ID, a.Role, a.FirstName, a.LastName, a.PhoneNumber, optional(a.NationalCode), status)
+		}
+	})
+}
+
+func loadAccounts(store *models.Store, role string) ([]account, error) {
+	var accounts []account
+	switch role {
+	case "doctor":
+		doctors, err := store.Doctors.GetAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range doctors {
+			accounts = append(accounts, account{ID: d.ID, Role: role, FirstName: d.FirstName, LastName: d.LastName, PhoneNumber: d.PhoneNumber, NationalCode: d.NationalCode})
+		}
+	case "patient":
+		patients, err := store.Patients.GetAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range patients {
+			accounts = append(accounts, account{ID: p.ID, Role: role, FirstName: p.FirstName, LastName: p.LastName, PhoneNumber: p.PhoneNumber, NationalCode: p.NationalCode})
+		}
+	default:
+		staff, err := store.Staff.GetAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range staff {
+			accounts = append(accounts, account{ID: s.ID, Role: s.Role, FirstName: s.FirstName, LastName: s.LastName, PhoneNumber: s.PhoneNumber})
+		}
+	}
+
+	for i := range accounts {
+		at, err := repository(store, role).DeactivatedAt(accounts[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		accounts[i].DeactivatedAt = at
+		accounts[i].Active = at == nil
+	}
+	return accounts, nil
+}
+
+// accountRepository is the part of the doctor and patient repositories that
+// clinicctl changes accounts through.
+type accountRepository interface {
+	UpdatePassword(id int, newPassword string) error
+	SetActive(id int, active bool) error
+	DeactivatedAt(id int) (*time.Time, error)
+}
+
+func repository(store *models.Store, role string) accountRepository {
+	switch role {
+	case "doctor":
+		return store.Doctors
+	case "patient":
+		return store.Patients
+	default:
+		return store.Staff
+	}
+}
+
+// accountArgs parses "doctor|patient|staff <id>".
+func accountArgs(args []string) (role string, id int, err error) {
+	if len(args) != 2 || (args[0] != "doctor" && args[0] != "patient" && args[0] != "staff") {
+		return "", 0, errUsage
+	}
+	id, err = parseID(args[0]+" ID", args[1])
+	return args[0], id, err
+}
+
+func resetPassword(store *models.Store, out *output, args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ContinueOnError)
+	password := fs.String("password", "", "new password (default: generate one)")
+	args, err := parseArgs(fs, out, args)
+	if err != nil {
+		return err
+	}
+	role, id, err := accountArgs(args)
+	if err != nil {
+		return err
+	}
+
+	generated := *password == ""
+	if generated {
+		if *password, err = utils.GenerateRandomString(16); err != nil {
+			return err
+		}
+	} else if len(*password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+
+	// DeactivatedAt doubles as the existence check, since UpdatePassword
+	// succeeds for unknown IDs.
+	if _, err := repository(store, role).DeactivatedAt(id); err == sql.ErrNoRows {
+		return fmt.Errorf("%s %d not found", role, id)
+	} else if err != nil {
+		return err
+	}
+	// UpdatePassword hashes with bcrypt, like the password change endpoints.
+	if err := repository(store, role).UpdatePassword(id, *password); err != nil {
+		return err
+	}
+
+	result := struct {
+		ID       int    `json:"id"`
+		Role     string `json:"role"`
+		Password string `json:"password,omitempty"`
+	}{ID: id, Role: role}
+	if generated {
+		result.Password = *password
+	}
+	return out.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Password of %s %d reset.\n", role, id)
+		if generated {
+			fmt.Fprintf(w, "New password: %s\n", *password)
+		}
+	})
+}
+
+// resetTwoFactor turns off TOTP for a doctor who lost both the
+// authenticator and the recovery codes. If the policy requires it, they
+// enroll again at their next login.
+func resetTwoFactor(store *models.Store, out *output, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("reset-2fa", flag.ContinueOnError), out, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errUsage
+	}
+	id, err := parseID("doctor ID", args[0])
+	if err != nil {
+		return err
+	}
+
+	if _, err := store.TOTP.Get(id); err == sql.ErrNoRows {
+		return fmt.Errorf("doctor %d has no two-factor authentication", id)
+	} else if err != nil {
+		return err
+	}
+	if err := store.TOTP.Disable(id); err != nil {
+		return err
+	}
+	event := &models.AuditEvent{
+		Action:  models.AuditTwoFactorDisabled,
+		Subject: fmt.Sprintf("doctor:%d", id),
+		Detail:  "reset with clinicctl",
+	}
+	if err := store.Audit.Record(event); err != nil {
+		return err
+	}
+
+	result := struct {
+		ID int `json:"id"`
+	}{id}
+	return out.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Two-factor authentication of doctor %d reset.\n", id)
+	})
+}
+
+func deactivate(store *models.Store, out *output, args []string) error {
+	return setActive(store, out, args, false)
+}
+
+func activate(store *models.Store, out *output, args []string) error {
+	return setActive(store, out, args, true)
+}
+
+func setActive(store *models.Store, out *output, args []string, active bool) error {
+	name := "deactivate"
+	if active {
+		name = "activate"
+	}
+	args, err := parseArgs(flag.NewFlagSet(name, flag.ContinueOnError), out, args)
+	if err != nil {
+		return err
+	}
+	role, id, err := accountArgs(args)
+	if err != nil {
+		return err
+	}
+
+	if err := repository(store, role).SetActive(id, active); err == sql.ErrNoRows {
+		return fmt.Errorf("%s %d not found", role, id)
+	} else if err != nil {
+		return err
+	}
+
+	result := struct {
+		ID     int    `json:"id"`
+		Role   string `json:"role"`
+		Active bool   `json:"active"`
+	}{id, role, active}
+	return out.print(result, func(w io.Writer) {
+		if active {
+			fmt.Fprintf(w, "Activated %s %d.\n", role, id)
+		} else {
+			fmt.Fprintf(w, "Deactivated %s %d.\n", role, id)
+		}
+	})
+}
+
+func createStaff(store *models.Store, out *output, args []string) error {
+	fs := flag.NewFlagSet("create-staff", flag.ContinueOnError)
+	firstName := fs.String("first-name", "", "first name (required)")
+	lastName := fs.String("last-name", "", "last name (required)")
+	password := fs.String("password", "", "password (default: generate one)")
+	args, err := parseArgs(fs, out, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 || (args[0] != models.StaffReceptionist && args[0] != models.StaffAdmin) {
+		return errUsage
+	}
+	if *firstName == "" || *lastName == "" {
+		return fmt.Errorf("%w: -first-name and -last-name are required", errUsage)
+	}
+	phone := args[1]
+	if !validation.Mobile(phone) {
+		return fmt.Errorf("invalid phone number %q", phone)
+	}
+
+	generated := *password == ""
+	if generated {
+		if *password, err = utils.GenerateRandomString(16); err != nil {
+			return err
+		}
+	} else if len(*password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(*password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	staff := &models.Staff{FirstName: *firstName, LastName: *lastName, PhoneNumber: phone, Password: string(hashed), Role: args[0]}
+	if err := store.Staff.Create(staff); err != nil {
+		return err
+	}
+
+	result := struct {
+		ID       int    `json:"id"`
+		Role     string `json:"role"`
+		Password string `json:"password,omitempty"`
+	}{ID: staff.ID, Role: staff.Role}
+	if generated {
+		result.Password = *password
+	}
+	return out.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Created %s %d.\n", staff.Role, staff.ID)
+		if generated {
+			fmt.Fprintf(w, "Password: %s\n", *password)
+		}
+	})
+}
+
+func optional(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,152 @@
+// ./cmd/clinicctl/appointments.go
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"onlineClinic/models"
+	"onlineClinic/utils"
+)
+
+func purgeAvailability(store *models.Store, out *output, args []string) error {
+	fs := flag.NewFlagSet("purge-availability", flag.ContinueOnError)
+	beforeSolar := fs.String("before", "", "delete slots that ended before this Solar date (yyyy-MM-dd) instead of now")
+	args, err := parseArgs(fs, out, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	// Slots are stored as wall-clock times labelled UTC.
+	now := time.Now()
+	before := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second(), 0, time.UTC)
+	if *beforeSolar != "" {
+		day, err := utils.SolarToGregorian(*beforeSolar)
+		if err != nil {
+			return fmt.Errorf("invalid -before: %v", err)
+		}
+		before = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	deleted, err := store.Availability.PurgeBefore(before)
+	if err != nil {
+		return err
+	}
+
+	result := struct {
+		Before  string `json:"before"`
+		Deleted int    `json:"deleted"`
+	}{before.Format("2006-01-02 15:04:05"), deleted}
+	return out.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Deleted %d slots that ended before %s.\n", deleted, result.Before)
+	})
+}
+
+func reassign(store *models.Store, out *output, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("reassign", flag.ContinueOnError), out, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return errUsage
+	}
+	appointmentID, err := parseID("appointment ID", args[0])
+	if err != nil {
+		return err
+	}
+	doctorID, err := parseID("doctor ID", args[1])
+	if err != nil {
+		return err
+	}
+
+	if err := store.Appointments.Reassign(appointmentID, doctorID); err == models.ErrTimeNotAvailable {
+		return fmt.Errorf("doctor %d has no free slot at the time of appointment %d", doctorID, appointmentID)
+	} else if err != nil {
+		return err
+	}
+
+	result := struct {
+		AppointmentID int `json:"appointmentId"`
+		DoctorID      int `json:"doctorId"`
+	}{appointmentID, doctorID}
+	return out.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Appointment %d reassigned to doctor %d.\n", appointmentID, doctorID)
+	})
+}
+
+func cancel(store *models.Store, out *output, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("cancel", flag.ContinueOnError), out, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errUsage
+	}
+	appointmentID, err := parseID("appointment ID", args[0])
+	if err != nil {
+		return err
+	}
+
+	// Delete also removes the prescription and gives the slot back.
+	if err := store.Appointments.Delete(appointmentID); err != nil {
+		return err
+	}
+
+	result := struct {
+		AppointmentID int  `json:"appointmentId"`
+		Cancelled     bool `json:"cancelled"`
+	}{appointmentID, true}
+	return out.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Appointment %d cancelled.\n", appointmentID)
+	})
+}
+
+func verify(store *models.Store, out *output, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("verify", flag.ContinueOnError), out, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	problems, err := models.CheckConsistency(store.DB)
+	if err != nil {
+		return err
+	}
+	if problems == nil {
+		problems = []models.Inconsistency{}
+	}
+
+	err = out.print(problems, func(w io.Writer) {
+		if len(problems) == 0 {
+			fmt.Fprintln(w, "No problems found.")
+			return
+		}
+		fmt.Fprintln(w, "KIND\tAPPOINTMENT\tSLOT\tDOCTOR\tPATIENT\tPROBLEM")
+		for _, p := range problems {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", p.Kind,
+				optionalID(p.AppointmentID), optionalID(p.SlotID), optionalID(p.DoctorID), optionalID(p.PatientID), p.Message)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return errProblems
+	}
+	return nil
+}
+
+func optionalID(id int) string {
+	if id == 0 {
+		return "-"
+	}
+	return fmt.Sprint(id)
+}
@@ -0,0 +1,77 @@
+// ./cmd/clinicctl/audit.go
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"onlineClinic/models"
+)
+
+func listAudit(store *models.Store, out *output, args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	action := fs.String("action", "", "only events with this action")
+	patient := fs.Int("patient", 0, "only events about this patient's data")
+	limit := fs.Int("limit", 50, "number of events")
+	args, err := parseArgs(fs, out, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 || *limit <= 0 || *patient < 0 {
+		return errUsage
+	}
+
+	events, err := store.Audit.List(models.AuditFilter{Action: *action, PatientID: *patient, Limit: *limit})
+	if err != nil {
+		return err
+	}
+	if events == nil {
+		events = []models.AuditEvent{}
+	}
+
+	return out.print(events, func(w io.Writer) {
+		fmt.Fprintln(w, "TIME\tACTION\tACTOR\tSUBJECT\tIP\tDETAIL")
+		for _, e := range events {
+			actor := "-"
+			if e.ActorID != nil {
+				actor = fmt.Sprintf("%s %d", e.ActorRole, *e.ActorID)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+				e.Action, actor, e.Subject, optional(e.IPAddress), optional(e.Detail))
+		}
+	})
+}
+
+// verifyAudit checks the hash chain of the audit trail.
+func verifyAudit(store *models.Store, out *output, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("audit-verify", flag.ContinueOnError), out, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	report, err := store.Audit.Verify()
+	if err != nil {
+		return err
+	}
+	if err := out.print(report, func(w io.Writer) {
+		fmt.Fprintf(w, "%d events, %d recorded before hash chaining.\n", report.Events, report.Unchained)
+		if report.Intact {
+			fmt.Fprintf(w, "Chain intact, head %s.\n", report.Head)
+		} else if report.BrokenAt != 0 {
+			fmt.Fprintf(w, "Chain broken at event %d: %s.\n", report.BrokenAt, report.Problem)
+		} else {
+			fmt.Fprintf(w, "Chain broken: %s.\n", report.Problem)
+		}
+	}); err != nil {
+		return err
+	}
+	if !report.Intact {
+		return errProblems
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// ./cmd/clinicctl/main.go
+
+// Command clinicctl is the operator's tool for fixing clinic data without
+// editing the database by hand. It reads the same configuration as the
+// server (config file, CLINIC_* environment variables and flags).
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"onlineClinic/config" // Configuration and database connection shared with the server
+	"onlineClinic/models" // Repositories for the configured database driver
+)
+
+const usage = `usage: clinicctl [config flags] <command> [-json] [arguments]
+
+Commands:
+  doctors [find <term>]               list doctors, or those whose name, phone
+                                      number or national code contains term
+  patients [find <term>]              the same for patients
+  staff [find <term>]                 the same for receptionists and admins
+  create-staff receptionist|admin <phone> -first-name <name> -last-name <name>
+                                      add a staff account; a password is
+                                      generated unless -password is given
+  reset-password doctor|patient|staff <id>
+                                      set a new password; one is generated
+                                      unless -password is given
+  deactivate doctor|patient|staff <id>
+                                      block logins but keep the account's data
+  activate doctor|patient|staff <id>  undo deactivate
+  reset-2fa <doctorId>                turn off a doctor's TOTP login codes,
+                                      e.g. after a lost phone
+  purge-availability                  delete slots that have ended; -before
+                                      takes a Solar date (yyyy-MM-dd) instead
+  reassign <appointmentId> <doctorId> move an appointment to another doctor
+                                      with a free slot at the same time
+  cancel <appointmentId>              delete an appointment and its
+                                      prescription and free the slot again
+  verify                              check appointments and availability for
+                                      consistency; exits 1 if problems are found
+  audit [-action <action>] [-patient <id>] [-limit <n>]
+                                      show the newest audit events, such as
+                                      login lockouts (login.lockout) or reads
+                                      of a patient's medical data
+  audit-verify                        check that no audit event was changed or
+                                      removed; exits 1 if one was
+
+-json prints machine-readable output instead of tables.
+Run 'clinicctl -h' for the config flags.
+`
+
+// errUsage makes main print the usage and exit with status 2.
+var errUsage = errors.New("invalid usage")
+
+// errProblems makes main exit with status 1 without printing an error.
+var errProblems = errors.New("problems found")
+
+type command func(store *models.Store, out *output, args []string) error
+
+var commands = map[string]command{
+	"doctors":            listDoctors,
+	"patients":           listPatients,
+	"staff":              listStaff,
+	"create-staff":       createStaff,
+	"reset-password":     resetPassword,
+	"deactivate":         deactivate,
+	"activate":           activate,
+	"reset-2fa":          resetTwoFactor,
+	"purge-availability": purgeAvailability,
+	"reassign":           reassign,
+	"cancel":             cancel,
+	"verify":             verify,
+	"audit":              listAudit,
+	"audit-verify":       verifyAudit,
+}
+
+func main() {
+	args, err := config.LoadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	run, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "clinicctl: unknown command %q\n\n%s", args[0], usage)
+		os.Exit(2)
+	}
+
+	config.ConnectDB()
+	store, err := models.NewStore(config.Cfg.DBDriver, config.DB)
+	if err != nil {
+		log.Fatalf("clinicctl: %v", err)
+	}
+
+	out := &output{w: os.Stdout}
+	err = run(store, out, args[1:])
+	config.DB.Close()
+
+	switch {
+	case err == nil:
+	case errors.Is(err, errUsage):
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	case errors.Is(err, errProblems):
+		os.Exit(1)
+	default:
+		fmt.Fprintf(os.Stderr, "clinicctl %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+}
+
+// parseArgs parses the flags of a command, which may be given before,
+// between or after its positional arguments, and returns the positional
+// arguments. Every command accepts -json.
+func parseArgs(fs *flag.FlagSet, out *output, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&out.json, "json", false, "print JSON")
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, fmt.Errorf("%w: %v", errUsage, err)
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// output prints results as aligned tables or, with -json, as JSON.
+type output struct {
+	w    io.Writer
+	json bool
+}
+
+// print writes v as JSON, or calls table to write it for humans.
+func (o *output) print(v any, table func(w io.Writer)) error {
+	if o.json {
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+// parseID parses a positive numeric ID argument.
+func parseID(name, raw string) (int, error) {
+	id, err := strconv.Atoi(raw)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, raw)
+	}
+	return id, nil
+}
@@ -3,8 +3,10 @@
 package main
 
 import (
-	// For formatted I/O operations
-	// For logging errors and informational messages
+	"context"    // For shutdown deadlines and stopping background workers
+	"crypto/tls" // For serving HTTPS with a reloadable certificate
+	"log"        // For failures before the logger is configured
+	"log/slog"   // Structured, leveled logging
 
 	"net/http"  // For handling HTTP requests and responses
 	"os"        // For interacting with the operating system
@@ -12,26 +14,99 @@ import (
 	"syscall"   // For system call constants (e.g., SIGINT, SIGTERM)
 	"time"      // For time-related operations (e.g., timeouts)
 
-	"onlineClinic/config" // Custom package for loading configuration and database connection
-	"onlineClinic/routes" // Custom package for setting up application routes
+	"onlineClinic/config"      // Custom package for loading configuration and database connection
+	"onlineClinic/controllers" // HTTP handlers, which read and write through the store
+	"onlineClinic/keys"        // Rotating RSA keys that sign access tokens
+	"onlineClinic/metrics"     // Prometheus metrics, including the database pool
+	"onlineClinic/models"      // Repositories for the configured database driver
+	"onlineClinic/routes"      // Custom package for setting up application routes
+	"onlineClinic/services"    // SMS delivery of verification codes
+	"onlineClinic/throttle"    // Counts failed logins for backoff and lockout
+	"onlineClinic/utils"       // Custom package for utility functions (e.g., upload directories)
 
 	"github.com/gorilla/mux" // Gorilla Mux router for handling HTTP routes
 )
 
 func main() {
 	// Load the application configuration from the config package.
-	// This typically involves reading environment variables or a configuration file.
-	config.LoadConfig()
+	// Defaults are layered with the config file, CLINIC_* environment variables and flags.
+	args, err := config.LoadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Run a subcommand instead of the server when one is given.
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			os.Exit(runMigrate(args[1:]))
+		case "seed":
+			os.Exit(runSeed(args[1:]))
+		default:
+			fatal("unknown command", "command", args[0])
+		}
+	}
 
 	// Establish a connection to the database using the config package.
-	// The defer statement ensures the database connection is closed when the program exits.
+	// The connection is closed by shutdown once nothing uses it any more.
 	config.ConnectDB()
-	defer config.DB.Close()
+
+	// Pick the repositories that match the configured database driver.
+	store, err := models.NewStore(config.Cfg.DBDriver, config.DB)
+	if err != nil {
+		fatal("failed to open store", "err", err)
+	}
+	controllers.Store = store
+	utils.Revocations = store.Sessions // Access tokens of ended sessions are rejected
+
+	// Load the token signing keys, generating the first one on a new install.
+	// Replaced keys stay published for one access token lifetime.
+	ring, err := keys.Open(config.Cfg.JWTKeyDir, config.Cfg.JWTKeyRotation, config.Cfg.AccessTokenTTL)
+	if err != nil {
+		fatal("failed to load token signing keys", "err", err)
+	}
+	utils.Keys = ring
+	routes.Keys = ring
+
+	// Client IPs come from X-Forwarded-For only behind a trusted proxy. The
+	// configuration was validated, so the proxies parse.
+	utils.TrustedProxies, _ = config.Cfg.TrustedProxyPrefixes()
+
+	// Count failed logins in the database unless configured otherwise, so
+	// that a lockout applies on every instance. Client IPs are only locked
+	// out, without backoff, as many users may share one.
+	throttleStore := throttle.Store(throttle.NewMemoryStore())
+	if config.Cfg.LoginThrottleStore == "sql" {
+		throttleStore = throttle.NewSQLStore(config.DB)
+	}
+	controllers.LoginLimiter = throttle.New(throttleStore,
+		throttle.Policy{
+			MaxFailures: config.Cfg.LoginMaxFailures,
+			Backoff:     config.Cfg.LoginBackoff,
+			Lockout:     config.Cfg.LoginLockout,
+			Window:      config.Cfg.LoginFailureWindow,
+		},
+		throttle.Policy{
+			MaxFailures: config.Cfg.LoginIPMaxFailures,
+			Lockout:     config.Cfg.LoginLockout,
+			Window:      config.Cfg.LoginFailureWindow,
+		})
+
+	// Verification codes are written out instead of sent until an SMS
+	// provider is configured.
+	if config.Cfg.SMSSender == "file" {
+		controllers.SMS = services.NewFileSMS(config.Cfg.SMSFile)
+	} else {
+		controllers.SMS = services.NewConsoleSMS(os.Stderr)
+	}
+
+	metrics.RegisterDB(config.DB, config.Cfg.DBName)
 
 	// Create necessary directories for storing uploaded files.
 	// These directories are used for profile pictures and chat-related files.
-	os.MkdirAll("uploads/profile", 0755) // Create "uploads/profile" directory with permissions 0755
-	os.MkdirAll("uploads/chat", 0755)    // Create "uploads/chat" directory with permissions 0755
+	if err := utils.EnsureUploadDirs(); err != nil {
+		fatal("failed to prepare upload directories", "err", err)
+	}
 
 	// Initialize a new Gorilla Mux router for handling HTTP requests.
 	router := mux.NewRouter()
@@ -40,46 +115,144 @@ func main() {
 	// This function returns an http.Handler that includes all the defined routes.
 	handler := routes.SetupRoutes(router)
 
-	// Serve static files from the "uploads" directory.
-	// This allows clients to access files stored in the "uploads" directory via HTTP.
-	fs := http.FileServer(http.Dir("uploads"))                                // Create a file server for the "uploads" directory
+	// Serve static files from the configured upload directory.
+	// This allows clients to access uploaded files via HTTP.
+	fs := http.FileServer(http.Dir(config.Cfg.UploadDir))                     // Create a file server for the upload directory
 	router.PathPrefix("/uploads/").Handler(http.StripPrefix("/uploads/", fs)) // Strip "/uploads/" prefix and serve files
 
 	// Configure the HTTP server with longer timeouts for file uploads
 	server := &http.Server{
-		Addr:         ":8080",           // Listen on port 8080
-		Handler:      handler,           // Use the handler returned by SetupRoutes
-		ReadTimeout:  300 * time.Second, // 5 minutes for reading request (increased for file uploads)
-		WriteTimeout: 300 * time.Second, // 5 minutes for writing response (increased for file uploads)
-		IdleTimeout:  120 * time.Second, // 2 minutes for idle connections
+		Addr:         config.Cfg.ListenAddr, // Listen on the configured address
+		Handler:      handler,               // Use the handler returned by SetupRoutes
+		ReadTimeout:  300 * time.Second,     // 5 minutes for reading request (increased for file uploads)
+		WriteTimeout: 300 * time.Second,     // 5 minutes for writing response (increased for file uploads)
+		IdleTimeout:  120 * time.Second,     // 2 minutes for idle connections
 		// Add these for better file upload handling
 		ReadHeaderTimeout: 60 * time.Second, // 1 minute to read headers
 	}
 
-	// Start the HTTP server in a goroutine to allow it to run concurrently with other operations.
-	go func() {
-		// fmt.Printf("Server running on https://online-clinic.liara.run%s\n", server.Addr) // Print server address to the console
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed { // Start the server
-			// log.Fatalf("Server failed to start: %v", err) // Log a fatal error if the server fails to start
+	// Background workers are stopped by shutdown before the database is closed.
+	workers := newWorkerGroup()
+
+	// Serve HTTPS directly when a certificate is configured. The certificate is
+	// reloaded on SIGHUP and when its files change.
+	if config.Cfg.TLSEnabled() {
+		certs, err := newCertReloader(config.Cfg.TLSCertFile, config.Cfg.TLSKeyFile)
+		if err != nil {
+			fatal("failed to load TLS certificate", "err", err)
 		}
-	}()
+		minVersion, _ := config.Cfg.MinTLSVersion() // Checked by config validation
+		server.TLSConfig = &tls.Config{
+			MinVersion:     minVersion,
+			GetCertificate: certs.GetCertificate,
+		}
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		workers.Go(func(ctx context.Context) { certs.watch(ctx, hup) })
+	}
+
+	// Expired sessions are kept a day for the reuse check of their last
+	// refresh token, then deleted.
+	workers.Go(func(ctx context.Context) { purgeSessions(ctx, store.Sessions) })
+	workers.Go(ring.Run) // Picks up keys of other instances and rotates when due
+	workers.Go(controllers.LoginLimiter.Run)
+	workers.Go(func(ctx context.Context) { purgeVerificationCodes(ctx, store.Verifications) })
+	workers.Go(func(ctx context.Context) { purgeWSTickets(ctx, store.WSTickets) })
+
+	servers := []*http.Server{server}
+
+	// Optionally answer plain HTTP with a redirect to HTTPS.
+	if config.Cfg.HTTPRedirectAddr != "" {
+		servers = append(servers, &http.Server{
+			Addr:              config.Cfg.HTTPRedirectAddr,
+			Handler:           redirectHandler(config.Cfg.ListenAddr),
+			ReadHeaderTimeout: 10 * time.Second,
+			IdleTimeout:       120 * time.Second,
+		})
+	}
+
+	// Metrics reveal traffic and booking volumes, so they are served on a
+	// separate address that only the scraper should reach.
+	if config.Cfg.MetricsAddr != "" {
+		adminMux := http.NewServeMux()
+		adminMux.Handle("GET /metrics", metrics.Handler())
+		servers = append(servers, &http.Server{
+			Addr:              config.Cfg.MetricsAddr,
+			Handler:           adminMux,
+			ReadHeaderTimeout: 10 * time.Second,
+			IdleTimeout:       120 * time.Second,
+		})
+	}
+
+	// Start every server in its own goroutine so they run alongside the signal handling below.
+	serverErr := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			var err error
+			if srv.TLSConfig != nil {
+				slog.Info("server listening", "addr", srv.Addr, "tls", true)
+				err = srv.ListenAndServeTLS("", "") // Certificates come from TLSConfig
+			} else {
+				slog.Info("server listening", "addr", srv.Addr)
+				err = srv.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
+				serverErr <- err
+			}
+		}(srv)
+	}
 
 	// Set up a channel to listen for OS signals such as SIGINT (Ctrl+C) or SIGTERM.
 	quit := make(chan os.Signal, 1)                      // Create a buffered channel to receive signals
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM) // Notify the channel of the specified signals
 
-	// Wait for a signal to be received, indicating that the server should shut down gracefully.
-	<-quit
-	// log.Println("Server is shutting down...") // Log a message indicating the server is shutting down
+	// Wait for a signal, or for a server to fail, before shutting down.
+	select {
+	case <-quit:
+	case err := <-serverErr:
+		slog.Error("server failed", "err", err)
+	}
+	slog.Info("server is shutting down")
+
+	shutdown(servers, workers, config.Cfg.ShutdownTimeout)
+
+	// Log a message indicating that the server has stopped.
+	slog.Info("server stopped")
+}
+
+// shutdown stops the servers in order: stop accepting connections and drain
+// in-flight requests, close WebSocket clients through the Hub, stop the
+// background workers, and only then close the database. All steps share
+// one deadline.
+func shutdown(servers []*http.Server, workers *workerGroup, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Shutdown does not wait for hijacked connections, so WebSockets are
+	// closed by the Hub below.
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("HTTP server shutdown", "addr", server.Addr, "err", err)
+		}
+	}
+
+	if err := routes.Hub.Shutdown(ctx); err != nil {
+		slog.Error("WebSocket hub shutdown", "err", err)
+	}
 
-	// Close all WebSocket connections managed by the hub in the routes package.
-	for client := range routes.Hub.Clients {
-		client.Conn.Close() // Close each WebSocket connection
+	if err := workers.Stop(ctx); err != nil {
+		slog.Error("background workers did not stop", "err", err)
 	}
 
 	// Close the database connection to release resources.
-	config.DB.Close()
+	if err := config.DB.Close(); err != nil {
+		slog.Error("closing database", "err", err)
+	}
+}
 
-	// Log a message indicating that the server has stopped.
-	// log.Println("Server stopped")
+// fatal logs msg at error level and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
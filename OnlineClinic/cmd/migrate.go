@@ -0,0 +1,122 @@
+// ./cmd/migrate.go
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"onlineClinic/config"     // Custom package for loading configuration and database connection
+	"onlineClinic/migrations" // Custom package for the embedded schema migrations
+)
+
+const migrateUsage = `usage: onlineClinic [flags] migrate <command>
+
+Commands:
+  up              apply every pending migration
+  down [n]        roll back the last n applied migrations (default 1)
+  status          list migrations and whether they have been applied
+  create <name>   write an empty up/down pair for a new migration
+                  (in the directory of the configured db_driver)
+`
+
+// runMigrate implements the migrate subcommand and returns the process exit code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	command, args := args[0], args[1:]
+
+	// create only touches the source tree, so it does not need a database.
+	if command == "create" {
+		fs := flag.NewFlagSet("migrate create", flag.ContinueOnError)
+		dir := fs.String("dir", migrations.SourceDir(config.Cfg.DBDriver), "directory to write the migration files to")
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() != 1 {
+			fmt.Fprint(os.Stderr, migrateUsage)
+			return 2
+		}
+		paths, err := migrations.Create(*dir, fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate create: %v\n", err)
+			return 1
+		}
+		for _, path := range paths {
+			fmt.Println("Created", path)
+		}
+		return 0
+	}
+
+	config.ConnectDB()
+	defer config.DB.Close()
+
+	migrator, err := migrations.NewForDriver(config.Cfg.DBDriver, config.DB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate up: %v\n", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			if steps, err = strconv.Atoi(args[0]); err != nil || steps < 1 {
+				fmt.Fprintf(os.Stderr, "migrate down: invalid step count %q\n", args[0])
+				return 2
+			}
+		}
+		rolledBack, err := migrator.Down(steps)
+		for _, m := range rolledBack {
+			fmt.Printf("Rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate down: %v\n", err)
+			return 1
+		}
+		if len(rolledBack) == 0 {
+			fmt.Println("No applied migrations")
+		}
+
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate status: %v\n", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		w.Flush()
+
+	default:
+		fmt.Fprintf(os.Stderr, "migrate: unknown command %q\n\n%s", command, migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+// ./cmd/seed.go
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"onlineClinic/config" // Custom package for loading configuration and database connection
+	"onlineClinic/models" // Repositories for the configured database driver
+	"onlineClinic/seed"   // Demo data generator
+	"onlineClinic/utils"  // Custom package for Solar calendar dates
+)
+
+const seedUsage = `usage: onlineClinic [flags] seed [seed flags]
+
+Fills the configured database with demo doctors, patients, availability,
+appointments with prescriptions, and chats. The same -seed and -start
+always produce the same data; run it against a freshly migrated database.
+
+Seed flags:
+`
+
+// runSeed implements the seed subcommand and returns the process exit code.
+func runSeed(args []string) int {
+	tomorrow := time.Now().AddDate(0, 0, 1)
+
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, seedUsage)
+		fs.PrintDefaults()
+	}
+	opts := seed.Options{UploadDir: config.Cfg.UploadDir}
+	fs.Int64Var(&opts.Seed, "seed", 1, "random seed; the same seed gives the same dataset")
+	fs.IntVar(&opts.Doctors, "doctors", 5, "number of doctors")
+	fs.IntVar(&opts.Patients, "patients", 20, "number of patients")
+	fs.IntVar(&opts.Weeks, "weeks", 4, "weeks of availability per doctor")
+	fs.IntVar(&opts.Appointments, "appointments", 30, "number of booked appointments")
+	fs.IntVar(&opts.Messages, "messages", 6, "messages per doctor/patient chat")
+	fs.StringVar(&opts.Password, "password", "password123", "password of every seeded account")
+	start := fs.String("start", utils.GregorianToSolar(tomorrow), "first day of availability as a Solar date (yyyy-MM-dd), not in the past")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 || opts.Doctors < 0 || opts.Patients < 0 || opts.Weeks < 0 || opts.Appointments < 0 || opts.Messages < 0 {
+		fs.Usage()
+		return 2
+	}
+
+	startDate, err := utils.SolarToGregorian(*start)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "seed: invalid -start: %v\n", err)
+		return 2
+	}
+	opts.Start = startDate
+
+	config.ConnectDB()
+	defer config.DB.Close()
+
+	store, err := models.NewStore(config.Cfg.DBDriver, config.DB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "seed: %v\n", err)
+		return 1
+	}
+
+	summary, err := seed.Run(store, opts)
+	if summary != nil {
+		fmt.Printf("Doctors:       %d\n", summary.Doctors)
+		fmt.Printf("Patients:      %d\n", summary.Patients)
+		fmt.Printf("Slots:         %d\n", summary.Slots)
+		fmt.Printf("Appointments:  %d\n", summary.Appointments)
+		fmt.Printf("Prescriptions: %d\n", summary.Prescriptions)
+		fmt.Printf("Chats:         %d (%d messages)\n", summary.Chats, summary.Messages)
+		if len(summary.DoctorPhones) > 0 && len(summary.PatientPhones) > 0 {
+			fmt.Printf("\nLog in as doctor %s or patient %s with password %q.\n",
+				summary.DoctorPhones[0], summary.PatientPhones[0], opts.Password)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "seed: %v\n", err)
+		return 1
+	}
+	return 0
+}
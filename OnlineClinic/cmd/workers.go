@@ -0,0 +1,101 @@
+// ./cmd/workers.go
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"onlineClinic/models"
+)
+
+// workerGroup runs background goroutines that must stop before the database
+// is closed on shutdown.
+type workerGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newWorkerGroup() *workerGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workerGroup{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in its own goroutine. fn must return soon after ctx is done.
+func (g *workerGroup) Go(fn func(ctx context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		fn(g.ctx)
+	}()
+}
+
+// Stop cancels the workers and waits for them to return, or until ctx is done.
+func (g *workerGroup) Stop(ctx context.Context) error {
+	g.cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// purgeInterval is how often expired rows are deleted.
+const purgeInterval = time.Hour
+
+// purgeExpired calls purge every purgeInterval until ctx is done. what names
+// the deleted rows in log messages.
+func purgeExpired(ctx context.Context, what string, purge func() (int, error)) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := purge()
+		if err != nil {
+			slog.Error("purging expired "+what, "err", err)
+		} else if deleted > 0 {
+			slog.Info("purged expired "+what, "deleted", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeSessions deletes sessions that expired more than a day ago, until
+// ctx is done.
+func purgeSessions(ctx context.Context, sessions models.SessionRepository) {
+	purgeExpired(ctx, "sessions", func() (int, error) {
+		return sessions.DeleteExpired(time.Now().Add(-24 * time.Hour))
+	})
+}
+
+// purgeVerificationCodes deletes phone verification codes once they have
+// expired, until ctx is done.
+func purgeVerificationCodes(ctx context.Context, verifications models.PhoneVerificationRepository) {
+	purgeExpired(ctx, "verification codes", func() (int, error) {
+		return verifications.DeleteExpired(time.Now())
+	})
+}
+
+// purgeWSTickets deletes WebSocket tickets that expired unused, until ctx
+// is done.
+func purgeWSTickets(ctx context.Context, tickets models.WSTicketRepository) {
+	purgeExpired(ctx, "websocket tickets", func() (int, error) {
+		return tickets.DeleteExpired(time.Now())
+	})
+}
@@ -1,11 +1,17 @@
 package config
 
 import (
+	"crypto/tls"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/netip"
+	"onlineClinic/logging"
+	"os"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql" // MySQL driver
+	_ "github.com/mattn/go-sqlite3"    // SQLite driver for development and tests
 )
 
 // Define global variables for the database connection and configuration.
@@ -15,52 +21,193 @@ var (
 )
 
 // Define a struct to hold configuration values for the application.
+//
+// Every field can be set, in increasing order of precedence, from the
+// built-in defaults, a YAML or TOML config file, a CLINIC_* environment
+// variable and a command-line flag. Fields tagged secret are redacted
+// whenever the configuration is logged.
 type Config struct {
-	DBUser     string // Database username
-	DBPassword string // Database password
-	DBHost     string // Database host (e.g., IP address or hostname)
-	DBPort     string // Database port (e.g., 3306 for MySQL)
-	DBName     string // Name of the database to connect to
-	JWTSecret  string // Secret key used for JSON Web Token (JWT) signing
+	Env         string   `yaml:"env" toml:"env" env:"ENV" flag:"env" usage:"deployment environment (development, staging, production)"`
+	ListenAddr  string   `yaml:"listen_addr" toml:"listen_addr" env:"LISTEN_ADDR" flag:"listen" usage:"address the HTTP server listens on"`
+	BaseURL     string   `yaml:"base_url" toml:"base_url" env:"BASE_URL" flag:"base-url" usage:"public base URL used to build links to uploaded files"`
+	CORSOrigins []string `yaml:"cors_origins" toml:"cors_origins" env:"CORS_ORIGINS" flag:"cors-origins" usage:"comma-separated list of allowed CORS origins"`
+	UploadDir   string   `yaml:"upload_dir" toml:"upload_dir" env:"UPLOAD_DIR" flag:"upload-dir" usage:"directory for uploaded profile photos and chat files"`
+	DocumentDir string   `yaml:"document_dir" toml:"document_dir" env:"DOCUMENT_DIR" flag:"document-dir" usage:"private directory for doctors' license documents; must not be inside upload_dir, which is served publicly"`
+
+	LogLevel        string        `yaml:"log_level" toml:"log_level" env:"LOG_LEVEL" flag:"log-level" usage:"minimum log level (debug, info, warn, error)"`
+	LogFormat       string        `yaml:"log_format" toml:"log_format" env:"LOG_FORMAT" flag:"log-format" usage:"log output format (text or json)"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"how long to wait for in-flight requests and WebSocket clients on shutdown"`
+	HealthTimeout   time.Duration `yaml:"health_timeout" toml:"health_timeout" env:"HEALTH_TIMEOUT" flag:"health-timeout" usage:"time limit for each readiness check, such as the database ping"`
+	MetricsAddr     string        `yaml:"metrics_addr" toml:"metrics_addr" env:"METRICS_ADDR" flag:"metrics-addr" usage:"address of a separate listener that serves /metrics, kept off the public network; empty disables it"`
+
+	TLSCertFile      string `yaml:"tls_cert_file" toml:"tls_cert_file" env:"TLS_CERT_FILE" flag:"tls-cert" usage:"PEM certificate chain; serves HTTPS on listen_addr when set together with tls_key_file"`
+	TLSKeyFile       string `yaml:"tls_key_file" toml:"tls_key_file" env:"TLS_KEY_FILE" flag:"tls-key" usage:"PEM private key for tls_cert_file"`
+	TLSMinVersion    string `yaml:"tls_min_version" toml:"tls_min_version" env:"TLS_MIN_VERSION" flag:"tls-min-version" usage:"minimum TLS version (1.2 or 1.3)"`
+	HTTPRedirectAddr string `yaml:"http_redirect_addr" toml:"http_redirect_addr" env:"HTTP_REDIRECT_ADDR" flag:"http-redirect-addr" usage:"optional plain-HTTP address that redirects to HTTPS (e.g. :80)"`
+
+	DBDriver   string `yaml:"db_driver" toml:"db_driver" env:"DB_DRIVER" flag:"db-driver" usage:"database driver (mysql or sqlite3)"`
+	DBPath     string `yaml:"db_path" toml:"db_path" env:"DB_PATH" flag:"db-path" usage:"SQLite database file (sqlite3 driver only)"`
+	DBUser     string `yaml:"db_user" toml:"db_user" env:"DB_USER" flag:"db-user" usage:"database username"`
+	DBPassword string `yaml:"db_password" toml:"db_password" env:"DB_PASSWORD" flag:"db-password" secret:"true" usage:"database password"`
+	DBHost     string `yaml:"db_host" toml:"db_host" env:"DB_HOST" flag:"db-host" usage:"database host (e.g., IP address or hostname)"`
+	DBPort     string `yaml:"db_port" toml:"db_port" env:"DB_PORT" flag:"db-port" usage:"database port (e.g., 3306 for MySQL)"`
+	DBName     string `yaml:"db_name" toml:"db_name" env:"DB_NAME" flag:"db-name" usage:"name of the database to connect to"`
+
+	JWTKeyDir       string        `yaml:"jwt_key_dir" toml:"jwt_key_dir" env:"JWT_KEY_DIR" flag:"jwt-key-dir" usage:"directory of the RSA keys that sign access tokens; created and rotated by the server and may be shared by instances"`
+	JWTKeyRotation  time.Duration `yaml:"jwt_key_rotation" toml:"jwt_key_rotation" env:"JWT_KEY_ROTATION" flag:"jwt-key-rotation" usage:"how often a new token signing key is generated"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl" toml:"access_token_ttl" env:"ACCESS_TOKEN_TTL" flag:"access-token-ttl" usage:"lifetime of issued access tokens"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL" flag:"refresh-token-ttl" usage:"lifetime of a login session; refresh tokens renew access tokens until then"`
+
+	WSTicketTTL       time.Duration `yaml:"ws_ticket_ttl" toml:"ws_ticket_ttl" env:"WS_TICKET_TTL" flag:"ws-ticket-ttl" usage:"how long a single-use ticket for opening the chat WebSocket stays valid"`
+	WSSessionInterval time.Duration `yaml:"ws_session_interval" toml:"ws_session_interval" env:"WS_SESSION_INTERVAL" flag:"ws-session-interval" usage:"how often open WebSocket connections check that their session has not been revoked"`
+
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies" env:"TRUSTED_PROXIES" flag:"trusted-proxies" usage:"comma-separated IPs or CIDRs of reverse proxies whose X-Forwarded-For and X-Real-IP headers give the client IP"`
+
+	LoginThrottleStore string        `yaml:"login_throttle_store" toml:"login_throttle_store" env:"LOGIN_THROTTLE_STORE" flag:"login-throttle-store" usage:"where failed login attempts are counted: sql (shared by instances) or memory"`
+	LoginMaxFailures   int           `yaml:"login_max_failures" toml:"login_max_failures" env:"LOGIN_MAX_FAILURES" flag:"login-max-failures" usage:"failed logins that lock an account out"`
+	LoginIPMaxFailures int           `yaml:"login_ip_max_failures" toml:"login_ip_max_failures" env:"LOGIN_IP_MAX_FAILURES" flag:"login-ip-max-failures" usage:"failed logins, to any account, that lock a client IP out"`
+	LoginBackoff       time.Duration `yaml:"login_backoff" toml:"login_backoff" env:"LOGIN_BACKOFF" flag:"login-backoff" usage:"wait after an account's first failed login, doubled after each further failure"`
+	LoginLockout       time.Duration `yaml:"login_lockout" toml:"login_lockout" env:"LOGIN_LOCKOUT" flag:"login-lockout" usage:"how long a locked out account or client IP must wait"`
+	LoginFailureWindow time.Duration `yaml:"login_failure_window" toml:"login_failure_window" env:"LOGIN_FAILURE_WINDOW" flag:"login-failure-window" usage:"failed logins further apart than this start the count over"`
+
+	SMSSender           string        `yaml:"sms_sender" toml:"sms_sender" env:"SMS_SENDER" flag:"sms-sender" usage:"how verification codes are delivered: console (stderr) or file"`
+	SMSFile             string        `yaml:"sms_file" toml:"sms_file" env:"SMS_FILE" flag:"sms-file" usage:"file that messages are appended to when sms_sender is file"`
+	VerificationCodeTTL time.Duration `yaml:"verification_code_ttl" toml:"verification_code_ttl" env:"VERIFICATION_CODE_TTL" flag:"verification-code-ttl" usage:"how long a phone verification code stays valid"`
+
+	DoctorTwoFactorRequired bool `yaml:"doctor_2fa_required" toml:"doctor_2fa_required" env:"DOCTOR_2FA_REQUIRED" flag:"doctor-2fa-required" usage:"require every doctor to log in with a TOTP code; doctors without one enroll at their next login"`
 }
 
-// LoadConfig initializes the application configuration.
-func LoadConfig() {
-	Cfg = Config{
-		DBUser:     "root",
-		DBPassword: "gApczcyLDZFavkfufpe7iA6t",
-		DBHost:     "damavand.liara.cloud",
-		DBPort:     "33525",
-		DBName:     "OnlineClinic",
-		JWTSecret:  "superS3cr3tK3y!123#MyClinicApp",
+// Defaults returns the configuration used before any file, environment
+// variable or flag is applied. It is suitable for local development only.
+func Defaults() Config {
+	return Config{
+		Env:             "development",
+		ListenAddr:      ":8080",
+		BaseURL:         "http://localhost:8080",
+		CORSOrigins:     []string{"http://localhost:3000"},
+		UploadDir:       "./uploads",
+		DocumentDir:     "./documents",
+		LogLevel:        "info",
+		LogFormat:       "text",
+		ShutdownTimeout: 30 * time.Second,
+		HealthTimeout:   2 * time.Second,
+		MetricsAddr:     "127.0.0.1:9090",
+		TLSMinVersion:   "1.2",
+		DBDriver:        "mysql",
+		DBPath:          "./onlineClinic.db",
+		DBUser:          "root",
+		DBHost:          "127.0.0.1",
+		DBPort:          "3306",
+		DBName:          "OnlineClinic",
+		JWTKeyDir:       "./jwt-keys",
+		JWTKeyRotation:  30 * 24 * time.Hour,
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+
+		WSTicketTTL:       30 * time.Second,
+		WSSessionInterval: time.Minute,
+
+		LoginThrottleStore: "sql",
+		LoginMaxFailures:   5,
+		LoginIPMaxFailures: 50,
+		LoginBackoff:       time.Second,
+		LoginLockout:       15 * time.Minute,
+		LoginFailureWindow: time.Hour,
+
+		SMSSender:           "console",
+		SMSFile:             "./sms.log",
+		VerificationCodeTTL: 10 * time.Minute,
 	}
+}
 
-	log.Printf("Loaded configuration: %+v\n", Cfg)
-	log.Println("Configuration loaded successfully.")
+// IsProduction reports whether the server runs in the production environment.
+func (c Config) IsProduction() bool {
+	return c.Env == "production"
+}
 
-	ConnectDB()
-	log.Println("Database connection established successfully.")
+// TLSEnabled reports whether the server serves HTTPS itself.
+func (c Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
 }
 
-// ConnectDB establishes a connection to the MySQL database.
+// TrustedProxyPrefixes parses TrustedProxies. A single IP is a prefix of
+// its full length.
+func (c Config) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(c.TrustedProxies))
+	for _, proxy := range c.TrustedProxies {
+		if addr, err := netip.ParseAddr(proxy); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("trusted_proxies: %q is not an IP address or CIDR", proxy)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// MinTLSVersion returns the crypto/tls constant for TLSMinVersion.
+func (c Config) MinTLSVersion() (uint16, error) {
+	switch c.TLSMinVersion {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("tls_min_version must be 1.2 or 1.3, got %q", c.TLSMinVersion)
+	}
+}
+
+// LoadConfig initializes the application configuration from defaults, the
+// config file, CLINIC_* environment variables and the given command-line
+// arguments. It returns the arguments left over after flag parsing.
+func LoadConfig(args []string) ([]string, error) {
+	cfg, rest, err := load(args)
+	if err != nil {
+		return nil, err
+	}
+	Cfg = cfg
+
+	// Switch to the configured logger before anything else is logged.
+	if _, err := logging.Setup(Cfg.LogLevel, Cfg.LogFormat, os.Stderr); err != nil {
+		return nil, err
+	}
+
+	slog.Info("configuration loaded", "config", Cfg.Redacted())
+	return rest, nil
+}
+
+// DSN returns the data source name for the configured database driver.
+func (c Config) DSN() string {
+	if c.DBDriver == "sqlite3" {
+		// Foreign keys are off by default in SQLite, and WAL with a busy timeout
+		// lets the HTTP handlers read while a booking transaction writes.
+		return fmt.Sprintf("file:%s?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000", c.DBPath)
+	}
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
+		c.DBUser, c.DBPassword, c.DBHost, c.DBPort, c.DBName)
+}
+
+// ConnectDB establishes a connection to the configured database.
 func ConnectDB() {
 	var err error
 
-	// Connection string without TLS
-	connStr := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
-		Cfg.DBUser, Cfg.DBPassword, Cfg.DBHost, Cfg.DBPort, Cfg.DBName)
-
 	// Open the database connection
-	DB, err = sql.Open("mysql", connStr)
+	DB, err = sql.Open(Cfg.DBDriver, Cfg.DSN())
 	if err != nil {
-		log.Fatalf("Error connecting to the %s database: %v", Cfg.DBName, err)
+		slog.Error("error connecting to the database", "db", Cfg.DBName, "err", err)
+		os.Exit(1)
 	}
 
 	// Test the connection
 	err = DB.Ping()
 	if err != nil {
-		// log.Fatalf("Error connecting to the database: %v", err)
+		slog.Error("error connecting to the database", "db", Cfg.DBName, "err", err)
+		os.Exit(1)
 	}
 
-	fmt.Printf("Successfully connected to the %s database\n", Cfg.DBName)
+	slog.Info("connected to the database", "driver", Cfg.DBDriver, "db", Cfg.DBName)
 }
@@ -0,0 +1,354 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"onlineClinic/logging"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is prepended to the env tag of every Config field.
+const EnvPrefix = "CLINIC_"
+
+// load builds a Config by layering defaults, the config file, environment
+// variables and flags, then validates the result.
+func load(args []string) (Config, []string, error) {
+	cfg := Defaults()
+
+	fs := flag.NewFlagSet("onlineClinic", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv(EnvPrefix+"CONFIG"), "path to a YAML or TOML config file (env "+EnvPrefix+"CONFIG)")
+	flagValues := registerFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(&cfg, *configFile); err != nil {
+			return Config{}, nil, err
+		}
+	}
+
+	if err := applyEnv(&cfg, os.LookupEnv); err != nil {
+		return Config{}, nil, err
+	}
+
+	// Only flags given explicitly on the command line override the lower layers.
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		raw, ok := flagValues[f.Name]
+		if !ok || flagErr != nil {
+			return
+		}
+		flagErr = setField(&cfg, f.Name, *raw, "flag -"+f.Name)
+	})
+	if flagErr != nil {
+		return Config{}, nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+// registerFlags declares one string flag per Config field and returns the
+// raw values keyed by flag name.
+func registerFlags(fs *flag.FlagSet) map[string]*string {
+	values := make(map[string]*string)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("flag")
+		if name == "" {
+			continue
+		}
+		usage := field.Tag.Get("usage")
+		if env := field.Tag.Get("env"); env != "" {
+			usage += " (env " + EnvPrefix + env + ")"
+		}
+		values[name] = fs.String(name, "", usage)
+	}
+	return values
+}
+
+// loadFile decodes a YAML (.yaml, .yml) or TOML (.toml) file on top of cfg.
+// Unknown keys are rejected so that typos do not go unnoticed.
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: reading %s: %v", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("config: parsing %s: %v", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("config: parsing %s: %v", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("config: parsing %s: unknown keys %v", path, undecoded)
+		}
+	default:
+		return fmt.Errorf("config: unsupported config file type %q (use .yaml, .yml or .toml)", filepath.Ext(path))
+	}
+	return nil
+}
+
+// applyEnv overrides cfg with every CLINIC_* variable that is set.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	t := reflect.TypeOf(*cfg)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		env := field.Tag.Get("env")
+		if env == "" {
+			continue
+		}
+		raw, ok := lookup(EnvPrefix + env)
+		if !ok {
+			continue
+		}
+		if err := setField(cfg, field.Tag.Get("flag"), raw, "env "+EnvPrefix+env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setField parses raw into the Config field whose flag tag is name.
+func setField(cfg *Config, name, raw, source string) error {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("flag") != name {
+			continue
+		}
+		if err := parseInto(v.Field(i), raw); err != nil {
+			return fmt.Errorf("config: invalid value for %s: %v", source, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("config: unknown setting %s", source)
+}
+
+func parseInto(field reflect.Value, raw string) error {
+	switch field.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	case []string:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// Validate checks that every required setting is present and well formed.
+// All problems are reported together so a broken deployment can be fixed in
+// one pass.
+func (c Config) Validate() error {
+	var errs []error
+	required := func(value, name string) {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, fmt.Errorf("%s is required", name))
+		}
+	}
+
+	switch c.Env {
+	case "development", "staging", "production":
+	default:
+		errs = append(errs, fmt.Errorf("env must be one of development, staging or production, got %q", c.Env))
+	}
+
+	required(c.ListenAddr, "listen_addr")
+	required(c.UploadDir, "upload_dir")
+	required(c.DocumentDir, "document_dir")
+	if c.DocumentDir != "" && c.UploadDir != "" && isWithin(c.DocumentDir, c.UploadDir) {
+		errs = append(errs, errors.New("document_dir must not be inside upload_dir, which is served publicly"))
+	}
+	required(c.JWTKeyDir, "jwt_key_dir")
+
+	switch c.DBDriver {
+	case "mysql":
+		required(c.DBUser, "db_user")
+		required(c.DBHost, "db_host")
+		required(c.DBPort, "db_port")
+		required(c.DBName, "db_name")
+	case "sqlite3":
+		required(c.DBPath, "db_path")
+	default:
+		errs = append(errs, fmt.Errorf("db_driver must be mysql or sqlite3, got %q", c.DBDriver))
+	}
+
+	if c.JWTKeyRotation <= c.AccessTokenTTL {
+		errs = append(errs, errors.New("jwt_key_rotation must be longer than access_token_ttl"))
+	}
+	if c.AccessTokenTTL <= 0 {
+		errs = append(errs, errors.New("access_token_ttl must be positive"))
+	}
+	if c.RefreshTokenTTL <= 0 {
+		errs = append(errs, errors.New("refresh_token_ttl must be positive"))
+	}
+	if c.WSTicketTTL <= 0 || c.WSSessionInterval <= 0 {
+		errs = append(errs, errors.New("ws_ticket_ttl and ws_session_interval must be positive"))
+	}
+
+	if c.LoginThrottleStore != "sql" && c.LoginThrottleStore != "memory" {
+		errs = append(errs, fmt.Errorf("login_throttle_store must be sql or memory, got %q", c.LoginThrottleStore))
+	}
+	if c.LoginMaxFailures < 1 || c.LoginIPMaxFailures < 1 {
+		errs = append(errs, errors.New("login_max_failures and login_ip_max_failures must be at least 1"))
+	}
+	if c.LoginBackoff < 0 {
+		errs = append(errs, errors.New("login_backoff must not be negative"))
+	}
+	if c.LoginLockout <= 0 || c.LoginFailureWindow <= 0 {
+		errs = append(errs, errors.New("login_lockout and login_failure_window must be positive"))
+	}
+	if _, err := c.TrustedProxyPrefixes(); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch c.SMSSender {
+	case "console":
+	case "file":
+		required(c.SMSFile, "sms_file")
+	default:
+		errs = append(errs, fmt.Errorf("sms_sender must be console or file, got %q", c.SMSSender))
+	}
+	if c.VerificationCodeTTL <= 0 {
+		errs = append(errs, errors.New("verification_code_ttl must be positive"))
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("log_level: %v", err))
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("log_format must be text or json, got %q", c.LogFormat))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout must be positive"))
+	}
+	if c.HealthTimeout <= 0 {
+		errs = append(errs, errors.New("health_timeout must be positive"))
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("tls_cert_file and tls_key_file must be set together"))
+	}
+	if _, err := c.MinTLSVersion(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.HTTPRedirectAddr != "" && !c.TLSEnabled() {
+		errs = append(errs, errors.New("http_redirect_addr requires tls_cert_file and tls_key_file"))
+	}
+	if c.MetricsAddr != "" && (c.MetricsAddr == c.ListenAddr || c.MetricsAddr == c.HTTPRedirectAddr) {
+		errs = append(errs, errors.New("metrics_addr must differ from listen_addr and http_redirect_addr, which are public"))
+	}
+
+	if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("base_url must be an absolute http(s) URL, got %q", c.BaseURL))
+	}
+
+	if c.IsProduction() {
+		if c.DBDriver == "sqlite3" {
+			errs = append(errs, errors.New("db_driver sqlite3 is for development and tests only"))
+		} else {
+			required(c.DBPassword, "db_password")
+		}
+		if len(c.CORSOrigins) == 0 {
+			errs = append(errs, errors.New("cors_origins is required in production"))
+		}
+		// Neither delivers codes to the phone, they only write them down
+		if c.SMSSender == "console" || c.SMSSender == "file" {
+			errs = append(errs, fmt.Errorf("sms_sender %s is for development and tests only", c.SMSSender))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// isWithin reports whether dir is parent or a directory below it.
+func isWithin(dir, parent string) bool {
+	abs, err1 := filepath.Abs(dir)
+	absParent, err2 := filepath.Abs(parent)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absParent, abs)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Redacted renders the configuration for logging with every secret masked.
+func (c Config) Redacted() string {
+	v := reflect.ValueOf(c)
+	t := v.Type()
+
+	var b strings.Builder
+	b.WriteString("{")
+	for i := 0; i < t.NumField(); i++ {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		field := t.Field(i)
+		value := v.Field(i).Interface()
+		if field.Tag.Get("secret") == "true" {
+			if v.Field(i).IsZero() {
+				value = ""
+			} else {
+				value = "****"
+			}
+		}
+		fmt.Fprintf(&b, "%s:%v", field.Name, value)
+	}
+	b.WriteString("}")
+	return b.String()
+}
@@ -3,19 +3,18 @@
 package controllers
 
 import (
-	"bytes"         // For handling byte buffers
-	"database/sql"  // For database operations
-	"encoding/json" // For encoding/decoding JSON data
-	"fmt"           // For formatted I/O operations
-	"io"            // For input/output operations
-
-	// For logging errors and informational messages
-	"net/http"            // For handling HTTP requests and responses
-	"onlineClinic/config" // Custom package for configuration (e.g., database connection)
-	"onlineClinic/models" // Custom package for models (e.g., appointment data structures)
-	"onlineClinic/utils"  // Custom package for utility functions (e.g., user claims)
-	"strconv"             // For string-to-integer conversions
-	"time"                // For time-related operations
+	"bytes"                // For handling byte buffers
+	"encoding/json"        // For encoding/decoding JSON data
+	"fmt"                  // For formatted I/O operations
+	"io"                   // For input/output operations
+	"log/slog"             // For logging errors and informational messages
+	"net/http"             // For handling HTTP requests and responses
+	"onlineClinic/metrics" // Prometheus counters for bookings
+	"onlineClinic/models"  // Custom package for models (e.g., appointment data structures)
+	"onlineClinic/problem"
+	"onlineClinic/utils" // Custom package for utility functions (e.g., user claims)
+	"strconv"            // For string-to-integer conversions
+	"time"               // For time-related operations
 
 	"github.com/gorilla/mux" // Gorilla Mux router for handling HTTP routes
 )
@@ -28,7 +27,7 @@ func CreateAppointment(w http.ResponseWriter, r *http.Request) {
 	claims, ok := utils.GetUserClaims(r.Context())
 	if !ok {
 		// log.Print("No user claims found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Write(w, r, problem.CodeUnauthenticated)
 		return
 	}
 
@@ -36,36 +35,65 @@ func CreateAppointment(w http.ResponseWriter, r *http.Request) {
 	var appointmentReq models.AppointmentRequest
 	if err := json.NewDecoder(r.Body).Decode(&appointmentReq); err != nil {
 		// log.Printf("Error decoding request body: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidBody)
 		return
 	}
-
-	// Convert the patient ID from the request to an integer for validation.
-	reqPatientID, err := strconv.Atoi(appointmentReq.PatientID)
-	if err != nil {
-		// log.Printf("Invalid patient ID format: %v", err)
-		http.Error(w, "Invalid patient ID format", http.StatusBadRequest)
+	if invalid(w, r, validateAppointmentRequest(&appointmentReq)) {
 		return
 	}
 
-	// Ensure the patient ID in the request matches the authenticated user's ID.
+	// The IDs were checked by validateAppointmentRequest.
+	reqPatientID, _ := strconv.Atoi(appointmentReq.PatientID)
+
+	// Ensure the patient ID in the request matches the authenticated user's
+	// ID, or that of a minor dependent of theirs, whose appointment it is.
 	if reqPatientID != claims.UserID {
-		// log.Printf("Patient ID mismatch. Token: %d, Request: %d", claims.UserID, reqPatientID)
-		http.Error(w, "Unauthorized", http.StatusForbidden)
+		guardian, err := actsFor(claims, reqPatientID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error checking guardian", "patient_id", reqPatientID, "err", err)
+			problem.Write(w, r, problem.CodeInternal)
+			return
+		}
+		if !guardian {
+			// log.Printf("Patient ID mismatch. Token: %d, Request: %d", claims.UserID, reqPatientID)
+			problem.Write(w, r, problem.CodeForbidden)
+			return
+		}
+	}
+
+	// Only approved doctors can be booked.
+	doctorID, _ := strconv.Atoi(appointmentReq.DoctorID)
+	if !requireApprovedDoctor(w, r, doctorID) {
 		return
 	}
 
 	// Attempt to create the appointment in the database.
-	if err := models.CreateAppointment(config.DB, &appointmentReq); err != nil {
-		// log.Printf("Error creating appointment: %v", err)
+	if err := Store.Appointments.Create(&appointmentReq); err != nil {
 		if err == models.ErrTimeNotAvailable {
-			http.Error(w, "Selected time slot is not available", http.StatusConflict)
+			metrics.Bookings.WithLabelValues("time_not_available").Inc()
+			slog.InfoContext(r.Context(), "booking rejected: slot not available",
+				"doctor_id", appointmentReq.DoctorID, "date", appointmentReq.Date, "time", appointmentReq.Time, "type", appointmentReq.Type)
+			problem.Write(w, r, problem.CodeSlotUnavailable)
 			return
 		}
-		http.Error(w, "Error creating appointment", http.StatusInternalServerError)
+		if err == models.ErrTimeInPast {
+			metrics.Bookings.WithLabelValues("time_in_past").Inc()
+			slog.InfoContext(r.Context(), "booking rejected: time in the past",
+				"doctor_id", appointmentReq.DoctorID, "date", appointmentReq.Date, "time", appointmentReq.Time, "type", appointmentReq.Type)
+			problem.Write(w, r, problem.CodeAppointmentInPast)
+			return
+		}
+		metrics.Bookings.WithLabelValues("error").Inc()
+		slog.ErrorContext(r.Context(), "booking failed", "err", err,
+			"doctor_id", appointmentReq.DoctorID, "date", appointmentReq.Date, "time", appointmentReq.Time, "type", appointmentReq.Type)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
+	metrics.Bookings.WithLabelValues("ok").Inc()
+	slog.InfoContext(r.Context(), "appointment booked",
+		"doctor_id", appointmentReq.DoctorID, "date", appointmentReq.Date, "time", appointmentReq.Time, "type", appointmentReq.Type)
+
 	// If successful, return a success message with a 201 Created status.
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Appointment created successfully"})
@@ -77,29 +105,20 @@ func GetPatientTwoNearestAppointments(w http.ResponseWriter, r *http.Request) {
 	patientID, err := strconv.Atoi(vars["id"]) // Convert the patient ID to an integer
 	if err != nil {
 		// log.Printf("Invalid patient ID: %v", err)
-		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
-		return
-	}
-
-	// Verify that the authenticated user is requesting their own appointments.
-	claims, _ := utils.GetUserClaims(r.Context())
-	if claims.UserID != patientID {
-		// log.Printf("Unauthorized access attempt. Token UserID: %d, Requested PatientID: %d", claims.UserID, patientID)
-		http.Error(w, "Unauthorized", http.StatusForbidden)
+		problem.Write(w, r, problem.CodeInvalidPatientID)
 		return
 	}
 
 	// Retrieve the two nearest appointments for the patient from the database.
-	appointments, err := models.GetPatientTwoNearestAppointments(config.DB, patientID)
+	appointments, err := Store.Appointments.PatientNearest(patientID)
 	if err != nil {
-		// log.Printf("Error retrieving appointments: %v", err)
-		http.Error(w, "Error retrieving appointments", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error retrieving appointments", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
 	// Return the appointments as a JSON response.
 	w.Header().Set("Content-Type", "application/json")
-	fmt.Println(appointments)
 	json.NewEncoder(w).Encode(appointments)
 }
 
@@ -109,29 +128,20 @@ func GetDoctorTwoNearestAppointments(w http.ResponseWriter, r *http.Request) {
 	doctorID, err := strconv.Atoi(vars["id"]) // Convert the doctor ID to an integer
 	if err != nil {
 		// log.Printf("Invalid doctor ID: %v", err)
-		http.Error(w, "Invalid doctor ID", http.StatusBadRequest)
-		return
-	}
-
-	// Verify that the authenticated user is requesting their own appointments.
-	claims, _ := utils.GetUserClaims(r.Context())
-	if claims.UserID != doctorID {
-		// log.Printf("Unauthorized access attempt. Token UserID: %d, Requested DoctorID: %d", claims.UserID, doctorID)
-		http.Error(w, "Unauthorized", http.StatusForbidden)
+		problem.Write(w, r, problem.CodeInvalidDoctorID)
 		return
 	}
 
 	// Retrieve the two nearest appointments for the doctor from the database.
-	appointments, err := models.GetDoctorTwoNearestAppointments(config.DB, doctorID)
+	appointments, err := Store.Appointments.DoctorNearest(doctorID)
 	if err != nil {
-		// log.Printf("Error retrieving appointments: %v", err)
-		http.Error(w, "Error retrieving appointments", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error retrieving appointments", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
 	// Return the appointments as a JSON response.
 	w.Header().Set("Content-Type", "application/json")
-	fmt.Println(appointments)
 	json.NewEncoder(w).Encode(appointments)
 }
 
@@ -141,37 +151,13 @@ func DeleteAppointment(w http.ResponseWriter, r *http.Request) {
 	appointmentID, err := strconv.Atoi(vars["id"]) // Convert the appointment ID to an integer
 	if err != nil {
 		// log.Printf("Invalid appointment ID: %v", err)
-		http.Error(w, "Invalid appointment ID", http.StatusBadRequest)
-		return
-	}
-
-	// Retrieve user claims from the context to ensure authentication.
-	claims, _ := utils.GetUserClaims(r.Context())
-
-	// Retrieve the appointment details from the database.
-	appointment, err := models.GetAppointmentById(config.DB, appointmentID)
-	if err != nil {
-		// log.Printf("Error retrieving appointment: %v", err)
-		http.Error(w, "Error retrieving appointment", http.StatusInternalServerError)
-		return
-	}
-
-	// Ensure the authenticated user has permission to delete the appointment.
-	if claims.IsPatient && appointment.PatientID != claims.UserID {
-		// log.Printf("Unauthorized deletion attempt by patient. Token UserID: %d, Appointment PatientID: %d", claims.UserID, appointment.PatientID)
-		http.Error(w, "Unauthorized", http.StatusForbidden)
-		return
-	}
-	if claims.IsDoctor && appointment.DoctorID != claims.UserID {
-		// log.Printf("Unauthorized deletion attempt by doctor. Token UserID: %d, Appointment DoctorID: %d", claims.UserID, appointment.DoctorID)
-		http.Error(w, "Unauthorized", http.StatusForbidden)
+		problem.Write(w, r, problem.CodeInvalidAppointmentID)
 		return
 	}
 
 	// Delete the appointment from the database.
-	if err := models.DeleteAppointment(config.DB, appointmentID); err != nil {
-		// log.Printf("Error deleting appointment: %v", err)
-		http.Error(w, "Error deleting appointment", http.StatusInternalServerError)
+	if err := Store.Appointments.Delete(appointmentID); err != nil {
+		writeError(w, r, err, "error deleting appointment")
 		return
 	}
 
@@ -188,7 +174,7 @@ func DeleteUnreservedAvailability(w http.ResponseWriter, r *http.Request) {
 	claims, ok := utils.GetUserClaims(r.Context())
 	if !ok {
 		// log.Print("ERROR: No claims found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Write(w, r, problem.CodeUnauthenticated)
 		return
 	}
 	// log.Printf("INFO: Claims found - DoctorID: %d, IsDoctor: %v", claims.UserID, claims.IsDoctor)
@@ -197,7 +183,7 @@ func DeleteUnreservedAvailability(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		// log.Printf("ERROR: Failed to read request body: %v", err)
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidBody)
 		return
 	}
 	// log.Printf("DEBUG: Raw request body: %s", string(body))
@@ -212,7 +198,7 @@ func DeleteUnreservedAvailability(w http.ResponseWriter, r *http.Request) {
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		// log.Printf("ERROR: Failed to decode JSON: %v", err)
 		// log.Printf("DEBUG: Request body was: %s", string(body))
-		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidBody)
 		return
 	}
 	// log.Printf("INFO: Successfully decoded request - Type: %s", req.Type)
@@ -220,7 +206,7 @@ func DeleteUnreservedAvailability(w http.ResponseWriter, r *http.Request) {
 	// Validate the visit type field.
 	if req.Type == "" {
 		// log.Print("ERROR: Type field is empty")
-		http.Error(w, "Type field is required", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidVisitType)
 		return
 	}
 
@@ -235,14 +221,14 @@ func DeleteUnreservedAvailability(w http.ResponseWriter, r *http.Request) {
 		// log.Printf("INFO: Using provided type: %s", visitType)
 	default:
 		// log.Printf("ERROR: Invalid visit type: %s", req.Type)
-		http.Error(w, "Visit type must be 'online', 'in-person', or 'آنلاین'", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidVisitType)
 		return
 	}
 
 	// Delete the unreserved availability slots for the specified visit type.
-	if err := models.DeleteUnreservedAvailability(config.DB, claims.UserID, visitType); err != nil {
-		// log.Printf("ERROR: Failed to delete availability: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to delete availability: %v", err), http.StatusInternalServerError)
+	if _, err := Store.Availability.DeleteUnreserved(claims.UserID, visitType); err != nil {
+		slog.ErrorContext(r.Context(), "failed to delete availability", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -261,58 +247,17 @@ func GetPatientAppointments(w http.ResponseWriter, r *http.Request) {
 	patientID, err := strconv.Atoi(vars["id"]) // Convert the patient ID to an integer
 	if err != nil {
 		// log.Printf("Invalid patient ID: %v", err)                  // Log the error
-		http.Error(w, "Invalid patient ID", http.StatusBadRequest) // Return a 400 Bad Request response
-		return
-	}
-
-	// Retrieve user claims from the context to ensure authentication.
-	claims, ok := utils.GetUserClaims(r.Context())
-	if !ok {
-		// log.Printf("No user claims found in context")          // Log if no claims are found
-		http.Error(w, "Unauthorized", http.StatusUnauthorized) // Return a 401 Unauthorized response
-		return
-	}
-
-	// Ensure the authenticated user is accessing their own appointments.
-	if claims.IsPatient && claims.UserID != patientID {
-		// log.Printf("Unauthorized access attempt. Token UserID: %d, Requested PatientID: %d", claims.UserID, patientID)
-		http.Error(w, "Unauthorized", http.StatusForbidden) // Return a 403 Forbidden response
+		problem.Write(w, r, problem.CodeInvalidPatientID) // Return a 400 Bad Request response
 		return
 	}
 
-	// If the user is a doctor, verify they are authorized to access the patient's appointments.
-	if claims.IsDoctor {
-		isAuthorized, err := isDoctorAuthorizedForPatient(claims.UserID, patientID)
-		if err != nil {
-			// log.Printf("Error checking doctor authorization: %v", err)             // Log any errors during authorization check
-			http.Error(w, "Internal server error", http.StatusInternalServerError) // Return a 500 Internal Server Error response
-			return
-		}
-		if !isAuthorized {
-			// log.Printf("Doctor %d is not authorized to access appointments for patient %d", claims.UserID, patientID)
-			http.Error(w, "Unauthorized", http.StatusForbidden) // Return a 403 Forbidden response
-			return
-		}
-	}
-
-	// Query the database for the patient's appointments.
-	query := `
-        SELECT 
-            CONCAT(d.first_name, ' ', d.last_name) as name, 
-            a.visit_type as type,
-            a.start_time as date
-        FROM appointments a
-        JOIN doctors d ON a.doctor_id = d.id
-        WHERE a.patient_id = ? AND a.start_time >= NOW()
-        ORDER BY a.start_time ASC`
-
-	rows, err := config.DB.Query(query, patientID) // Execute the query
+	// Load the patient's appointments from the store.
+	allAppointments, err := Store.Appointments.ListByPatient(patientID)
 	if err != nil {
-		// log.Printf("Error querying appointments: %v", err)                             // Log any database query errors
-		http.Error(w, "Error retrieving appointments", http.StatusInternalServerError) // Return a 500 Internal Server Error response
+		slog.ErrorContext(r.Context(), "error querying appointments", "err", err)
+		problem.Write(w, r, problem.CodeInternal) // Return a 500 Internal Server Error response
 		return
 	}
-	defer rows.Close() // Ensure the database rows are closed after use
 
 	// Define a struct to represent the appointment response.
 	type AppointmentListResponse struct {
@@ -323,35 +268,19 @@ func GetPatientAppointments(w http.ResponseWriter, r *http.Request) {
 
 	var appointments []AppointmentListResponse // Slice to store the appointment data
 
-	// Iterate through the query results and populate the appointments slice.
-	for rows.Next() {
-		var appt AppointmentListResponse
-		var dateStr []uint8 // Use []uint8 to read the raw date string from the database
-		err := rows.Scan(&appt.Name, &appt.Type, &dateStr)
-		if err != nil {
-			// log.Printf("Error scanning appointment: %v", err)                              // Log any errors during row scanning
-			http.Error(w, "Error processing appointments", http.StatusInternalServerError) // Return a 500 Internal Server Error response
-			return
-		}
-
-		// Convert the []uint8 date string to a time.Time object.
-		startTime, err := time.Parse("2006-01-02 15:04:05", string(dateStr)) // Adjust the layout based on your date format
-		if err != nil {
-			// log.Printf("Error parsing date: %v", err)                              // Log any errors during date parsing
-			http.Error(w, "Error processing date", http.StatusInternalServerError) // Return a 500 Internal Server Error response
-			return
+	// Keep only the upcoming appointments and convert their dates.
+	nowFormatted := time.Now().Format("2006-01-02 15:04:05")
+	for _, a := range allAppointments {
+		if a.StartTime.Format("2006-01-02 15:04:05") < nowFormatted {
+			continue
 		}
 
 		// Convert Gregorian date to Hijri date using a utility function.
-		appt.Date = utils.GregorianToSolar(startTime)
-		appointments = append(appointments, appt) // Add the appointment to the slice
-	}
-
-	// Check for any errors after iterating through the rows.
-	if err = rows.Err(); err != nil {
-		// log.Printf("Error after iterating rows: %v", err)                              // Log any errors that occurred during iteration
-		http.Error(w, "Error processing appointments", http.StatusInternalServerError) // Return a 500 Internal Server Error response
-		return
+		appointments = append(appointments, AppointmentListResponse{
+			Name: a.DoctorName,
+			Type: a.VisitType,
+			Date: utils.GregorianToSolar(a.StartTime),
+		})
 	}
 
 	// Log the number of retrieved appointments.
@@ -362,71 +291,34 @@ func GetPatientAppointments(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(appointments)
 }
 
-// isDoctorAuthorizedForPatient checks if a doctor is authorized to access a patient's appointments.
-func isDoctorAuthorizedForPatient(doctorID, patientID int) (bool, error) {
-	// Query the database to check if the patient has an appointment with the doctor.
-	query := `
-        SELECT EXISTS (
-            SELECT 1
-            FROM appointments
-            WHERE doctor_id = ? AND patient_id = ?
-        )`
-	var exists bool
-	err := config.DB.QueryRow(query, doctorID, patientID).Scan(&exists)
-	if err != nil {
-		return false, err // Return false and the error if the query fails
-	}
-	return exists, nil // Return true if the doctor is authorized, otherwise false
-}
-
 // GetDoctorAppointments retrieves all future appointments for a doctor
 func GetDoctorAppointments(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	doctorID, err := strconv.Atoi(vars["id"])
 	if err != nil {
 		// log.Printf("Invalid doctor ID: %v", err)
-		http.Error(w, "Invalid doctor ID", http.StatusBadRequest)
-		return
-	}
-
-	// Verify the authenticated user is requesting their own appointments
-	claims, _ := utils.GetUserClaims(r.Context())
-	if claims.UserID != doctorID {
-		// log.Printf("Unauthorized access attempt. Token UserID: %d, Requested DoctorID: %d", claims.UserID, doctorID)
-		http.Error(w, "Unauthorized", http.StatusForbidden)
+		problem.Write(w, r, problem.CodeInvalidDoctorID)
 		return
 	}
 
 	// Load Asia/Tehran timezone
 	tehranLoc, err := time.LoadLocation("Asia/Tehran")
 	if err != nil {
-		// log.Printf("Error loading Asia/Tehran timezone: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error loading Asia/Tehran timezone", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
-	// Get current time in Asia/Tehran and format for SQL query
+	// Get current time in Asia/Tehran, formatted like the stored start times
 	now := time.Now().In(tehranLoc)
 	nowFormatted := now.Format("2006-01-02 15:04:05")
 
-	query := `
-        SELECT 
-            CONCAT(p.first_name, ' ', p.last_name) as name,
-            a.visit_type as type,
-            DATE_FORMAT(a.start_time, '%Y-%m-%d %H:%i:%s') as start_time,
-            DATE_FORMAT(a.start_time, '%H:%i') as time
-        FROM appointments a
-        JOIN patients p ON a.patient_id = p.id
-        WHERE a.doctor_id = ? AND a.start_time >= ?
-        ORDER BY a.start_time ASC`
-
-	rows, err := config.DB.Query(query, doctorID, nowFormatted)
+	allAppointments, err := Store.Appointments.ListByDoctor(doctorID)
 	if err != nil {
-		// log.Printf("Error querying appointments: %v", err)
-		http.Error(w, "Error retrieving appointments", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error querying appointments", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
-	defer rows.Close()
 
 	type DoctorAppointmentResponse struct {
 		PatientName string `json:"name"`
@@ -436,40 +328,23 @@ func GetDoctorAppointments(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var appointments []DoctorAppointmentResponse
-	for rows.Next() {
-		var appt DoctorAppointmentResponse
-		var startTimeStr string
-		err := rows.Scan(&appt.PatientName, &appt.Type, &startTimeStr, &appt.Time)
-		if err != nil {
-			// log.Printf("Error scanning appointment: %v", err)
-			http.Error(w, "Error processing appointments", http.StatusInternalServerError)
-			return
+	for _, a := range allAppointments {
+		if a.StartTime.Format("2006-01-02 15:04:05") < nowFormatted {
+			continue
 		}
 
-		// Parse the start_time string
-		startTime, err := time.Parse("2006-01-02 15:04:05", startTimeStr)
-		if err != nil {
-			// log.Printf("Error parsing date: %v", err)
-			http.Error(w, "Error processing date", http.StatusInternalServerError)
-			return
+		appt := DoctorAppointmentResponse{
+			PatientName: a.PatientName,
+			Type:        a.VisitType,
+			Time:        a.StartTime.Format("15:04"),
 		}
 
-		// Convert to Asia/Tehran timezone
-		startTime = startTime.In(tehranLoc)
-
-		// Convert Gregorian date to Hijri date
-		appt.Date = utils.GregorianToSolar(startTime)
-		// Time is already formatted by SQL as HH:MM, no further conversion needed
+		// Convert Gregorian date to Hijri date in Asia/Tehran
+		appt.Date = utils.GregorianToSolar(a.StartTime.In(tehranLoc))
 
 		appointments = append(appointments, appt)
 	}
 
-	if err = rows.Err(); err != nil {
-		// log.Printf("Error after iterating rows: %v", err)
-		http.Error(w, "Error processing appointments", http.StatusInternalServerError)
-		return
-	}
-
 	// log.Printf("Retrieved %d appointments for doctor %d", len(appointments), doctorID)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -482,40 +357,17 @@ func GetDoctorAllAppointments(w http.ResponseWriter, r *http.Request) {
 	doctorID, err := strconv.Atoi(vars["id"]) // Convert the doctor ID to an integer
 	if err != nil {
 		// log.Printf("Invalid doctor ID: %v", err)                  // Log the error
-		http.Error(w, "Invalid doctor ID", http.StatusBadRequest) // Return a 400 Bad Request response
+		problem.Write(w, r, problem.CodeInvalidDoctorID) // Return a 400 Bad Request response
 		return
 	}
 
-	// Verify the authenticated user is requesting their own appointments.
-	claims, _ := utils.GetUserClaims(r.Context())
-	if claims.UserID != doctorID {
-		// log.Printf("Unauthorized access attempt. Token UserID: %d, Requested DoctorID: %d", claims.UserID, doctorID)
-		http.Error(w, "Unauthorized", http.StatusForbidden) // Return a 403 Forbidden response
-		return
-	}
-
-	// Query the database for all the doctor's appointments.
-	query := `
-        SELECT 
-            a.id as appointment_id,
-            a.doctor_id,
-            a.patient_id,
-            a.visit_type as type,
-            a.start_time as date,
-            DATE_FORMAT(a.start_time, '%H:%i') as time,
-            CONCAT(p.first_name, ' ', p.last_name) as name
-        FROM appointments a
-        JOIN patients p ON a.patient_id = p.id
-        WHERE a.doctor_id = ?
-        ORDER BY a.start_time ASC`
-
-	rows, err := config.DB.Query(query, doctorID) // Execute the query
+	// Load all the doctor's appointments from the store.
+	allAppointments, err := Store.Appointments.ListByDoctor(doctorID)
 	if err != nil {
-		// log.Printf("Error querying appointments: %v", err)                             // Log any database query errors
-		http.Error(w, "Error retrieving appointments", http.StatusInternalServerError) // Return a 500 Internal Server Error response
+		slog.ErrorContext(r.Context(), "error querying appointments", "err", err)
+		problem.Write(w, r, problem.CodeInternal) // Return a 500 Internal Server Error response
 		return
 	}
-	defer rows.Close() // Ensure the database rows are closed after use
 
 	// Define a struct to represent the appointment response.
 	type DoctorAppointmentResponse struct {
@@ -530,41 +382,17 @@ func GetDoctorAllAppointments(w http.ResponseWriter, r *http.Request) {
 
 	var appointments []DoctorAppointmentResponse // Slice to store the appointment data
 
-	// Iterate through the query results and populate the appointments slice.
-	for rows.Next() {
-		var appt DoctorAppointmentResponse
-		var appointmentID, doctorID, patientID int
-		var dateStr []uint8 // Use []uint8 to read the raw date string from the database
-		err := rows.Scan(&appointmentID, &doctorID, &patientID, &appt.Type, &dateStr, &appt.Time, &appt.PatientName)
-		if err != nil {
-			// log.Printf("Error scanning appointment: %v", err)                              // Log any errors during row scanning
-			http.Error(w, "Error processing appointments", http.StatusInternalServerError) // Return a 500 Internal Server Error response
-			return
-		}
-
-		// Convert the []uint8 date string to a time.Time object.
-		startTime, err := time.Parse(time.RFC3339, string(dateStr)) // Use RFC3339 format
-		if err != nil {
-			// log.Printf("Error parsing date: %v", err)                              // Log any errors during date parsing
-			http.Error(w, "Error processing date", http.StatusInternalServerError) // Return a 500 Internal Server Error response
-			return
-		}
-
-		// Convert IDs to strings.
-		appt.ID = strconv.Itoa(appointmentID)
-		appt.DoctorID = strconv.Itoa(doctorID)
-		appt.PatientID = strconv.Itoa(patientID)
-
-		// Convert Gregorian date to Hijri date using a utility function.
-		appt.Date = utils.GregorianToSolar(startTime)
-		appointments = append(appointments, appt) // Add the appointment to the slice
-	}
-
-	// Check for any errors after iterating through the rows.
-	if err = rows.Err(); err != nil {
-		// log.Printf("Error after iterating rows: %v", err)                              // Log any errors that occurred during iteration
-		http.Error(w, "Error processing appointments", http.StatusInternalServerError) // Return a 500 Internal Server Error response
-		return
+	// Convert IDs to strings and dates to Hijri format.
+	for _, a := range allAppointments {
+		appointments = append(appointments, DoctorAppointmentResponse{
+			ID:          strconv.Itoa(a.ID),
+			DoctorID:    strconv.Itoa(a.DoctorID),
+			PatientID:   strconv.Itoa(a.PatientID),
+			Type:        a.VisitType,
+			Date:        utils.GregorianToSolar(a.StartTime),
+			Time:        a.StartTime.Format("15:04"),
+			PatientName: a.PatientName,
+		})
 	}
 
 	// Log the number of retrieved appointments.
@@ -581,7 +409,7 @@ func GetPatientAllAppointments(w http.ResponseWriter, r *http.Request) {
 	patientID, err := strconv.Atoi(vars["id"]) // Convert the patient ID to an integer
 	if err != nil {
 		// log.Printf("Invalid patient ID: %v", err)                  // Log the error
-		http.Error(w, "Invalid patient ID", http.StatusBadRequest) // Return a 400 Bad Request response
+		problem.Write(w, r, problem.CodeInvalidPatientID) // Return a 400 Bad Request response
 		return
 	}
 
@@ -589,55 +417,17 @@ func GetPatientAllAppointments(w http.ResponseWriter, r *http.Request) {
 	claims, ok := utils.GetUserClaims(r.Context())
 	if !ok {
 		// log.Printf("Failed to retrieve user claims")           // Log if no claims are found
-		http.Error(w, "Unauthorized", http.StatusUnauthorized) // Return a 401 Unauthorized response
-		return
-	}
-
-	// Ensure the authenticated user is accessing their own appointments or is authorized as a doctor.
-	if claims.IsPatient && claims.UserID != patientID {
-		// log.Printf("Unauthorized access attempt. Token UserID: %d, Requested PatientID: %d", claims.UserID, patientID)
-		http.Error(w, "Unauthorized", http.StatusForbidden) // Return a 403 Forbidden response
+		problem.Write(w, r, problem.CodeUnauthenticated) // Return a 401 Unauthorized response
 		return
-	} else if !claims.IsDoctor && !claims.IsPatient {
-		// log.Printf("Unauthorized access attempt. User is neither a doctor nor a patient")
-		http.Error(w, "Unauthorized", http.StatusForbidden) // Return a 403 Forbidden response
-		return
-	}
-
-	// Build the query based on the user's role.
-	query := `
-        SELECT 
-            a.id as appointment_id,
-            a.doctor_id,
-            a.patient_id,
-            a.visit_type as type,
-            a.start_time as date,
-            DATE_FORMAT(a.start_time, '%H:%i') as time,
-            CONCAT(d.first_name, ' ', d.last_name) as name
-        FROM appointments a
-        JOIN doctors d ON a.doctor_id = d.id
-        WHERE a.patient_id = ?`
-
-	// If the user is a doctor, ensure they are only accessing appointments of their patients.
-	if claims.IsDoctor {
-		query += " AND a.doctor_id = ?"
 	}
 
-	query += " ORDER BY a.start_time ASC"
-
-	// Execute the query.
-	var rows *sql.Rows
-	if claims.IsDoctor {
-		rows, err = config.DB.Query(query, patientID, claims.UserID)
-	} else {
-		rows, err = config.DB.Query(query, patientID)
-	}
+	// Load all the patient's appointments from the store.
+	allAppointments, err := Store.Appointments.ListByPatient(patientID)
 	if err != nil {
-		// log.Printf("Error querying appointments: %v", err)                             // Log any database query errors
-		http.Error(w, "Error retrieving appointments", http.StatusInternalServerError) // Return a 500 Internal Server Error response
+		slog.ErrorContext(r.Context(), "error querying appointments", "err", err)
+		problem.Write(w, r, problem.CodeInternal) // Return a 500 Internal Server Error response
 		return
 	}
-	defer rows.Close() // Ensure the database rows are closed after use
 
 	// Define a struct to represent the appointment response.
 	type PatientAppointmentResponse struct {
@@ -652,46 +442,31 @@ func GetPatientAllAppointments(w http.ResponseWriter, r *http.Request) {
 
 	var appointments []PatientAppointmentResponse // Slice to store the appointment data
 
-	// Iterate through the query results and populate the appointments slice.
-	for rows.Next() {
-		var appt PatientAppointmentResponse
-		var appointmentID, doctorID, patientID int
-		var dateStr []uint8 // Use []uint8 to read the raw date string from the database
-		err := rows.Scan(&appointmentID, &doctorID, &patientID, &appt.Type, &dateStr, &appt.Time, &appt.Name)
-		if err != nil {
-			// log.Printf("Error scanning appointment: %v", err)                              // Log any errors during row scanning
-			http.Error(w, "Error processing appointments", http.StatusInternalServerError) // Return a 500 Internal Server Error response
-			return
+	for _, a := range allAppointments {
+		// If the user is a doctor, only include appointments with that doctor.
+		if claims.IsDoctor && a.DoctorID != claims.UserID {
+			continue
 		}
 
-		// Convert the []uint8 date string to a time.Time object.
-		startTime, err := time.Parse(time.RFC3339, string(dateStr)) // Use RFC3339 format
-		if err != nil {
-			// log.Printf("Error parsing date: %v", err)                              // Log any errors during date parsing
-			http.Error(w, "Error processing date", http.StatusInternalServerError) // Return a 500 Internal Server Error response
-			return
-		}
-
-		// Convert IDs to strings.
-		appt.ID = strconv.Itoa(appointmentID)
-		appt.DoctorID = strconv.Itoa(doctorID)
-		appt.PatientID = strconv.Itoa(patientID)
-
-		// Convert Gregorian date to Hijri date using a utility function.
-		appt.Date = utils.GregorianToSolar(startTime)
-		appointments = append(appointments, appt) // Add the appointment to the slice
-	}
-
-	// Check for any errors after iterating through the rows.
-	if err = rows.Err(); err != nil {
-		// log.Printf("Error after iterating rows: %v", err)                              // Log any errors that occurred during iteration
-		http.Error(w, "Error processing appointments", http.StatusInternalServerError) // Return a 500 Internal Server Error response
-		return
+		// Convert IDs to strings and the date to Hijri format.
+		appointments = append(appointments, PatientAppointmentResponse{
+			ID:        strconv.Itoa(a.ID),
+			DoctorID:  strconv.Itoa(a.DoctorID),
+			PatientID: strconv.Itoa(a.PatientID),
+			Type:      a.VisitType,
+			Date:      utils.GregorianToSolar(a.StartTime),
+			Time:      a.StartTime.Format("15:04"),
+			Name:      a.DoctorName,
+		})
 	}
 
 	// Log the number of retrieved appointments.
 	// log.Printf("Retrieved %d appointments for patient %d", len(appointments), patientID)
 
+	if !recordAccess(w, r, models.AuditAppointmentsRead, "patient:"+strconv.Itoa(patientID)+"/appointments", patientID) {
+		return
+	}
+
 	// Set the response content type to JSON and encode the appointments slice.
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(appointments)
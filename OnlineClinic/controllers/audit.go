@@ -0,0 +1,225 @@
+// controllers/audit.go
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"onlineClinic/models"
+	"onlineClinic/problem"
+	"onlineClinic/utils"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultAuditLimit and maxAuditLimit bound the pages of the audit
+	// endpoints. CSV exports are not paged.
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// recordAccess writes to the audit trail that the logged-in user read
+// resource, which holds medical data of patientID. A patient reading their
+// own data is not recorded. Access that cannot be recorded is refused:
+// recordAccess then writes the error response and returns false.
+func recordAccess(w http.ResponseWriter, r *http.Request, action, resource string, patientID int) bool {
+	claims, ok := utils.GetUserClaims(r.Context())
+	if !ok {
+		problem.Write(w, r, problem.CodeUnauthenticated)
+		return false
+	}
+	if claims.Is(utils.Principal{Role: utils.RolePatient, ID: patientID}) {
+		return true
+	}
+
+	actorID := claims.UserID
+	event := &models.AuditEvent{
+		Action:    action,
+		ActorRole: string(claims.Role),
+		ActorID:   &actorID,
+		Subject:   resource,
+		PatientID: &patientID,
+		IPAddress: utils.ClientIP(r),
+	}
+	if err := Store.Audit.Record(event); err != nil {
+		slog.ErrorContext(r.Context(), "error recording access in audit trail", "action", action, "resource", resource, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return false
+	}
+	return true
+}
+
+// AccessLogEntry is one access to a patient's data as shown to the patient.
+type AccessLogEntry struct {
+	ID        int64     `json:"id"`
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	ActorRole string    `json:"actorRole"`
+	ActorID   int       `json:"actorId"`
+	Resource  string    `json:"resource"`
+}
+
+// GetPatientAccessLog lists who read the medical data of a patient, newest
+// first. ?before=<id> returns the next page.
+func GetPatientAccessLog(w http.ResponseWriter, r *http.Request) {
+	patientID, err := utils.PathID(r, "id")
+	if err != nil {
+		problem.Write(w, r, problem.CodeInvalidPatientID)
+		return
+	}
+	filter, ok := auditPage(w, r)
+	if !ok {
+		return
+	}
+	filter.PatientID = patientID
+
+	events, err := Store.Audit.List(filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing patient access log", "patient_id", patientID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	entries := make([]AccessLogEntry, 0, len(events))
+	for _, e := range events {
+		entry := AccessLogEntry{ID: e.ID, Time: e.CreatedAt, Action: e.Action, ActorRole: e.ActorRole, Resource: e.Subject}
+		if e.ActorID != nil {
+			entry.ActorID = *e.ActorID
+		}
+		entries = append(entries, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// ListAuditEvents lets admins query the audit trail by action, actor,
+// patient and time (?since and ?until, RFC 3339 or yyyy-mm-dd in UTC).
+// ?format=csv exports every matching event, hashes included.
+func ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter, ok := auditPage(w, r)
+	if !ok {
+		return
+	}
+	filter.Action = q.Get("action")
+	filter.ActorRole = q.Get("actorRole")
+
+	var err error
+	for name, dst := range map[string]*int{"actorId": &filter.ActorID, "patientId": &filter.PatientID} {
+		if raw := q.Get(name); raw != "" {
+			if *dst, err = strconv.Atoi(raw); err != nil || *dst <= 0 {
+				problem.WriteDetail(w, r, problem.CodeInvalidQuery, "Invalid "+name)
+				return
+			}
+		}
+	}
+	for name, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if raw := q.Get(name); raw != "" {
+			if *dst, err = parseAuditTime(raw); err != nil {
+				problem.WriteDetail(w, r, problem.CodeInvalidQuery, "Invalid "+name+", use RFC 3339 or yyyy-mm-dd")
+				return
+			}
+		}
+	}
+
+	if q.Get("format") == "csv" {
+		if q.Get("limit") == "" {
+			filter.Limit = 0
+		}
+		exportAuditEvents(w, r, filter)
+		return
+	}
+
+	events, err := Store.Audit.List(filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing audit events", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+	if events == nil {
+		events = []models.AuditEvent{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// exportAuditEvents streams the events matching filter as CSV. An error
+// after the first row can only be noted in the log.
+func exportAuditEvents(w http.ResponseWriter, r *http.Request, filter models.AuditFilter) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.csv"`, time.Now().UTC().Format("20060102T150405Z")))
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "created_at", "action", "actor_role", "actor_id", "subject", "patient_id", "ip_address", "detail", "prev_hash", "hash"})
+	err := Store.Audit.Each(filter, func(e models.AuditEvent) error {
+		return out.Write([]string{
+			strconv.FormatInt(e.ID, 10), e.CreatedAt.UTC().Format(time.RFC3339), e.Action, e.ActorRole,
+			optionalID(e.ActorID), e.Subject, optionalID(e.PatientID), e.IPAddress, e.Detail, e.PrevHash, e.Hash,
+		})
+	})
+	out.Flush()
+	if err == nil {
+		err = out.Error()
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error exporting audit events", "err", err)
+	}
+}
+
+// VerifyAuditChain checks that no audit event was changed or removed.
+func VerifyAuditChain(w http.ResponseWriter, r *http.Request) {
+	report, err := Store.Audit.Verify()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error verifying audit chain", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+	if !report.Intact {
+		slog.WarnContext(r.Context(), "audit chain broken", "broken_at", report.BrokenAt, "problem", report.Problem)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// auditPage reads ?limit and ?before. It writes the error response itself
+// and returns false if they are invalid.
+func auditPage(w http.ResponseWriter, r *http.Request) (models.AuditFilter, bool) {
+	filter := models.AuditFilter{Limit: defaultAuditLimit}
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxAuditLimit {
+			problem.WriteDetail(w, r, problem.CodeInvalidQuery, fmt.Sprintf("Invalid limit, must be 1 to %d", maxAuditLimit))
+			return filter, false
+		}
+		filter.Limit = limit
+	}
+	if raw := r.URL.Query().Get("before"); raw != "" {
+		before, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || before <= 0 {
+			problem.WriteDetail(w, r, problem.CodeInvalidQuery, "Invalid before")
+			return filter, false
+		}
+		filter.BeforeID = before
+	}
+	return filter, true
+}
+
+func parseAuditTime(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", raw)
+}
+
+func optionalID(id *int) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.Itoa(*id)
+}
@@ -0,0 +1,57 @@
+// controllers/audit_test.go
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"onlineClinic/models"
+	"onlineClinic/utils"
+)
+
+func TestPrescriptionSearchIsAudited(t *testing.T) {
+	seedOwnership(t, useTestStore(t))
+	query := "date=" + time.Now().UTC().Format("2006-01-02")
+
+	tests := []struct {
+		name   string
+		claims *utils.Claims
+		want   []int // Patients recorded
+	}{
+		{"doctor", &utils.Claims{UserID: 1, Role: utils.RoleDoctor, Permissions: utils.PermissionsOf(utils.RoleDoctor)}, []int{1, 2}},
+		// Patients reading their own prescriptions are not recorded
+		{"guardian", &utils.Claims{UserID: 1, Role: utils.RolePatient, Permissions: utils.PermissionsOf(utils.RolePatient)}, []int{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/prescriptions/search?"+query, nil)
+			r = r.WithContext(utils.SetUserClaims(r.Context(), tt.claims))
+			w := httptest.NewRecorder()
+			GetPrescriptionsByPatientNameAndDateHandler(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+
+			events, err := Store.Audit.List(models.AuditFilter{
+				Action:    models.AuditPrescriptionsSearch,
+				ActorRole: string(tt.claims.Role),
+				ActorID:   tt.claims.UserID,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var patients []int
+			for _, e := range events {
+				patients = append(patients, *e.PatientID)
+			}
+			slices.Sort(patients)
+			if !slices.Equal(patients, tt.want) {
+				t.Errorf("audited patients = %v, want %v", patients, tt.want)
+			}
+		})
+	}
+}
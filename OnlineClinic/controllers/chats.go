@@ -2,13 +2,21 @@
 package controllers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"onlineClinic/config"
+	"onlineClinic/logging"
+	"onlineClinic/metrics"
 	"onlineClinic/models"
+	"onlineClinic/problem"
 	"onlineClinic/utils"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -16,9 +24,8 @@ import (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all connections (in production, restrict this to trusted origins)
-	},
+	CheckOrigin:     checkWsOrigin,
+	Subprotocols:    []string{wsSubprotocol},
 }
 
 // Hub maintains the set of active Clients and broadcasts messages to them
@@ -27,7 +34,13 @@ type Hub struct {
 	broadcast  chan []byte      // Inbound messages from Clients
 	register   chan *Client     // Register requests from Clients
 	unregister chan *Client     // Unregister requests from Clients
+	ping       chan struct{}    // Answered by Run, see Ping
 	mu         sync.Mutex       // Mutex to protect the Clients map
+
+	quit     chan struct{}  // Closed by Shutdown to stop Run
+	done     chan struct{}  // Closed when Run has returned
+	stopOnce sync.Once      // Guards closing quit
+	writers  sync.WaitGroup // Running writePumps
 }
 
 // Client represents a WebSocket connection
@@ -35,7 +48,13 @@ type Client struct {
 	hub  *Hub
 	Conn *websocket.Conn
 	send chan []byte
-	ID   int // User ID of the client
+	ID   int             // Principal ID of the client
+	ctx  context.Context // Carries the request ID and user for logging
+
+	sessionID string    // Login session of the access token
+	expiresAt time.Time // When the access token expires
+
+	closeMsg []byte // Close frame payload, set by the hub before closing send
 }
 
 // NewHub initializes a new Hub
@@ -44,21 +63,30 @@ func NewHub() *Hub {
 		broadcast:  make(chan []byte),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		ping:       make(chan struct{}),
 		Clients:    make(map[*Client]bool),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
 	}
 }
 
-// Run starts the Hub
+// Run starts the Hub. It returns after Shutdown has been called and every
+// client has been told to close.
 func (h *Hub) Run() {
+	defer close(h.done)
+
 	for {
 		select {
+		case <-h.ping:
 		case client := <-h.register:
 			h.Clients[client] = true
+			metrics.HubClients.Set(float64(len(h.Clients)))
 			// log.Printf("Client registered: %d", client.ID)
 		case client := <-h.unregister:
 			if _, ok := h.Clients[client]; ok {
 				delete(h.Clients, client)
 				close(client.send)
+				metrics.HubClients.Set(float64(len(h.Clients)))
 				// log.Printf("Client unregistered: %d", client.ID)
 			}
 		case message := <-h.broadcast:
@@ -66,50 +94,130 @@ func (h *Hub) Run() {
 				select {
 				case client.send <- message:
 				default:
+					metrics.WSSendsDropped.Inc()
 					close(client.send)
 					delete(h.Clients, client)
 				}
 			}
+			metrics.HubClients.Set(float64(len(h.Clients)))
+		case <-h.quit:
+			// Closing send makes each writePump flush the close frame and exit
+			for client := range h.Clients {
+				client.closeMsg = websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+				close(client.send)
+				delete(h.Clients, client)
+			}
+			metrics.HubClients.Set(0)
+			return
 		}
 	}
 }
 
+// ErrHubStopped is returned by Ping once Run has returned.
+var ErrHubStopped = errors.New("chat hub is stopped")
+
+// Ping reports whether Run is serving the Hub. It fails if Run has not been
+// started, is stuck or has stopped.
+func (h *Hub) Ping(ctx context.Context) error {
+	select {
+	case h.ping <- struct{}{}:
+		return nil
+	case <-h.done:
+		return ErrHubStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown stops the Hub and waits until every connected client has been sent
+// a close frame, or until ctx is done.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.stopOnce.Do(func() { close(h.quit) })
+
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	flushed := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // ServeWs handles WebSocket requests from Clients
 func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
-	// Extract the token from the query parameters
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		http.Error(w, "Missing token", http.StatusUnauthorized)
+	// Check the origin before using up a ticket; the upgrader checks again
+	if !checkWsOrigin(r) {
+		slog.WarnContext(r.Context(), "websocket origin not allowed", "origin", r.Header.Get("Origin"))
+		problem.Write(w, r, problem.CodeOriginNotAllowed)
 		return
 	}
 
-	// Add "Bearer " prefix to the token
-	fullToken := token
+	claims, ok := authenticateWs(w, r)
+	if !ok {
+		return
+	}
 
-	// Validate the token
-	claims, err := utils.VerifyToken(fullToken)
-	if err != nil {
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+	// Only doctors and patients chat
+	if _, ok := claims.Permissions[utils.PermChat]; !ok {
+		problem.Write(w, r, problem.CodeForbidden)
 		return
 	}
 
+	// Messages name their sender and receiver by principal ID. Guardians
+	// open a connection of its own for each dependent they chat for.
+	principalID, ok := chatAs(w, r, claims)
+	if !ok {
+		return
+	}
+
+	// Refuse new connections once the hub is shutting down
+	select {
+	case <-hub.quit:
+		problem.Write(w, r, problem.CodeShuttingDown)
+		return
+	default:
+	}
+
 	// Upgrade the HTTP connection to a WebSocket connection
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		// log.Println(err)
+		slog.WarnContext(r.Context(), "websocket upgrade failed", "err", err)
 		return
 	}
 
-	// Create a new client with the authenticated user ID
+	// Create a new client with the authenticated principal ID
 	client := &Client{
 		hub:  hub,
 		Conn: conn,
 		send: make(chan []byte, 256),
-		ID:   claims.UserID,
+		ID:   principalID,
+		ctx:  logging.WithUser(context.WithoutCancel(r.Context()), claims.UserID, string(claims.Role)),
+
+		sessionID: claims.SessionID,
+		expiresAt: time.Unix(claims.ExpiresAt, 0),
 	}
 
-	// Register the client
-	client.hub.register <- client
+	// Register the client, unless the hub stopped while we were upgrading
+	hub.writers.Add(1)
+	select {
+	case client.hub.register <- client:
+	case <-hub.done:
+		hub.writers.Done()
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+		conn.Close()
+		return
+	}
 
 	// Start goroutines to handle reading and writing messages
 	go client.writePump()
@@ -119,7 +227,10 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 // readPump reads messages from the WebSocket connection
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
 		c.Conn.Close()
 		// log.Printf("Client %d disconnected", c.ID)
 	}()
@@ -128,36 +239,52 @@ func (c *Client) readPump() {
 		_, message, err := c.Conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				// log.Printf("WebSocket error: %v", err)
+				slog.InfoContext(c.ctx, "websocket closed unexpectedly", "err", err)
 			}
 			break
 		}
+		metrics.WSMessagesIn.Inc()
 
 		// Parse the incoming message
 		var msg models.WSMessage
 		if err := json.Unmarshal(message, &msg); err != nil {
-			// log.Printf("Error unmarshaling message: %v", err)
+			slog.WarnContext(c.ctx, "invalid websocket message", "err", err)
 			continue
 		}
 
 		// Validate the message
 		if msg.SenderID != c.ID {
-			// log.Printf("Unauthorized message sender: expected %d, got %d", c.ID, msg.SenderID)
+			slog.WarnContext(c.ctx, "websocket message from another sender", "sender_id", msg.SenderID)
+			continue
+		}
+
+		// The receiver has to be a doctor or patient; the foreign keys
+		// only ensure it is a principal
+		peer, err := chatPeer(msg.ReceiverID)
+		if err != nil {
+			slog.ErrorContext(c.ctx, "error looking up receiver", "err", err)
+			continue
+		}
+		if peer == nil {
+			c.Conn.WriteJSON(map[string]string{
+				"error": "Receiver not found",
+				"code":  string(problem.CodeChatPeerNotFound),
+			})
 			continue
 		}
 
 		// Check if a chat exists between the sender and receiver
-		existingChatID, err := models.ChatExists(config.DB, msg.SenderID, msg.ReceiverID)
+		existingChatID, err := Store.Chats.Exists(msg.SenderID, msg.ReceiverID)
 		if err != nil {
-			// log.Printf("Error checking for existing chat: %v", err)
+			slog.ErrorContext(c.ctx, "error checking for existing chat", "err", err)
 			continue
 		}
 
 		// If no chat exists, create one
 		if existingChatID == 0 {
-			existingChatID, err = models.CreateChat(config.DB, msg.SenderID, msg.ReceiverID)
+			existingChatID, err = Store.Chats.Create(msg.SenderID, msg.ReceiverID)
 			if err != nil {
-				// log.Printf("Error creating chat: %v", err)
+				slog.ErrorContext(c.ctx, "error creating chat", "err", err)
 				continue
 			}
 			// log.Printf("Created new chat with ID: %d", existingChatID)
@@ -172,75 +299,213 @@ func (c *Client) readPump() {
 			repliedMessage = *msg.RepliedMessage
 		}
 
-		if err := models.AddMessage(config.DB, msg.ChatID, msg.SenderID, msg.ReceiverID, msg.Message, msg.Time, repliedMessage, msg.RepliedMessageID, msg.Date, msg.AttachedFile, msg.IsRead); err != nil {
-			// log.Printf("Error saving message to database: %v", err)
+		if err := Store.Chats.AddMessage(msg.ChatID, msg.SenderID, msg.ReceiverID, msg.Message, msg.Time, repliedMessage, msg.RepliedMessageID, msg.Date, msg.AttachedFile, msg.IsRead); err != nil {
+			slog.ErrorContext(c.ctx, "error saving message to database", "chat_id", msg.ChatID, "err", err)
 
 			// Notify the client that the message could not be sent
 			c.Conn.WriteJSON(map[string]string{
-				"error":   "Failed to send message",
-				"details": err.Error(),
+				"error": "Failed to send message",
+				"code":  string(problem.CodeChatSendFailed),
 			})
 			continue
 		}
 
 		// Broadcast the message to all Clients
-		c.hub.broadcast <- message
+		select {
+		case c.hub.broadcast <- message:
+		case <-c.hub.done:
+			return
+		}
 	}
 }
 
-// writePump writes messages to the WebSocket connection
+// writePump writes messages to the WebSocket connection. It closes the
+// connection when the access token expires or its session is revoked.
 func (c *Client) writePump() {
+	expired := time.NewTimer(time.Until(c.expiresAt))
+	sessionCheck := time.NewTicker(config.Cfg.WSSessionInterval)
 	defer func() {
+		expired.Stop()
+		sessionCheck.Stop()
 		c.Conn.Close()
+		c.hub.writers.Done()
 		// log.Printf("Client %d write pump closed", c.ID)
 	}()
 
 	for {
 		select {
+		case <-expired.C:
+			c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "token expired"))
+			return
+
+		case <-sessionCheck.C:
+			revoked, err := utils.Revocations.Revoked(c.sessionID)
+			if err != nil {
+				slog.ErrorContext(c.ctx, "error checking websocket session", "session_id", c.sessionID, "err", err)
+				continue
+			}
+			if revoked {
+				c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session revoked"))
+				return
+			}
+
 		case message, ok := <-c.send:
 			if !ok {
 				// The hub closed the channel
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.Conn.WriteMessage(websocket.CloseMessage, c.closeMsg)
 				return
 			}
 
 			// Write the message to the WebSocket connection
 			if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
-				// log.Printf("Error writing message: %v", err)
+				slog.InfoContext(c.ctx, "error writing websocket message", "err", err)
 				return
 			}
+			metrics.WSMessagesOut.Inc()
 		}
 	}
 }
 
-func GetChatHistory(w http.ResponseWriter, r *http.Request) {
+// principalOf returns the principal ID of the account claims were issued to.
+func principalOf(claims *utils.Claims) (int, error) {
+	role := models.PrincipalStaff
+	switch claims.Role {
+	case utils.RoleDoctor:
+		role = models.PrincipalDoctor
+	case utils.RolePatient:
+		role = models.PrincipalPatient
+	}
+	return Store.Principals.ForAccount(role, claims.UserID)
+}
+
+// chatPrincipal returns the claims of the request and the principal ID its
+// user chats as, see chatAs. It writes a problem and returns false if
+// either is missing.
+func chatPrincipal(w http.ResponseWriter, r *http.Request) (*utils.Claims, int, bool) {
 	claims, ok := r.Context().Value(utils.UserClaimsKey).(*utils.Claims)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Write(w, r, problem.CodeUnauthenticated)
+		return nil, 0, false
+	}
+	principalID, ok := chatAs(w, r, claims)
+	if !ok {
+		return nil, 0, false
+	}
+	return claims, principalID, true
+}
+
+// chatAs returns the principal ID of the user, or that of the minor
+// dependent named by ?dependent_id, for whom their guardian chats. It
+// writes a problem and returns false if it cannot.
+func chatAs(w http.ResponseWriter, r *http.Request, claims *utils.Claims) (int, bool) {
+	param := r.URL.Query().Get("dependent_id")
+	if param == "" {
+		principalID, err := principalOf(claims)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error resolving principal", "err", err)
+			problem.Write(w, r, problem.CodeInternal)
+			return 0, false
+		}
+		return principalID, true
+	}
+
+	dependentID, err := strconv.Atoi(param)
+	if err != nil {
+		problem.Write(w, r, problem.CodeInvalidPatientID)
+		return 0, false
+	}
+	guardian, err := actsFor(claims, dependentID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error checking guardian", "patient_id", dependentID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return 0, false
+	}
+	if !guardian {
+		problem.Write(w, r, problem.CodeForbidden)
+		return 0, false
+	}
+	principalID, err := Store.Principals.ForAccount(models.PrincipalPatient, dependentID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error resolving principal", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return 0, false
+	}
+	return principalID, true
+}
+
+// chatPeer returns principal id if it is a doctor or patient, the only
+// principals that chat, or nil if it is not.
+func chatPeer(id int) (*models.Principal, error) {
+	peer, err := Store.Principals.Get(id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if peer.Role != models.PrincipalDoctor && peer.Role != models.PrincipalPatient {
+		return nil, nil
+	}
+	return peer, nil
+}
+
+// requireChatPeer is chatPeer for handlers: it writes a problem and returns
+// nil if id is not a doctor or patient.
+func requireChatPeer(w http.ResponseWriter, r *http.Request, id int) *models.Principal {
+	peer, err := chatPeer(id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error looking up chat participant", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return nil
+	}
+	if peer == nil {
+		problem.Write(w, r, problem.CodeChatPeerNotFound)
+	}
+	return peer
+}
+
+// GetChatHistory returns the messages between the user and the principal
+// receiver_id.
+func GetChatHistory(w http.ResponseWriter, r *http.Request) {
+	claims, principalID, ok := chatPrincipal(w, r)
+	if !ok {
 		return
 	}
 
 	receiverIDStr := r.URL.Query().Get("receiver_id")
 	receiverID, err := strconv.Atoi(receiverIDStr)
 	if err != nil {
-		http.Error(w, "Invalid receiver ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidReceiverID)
+		return
+	}
+	peer := requireChatPeer(w, r, receiverID)
+	if peer == nil {
 		return
 	}
 
-	chats, err := models.GetChatHistory(config.DB, claims.UserID, receiverID)
+	chats, err := Store.Chats.History(principalID, receiverID)
 	if err != nil {
-		http.Error(w, "Failed to get chat history", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "failed to get chat history", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
+	// A doctor reading a chat with a patient reads the patient's messages.
+	if claims.Role == utils.RoleDoctor && peer.Role == models.PrincipalPatient {
+		resource := "patient:" + strconv.Itoa(peer.AccountID) + "/chat/doctor:" + strconv.Itoa(claims.UserID)
+		if !recordAccess(w, r, models.AuditChatHistoryRead, resource, peer.AccountID) {
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(chats)
 }
 
+// CreateChat opens a chat between two principals, one of them the user.
 func CreateChat(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value(utils.UserClaimsKey).(*utils.Claims)
+	_, principalID, ok := chatPrincipal(w, r)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
@@ -249,26 +514,34 @@ func CreateChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidBody)
 		return
 	}
 
 	if len(request.Participants) != 2 {
-		http.Error(w, "Exactly two participants are required", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeChatParticipants)
 		return
 	}
 
 	// Ensure the authenticated user is one of the participants
-	if claims.UserID != request.Participants[0] && claims.UserID != request.Participants[1] {
-		http.Error(w, "Unauthorized: You must be one of the participants", http.StatusForbidden)
+	other := request.Participants[1]
+	switch principalID {
+	case request.Participants[0]:
+	case request.Participants[1]:
+		other = request.Participants[0]
+	default:
+		problem.Write(w, r, problem.CodeForbidden)
+		return
+	}
+	if requireChatPeer(w, r, other) == nil {
 		return
 	}
 
 	// Create the chat
-	chatID, err := models.CreateChat(config.DB, request.Participants[0], request.Participants[1])
+	chatID, err := Store.Chats.Create(request.Participants[0], request.Participants[1])
 	if err != nil {
-		// log.Printf("Error creating chat: %v", err)
-		http.Error(w, "Failed to create chat", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error creating chat", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -285,17 +558,17 @@ func CreateChat(w http.ResponseWriter, r *http.Request) {
 
 // GetAllChats retrieves all chats for a user (doctor or patient)
 func GetAllChats(w http.ResponseWriter, r *http.Request) {
-	// Get the user ID from the JWT token
-	claims, ok := r.Context().Value(utils.UserClaimsKey).(*utils.Claims)
+	// Get the principal of the user from the JWT token
+	_, principalID, ok := chatPrincipal(w, r)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	// Call the stored procedure to get all chats for the user
-	chats, err := models.GetAllChats(config.DB, claims.UserID)
+	chats, err := Store.Chats.List(principalID)
 	if err != nil {
-		http.Error(w, "Failed to get chats", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "failed to get chats", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -305,17 +578,16 @@ func GetAllChats(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetUnreadChats(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value(utils.UserClaimsKey).(*utils.Claims)
+	_, principalID, ok := chatPrincipal(w, r)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	// Fetch unread chats from the database
-	unreadChats, err := models.GetUnreadChats(config.DB, claims.UserID)
+	unreadChats, err := Store.Chats.Unread(principalID)
 	if err != nil {
-		// log.Printf("Failed to fetch unread chats for user %d: %v", claims.UserID, err)
-		http.Error(w, "Failed to fetch unread chats", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "failed to fetch unread chats", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
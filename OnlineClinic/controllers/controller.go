@@ -1,14 +1,21 @@
 // Placeholder for controllers/controller.go
 package controllers
 
-import "regexp"
+import (
+	"onlineClinic/models"
+	"onlineClinic/throttle"
+	"strings"
+)
 
-func validatePhoneNumber(phone string) bool {
-	matched, _ := regexp.MatchString(`^09[0-9]{9}$`, phone)
-	return matched
-}
+// Store holds the repositories used by the handlers; it is set by main.
+var Store *models.Store
+
+// LoginLimiter throttles failed logins; it is set by main.
+var LoginLimiter *throttle.Limiter
 
-func validateNationalCode(code string) bool {
-	matched, _ := regexp.MatchString(`^[0-9]{10}$`, code)
-	return matched
+// isDuplicateEntry reports whether err is a unique key violation from MySQL
+// or SQLite.
+func isDuplicateEntry(err error) bool {
+	return strings.Contains(err.Error(), "Duplicate entry") ||
+		strings.Contains(err.Error(), "UNIQUE constraint failed")
 }
@@ -4,23 +4,23 @@ package controllers
 import (
 	"encoding/json"
 	"net/http"
-	"onlineClinic/config"
+	"onlineClinic/problem"
 )
 
 // VerifyStoredHash - Development only endpoint to verify stored hashes
 func VerifyStoredHash(w http.ResponseWriter, r *http.Request) {
 	phoneNumber := r.URL.Query().Get("phone")
 	if phoneNumber == "" {
-		http.Error(w, "Phone number required", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodePhoneRequired)
 		return
 	}
 
 	// log.Printf("Debugging hash for phone: %s", phoneNumber)
 
 	// Check patients table
-	var patientHash string
-	err := config.DB.QueryRow("SELECT password FROM patients WHERE phone_number = ?", phoneNumber).Scan(&patientHash)
+	patient, err := Store.Patients.GetByPhone(phoneNumber)
 	if err == nil {
+		patientHash := patient.Password
 		// log.Printf("Found patient hash. Length: %d, Hash: %s", len(patientHash), patientHash)
 		json.NewEncoder(w).Encode(map[string]string{
 			"type": "patient",
@@ -30,9 +30,9 @@ func VerifyStoredHash(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check doctors table
-	var doctorHash string
-	err = config.DB.QueryRow("SELECT password FROM doctors WHERE phone_number = ?", phoneNumber).Scan(&doctorHash)
+	doctor, err := Store.Doctors.GetByPhone(phoneNumber)
 	if err == nil {
+		doctorHash := doctor.Password
 		// log.Printf("Found doctor hash. Length: %d, Hash: %s", len(doctorHash), doctorHash)
 		json.NewEncoder(w).Encode(map[string]string{
 			"type": "doctor",
@@ -42,5 +42,5 @@ func VerifyStoredHash(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// log.Printf("No hash found for phone number: %s", phoneNumber)
-	http.Error(w, "No hash found", http.StatusNotFound)
+	problem.Write(w, r, problem.CodeNotFound)
 }
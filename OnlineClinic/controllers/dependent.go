@@ -0,0 +1,276 @@
+// controllers/dependent.go
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"onlineClinic/models"
+	"onlineClinic/problem"
+	"onlineClinic/utils"
+	"onlineClinic/validation"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// DependentRequest adds a dependent. BirthDate is a Solar date such as
+// 1395-04-12.
+type DependentRequest struct {
+	FirstName    string `json:"firstName"`
+	LastName     string `json:"lastName"`
+	NationalCode string `json:"nationalCode"`
+	Gender       string `json:"gender"`
+	BirthDate    string `json:"birthDate"`
+}
+
+type DependentResponse struct {
+	models.Dependent
+	BirthDate string `json:"birthDate"` // Solar
+	Age       int    `json:"age"`
+	Minor     bool   `json:"minor"` // The guardian acts for the dependent while true
+}
+
+type HandoverRequest struct {
+	PhoneNumber      string `json:"phoneNumber"`
+	VerificationCode string `json:"verificationCode"` // Sent by RequestHandoverCode
+}
+
+func dependentResponse(d *models.Dependent) DependentResponse {
+	now := time.Now()
+	return DependentResponse{
+		Dependent: *d,
+		BirthDate: utils.GregorianToSolar(d.BirthDate),
+		Age:       d.AgeAt(now),
+		Minor:     d.IsMinor(now),
+	}
+}
+
+// ListDependents lists the dependents of the patient in the path.
+func ListDependents(w http.ResponseWriter, r *http.Request) {
+	guardianID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Write(w, r, problem.CodeInvalidPatientID)
+		return
+	}
+
+	dependents, err := Store.Dependents.List(guardianID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing dependents", "guardian_id", guardianID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	resp := make([]DependentResponse, 0, len(dependents))
+	for i := range dependents {
+		resp = append(resp, dependentResponse(&dependents[i]))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// AddDependent adds a minor as a dependent of the patient in the path, who
+// then books, reads prescriptions and chats for them. Adults register
+// their own account.
+func AddDependent(w http.ResponseWriter, r *http.Request) {
+	guardianID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Write(w, r, problem.CodeInvalidPatientID)
+		return
+	}
+
+	var req DependentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.CodeInvalidBody)
+		return
+	}
+	dependent := models.Dependent{
+		GuardianID:   guardianID,
+		FirstName:    strings.TrimSpace(req.FirstName),
+		LastName:     strings.TrimSpace(req.LastName),
+		NationalCode: strings.TrimSpace(req.NationalCode),
+		Gender:       req.Gender,
+	}
+	if invalid(w, r, validateDependent(&dependent, req.BirthDate)) {
+		return
+	}
+
+	// Only patients with an account of their own are guardians
+	if _, err := Store.Dependents.Get(guardianID); err == nil {
+		problem.Write(w, r, problem.CodeDependentNested)
+		return
+	} else if !errors.Is(err, models.ErrDependentNotFound) {
+		slog.ErrorContext(r.Context(), "error loading guardian", "guardian_id", guardianID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	if err := Store.Dependents.Create(&dependent); err != nil {
+		if isDuplicateEntry(err) {
+			problem.Write(w, r, problem.CodeAlreadyRegistered)
+			return
+		}
+		slog.ErrorContext(r.Context(), "error adding dependent", "guardian_id", guardianID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dependentResponse(&dependent))
+}
+
+// RequestHandoverCode sends a code to the phone number of a dependent who
+// has come of age, which HandOverDependent requires.
+func RequestHandoverCode(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Write(w, r, problem.CodeInvalidPatientID)
+		return
+	}
+
+	var req VerificationCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.CodeInvalidBody)
+		return
+	}
+	req.PhoneNumber = strings.TrimSpace(req.PhoneNumber)
+	var v validation.Validator
+	v.Mobile("phoneNumber", req.PhoneNumber)
+	if invalid(w, r, v.Err()) {
+		return
+	}
+
+	dependent, err := Store.Dependents.Get(id)
+	if err != nil {
+		writeError(w, r, err, "error loading dependent")
+		return
+	}
+	if dependent.IsMinor(time.Now()) {
+		problem.Write(w, r, problem.CodeDependentMinor)
+		return
+	}
+
+	sendVerificationCode(w, r, req.PhoneNumber, models.VerifyPhoneChange(string(utils.RolePatient), id))
+}
+
+// HandOverDependent gives a dependent who has come of age their account:
+// it gets their verified phone number and the guardian loses access. They
+// set a password through the password reset and then log in as patients.
+func HandOverDependent(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Write(w, r, problem.CodeInvalidPatientID)
+		return
+	}
+
+	var req HandoverRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.CodeInvalidBody)
+		return
+	}
+	req.PhoneNumber = strings.TrimSpace(req.PhoneNumber)
+	var v validation.Validator
+	v.Mobile("phoneNumber", req.PhoneNumber)
+	if invalid(w, r, v.Err()) {
+		return
+	}
+
+	// The code proves the dependent holds the phone number
+	if !verifyPhone(w, r, req.PhoneNumber, models.VerifyPhoneChange(string(utils.RolePatient), id), req.VerificationCode) {
+		return
+	}
+
+	if err := Store.Dependents.HandOver(id, req.PhoneNumber); err != nil {
+		if isDuplicateEntry(err) {
+			problem.Write(w, r, problem.CodePhoneTaken)
+			return
+		}
+		writeError(w, r, err, "error handing over dependent")
+		return
+	}
+	slog.InfoContext(r.Context(), "dependent handed over", "patient_id", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Account handed over; set a password with /api/password/forgot to log in"})
+}
+
+// minorGuardian returns the guardian of patientID while patientID is a
+// dependent who has not come of age, and nil otherwise.
+func minorGuardian(patientID int) (*utils.Principal, error) {
+	dependent, err := Store.Dependents.Get(patientID)
+	if errors.Is(err, models.ErrDependentNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !dependent.IsMinor(time.Now()) {
+		return nil, nil
+	}
+	return &utils.Principal{Role: utils.RolePatient, ID: dependent.GuardianID}, nil
+}
+
+// minorDependents returns the IDs of the dependents of guardianID who have
+// not come of age, for whom the guardian reads records.
+func minorDependents(guardianID int) ([]int, error) {
+	dependents, err := Store.Dependents.List(guardianID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var ids []int
+	for i := range dependents {
+		if dependents[i].IsMinor(now) {
+			ids = append(ids, dependents[i].ID)
+		}
+	}
+	return ids, nil
+}
+
+// actsFor reports whether claims belong to the guardian of patientID, who
+// books, reads prescriptions and chats for them while they are a minor.
+func actsFor(claims *utils.Claims, patientID int) (bool, error) {
+	if claims.Role != utils.RolePatient || claims.UserID == patientID {
+		return false, nil
+	}
+	guardian, err := minorGuardian(patientID)
+	if err != nil || guardian == nil {
+		return false, err
+	}
+	return claims.Is(*guardian), nil
+}
+
+// recordUserID returns the user ID claims read the records of patientID
+// as: that of the patient for their guardian, and their own otherwise.
+func recordUserID(claims *utils.Claims, patientID int) (int, error) {
+	guardian, err := actsFor(claims, patientID)
+	if err != nil || !guardian {
+		return claims.UserID, err
+	}
+	return patientID, nil
+}
+
+// validateDependent checks d and parses birthDate into it.
+func validateDependent(d *models.Dependent, birthDate string) error {
+	var v validation.Validator
+	v.Name("firstName", d.FirstName)
+	v.Name("lastName", d.LastName)
+	v.NationalCode("nationalCode", d.NationalCode)
+	v.Gender("gender", d.Gender)
+	v.Required("birthDate", birthDate)
+	if !v.Failed("birthDate") {
+		born, err := utils.SolarToGregorian(birthDate)
+		v.Check(err == nil && utils.IsSolarDateValid(birthDate), "birthDate", "must be a Solar date such as 1395-04-12")
+		if !v.Failed("birthDate") {
+			d.BirthDate = time.Date(born.Year(), born.Month(), born.Day(), 0, 0, 0, 0, time.UTC)
+			v.Check(!d.BirthDate.After(time.Now()), "birthDate", "must not be in the future")
+			v.Check(d.IsMinor(time.Now()), "birthDate", "must be of someone under "+strconv.Itoa(models.AgeOfMajority))
+		}
+	}
+	return v.Err()
+}
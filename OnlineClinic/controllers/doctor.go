@@ -4,14 +4,14 @@ package controllers
 import (
 	"database/sql"
 	"encoding/json"
-	"errors"
+	"log/slog"
 	"net/http"
-	"onlineClinic/config"
 	"onlineClinic/models"
+	"onlineClinic/problem"
 	"onlineClinic/utils"
+	"onlineClinic/validation"
 	"sort"
 	"strconv"
-	"strings"
 
 	"github.com/gorilla/mux"
 )
@@ -24,20 +24,20 @@ func SearchDoctors(w http.ResponseWriter, r *http.Request) {
 	var req SearchRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		// log.Printf("Error decoding search request: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidBody)
 		return
 	}
 
 	// Validate search term
 	if req.UserSearch == "" {
-		http.Error(w, "Search term cannot be empty", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeSearchTermRequired)
 		return
 	}
 
-	results, err := models.SearchDoctors(config.DB, req.UserSearch)
+	results, err := Store.Doctors.Search(req.UserSearch)
 	if err != nil {
-		// log.Printf("Error searching doctors: %v", err)
-		http.Error(w, "Error performing search", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error searching doctors", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -50,18 +50,18 @@ func GetDoctorProfile(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid doctor ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidDoctorID)
 		return
 	}
 
-	doctor, err := models.GetDoctorById(config.DB, id)
+	doctor, err := Store.Doctors.GetByID(id)
 	if err != nil {
-		if err.Error() == "doctor not found" {
-			http.Error(w, "Doctor not found", http.StatusNotFound)
+		if err == sql.ErrNoRows {
+			problem.Write(w, r, problem.CodeDoctorNotFound)
 			return
 		}
-		// log.Printf("Error retrieving doctor: %v", err)
-		http.Error(w, "Error retrieving doctor profile", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error retrieving doctor", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -74,73 +74,64 @@ func UpdateDoctorProfile(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid doctor ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidDoctorID)
 		return
 	}
 
-	var doctor models.Doctor
-	if err := json.NewDecoder(r.Body).Decode(&doctor); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	// Validate input
-	if doctor.FirstName == "" || doctor.LastName == "" || doctor.PhoneNumber == "" ||
-		doctor.MedicalCouncilCode == nil || *doctor.MedicalCouncilCode == "" { // Added validation
-		http.Error(w, "Required fields cannot be empty", http.StatusBadRequest)
-		return
+	var req struct {
+		models.Doctor
+		VerificationCode string `json:"verificationCode"` // Needed to change the phone number
 	}
-
-	if !validatePhoneNumber(doctor.PhoneNumber) {
-		http.Error(w, "Invalid phone number format", http.StatusBadRequest)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.CodeInvalidBody)
 		return
 	}
+	doctor := req.Doctor
 
-	if doctor.NationalCode != "" && !validateNationalCode(doctor.NationalCode) {
-		http.Error(w, "Invalid national code format", http.StatusBadRequest)
+	// Validate every field at once
+	if invalid(w, r, validateDoctor(&doctor, false)) {
 		return
 	}
 
 	// Set the ID from the URL
 	doctor.ID = id
 
-	// Start a transaction
-	tx, err := config.DB.Begin()
-	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-	defer tx.Rollback()
-
-	// Fetch the existing profile photo path from the database
-	var existingProfilePhotoPath sql.NullString
-	err = tx.QueryRow("SELECT profile_photo_path FROM doctors WHERE id = ?", id).Scan(&existingProfilePhotoPath)
+	// Keep the existing profile photo; it is changed through the upload endpoint
+	existing, err := Store.Doctors.GetByID(id)
 	if err != nil {
-		http.Error(w, "Error fetching profile photo path", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error fetching profile photo path", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
+	doctor.ProfilePhotoPath = existing.ProfilePhotoPath
 
-	// Set the profile photo path to the existing value if valid, else nil
-	if existingProfilePhotoPath.Valid {
-		doctor.ProfilePhotoPath = &existingProfilePhotoPath.String
-	} else {
-		doctor.ProfilePhotoPath = nil
+	// Doctors prove they own a new phone number with a code sent to it by
+	// RequestPhoneChangeCode; staff may correct the number directly.
+	if doctor.PhoneNumber != existing.PhoneNumber {
+		claims, _ := utils.GetUserClaims(r.Context())
+		self := utils.Principal{Role: utils.RoleDoctor, ID: id}
+		if claims.Is(self) && !verifyPhone(w, r, doctor.PhoneNumber, models.VerifyPhoneChange(string(self.Role), id), req.VerificationCode) {
+			return
+		}
 	}
 
 	// Update the main profile information
-	if err := models.UpdateDoctor(tx, &doctor); err != nil {
-		if strings.Contains(err.Error(), "Duplicate entry") {
-			http.Error(w, "Phone number already exists", http.StatusConflict)
+	if err := Store.Doctors.Update(&doctor); err != nil {
+		if isDuplicateEntry(err) {
+			problem.Write(w, r, problem.CodePhoneTaken)
 			return
 		}
-		http.Error(w, "Error updating doctor profile", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error updating doctor profile", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		http.Error(w, "Error updating profile", http.StatusInternalServerError)
-		return
+	// A new medical council code has to be verified again before the
+	// doctor can be booked.
+	if claims, _ := utils.GetUserClaims(r.Context()); claims.Is(utils.Principal{Role: utils.RoleDoctor, ID: id}) &&
+		existing.VerificationStatus == models.DoctorApproved &&
+		(existing.MedicalCouncilCode == nil || *existing.MedicalCouncilCode != *doctor.MedicalCouncilCode) {
+		reverifyDoctor(r, id)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -151,9 +142,10 @@ func UpdateDoctorProfile(w http.ResponseWriter, r *http.Request) {
 // GetAllDoctors handles GET requests to list all doctors
 func GetAllDoctors(w http.ResponseWriter, r *http.Request) {
 	// Call the GetAllDoctors function from the models package
-	doctors, err := models.GetAllDoctors(config.DB)
+	doctors, err := Store.Doctors.GetAll()
 	if err != nil {
-		http.Error(w, "Error retrieving doctors list", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error retrieving doctors list", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -170,9 +162,13 @@ func GetAllDoctors(w http.ResponseWriter, r *http.Request) {
 		MedicalCouncilCode *string `json:"medicalCouncilCode,omitempty"` // Added field
 	}
 
-	// Convert the list of doctors to the response struct
+	// Convert the list of doctors to the response struct. Only approved
+	// doctors are listed; the others are in the verification queue.
 	var docs []doctorWithoutPass
 	for _, d := range doctors {
+		if d.VerificationStatus != models.DoctorApproved {
+			continue
+		}
 		doc := doctorWithoutPass{
 			ID:                 d.ID,
 			FirstName:          d.FirstName,
@@ -208,7 +204,7 @@ func UpdateDoctorPassword(w http.ResponseWriter, r *http.Request) {
 	doctorID, ok := utils.GetUserID(r.Context())
 	if !ok {
 		// log.Printf("Failed to get doctorID from context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Write(w, r, problem.CodeUnauthenticated)
 		return
 	}
 	// log.Printf("Processing password update for doctor ID: %d", doctorID)
@@ -220,45 +216,27 @@ func UpdateDoctorPassword(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(&passwordUpdate); err != nil {
 		// log.Printf("Error decoding request body: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidBody)
 		return
 	}
 	// log.Println("Request body decoded successfully")
 
 	// Validate new password
-	if passwordUpdate.UserNewPassword == "" {
-		// log.Println("Empty password received")
-		http.Error(w, "New password cannot be empty", http.StatusBadRequest)
+	var v validation.Validator
+	validatePassword(&v, "userNewPassword", passwordUpdate.UserNewPassword)
+	if invalid(w, r, v.Err()) {
 		return
 	}
 	// log.Println("Password validation passed")
 
-	// Start a transaction
-	tx, err := config.DB.Begin()
-	if err != nil {
-		// log.Printf("Error starting transaction: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-	defer tx.Rollback()
-	// log.Println("Transaction started")
-
 	// Update the password
-	if err := models.UpdateDoctorPassword(tx, doctorID, passwordUpdate.UserNewPassword); err != nil {
-		// log.Printf("Error updating password in database: %v", err)
-		http.Error(w, "Error updating password", http.StatusInternalServerError)
+	if err := Store.Doctors.UpdatePassword(doctorID, passwordUpdate.UserNewPassword); err != nil {
+		slog.ErrorContext(r.Context(), "error updating password in database", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 	// log.Println("Password updated in database")
 
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		// log.Printf("Error committing transaction: %v", err)
-		http.Error(w, "Error updating password", http.StatusInternalServerError)
-		return
-	}
-	// log.Println("Transaction committed successfully")
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Password updated successfully"})
@@ -270,13 +248,13 @@ func DeleteDoctorProfile(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid doctor ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidDoctorID)
 		return
 	}
 
-	if err := models.DeleteDoctor(config.DB, id); err != nil {
-		// log.Printf("Error deleting doctor: %v", err)
-		http.Error(w, "Error deleting doctor profile", http.StatusInternalServerError)
+	if err := Store.Doctors.Delete(id); err != nil {
+		slog.ErrorContext(r.Context(), "error deleting doctor", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -290,14 +268,14 @@ func GetDoctorPrescriptions(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid doctor ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidDoctorID)
 		return
 	}
 
-	prescriptions, err := models.GetDoctorPrescriptions(config.DB, id)
+	prescriptions, err := Store.Doctors.Prescriptions(id)
 	if err != nil {
-		// log.Printf("Error retrieving prescriptions: %v", err)
-		http.Error(w, "Error retrieving prescriptions", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error retrieving prescriptions", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -310,25 +288,25 @@ func GetDoctorAvailability(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	doctorID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid doctor ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidDoctorID)
 		return
 	}
 
 	visitType := r.URL.Query().Get("visitType")
 	if visitType == "" {
-		http.Error(w, "Visit type is required", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidVisitType)
 		return
 	}
 
 	if visitType != "online" && visitType != "in-person" {
-		http.Error(w, "Invalid visit type. Must be 'online' or 'in-person'", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidVisitType)
 		return
 	}
 
-	slots, err := models.GetDoctorAvailability(config.DB, doctorID, visitType)
+	slots, err := Store.Availability.List(doctorID, visitType)
 	if err != nil {
-		// log.Printf("Error retrieving availability slots: %v", err)
-		http.Error(w, "Error retrieving availability slots", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error retrieving availability slots", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -390,13 +368,16 @@ func SetDoctorAvailability(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid doctor ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidDoctorID)
+		return
+	}
+	if !requireApprovedDoctor(w, r, id) {
 		return
 	}
 
 	var availabilityReq models.AvailabilityRequest
 	if err := json.NewDecoder(r.Body).Decode(&availabilityReq); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidBody)
 		return
 	}
 
@@ -410,14 +391,14 @@ func SetDoctorAvailability(w http.ResponseWriter, r *http.Request) {
 
 	for _, dateRange := range availabilityReq.DatesRange {
 		if dateRange.Start.After(dateRange.End) {
-			http.Error(w, "Invalid date range: end date must be after start date", http.StatusBadRequest)
+			problem.Write(w, r, problem.CodeInvalidTimeRange)
 			return
 		}
 	}
 
-	if err := models.SetDoctorAvailability(config.DB, id, &availabilityReq); err != nil {
-		// log.Printf("Error setting availability: %v", err)
-		http.Error(w, "Error setting availability slots", http.StatusInternalServerError)
+	if err := Store.Availability.Set(id, &availabilityReq); err != nil {
+		slog.ErrorContext(r.Context(), "error setting availability", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -431,31 +412,19 @@ func DeleteDoctorAvailability(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	doctorID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid doctor ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidDoctorID)
 		return
 	}
 
 	slotID, err := strconv.Atoi(vars["slotId"])
 	if err != nil {
-		http.Error(w, "Invalid slot ID", http.StatusBadRequest)
-		return
-	}
-
-	// Get authenticated user claims
-	claims, ok := r.Context().Value(utils.UserClaimsKey).(*utils.Claims)
-	if !ok || !claims.IsDoctor || claims.UserID != doctorID {
-		http.Error(w, "Unauthorized: You can only delete your own availability slots", http.StatusForbidden)
+		problem.Write(w, r, problem.CodeInvalidSlotID)
 		return
 	}
 
 	// Delete the availability slot
-	if err := models.DeleteDoctorAvailability(config.DB, slotID, doctorID); err != nil {
-		if errors.Is(err, models.ErrAvailabilityNotFound) {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		// log.Printf("Error deleting availability slot: %v", err)
-		http.Error(w, "Error deleting availability slot", http.StatusInternalServerError)
+	if err := Store.Availability.Delete(slotID, doctorID); err != nil {
+		writeError(w, r, err, "error deleting availability slot")
 		return
 	}
 
@@ -468,13 +437,13 @@ func DeleteDoctorProfilePhoto(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidPatientID)
 		return
 	}
 
-	if err := models.DeleteDoctorPhoto(config.DB, id); err != nil {
-		// log.Printf("Error deleting patient profile photo: %v", err)
-		http.Error(w, "Error deleting patient profile photo", http.StatusInternalServerError)
+	if err := Store.Doctors.DeletePhoto(id); err != nil {
+		slog.ErrorContext(r.Context(), "error deleting patient profile photo", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -0,0 +1,418 @@
+// controllers/doctor_verification.go
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"onlineClinic/models"
+	"onlineClinic/problem"
+	"onlineClinic/utils"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type DoctorVerificationResponse struct {
+	Status    string                  `json:"status"`
+	Reviews   []models.DoctorReview   `json:"reviews"`
+	Documents []models.DoctorDocument `json:"documents"`
+}
+
+type ReviewDoctorRequest struct {
+	Status string `json:"status"`
+	Notes  string `json:"notes"`
+}
+
+// requireApprovedDoctor reports whether doctorID is an approved doctor. If
+// not, it writes the error response itself.
+func requireApprovedDoctor(w http.ResponseWriter, r *http.Request, doctorID int) bool {
+	status, err := Store.DoctorVerification.Status(doctorID)
+	if err == sql.ErrNoRows {
+		problem.Write(w, r, problem.CodeDoctorNotFound)
+		return false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error checking doctor verification status", "doctor_id", doctorID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return false
+	}
+	if status != models.DoctorApproved {
+		problem.Write(w, r, problem.CodeDoctorNotApproved)
+		return false
+	}
+	return true
+}
+
+// UploadDoctorDocument stores a license document (multipart field "file")
+// of the kind given in the form field "kind" for review.
+func UploadDoctorDocument(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.PathID(r, "id")
+	if err != nil {
+		problem.Write(w, r, problem.CodeInvalidDoctorID)
+		return
+	}
+
+	if err := r.ParseMultipartForm(MaxUploadSize); err != nil {
+		problem.Write(w, r, problem.CodeInvalidForm)
+		return
+	}
+	kind := r.FormValue("kind")
+	if kind == "" {
+		kind = models.DocumentLicense
+	}
+	if !models.IsDocumentKind(kind) {
+		problem.Write(w, r, problem.CodeInvalidDocumentKind)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		problem.Write(w, r, problem.CodeFileMissing)
+		return
+	}
+	defer file.Close()
+
+	path, contentType, err := utils.SaveDoctorDocument(file, header, id)
+	if err != nil {
+		writeError(w, r, err, "error saving doctor document")
+		return
+	}
+
+	name := filepath.Base(header.Filename)
+	if len(name) > 255 {
+		name = name[len(name)-255:]
+	}
+	doc := &models.DoctorDocument{
+		DoctorID:    id,
+		Kind:        kind,
+		FileName:    name,
+		ContentType: contentType,
+		Size:        header.Size,
+		Path:        path,
+	}
+	if err := Store.DoctorVerification.AddDocument(doc); err != nil {
+		slog.ErrorContext(r.Context(), "error recording doctor document", "doctor_id", id, "err", err)
+		os.Remove(utils.DocumentPath(path))
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(doc)
+}
+
+// DownloadDoctorDocument serves one license document. It is never served
+// inline, so a crafted file cannot run as a page of the site.
+func DownloadDoctorDocument(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.PathID(r, "id")
+	if err != nil {
+		problem.Write(w, r, problem.CodeInvalidDoctorID)
+		return
+	}
+	documentID, err := strconv.ParseInt(mux.Vars(r)["documentId"], 10, 64)
+	if err != nil || documentID <= 0 {
+		problem.Write(w, r, problem.CodeInvalidDocumentID)
+		return
+	}
+
+	doc, err := Store.DoctorVerification.Document(id, documentID)
+	if err == sql.ErrNoRows {
+		problem.Write(w, r, problem.CodeDocumentNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error loading doctor document", "doctor_id", id, "document_id", documentID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	f, err := os.Open(utils.DocumentPath(doc.Path))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error opening doctor document", "doctor_id", id, "document_id", documentID, "err", err)
+		problem.Write(w, r, problem.CodeDocumentNotFound)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", doc.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, r, "", doc.UploadedAt, f)
+}
+
+// GetDoctorVerification returns the verification status of a doctor with
+// the reviews and documents behind it.
+func GetDoctorVerification(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.PathID(r, "id")
+	if err != nil {
+		problem.Write(w, r, problem.CodeInvalidDoctorID)
+		return
+	}
+
+	var resp DoctorVerificationResponse
+	resp.Status, err = Store.DoctorVerification.Status(id)
+	if err == nil {
+		resp.Reviews, err = Store.DoctorVerification.Reviews(id)
+	}
+	if err == nil {
+		resp.Documents, err = Store.DoctorVerification.Documents(id)
+	}
+	if err == sql.ErrNoRows {
+		problem.Write(w, r, problem.CodeDoctorNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error loading doctor verification", "doctor_id", id, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+	if resp.Reviews == nil {
+		resp.Reviews = []models.DoctorReview{}
+	}
+	if resp.Documents == nil {
+		resp.Documents = []models.DoctorDocument{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// SubmitDoctorVerification puts a rejected doctor back in the review queue
+// once they have uploaded a document since the rejection.
+func SubmitDoctorVerification(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r.Context())
+	if !ok {
+		problem.Write(w, r, problem.CodeUnauthenticated)
+		return
+	}
+	id, err := utils.PathID(r, "id")
+	if err != nil {
+		problem.Write(w, r, problem.CodeInvalidDoctorID)
+		return
+	}
+
+	var req ReviewDoctorRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			problem.Write(w, r, problem.CodeInvalidBody)
+			return
+		}
+	}
+
+	status, err := Store.DoctorVerification.Status(id)
+	var reviews []models.DoctorReview
+	var docs []models.DoctorDocument
+	if err == nil {
+		reviews, err = Store.DoctorVerification.Reviews(id)
+	}
+	if err == nil {
+		docs, err = Store.DoctorVerification.Documents(id)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error loading doctor verification", "doctor_id", id, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+	if status != models.DoctorRejected {
+		problem.Write(w, r, problem.CodeDoctorNotRejected)
+		return
+	}
+	if len(reviews) > 0 && (len(docs) == 0 || docs[len(docs)-1].UploadedAt.Before(reviews[len(reviews)-1].CreatedAt)) {
+		problem.Write(w, r, problem.CodeDoctorNoNewDocument)
+		return
+	}
+
+	review := &models.DoctorReview{
+		DoctorID:     id,
+		FromStatus:   status,
+		ToStatus:     models.DoctorPending,
+		ReviewerRole: string(claims.Role),
+		ReviewerID:   &claims.UserID,
+		Notes:        strings.TrimSpace(req.Notes),
+	}
+	transitionDoctor(w, r, review)
+}
+
+// ReviewDoctor lets an admin approve, reject, suspend or reinstate a
+// doctor. Rejections and suspensions need notes, which the doctor sees.
+func ReviewDoctor(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r.Context())
+	if !ok {
+		problem.Write(w, r, problem.CodeUnauthenticated)
+		return
+	}
+	id, err := utils.PathID(r, "id")
+	if err != nil {
+		problem.Write(w, r, problem.CodeInvalidDoctorID)
+		return
+	}
+
+	var req ReviewDoctorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.CodeInvalidBody)
+		return
+	}
+	req.Notes = strings.TrimSpace(req.Notes)
+	switch req.Status {
+	case models.DoctorApproved:
+	case models.DoctorRejected, models.DoctorSuspended:
+		if req.Notes == "" {
+			problem.Write(w, r, problem.CodeDoctorReviewNotes)
+			return
+		}
+	default:
+		problem.Write(w, r, problem.CodeDoctorInvalidStatus)
+		return
+	}
+
+	status, err := Store.DoctorVerification.Status(id)
+	var docs []models.DoctorDocument
+	if err == nil {
+		docs, err = Store.DoctorVerification.Documents(id)
+	}
+	if err == sql.ErrNoRows {
+		problem.Write(w, r, problem.CodeDoctorNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error loading doctor verification", "doctor_id", id, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+	if req.Status == models.DoctorApproved && len(docs) == 0 {
+		problem.Write(w, r, problem.CodeDoctorNoDocuments)
+		return
+	}
+
+	review := &models.DoctorReview{
+		DoctorID:     id,
+		FromStatus:   status,
+		ToStatus:     req.Status,
+		ReviewerRole: string(claims.Role),
+		ReviewerID:   &claims.UserID,
+		Notes:        req.Notes,
+	}
+	if !transitionDoctor(w, r, review) {
+		return
+	}
+	notifyDoctorReview(r, review)
+}
+
+// ListDoctorVerifications returns the doctors with the status in ?status,
+// pending by default: the review queue.
+func ListDoctorVerifications(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.DoctorPending
+	}
+	if _, ok := doctorStatusNames[status]; !ok {
+		problem.Write(w, r, problem.CodeDoctorInvalidStatus)
+		return
+	}
+
+	doctors, err := Store.DoctorVerification.ListByStatus(status)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing doctors by verification status", "status", status, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+	if doctors == nil {
+		doctors = []models.DoctorVerificationSummary{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(doctors)
+}
+
+// doctorStatusNames describe the statuses in messages to doctors.
+var doctorStatusNames = map[string]string{
+	models.DoctorPending:   "is waiting for review",
+	models.DoctorApproved:  "has been approved",
+	models.DoctorRejected:  "has been rejected",
+	models.DoctorSuspended: "has been suspended",
+}
+
+// transitionDoctor applies review, records it in the audit trail and
+// writes it as the response. It writes the error response itself and
+// returns false on failure.
+func transitionDoctor(w http.ResponseWriter, r *http.Request, review *models.DoctorReview) bool {
+	err := Store.DoctorVerification.Transition(review)
+	if errors.Is(err, models.ErrInvalidTransition) {
+		problem.WriteDetail(w, r, problem.CodeDoctorStatusChange,
+			fmt.Sprintf("Cannot change verification status from %s to %s", review.FromStatus, review.ToStatus))
+		return false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error changing doctor verification status", "doctor_id", review.DoctorID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return false
+	}
+
+	recordDoctorReview(r, review)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(review)
+	return true
+}
+
+// reverifyDoctor sends an approved doctor back to review after they
+// changed their medical council code. Failures are only logged, since the
+// profile update has already succeeded.
+func reverifyDoctor(r *http.Request, doctorID int) {
+	review := &models.DoctorReview{
+		DoctorID:     doctorID,
+		FromStatus:   models.DoctorApproved,
+		ToStatus:     models.DoctorPending,
+		ReviewerRole: string(utils.RoleDoctor),
+		ReviewerID:   &doctorID,
+		Notes:        "Medical council code changed",
+	}
+	if err := Store.DoctorVerification.Transition(review); err != nil {
+		slog.ErrorContext(r.Context(), "error sending doctor back to review", "doctor_id", doctorID, "err", err)
+		return
+	}
+	recordDoctorReview(r, review)
+}
+
+func recordDoctorReview(r *http.Request, review *models.DoctorReview) {
+	event := &models.AuditEvent{
+		Action:    models.AuditDoctorVerification,
+		ActorRole: review.ReviewerRole,
+		ActorID:   review.ReviewerID,
+		Subject:   "doctor:" + strconv.Itoa(review.DoctorID),
+		IPAddress: utils.ClientIP(r),
+		Detail:    review.FromStatus + " -> " + review.ToStatus,
+	}
+	if review.Notes != "" {
+		event.Detail += ": " + review.Notes
+	}
+	if err := Store.Audit.Record(event); err != nil {
+		slog.ErrorContext(r.Context(), "error recording doctor review in audit trail", "doctor_id", review.DoctorID, "err", err)
+	}
+}
+
+// notifyDoctorReview tells the doctor about an admin's decision by SMS.
+func notifyDoctorReview(r *http.Request, review *models.DoctorReview) {
+	doctor, err := Store.Doctors.GetByID(review.DoctorID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error loading doctor to notify", "doctor_id", review.DoctorID, "err", err)
+		return
+	}
+	text := "Your OnlineClinic doctor account " + doctorStatusNames[review.ToStatus] + "."
+	if review.Notes != "" {
+		text += " " + review.Notes
+	}
+	if err := SMS.SendSMS(r.Context(), doctor.PhoneNumber, text); err != nil {
+		slog.ErrorContext(r.Context(), "error notifying doctor of review", "doctor_id", review.DoctorID, "err", err)
+	}
+}
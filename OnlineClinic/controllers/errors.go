@@ -0,0 +1,58 @@
+// controllers/errors.go
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"onlineClinic/models"
+	"onlineClinic/problem"
+	"onlineClinic/utils"
+)
+
+// errorCodes maps the errors of the models and utils packages to problems.
+// It is the one place that decides how they surface in the API.
+var errorCodes = []struct {
+	err  error
+	code problem.Code
+}{
+	{models.ErrTimeNotAvailable, problem.CodeSlotUnavailable},
+	{models.ErrTimeInPast, problem.CodeAppointmentInPast},
+	{models.ErrInvalidTimeSlot, problem.CodeInvalidSlot},
+	{models.ErrAppointmentNotFound, problem.CodeNotFound},
+	{models.ErrAvailabilityNotFound, problem.CodeSlotNotFound},
+	{models.ErrUnauthorizedAccess, problem.CodeForbidden},
+	{models.ErrInvalidTransition, problem.CodeDoctorStatusChange},
+	{models.ErrTOTPAlreadyEnabled, problem.CodeTwoFactorAlreadyEnabled},
+	{models.ErrVerificationCodeInvalid, problem.CodeVerificationInvalid},
+	{models.ErrVerificationAttempts, problem.CodeVerificationAttempts},
+	{models.ErrVerificationCooldown, problem.CodeVerificationCooldown},
+	{models.ErrSessionInvalid, problem.CodeInvalidRefreshToken},
+	{models.ErrRefreshTokenReused, problem.CodeInvalidRefreshToken},
+	{models.ErrWSTicketInvalid, problem.CodeInvalidWSTicket},
+	{models.ErrDependentNotFound, problem.CodeDependentNotFound},
+	{models.ErrDependentMinor, problem.CodeDependentMinor},
+	{models.ErrGuardianDependents, problem.CodeGuardianDependents},
+	{utils.ErrFileTooLarge, problem.CodeFileTooLarge},
+	{utils.ErrUnsupportedFileType, problem.CodeUnsupportedFileType},
+}
+
+// writeError writes err as a problem. An error errorCodes does not know is
+// logged with msg and reported as an internal error, so that no internal
+// detail reaches the client.
+func writeError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			// Mapped errors are only wrapped with messages of our own,
+			// which explain the problem better than its title.
+			detail := ""
+			if err != e.err {
+				detail = err.Error()
+			}
+			problem.WriteDetail(w, r, e.code, detail)
+			return
+		}
+	}
+	slog.ErrorContext(r.Context(), msg, "err", err)
+	problem.Write(w, r, problem.CodeInternal)
+}
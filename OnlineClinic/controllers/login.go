@@ -4,11 +4,16 @@ package controllers
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
-	"onlineClinic/config"
+	"onlineClinic/models"
+	"onlineClinic/problem"
 	"onlineClinic/utils"
 	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -26,12 +31,18 @@ type LoginResponse struct {
 	Gender       string `json:"gender"`
 	PhoneNumber  string `json:"phoneNumber"`
 	IsDoctor     bool   `json:"isDoctor"`
+	Role         string `json:"role"`
 	Age          int    `json:"age"`
 	Job          string `json:"job,omitempty"` // omitempty for doctors
 	Education    string `json:"education"`
 	Address      string `json:"address"`
 	Image        string `json:"image"`
 	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"` // Seconds until the access token expires
+	// RecoveryCodes are only set by the doctor login that completes a TOTP
+	// enrollment. They are shown once.
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
 }
 
 // Add this type to store temporary password during login
@@ -45,7 +56,7 @@ func LoginPatient(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		// log.Printf("Error decoding patient login request: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidBody)
 		return
 	}
 
@@ -53,62 +64,54 @@ func LoginPatient(w http.ResponseWriter, r *http.Request) {
 	req.PhoneNumber = strings.TrimSpace(req.PhoneNumber)
 	// log.Printf("Attempting patient login for phone number: '%s'", req.PhoneNumber)
 
-	var patientResponse LoginResponse
-	var patientCreds userCredentials
-	var patientID int
-	var nullAge sql.NullInt64
-	var nullJob, nullEducation, nullAddress, nullProfilePhotoPath sql.NullString
+	account := loginAccount(accountPatient, req.PhoneNumber)
+	if !loginAllowed(w, r, account) {
+		return
+	}
 
 	// Query for patient
 	// queryStart := time.Now()
-	err := config.DB.QueryRow(`
-        SELECT id, first_name, last_name, national_code, gender,
-               phone_number, password, age, job, education, address, 
-               profile_photo_path
-        FROM patients 
-        WHERE phone_number = ?`, req.PhoneNumber).Scan(
-		&patientID,
-		&patientResponse.FirstName,
-		&patientResponse.LastName,
-		&patientResponse.NationalCode,
-		&patientResponse.Gender,
-		&patientResponse.PhoneNumber,
-		&patientCreds.hashedPassword,
-		&nullAge,
-		&nullJob,
-		&nullEducation,
-		&nullAddress,
-		&nullProfilePhotoPath,
-	)
+	patient, err := Store.Patients.GetByPhone(req.PhoneNumber)
 
 	// log.Printf("Patient DB query took %v", time.Since(queryStart))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// log.Printf("No patient found with phone number: '%s'", req.PhoneNumber)
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			loginFailed(r, account)
+			problem.Write(w, r, problem.CodeInvalidCredentials)
 			return
 		}
-		// log.Printf("Database error during patient login: %v", err)
-		http.Error(w, "Server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "database error during patient login", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
+	patientID := patient.ID
+	patientCreds := userCredentials{hashedPassword: patient.Password}
+	patientResponse := LoginResponse{
+		FirstName:    patient.FirstName,
+		LastName:     patient.LastName,
+		NationalCode: patient.NationalCode,
+		Gender:       patient.Gender,
+		PhoneNumber:  patient.PhoneNumber,
+	}
+
 	// Convert nullable fields
-	if nullAge.Valid {
-		patientResponse.Age = int(nullAge.Int64)
+	if patient.Age != nil {
+		patientResponse.Age = *patient.Age
 	}
-	if nullJob.Valid {
-		patientResponse.Job = nullJob.String
+	if patient.Job != nil {
+		patientResponse.Job = *patient.Job
 	}
-	if nullEducation.Valid {
-		patientResponse.Education = nullEducation.String
+	if patient.Education != nil {
+		patientResponse.Education = *patient.Education
 	}
-	if nullAddress.Valid {
-		patientResponse.Address = nullAddress.String
+	if patient.Address != nil {
+		patientResponse.Address = *patient.Address
 	}
-	if nullProfilePhotoPath.Valid {
-		patientResponse.Image = nullProfilePhotoPath.String
+	if patient.ProfilePhotoPath != nil {
+		patientResponse.Image = *patient.ProfilePhotoPath
 	}
 
 	// Verify password
@@ -117,17 +120,28 @@ func LoginPatient(w http.ResponseWriter, r *http.Request) {
 
 	if err := bcrypt.CompareHashAndPassword([]byte(patientCreds.hashedPassword), []byte(req.Password)); err != nil {
 		// log.Printf("Password verification failed for patient ID %d: %v", patientID, err)
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		loginFailed(r, account)
+		problem.Write(w, r, problem.CodeInvalidCredentials)
 		return
 	}
+	loginSucceeded(r, account)
 
 	// log.Printf("Password verified successfully for patient ID: %d", patientID)
 
-	// Generate token
-	token, err := utils.GenerateToken(patientID, patientResponse.PhoneNumber, false, true)
+	// Deactivated accounts keep their data but may not log in
+	deactivatedAt, err := Store.Patients.DeactivatedAt(patientID)
 	if err != nil {
-		// log.Printf("Error generating token for patient ID %d: %v", patientID, err)
-		http.Error(w, "Error generating token", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error checking patient account status", "patient_id", patientID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+	if deactivatedAt != nil {
+		problem.Write(w, r, problem.CodeAccountDeactivated)
+		return
+	}
+
+	// Start a session and issue its access and refresh tokens
+	if !startSession(w, r, &patientResponse, patientID, utils.RolePatient) {
 		return
 	}
 
@@ -135,11 +149,11 @@ func LoginPatient(w http.ResponseWriter, r *http.Request) {
 
 	patientResponse.ID = strconv.Itoa(patientID)
 	patientResponse.IsDoctor = false
-	patientResponse.Token = token
 
 	// log.Printf("Login successful for patient ID: %d", patientID)
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
 	json.NewEncoder(w).Encode(patientResponse)
 }
 
@@ -149,7 +163,7 @@ func LoginDoctor(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		// log.Printf("Error decoding doctor login request: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidBody)
 		return
 	}
 
@@ -161,103 +175,215 @@ func LoginDoctor(w http.ResponseWriter, r *http.Request) {
 	// Validate phone number format
 	if len(req.PhoneNumber) != 11 || !strings.HasPrefix(req.PhoneNumber, "09") {
 		// log.Printf("Invalid phone number format: '%s'", req.PhoneNumber)
-		http.Error(w, "Invalid phone number format", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidPhone)
 		return
 	}
 
-	var doctorResponse LoginResponse
-	var doctorCreds userCredentials
-	var doctorID int
-	var nullAge sql.NullInt64
-	var nullEducation, nullAddress, nullProfilePhotoPath sql.NullString
+	account := loginAccount(accountDoctor, req.PhoneNumber)
+	if !loginAllowed(w, r, account) {
+		return
+	}
 
 	// Query for doctor
 	// queryStart := time.Now()
-	err := config.DB.QueryRow(`
-        SELECT 
-            id, 
-            first_name, 
-            last_name, 
-            national_code, 
-            gender,
-            phone_number,  
-            password, 
-            age,
-            education, 
-            address, 
-            profile_photo_path
-        FROM doctors 
-        WHERE phone_number = ?`, req.PhoneNumber).Scan(
-		&doctorID,
-		&doctorResponse.FirstName,
-		&doctorResponse.LastName,
-		&doctorResponse.NationalCode,
-		&doctorResponse.Gender,
-		&doctorResponse.PhoneNumber,
-		&doctorCreds.hashedPassword,
-		&nullAge,
-		&nullEducation,
-		&nullAddress,
-		&nullProfilePhotoPath,
-	)
+	doctor, err := Store.Doctors.GetByPhone(req.PhoneNumber)
 
 	// log.Printf("Doctor DB query took %v", time.Since(queryStart))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// log.Printf("No doctor found with phone number: '%s'", req.PhoneNumber)
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			loginFailed(r, account)
+			problem.Write(w, r, problem.CodeInvalidCredentials)
 			return
 		}
-		// log.Printf("Database error during doctor login: %v", err)
-		http.Error(w, "Server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "database error during doctor login", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(doctor.Password), []byte(req.Password)); err != nil {
+		// log.Printf("Password verification failed for doctor ID %d: %v", doctor.ID, err)
+		loginFailed(r, account)
+		problem.Write(w, r, problem.CodeInvalidCredentials)
+		return
+	}
+
+	// log.Printf("Password verified successfully for doctor ID: %d", doctor.ID)
+
+	// Deactivated accounts keep their data but may not log in
+	deactivatedAt, err := Store.Doctors.DeactivatedAt(doctor.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error checking doctor account status", "doctor_id", doctor.ID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
+	if deactivatedAt != nil {
+		problem.Write(w, r, problem.CodeAccountDeactivated)
+		return
+	}
+
+	// With two-factor authentication the failures keep counting until the
+	// code has been entered too, so that codes cannot be guessed for free.
+	if requireSecondFactor(w, r, doctor) {
+		return
+	}
+	loginSucceeded(r, account)
+
+	writeDoctorLogin(w, r, doctor, nil)
+}
+
+// writeDoctorLogin starts a session for doctor and writes its profile and
+// tokens, along with recoveryCodes if the login has just enabled TOTP.
+func writeDoctorLogin(w http.ResponseWriter, r *http.Request, doctor *models.Doctor, recoveryCodes []string) {
+	doctorResponse := LoginResponse{
+		FirstName:     doctor.FirstName,
+		LastName:      doctor.LastName,
+		NationalCode:  doctor.NationalCode,
+		Gender:        doctor.Gender,
+		PhoneNumber:   doctor.PhoneNumber,
+		RecoveryCodes: recoveryCodes,
+	}
 
 	// Convert nullable fields
-	if nullAge.Valid {
-		doctorResponse.Age = int(nullAge.Int64)
+	if doctor.Age != nil {
+		doctorResponse.Age = *doctor.Age
 	}
-	if nullEducation.Valid {
-		doctorResponse.Education = nullEducation.String
+	if doctor.Education != nil {
+		doctorResponse.Education = *doctor.Education
 	}
-	if nullAddress.Valid {
-		doctorResponse.Address = nullAddress.String
+	if doctor.Address != nil {
+		doctorResponse.Address = *doctor.Address
 	}
-	if nullProfilePhotoPath.Valid {
-		doctorResponse.Image = nullProfilePhotoPath.String
+	if doctor.ProfilePhotoPath != nil {
+		doctorResponse.Image = *doctor.ProfilePhotoPath
 	}
 
-	// log.Printf("Doctor found with ID: %d, verifying password", doctorID)
+	// Start a session and issue its access and refresh tokens
+	if !startSession(w, r, &doctorResponse, doctor.ID, utils.RoleDoctor) {
+		return
+	}
 
-	// Verify password
-	// log.Printf("Hashed password from DB: %s", doctorCreds.hashedPassword)
-	// log.Printf("Input password: %s", req.Password)
+	doctorResponse.ID = strconv.Itoa(doctor.ID)
+	doctorResponse.IsDoctor = true
+
+	// log.Printf("Login successful for doctor ID: %d", doctor.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(doctorResponse)
+}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(doctorCreds.hashedPassword), []byte(req.Password)); err != nil {
-		// log.Printf("Password verification failed for doctor ID %d: %v", doctorID, err)
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+// LoginStaff logs in a receptionist or clinic admin.
+func LoginStaff(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.CodeInvalidBody)
 		return
 	}
+	req.PhoneNumber = strings.TrimSpace(req.PhoneNumber)
 
-	// log.Printf("Password verified successfully for doctor ID: %d", doctorID)
+	account := loginAccount(accountStaff, req.PhoneNumber)
+	if !loginAllowed(w, r, account) {
+		return
+	}
 
-	// Generate token
-	token, err := utils.GenerateToken(doctorID, doctorResponse.PhoneNumber, true, false)
+	staff, err := Store.Staff.GetByPhone(req.PhoneNumber)
 	if err != nil {
-		// log.Printf("Error generating token for doctor ID %d: %v", doctorID, err)
-		http.Error(w, "Error generating token", http.StatusInternalServerError)
+		if err == sql.ErrNoRows {
+			loginFailed(r, account)
+			problem.Write(w, r, problem.CodeInvalidCredentials)
+			return
+		}
+		slog.ErrorContext(r.Context(), "database error during staff login", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
-	doctorResponse.ID = strconv.Itoa(doctorID)
-	doctorResponse.IsDoctor = true
-	doctorResponse.Token = token
+	if err := bcrypt.CompareHashAndPassword([]byte(staff.Password), []byte(req.Password)); err != nil {
+		loginFailed(r, account)
+		problem.Write(w, r, problem.CodeInvalidCredentials)
+		return
+	}
+	loginSucceeded(r, account)
 
-	// log.Printf("Login successful for doctor ID: %d", doctorID)
+	// Deactivated accounts keep their data but may not log in
+	deactivatedAt, err := Store.Staff.DeactivatedAt(staff.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error checking staff account status", "staff_id", staff.ID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+	if deactivatedAt != nil {
+		problem.Write(w, r, problem.CodeAccountDeactivated)
+		return
+	}
+
+	staffResponse := LoginResponse{
+		ID:          strconv.Itoa(staff.ID),
+		FirstName:   staff.FirstName,
+		LastName:    staff.LastName,
+		PhoneNumber: staff.PhoneNumber,
+	}
+	if !startSession(w, r, &staffResponse, staff.ID, utils.Role(staff.Role)) {
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(doctorResponse)
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(staffResponse)
+}
+
+// loginAccount names the account of the given kind with phoneNumber for
+// LoginLimiter. Receptionists and admins share the staff kind.
+func loginAccount(kind, phoneNumber string) string {
+	return kind + ":" + phoneNumber
+}
+
+// loginAllowed reports whether account may attempt to log in from the
+// client's IP. If not, it writes a 429 response telling when to retry.
+func loginAllowed(w http.ResponseWriter, r *http.Request, account string) bool {
+	wait, err := LoginLimiter.Check(account, utils.ClientIP(r))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error checking login throttle", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return false
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		problem.Write(w, r, problem.CodeTooManyLogins)
+		return false
+	}
+	return true
+}
+
+// loginFailed counts a failed login of account and writes the lockouts it
+// causes to the audit trail.
+func loginFailed(r *http.Request, account string) {
+	ip := utils.ClientIP(r)
+	lockouts, err := LoginLimiter.Fail(account, ip)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error counting failed login", "err", err)
+	}
+	for _, l := range lockouts {
+		slog.WarnContext(r.Context(), "login locked out", "key", l.Key, "failures", l.Failures, "until", l.Until)
+		event := &models.AuditEvent{
+			Action:    models.AuditLoginLockout,
+			Subject:   l.Key,
+			IPAddress: ip,
+			Detail:    fmt.Sprintf("%d failed logins, locked until %s", l.Failures, l.Until.UTC().Format(time.RFC3339)),
+		}
+		if err := Store.Audit.Record(event); err != nil {
+			slog.ErrorContext(r.Context(), "error recording lockout in audit trail", "key", l.Key, "err", err)
+		}
+	}
+}
+
+// loginSucceeded clears the failed logins of account.
+func loginSucceeded(r *http.Request, account string) {
+	if err := LoginLimiter.Succeed(account); err != nil {
+		slog.ErrorContext(r.Context(), "error resetting failed logins", "err", err)
+	}
 }
 
 // Helper function to log SQL queries (optional, for debugging)
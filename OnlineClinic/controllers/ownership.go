@@ -0,0 +1,126 @@
+// controllers/ownership.go
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"onlineClinic/models"
+	"onlineClinic/utils"
+)
+
+// The resolvers below look resources up in the store, which utils cannot
+// import. Routes pass them to utils.Authorize.
+
+// PatientOrGuardian resolves the patient whose ID is the path variable
+// name, and their guardian while they are a minor dependent.
+func PatientOrGuardian(name string) utils.ResourceResolver {
+	return func(r *http.Request) ([]utils.Principal, error) {
+		patientID, err := utils.PathID(r, name)
+		if err != nil {
+			return nil, err
+		}
+		return withGuardian([]utils.Principal{{Role: utils.RolePatient, ID: patientID}}, patientID)
+	}
+}
+
+// PatientCareTeam resolves the patient whose ID is the path variable name,
+// together with every doctor the patient has had an appointment with and
+// their guardian while they are a minor dependent.
+func PatientCareTeam(name string) utils.ResourceResolver {
+	return func(r *http.Request) ([]utils.Principal, error) {
+		patientID, err := utils.PathID(r, name)
+		if err != nil {
+			return nil, err
+		}
+		doctorIDs, err := Store.Appointments.PatientDoctors(patientID)
+		if err != nil {
+			return nil, err
+		}
+
+		owners := []utils.Principal{{Role: utils.RolePatient, ID: patientID}}
+		for _, id := range doctorIDs {
+			owners = append(owners, utils.Principal{Role: utils.RoleDoctor, ID: id})
+		}
+		return withGuardian(owners, patientID)
+	}
+}
+
+// AppointmentInPath resolves the patient and the doctor of the appointment
+// whose ID is the path variable name, and the patient's guardian while they
+// are a minor dependent.
+func AppointmentInPath(name string) utils.ResourceResolver {
+	return func(r *http.Request) ([]utils.Principal, error) {
+		id, err := utils.PathID(r, name)
+		if err != nil {
+			return nil, err
+		}
+		patientID, doctorID, err := Store.Appointments.Participants(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrResourceNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return withGuardian([]utils.Principal{
+			{Role: utils.RolePatient, ID: patientID},
+			{Role: utils.RoleDoctor, ID: doctorID},
+		}, patientID)
+	}
+}
+
+// DependentGuardian resolves the guardian of the dependent whose ID is the
+// path variable name, whether or not the dependent has come of age.
+func DependentGuardian(name string) utils.ResourceResolver {
+	return func(r *http.Request) ([]utils.Principal, error) {
+		id, err := utils.PathID(r, name)
+		if err != nil {
+			return nil, err
+		}
+		dependent, err := Store.Dependents.Get(id)
+		if errors.Is(err, models.ErrDependentNotFound) {
+			return nil, utils.ErrResourceNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []utils.Principal{{Role: utils.RolePatient, ID: dependent.GuardianID}}, nil
+	}
+}
+
+// SlotInPath resolves the doctor of the availability slot whose ID is the
+// path variable slot. The slot must belong to the doctor in the path
+// variable doctor.
+func SlotInPath(doctor, slot string) utils.ResourceResolver {
+	return func(r *http.Request) ([]utils.Principal, error) {
+		doctorID, err := utils.PathID(r, doctor)
+		if err != nil {
+			return nil, err
+		}
+		slotID, err := utils.PathID(r, slot)
+		if err != nil {
+			return nil, err
+		}
+		owner, err := Store.Availability.SlotDoctor(slotID)
+		if errors.Is(err, models.ErrAvailabilityNotFound) || (err == nil && owner != doctorID) {
+			return nil, utils.ErrResourceNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []utils.Principal{{Role: utils.RoleDoctor, ID: owner}}, nil
+	}
+}
+
+// withGuardian adds the guardian of patientID to owners while patientID is
+// a minor dependent.
+func withGuardian(owners []utils.Principal, patientID int) ([]utils.Principal, error) {
+	guardian, err := minorGuardian(patientID)
+	if err != nil {
+		return nil, err
+	}
+	if guardian != nil {
+		owners = append(owners, *guardian)
+	}
+	return owners, nil
+}
@@ -0,0 +1,221 @@
+// controllers/ownership_test.go
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"onlineClinic/migrations"
+	"onlineClinic/models"
+	"onlineClinic/utils"
+
+	"github.com/gorilla/mux"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// useTestStore points Store at a fresh, fully migrated SQLite database for
+// the rest of the test and returns the database.
+func useTestStore(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "clinic.db") + "?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000"
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrations.NewSQLite(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	previous := Store
+	Store = models.NewSQLiteStore(db)
+	t.Cleanup(func() { Store = previous })
+	return db
+}
+
+// The fixture: patients 1 and 2 have accounts, 3 is a minor dependent of 1
+// and 4 a dependent of 1 who has come of age. Doctor 1 sees patient 1 in
+// appointment 1 and patient 2 in appointment 3, and owns slot 1; doctor 2
+// sees patient 3 in appointment 2. Each appointment has a prescription.
+func seedOwnership(t *testing.T, db *sql.DB) {
+	t.Helper()
+	now := time.Now().UTC()
+	minor := time.Date(now.Year()-10, 1, 1, 0, 0, 0, 0, time.UTC)
+	adult := time.Date(now.Year()-30, 1, 1, 0, 0, 0, 0, time.UTC)
+	start := now.Add(48 * time.Hour).Truncate(time.Hour)
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`INSERT INTO doctors (id, first_name, last_name, national_code, gender, phone_number, password)
+          VALUES (1, 'Doctor', 'One', '0000000001', 'man', '09120000101', 'x'),
+                 (2, 'Doctor', 'Two', '0000000002', 'woman', '09120000102', 'x')`, nil},
+		{`INSERT INTO patients (id, first_name, last_name, national_code, gender, phone_number, password)
+          VALUES (1, 'Patient', 'One', '0000000011', 'man', '09120000201', 'x'),
+                 (2, 'Patient', 'Two', '0000000012', 'woman', '09120000202', 'x')`, nil},
+		{`INSERT INTO patients (id, first_name, last_name, national_code, gender, guardian_id, birth_date)
+          VALUES (3, 'Minor', 'One', '0000000013', 'woman', 1, ?),
+                 (4, 'Adult', 'One', '0000000014', 'man', 1, ?)`, []interface{}{minor, adult}},
+		{`INSERT INTO appointments (id, patient_id, doctor_id, start_time, end_time, visit_type)
+          VALUES (1, 1, 1, ?, ?, 'online'), (2, 3, 2, ?, ?, 'online'), (3, 2, 1, ?, ?, 'online')`,
+			[]interface{}{start, start.Add(15 * time.Minute), start, start.Add(15 * time.Minute),
+				start.Add(15 * time.Minute), start.Add(30 * time.Minute)}},
+		{`INSERT INTO prescriptions (id, appointment_id, instructions) VALUES (1, 1, 'a'), (2, 2, 'b'), (3, 3, 'c')`, nil},
+		{`INSERT INTO doctor_availability (id, doctor_id, start_time, end_time, type)
+          VALUES (1, 1, ?, ?, 'online')`, []interface{}{start.Add(time.Hour), start.Add(2 * time.Hour)}},
+	}
+	for _, s := range statements {
+		if _, err := db.Exec(s.query, s.args...); err != nil {
+			t.Fatalf("seeding: %v", err)
+		}
+	}
+}
+
+func TestResolversDenyCrossAccountAccess(t *testing.T) {
+	seedOwnership(t, useTestStore(t))
+
+	// The routes of routes.SetupRoutes that take IDs, in front of a handler
+	// that only reports it was reached.
+	reached := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	allow := utils.Authorize
+	router := mux.NewRouter()
+	router.HandleFunc("/api/patients/{id}", allow(utils.PermPatientProfileRead, PatientOrGuardian("id"))(reached)).Methods("GET")
+	router.HandleFunc("/api/patients/{id}", allow(utils.PermPatientProfileWrite, utils.PatientInPath("id"))(reached)).Methods("PUT")
+	router.HandleFunc("/api/patients/{id}", allow(utils.PermPatientDelete, utils.PatientInPath("id"))(reached)).Methods("DELETE")
+	router.HandleFunc("/api/patient/{id}", allow(utils.PermPatientInfoRead, PatientCareTeam("id"))(reached)).Methods("GET")
+	router.HandleFunc("/api/patients/{id}/appointments", allow(utils.PermPatientAppointmentsRead, PatientCareTeam("id"))(reached)).Methods("GET")
+	router.HandleFunc("/api/prescriptions/patient/{id}", allow(utils.PermPrescriptionRead, PatientCareTeam("id"))(reached)).Methods("GET")
+	router.HandleFunc("/api/prescriptions/{appointmentId}", allow(utils.PermPrescriptionRead, AppointmentInPath("appointmentId"))(reached)).Methods("GET")
+	router.HandleFunc("/api/appointments/{id}", allow(utils.PermAppointmentCancel, AppointmentInPath("id"))(reached)).Methods("DELETE")
+	router.HandleFunc("/api/doctors/{id}/availability/{slotId}", allow(utils.PermAvailabilityWrite, SlotInPath("id", "slotId"))(reached)).Methods("DELETE")
+	router.HandleFunc("/api/dependents/{id}/handover", allow(utils.PermDependentsManage, DependentGuardian("id"))(reached)).Methods("POST")
+
+	as := func(role utils.Role, id int) *utils.Claims {
+		return &utils.Claims{UserID: id, Role: role, Permissions: utils.PermissionsOf(role)}
+	}
+	patient1, patient2 := as(utils.RolePatient, 1), as(utils.RolePatient, 2)
+	doctor1, doctor2 := as(utils.RoleDoctor, 1), as(utils.RoleDoctor, 2)
+	receptionist := as(utils.RoleReceptionist, 1)
+
+	tests := []struct {
+		name   string
+		claims *utils.Claims
+		method string
+		path   string
+		want   int
+	}{
+		// Patients
+		{"patient reads own profile", patient1, "GET", "/api/patients/1", http.StatusNoContent},
+		{"patient reads another patient", patient2, "GET", "/api/patients/1", http.StatusForbidden},
+		{"patient updates another patient", patient2, "PUT", "/api/patients/1", http.StatusForbidden},
+		{"patient deletes another patient", patient2, "DELETE", "/api/patients/1", http.StatusForbidden},
+		{"patient reads another patient's info", patient2, "GET", "/api/patient/1", http.StatusForbidden},
+		{"patient reads another patient's appointments", patient2, "GET", "/api/patients/1/appointments", http.StatusForbidden},
+		{"patient reads another patient's prescriptions", patient2, "GET", "/api/prescriptions/patient/1", http.StatusForbidden},
+		{"patient reads another patient's prescription", patient2, "GET", "/api/prescriptions/1", http.StatusForbidden},
+		{"patient cancels another patient's appointment", patient2, "DELETE", "/api/appointments/1", http.StatusForbidden},
+		{"patient cancels own appointment", patient1, "DELETE", "/api/appointments/1", http.StatusNoContent},
+		{"unknown appointment", patient1, "DELETE", "/api/appointments/99", http.StatusNotFound},
+		{"invalid appointment ID", patient1, "DELETE", "/api/appointments/x", http.StatusBadRequest},
+
+		// Guardians
+		{"guardian reads minor dependent", patient1, "GET", "/api/patients/3", http.StatusNoContent},
+		{"guardian reads minor dependent's info", patient1, "GET", "/api/patient/3", http.StatusNoContent},
+		{"guardian reads minor dependent's appointments", patient1, "GET", "/api/patients/3/appointments", http.StatusNoContent},
+		{"guardian cancels minor dependent's appointment", patient1, "DELETE", "/api/appointments/2", http.StatusNoContent},
+		{"guardian updates minor dependent", patient1, "PUT", "/api/patients/3", http.StatusForbidden},
+		{"guardian reads adult dependent", patient1, "GET", "/api/patients/4", http.StatusForbidden},
+		{"other patient reads minor dependent", patient2, "GET", "/api/patients/3", http.StatusForbidden},
+		{"guardian hands over dependent", patient1, "POST", "/api/dependents/4/handover", http.StatusNoContent},
+		{"other patient hands over dependent", patient2, "POST", "/api/dependents/4/handover", http.StatusForbidden},
+		{"hand over a patient with an account", patient1, "POST", "/api/dependents/1/handover", http.StatusNotFound},
+
+		// Doctors
+		{"doctor reads own patient's appointments", doctor1, "GET", "/api/patients/1/appointments", http.StatusNoContent},
+		{"doctor reads another doctor's patient's appointments", doctor2, "GET", "/api/patients/1/appointments", http.StatusForbidden},
+		{"doctor reads another doctor's patient's prescriptions", doctor2, "GET", "/api/prescriptions/patient/1", http.StatusForbidden},
+		{"doctor reads another doctor's prescription", doctor2, "GET", "/api/prescriptions/1", http.StatusForbidden},
+		{"doctor reads own patient's info", doctor1, "GET", "/api/patient/1", http.StatusNoContent},
+		{"doctor reads another doctor's patient's info", doctor2, "GET", "/api/patient/1", http.StatusForbidden},
+		{"doctor reads a patient profile", doctor1, "GET", "/api/patients/1", http.StatusForbidden},
+		{"doctor cancels own appointment", doctor1, "DELETE", "/api/appointments/1", http.StatusNoContent},
+		{"doctor cancels another doctor's appointment", doctor2, "DELETE", "/api/appointments/1", http.StatusForbidden},
+		{"doctor deletes own slot", doctor1, "DELETE", "/api/doctors/1/availability/1", http.StatusNoContent},
+		{"doctor deletes another doctor's slot", doctor2, "DELETE", "/api/doctors/1/availability/1", http.StatusForbidden},
+		{"doctor deletes another doctor's slot under own ID", doctor2, "DELETE", "/api/doctors/2/availability/1", http.StatusNotFound},
+
+		// Staff
+		{"receptionist reads any patient", receptionist, "GET", "/api/patients/1", http.StatusNoContent},
+		{"receptionist deletes a patient", receptionist, "DELETE", "/api/patients/1", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			r = r.WithContext(utils.SetUserClaims(r.Context(), tt.claims))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.path, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestPrescriptionSearchIsScoped(t *testing.T) {
+	seedOwnership(t, useTestStore(t))
+	today := time.Now().UTC().Format("2006-01-02")
+
+	as := func(role utils.Role, id int) *utils.Claims {
+		return &utils.Claims{UserID: id, Role: role, Permissions: utils.PermissionsOf(role)}
+	}
+	tests := []struct {
+		name   string
+		claims *utils.Claims
+		query  string
+		want   []int // Patient IDs, in prescription order
+	}{
+		{"patient sees own and minor dependent's", as(utils.RolePatient, 1), "date=" + today, []int{1, 3}},
+		{"patient sees only own", as(utils.RolePatient, 2), "date=" + today, []int{2}},
+		{"patient searches another patient by name", as(utils.RolePatient, 2), "patientName=Patient+One", nil},
+		{"doctor sees own appointments", as(utils.RoleDoctor, 1), "date=" + today, []int{1, 2}},
+		{"doctor searches another doctor's patient", as(utils.RoleDoctor, 2), "patientName=Patient", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/prescriptions/search?"+tt.query, nil)
+			r = r.WithContext(utils.SetUserClaims(r.Context(), tt.claims))
+			w := httptest.NewRecorder()
+			GetPrescriptionsByPatientNameAndDateHandler(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+
+			var got []models.PrescriptionResponse
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			var patients []int
+			for _, p := range got {
+				patients = append(patients, p.PatientID)
+			}
+			if !slices.Equal(patients, tt.want) {
+				t.Errorf("patients = %v, want %v", patients, tt.want)
+			}
+		})
+	}
+}
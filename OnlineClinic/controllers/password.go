@@ -0,0 +1,173 @@
+// controllers/password.go
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"onlineClinic/models"
+	"onlineClinic/problem"
+	"onlineClinic/utils"
+	"onlineClinic/validation"
+	"strings"
+)
+
+// Password reset works for every kind of account. Staff are one kind, since
+// receptionists and admins share the staff table.
+const (
+	accountPatient = "patient"
+	accountDoctor  = "doctor"
+	accountStaff   = "staff"
+)
+
+type ForgotPasswordRequest struct {
+	PhoneNumber string `json:"phoneNumber"`
+	Role        string `json:"role"` // "patient", "doctor" or "staff"
+}
+
+type ResetPasswordRequest struct {
+	PhoneNumber      string `json:"phoneNumber"`
+	Role             string `json:"role"`
+	VerificationCode string `json:"verificationCode"`
+	UserNewPassword  string `json:"userNewPassword"`
+}
+
+// ForgotPassword sends a password reset code to the phone number of an
+// account. The response is the same whether or not the account exists, so
+// it cannot be used to find registered numbers.
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.CodeInvalidBody)
+		return
+	}
+	req.PhoneNumber = strings.TrimSpace(req.PhoneNumber)
+	var v validation.Validator
+	v.Mobile("phoneNumber", req.PhoneNumber)
+	if invalid(w, r, v.Err()) {
+		return
+	}
+
+	_, _, err := accountByPhone(req.Role, req.PhoneNumber)
+	switch {
+	case errors.Is(err, errUnknownAccountKind):
+		problem.Write(w, r, problem.CodeInvalidRole)
+		return
+	case err == sql.ErrNoRows:
+		writeCodeSent(w)
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "error looking up account for password reset", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	// A cooldown is not reported either, as unknown numbers never have one
+	err = issueVerificationCode(r, req.PhoneNumber, models.VerifyPasswordReset(req.Role))
+	if err != nil && !errors.Is(err, models.ErrVerificationCooldown) {
+		slog.ErrorContext(r.Context(), "error sending password reset code", "err", err)
+		problem.Write(w, r, problem.CodeVerificationSendFailed)
+		return
+	}
+	writeCodeSent(w)
+}
+
+// ResetPassword sets a new password with a code from ForgotPassword. It ends
+// every session of the account, so a stolen login stops working too.
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.CodeInvalidBody)
+		return
+	}
+	req.PhoneNumber = strings.TrimSpace(req.PhoneNumber)
+	var v validation.Validator
+	validatePassword(&v, "userNewPassword", req.UserNewPassword)
+	if invalid(w, r, v.Err()) {
+		return
+	}
+
+	id, sessionRole, err := accountByPhone(req.Role, req.PhoneNumber)
+	switch {
+	case errors.Is(err, errUnknownAccountKind):
+		problem.Write(w, r, problem.CodeInvalidRole)
+		return
+	case err == sql.ErrNoRows:
+		problem.Write(w, r, problem.CodeVerificationInvalid)
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "error looking up account for password reset", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	if !verifyPhone(w, r, req.PhoneNumber, models.VerifyPasswordReset(req.Role), req.VerificationCode) {
+		return
+	}
+
+	// The repositories hash the password like the authenticated endpoints do
+	switch req.Role {
+	case accountPatient:
+		err = Store.Patients.UpdatePassword(id, req.UserNewPassword)
+	case accountDoctor:
+		err = Store.Doctors.UpdatePassword(id, req.UserNewPassword)
+	default:
+		err = Store.Staff.UpdatePassword(id, req.UserNewPassword)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error updating password in database", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	if _, err := Store.Sessions.RevokeUser(id, sessionRole); err != nil {
+		slog.ErrorContext(r.Context(), "error revoking sessions after password reset", "role", sessionRole, "user_id", id, "err", err)
+	}
+	loginSucceeded(r, loginAccount(req.Role, req.PhoneNumber))
+
+	event := &models.AuditEvent{
+		Action:    models.AuditPasswordReset,
+		ActorRole: sessionRole,
+		ActorID:   &id,
+		Subject:   req.Role + ":" + req.PhoneNumber,
+		IPAddress: utils.ClientIP(r),
+	}
+	if err := Store.Audit.Record(event); err != nil {
+		slog.ErrorContext(r.Context(), "error recording password reset in audit trail", "err", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password reset successfully"})
+}
+
+var errUnknownAccountKind = errors.New("unknown account kind")
+
+// accountByPhone returns the ID of the account of the given kind with
+// phoneNumber, and the role its sessions are stored under. It returns
+// sql.ErrNoRows if there is none.
+func accountByPhone(kind, phoneNumber string) (int, string, error) {
+	switch kind {
+	case accountPatient:
+		patient, err := Store.Patients.GetByPhone(phoneNumber)
+		if err != nil {
+			return 0, "", err
+		}
+		return patient.ID, accountPatient, nil
+	case accountDoctor:
+		doctor, err := Store.Doctors.GetByPhone(phoneNumber)
+		if err != nil {
+			return 0, "", err
+		}
+		return doctor.ID, accountDoctor, nil
+	case accountStaff:
+		staff, err := Store.Staff.GetByPhone(phoneNumber)
+		if err != nil {
+			return 0, "", err
+		}
+		return staff.ID, staff.Role, nil
+	default:
+		return 0, "", errUnknownAccountKind
+	}
+}
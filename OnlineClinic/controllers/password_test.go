@@ -0,0 +1,51 @@
+// controllers/password_test.go
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"onlineClinic/problem"
+)
+
+func TestResetPasswordValidatesNewPassword(t *testing.T) {
+	useTestStore(t)
+
+	tests := []struct {
+		name     string
+		password string
+	}{
+		{"empty", ""},
+		// bcrypt refuses these, which used to surface as a 500
+		{"too long", strings.Repeat("a", 73)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(ResetPasswordRequest{
+				Role:             "patient",
+				PhoneNumber:      "09120000001",
+				VerificationCode: "123456",
+				UserNewPassword:  tt.password,
+			})
+			r := httptest.NewRequest("POST", "/api/password/reset", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			ResetPassword(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body)
+			}
+			var d problem.Details
+			if err := json.NewDecoder(w.Body).Decode(&d); err != nil {
+				t.Fatal(err)
+			}
+			if d.Code != problem.CodeValidationFailed || len(d.Errors) != 1 || d.Errors[0].Field != "userNewPassword" {
+				t.Errorf("problem = %s %v, want %s on userNewPassword", d.Code, d.Errors, problem.CodeValidationFailed)
+			}
+		})
+	}
+}
@@ -2,14 +2,14 @@
 package controllers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"log/slog"
 	"net/http"
-	"onlineClinic/config"
 	"onlineClinic/models"
+	"onlineClinic/problem"
 	"onlineClinic/utils"
+	"onlineClinic/validation"
 	"strconv"
-	"strings"
 
 	"github.com/gorilla/mux"
 )
@@ -18,14 +18,14 @@ func GetPatientProfile(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidPatientID)
 		return
 	}
 
-	patient, err := models.GetPatientById(config.DB, id)
+	patient, err := Store.Patients.GetByID(id)
 	if err != nil {
-		// log.Printf("Error retrieving patient: %v", err)
-		http.Error(w, "Error retrieving patient profile", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error retrieving patient", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -37,14 +37,14 @@ func GetPatientInfo(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidPatientID)
 		return
 	}
 
-	patient, err := models.GetPatientById(config.DB, id)
+	patient, err := Store.Patients.GetByID(id)
 	if err != nil {
-		// log.Printf("Error retrieving patient: %v", err)
-		http.Error(w, "Error retrieving patient profile", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error retrieving patient", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -64,6 +64,10 @@ func GetPatientInfo(w http.ResponseWriter, r *http.Request) {
 		Gender:    patient.Gender,
 	}
 
+	if !recordAccess(w, r, models.AuditPatientInfoRead, "patient:"+strconv.Itoa(id), id) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(p)
 }
@@ -72,57 +76,53 @@ func UpdatePatientProfile(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidPatientID)
 		return
 	}
 
-	var patient models.Patient
-	if err := json.NewDecoder(r.Body).Decode(&patient); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
+	var req struct {
+		models.Patient
+		VerificationCode string `json:"verificationCode"` // Needed to change the phone number
 	}
-
-	// Validate input
-	if patient.FirstName == "" || patient.LastName == "" || patient.PhoneNumber == "" {
-		http.Error(w, "Required fields cannot be empty", http.StatusBadRequest)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.CodeInvalidBody)
 		return
 	}
+	patient := req.Patient
 
-	if !utils.ValidatePhoneNumber(patient.PhoneNumber) {
-		http.Error(w, "Invalid phone number format", http.StatusBadRequest)
-		return
-	}
-
-	if patient.NationalCode != "" && !utils.ValidateNationalCode(patient.NationalCode) {
-		http.Error(w, "Invalid national code format", http.StatusBadRequest)
+	// Validate every field at once
+	if invalid(w, r, validatePatient(&patient, false)) {
 		return
 	}
 
 	patient.ID = id
 
-	// Fetch the existing profile photo path from the database
-	var existingProfilePhotoPath sql.NullString
-	err = config.DB.QueryRow("SELECT profile_photo_path FROM patients WHERE id = ?", id).Scan(&existingProfilePhotoPath)
+	// Keep the existing profile photo; it is changed through the upload endpoint
+	existing, err := Store.Patients.GetByID(id)
 	if err != nil {
-		// log.Printf("Error fetching existing profile photo path: %v", err)
-		http.Error(w, "Error fetching profile photo path", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error fetching existing profile photo path", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
+	patient.ProfilePhotoPath = existing.ProfilePhotoPath
 
-	// Set the profile photo path to the existing value if valid, else nil
-	if existingProfilePhotoPath.Valid {
-		patient.ProfilePhotoPath = &existingProfilePhotoPath.String
-	} else {
-		patient.ProfilePhotoPath = nil
+	// Patients prove they own a new phone number with a code sent to it by
+	// RequestPhoneChangeCode; staff may correct the number directly.
+	if patient.PhoneNumber != existing.PhoneNumber {
+		claims, _ := utils.GetUserClaims(r.Context())
+		self := utils.Principal{Role: utils.RolePatient, ID: id}
+		if claims.Is(self) && !verifyPhone(w, r, patient.PhoneNumber, models.VerifyPhoneChange(string(self.Role), id), req.VerificationCode) {
+			return
+		}
 	}
 
-	if err := models.UpdatePatient(config.DB, &patient); err != nil {
-		if strings.Contains(err.Error(), "Duplicate entry") {
-			http.Error(w, "Phone number already exists", http.StatusConflict)
+	if err := Store.Patients.Update(&patient); err != nil {
+		if isDuplicateEntry(err) {
+			problem.Write(w, r, problem.CodePhoneTaken)
 			return
 		}
-		// log.Printf("Error updating patient: %v", err)
-		http.Error(w, "Error updating patient profile", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error updating patient", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -138,7 +138,7 @@ func UpdatePatientPassword(w http.ResponseWriter, r *http.Request) {
 	patientID, ok := utils.GetUserID(r.Context())
 	if !ok {
 		// log.Printf("Failed to get patientID from context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Write(w, r, problem.CodeUnauthenticated)
 		return
 	}
 	// log.Printf("Processing password update for patient ID: %d", patientID)
@@ -150,45 +150,27 @@ func UpdatePatientPassword(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(&passwordUpdate); err != nil {
 		// log.Printf("Error decoding request body: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidBody)
 		return
 	}
 	// log.Println("Request body decoded successfully")
 
 	// Validate new password
-	if passwordUpdate.UserNewPassword == "" {
-		// log.Println("Empty password received")
-		http.Error(w, "New password cannot be empty", http.StatusBadRequest)
+	var v validation.Validator
+	validatePassword(&v, "userNewPassword", passwordUpdate.UserNewPassword)
+	if invalid(w, r, v.Err()) {
 		return
 	}
 	// log.Println("Password validation passed")
 
-	// Start a transaction
-	tx, err := config.DB.Begin()
-	if err != nil {
-		// log.Printf("Error starting transaction: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-	defer tx.Rollback()
-	// log.Println("Transaction started")
-
 	// Update the password
-	if err := models.UpdatePatientPassword(tx, patientID, passwordUpdate.UserNewPassword); err != nil {
-		// log.Printf("Error updating password in database: %v", err)
-		http.Error(w, "Error updating password", http.StatusInternalServerError)
+	if err := Store.Patients.UpdatePassword(patientID, passwordUpdate.UserNewPassword); err != nil {
+		slog.ErrorContext(r.Context(), "error updating password in database", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 	// log.Println("Password updated in database")
 
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		// log.Printf("Error committing transaction: %v", err)
-		http.Error(w, "Error updating password", http.StatusInternalServerError)
-		return
-	}
-	// log.Println("Transaction committed successfully")
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Password updated successfully"})
@@ -199,13 +181,12 @@ func DeletePatientProfile(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidPatientID)
 		return
 	}
 
-	if err := models.DeletePatient(config.DB, id); err != nil {
-		// log.Printf("Error deleting patient: %v", err)
-		http.Error(w, "Error deleting patient profile", http.StatusInternalServerError)
+	if err := Store.Patients.Delete(id); err != nil {
+		writeError(w, r, err, "error deleting patient")
 		return
 	}
 
@@ -218,13 +199,13 @@ func DeletePatientProfilePhoto(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidPatientID)
 		return
 	}
 
-	if err := models.DeletePatientPhoto(config.DB, id); err != nil {
-		// log.Printf("Error deleting patient profile photo: %v", err)
-		http.Error(w, "Error deleting patient profile photo", http.StatusInternalServerError)
+	if err := Store.Patients.DeletePhoto(id); err != nil {
+		slog.ErrorContext(r.Context(), "error deleting patient profile photo", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -234,10 +215,10 @@ func DeletePatientProfilePhoto(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetAllPatients(w http.ResponseWriter, r *http.Request) {
-	patients, err := models.GetAllPatients(config.DB)
+	patients, err := Store.Patients.GetAll()
 	if err != nil {
-		// log.Printf("Error retrieving patients: %v", err)
-		http.Error(w, "Error retrieving patients list", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error retrieving patients", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -2,10 +2,13 @@
 package controllers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
-	"onlineClinic/config"
 	"onlineClinic/models"
+	"onlineClinic/problem"
 	"onlineClinic/utils"
 	"strconv"
 	"time"
@@ -104,7 +107,7 @@ func GetPrescriptionsByDoctor(w http.ResponseWriter, r *http.Request) {
 	claims, ok := r.Context().Value(utils.UserClaimsKey).(*utils.Claims)
 	if !ok || !claims.IsDoctor {
 		// log.Printf("Unauthorized: User is not a doctor")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Write(w, r, problem.CodeUnauthenticated)
 		return
 	}
 
@@ -113,22 +116,15 @@ func GetPrescriptionsByDoctor(w http.ResponseWriter, r *http.Request) {
 	doctorID, err := strconv.Atoi(vars["id"])
 	if err != nil {
 		// log.Printf("Invalid doctor ID: %v", err)
-		http.Error(w, "Invalid doctor ID", http.StatusBadRequest)
-		return
-	}
-
-	// Ensure the doctor is accessing their own prescriptions
-	if claims.UserID != doctorID {
-		// log.Printf("Unauthorized: Doctor %d cannot access prescriptions for doctor %d", claims.UserID, doctorID)
-		http.Error(w, "Unauthorized to view these prescriptions", http.StatusForbidden)
+		problem.Write(w, r, problem.CodeInvalidDoctorID)
 		return
 	}
 
 	// Fetch prescriptions
-	prescriptions, err := models.GetPrescriptionsByDoctor(config.DB, doctorID)
+	prescriptions, err := Store.Prescriptions.ListByDoctor(doctorID)
 	if err != nil {
-		// log.Printf("Error retrieving prescriptions: %v", err)
-		http.Error(w, "Error retrieving prescriptions", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error retrieving prescriptions", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -143,7 +139,7 @@ func GetPrescriptionsByPatient(w http.ResponseWriter, r *http.Request) {
 	claims, ok := r.Context().Value(utils.UserClaimsKey).(*utils.Claims)
 	if !ok {
 		// log.Printf("Claims retrieval failed")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Write(w, r, problem.CodeUnauthenticated)
 		return
 	}
 
@@ -152,32 +148,36 @@ func GetPrescriptionsByPatient(w http.ResponseWriter, r *http.Request) {
 	patientID, err := strconv.Atoi(vars["id"])
 	if err != nil {
 		// log.Printf("Invalid patient ID: %v", err)
-		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidPatientID)
 		return
 	}
 
-	// Ensure the user is authorized to access these prescriptions
-	if !claims.IsDoctor && claims.UserID != patientID {
-		// log.Printf("Unauthorized: User %d cannot access prescriptions for patient %d", claims.UserID, patientID)
-		http.Error(w, "Unauthorized to view these prescriptions", http.StatusUnauthorized)
+	// Guardians read the prescriptions of a minor dependent as the dependent
+	userID, err := recordUserID(claims, patientID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error checking guardian", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
 	// Fetch prescriptions
-	prescriptions, err := models.GetPatientPrescriptions(
-		config.DB,
+	prescriptions, err := Store.Prescriptions.ListByPatient(
 		patientID,
-		claims.UserID,
+		userID,
 		claims.IsDoctor,
 	)
 	if err != nil {
-		if err.Error() == "unauthorized access" {
+		if errors.Is(err, models.ErrUnauthorizedAccess) {
 			// log.Printf("Unauthorized access to prescriptions for patient %d", patientID)
-			http.Error(w, "Unauthorized access", http.StatusForbidden)
+			problem.Write(w, r, problem.CodeForbidden)
 			return
 		}
-		// log.Printf("Error retrieving prescriptions: %v", err)
-		http.Error(w, "Error retrieving prescriptions", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error retrieving prescriptions", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	if !recordAccess(w, r, models.AuditPrescriptionsRead, "patient:"+strconv.Itoa(patientID)+"/prescriptions", patientID) {
 		return
 	}
 
@@ -192,7 +192,10 @@ func UpdatePrescription(w http.ResponseWriter, r *http.Request) {
 	claims, ok := r.Context().Value(utils.UserClaimsKey).(*utils.Claims)
 	if !ok || !claims.IsDoctor {
 		// log.Printf("Unauthorized: User is not a doctor")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Write(w, r, problem.CodeUnauthenticated)
+		return
+	}
+	if !requireApprovedDoctor(w, r, claims.UserID) {
 		return
 	}
 
@@ -200,39 +203,36 @@ func UpdatePrescription(w http.ResponseWriter, r *http.Request) {
 	var req models.PrescriptionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		// log.Printf("Error decoding request: %v", err)
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidBody)
 		return
 	}
 
-	// Validate the request - removed instructions length check
-	if req.AppointmentID == 0 || len(req.Medications) == 0 {
-		// log.Printf("Invalid request: missing required fields")
-		http.Error(w, "Appointment ID and medications are required", http.StatusBadRequest)
+	// Validate every field at once
+	if invalid(w, r, validatePrescriptionRequest(&req)) {
 		return
 	}
 
 	// Fetch the existing prescription
-	existingPrescription, err := models.GetPrescriptionByAppointment(
-		config.DB,
+	existingPrescription, err := Store.Prescriptions.GetByAppointment(
 		req.AppointmentID,
 		claims.UserID,
 		claims.IsDoctor,
 	)
 	if err != nil {
-		if err.Error() == "unauthorized access" {
+		if errors.Is(err, models.ErrUnauthorizedAccess) {
 			// log.Printf("Unauthorized access to update prescription for appointment %d", req.AppointmentID)
-			http.Error(w, "Unauthorized access", http.StatusForbidden)
+			problem.Write(w, r, problem.CodeForbidden)
 			return
 		}
-		// log.Printf("Error retrieving prescription: %v", err)
-		http.Error(w, "Error retrieving prescription", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error retrieving prescription", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
 	// Ensure the doctor owns this prescription
 	if existingPrescription.DoctorID != claims.UserID {
 		// log.Printf("Unauthorized: Doctor %d does not own prescription for appointment %d", claims.UserID, req.AppointmentID)
-		http.Error(w, "Unauthorized to modify this prescription", http.StatusForbidden)
+		problem.Write(w, r, problem.CodeForbidden)
 		return
 	}
 
@@ -244,9 +244,9 @@ func UpdatePrescription(w http.ResponseWriter, r *http.Request) {
 		Medications:   req.Medications,
 	}
 
-	if err := models.UpdatePrescriptionDB(config.DB, updatedPrescription); err != nil {
-		// log.Printf("Error updating prescription: %v", err)
-		http.Error(w, "Error updating prescription", http.StatusInternalServerError)
+	if err := Store.Prescriptions.Update(updatedPrescription); err != nil {
+		slog.ErrorContext(r.Context(), "error updating prescription", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -260,7 +260,7 @@ func GetPatientPrescriptions(w http.ResponseWriter, r *http.Request) {
 
 	claims, ok := r.Context().Value(utils.UserClaimsKey).(*utils.Claims)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Write(w, r, problem.CodeUnauthenticated)
 		return
 	}
 
@@ -268,29 +268,28 @@ func GetPatientPrescriptions(w http.ResponseWriter, r *http.Request) {
 	patientID, err := strconv.Atoi(vars["id"])
 	if err != nil {
 		// log.Printf("Invalid patient ID: %v", err)
-		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidPatientID)
 		return
 	}
 
 	// If not a doctor, can only view own prescriptions
 	if !claims.IsDoctor && claims.UserID != patientID {
-		http.Error(w, "Unauthorized to view these prescriptions", http.StatusUnauthorized)
+		problem.Write(w, r, problem.CodeForbidden)
 		return
 	}
 
-	prescriptions, err := models.GetPatientPrescriptions(
-		config.DB,
+	prescriptions, err := Store.Prescriptions.ListByPatient(
 		patientID,
 		claims.UserID,
 		claims.IsDoctor,
 	)
 	if err != nil {
-		if err.Error() == "unauthorized access" {
-			http.Error(w, "Unauthorized access", http.StatusForbidden)
+		if errors.Is(err, models.ErrUnauthorizedAccess) {
+			problem.Write(w, r, problem.CodeForbidden)
 			return
 		}
-		// log.Printf("Error retrieving prescriptions: %v", err)
-		http.Error(w, "Error retrieving prescriptions", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error retrieving prescriptions", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -304,88 +303,14 @@ func GetAllPrescriptions(w http.ResponseWriter, r *http.Request) {
 
 	claims, ok := r.Context().Value("claims").(*utils.Claims)
 	if !ok || !claims.IsDoctor {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Write(w, r, problem.CodeUnauthenticated)
 		return
 	}
 
-	rows, err := config.DB.Query(`
-        SELECT 
-            p.id,
-            p.appointment_id,
-            p.doctor_id,
-            p.patient_id,
-            p.instructions,
-            p.created_at,
-            a.visit_type
-        FROM prescriptions p
-        JOIN appointments a ON p.appointment_id = a.id
-        ORDER BY p.created_at DESC
-    `)
-
+	prescriptions, err := Store.Prescriptions.ListAll()
 	if err != nil {
-		// log.Printf("Error querying prescriptions: %v", err)
-		http.Error(w, "Error fetching prescriptions", http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
-
-	var prescriptions []models.PrescriptionResponse
-	for rows.Next() {
-		var p models.PrescriptionResponse
-		var createdAt time.Time
-
-		err := rows.Scan(
-			&p.ID,
-			&p.AppointmentID,
-			&p.DoctorID,
-			&p.PatientID,
-			&p.Instructions,
-			&createdAt,
-			&p.VisitType,
-		)
-		if err != nil {
-			// log.Printf("Error scanning prescription: %v", err)
-			http.Error(w, "Error scanning prescriptions", http.StatusInternalServerError)
-			return
-		}
-
-		// Convert Gregorian date to Hijri date
-		p.CreatedAt = utils.GregorianToSolar(createdAt)
-
-		// Get medications for this prescription
-		medRows, err := config.DB.Query(`
-            SELECT medicine, frequency 
-            FROM medications 
-            WHERE prescription_id = ?`, p.ID)
-		if err != nil {
-			// log.Printf("Error fetching medications: %v", err)
-			http.Error(w, "Error reading medications", http.StatusInternalServerError)
-			return
-		}
-		defer medRows.Close()
-
-		var medications []models.Medication
-		for medRows.Next() {
-			var med models.Medication
-			if err := medRows.Scan(&med.Medicine, &med.Frequency); err != nil {
-				// log.Printf("Error scanning medication: %v", err)
-				http.Error(w, "Error reading medication", http.StatusInternalServerError)
-				return
-			}
-			medications = append(medications, med)
-		}
-		p.Medications = medications
-
-		if p.VisitType == "online" {
-			p.VisitType = "آنلاین"
-		}
-
-		prescriptions = append(prescriptions, p)
-	}
-
-	if err = rows.Err(); err != nil {
-		// log.Printf("Error after scanning rows: %v", err)
-		http.Error(w, "Error reading prescriptions", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error querying prescriptions", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -403,7 +328,7 @@ func GetPrescriptionByAppointment(w http.ResponseWriter, r *http.Request) {
 	claims, ok := r.Context().Value(utils.UserClaimsKey).(*utils.Claims)
 	if !ok {
 		// log.Printf("Claims retrieval failed")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Write(w, r, problem.CodeUnauthenticated)
 		return
 	}
 
@@ -412,25 +337,42 @@ func GetPrescriptionByAppointment(w http.ResponseWriter, r *http.Request) {
 	appointmentID, err := strconv.Atoi(vars["appointmentId"])
 	if err != nil {
 		// log.Printf("Invalid appointment ID: %v", err)
-		http.Error(w, "Invalid appointment ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidAppointmentID)
 		return
 	}
 
+	// Guardians read the prescription of a minor dependent as the dependent
+	userID := claims.UserID
+	if claims.Role == utils.RolePatient {
+		patientID, _, err := Store.Appointments.Participants(appointmentID)
+		if err == nil {
+			userID, err = recordUserID(claims, patientID)
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			slog.ErrorContext(r.Context(), "error checking guardian", "err", err)
+			problem.Write(w, r, problem.CodeInternal)
+			return
+		}
+	}
+
 	// Fetch the prescription
-	prescription, err := models.GetPrescriptionByAppointment(
-		config.DB,
+	prescription, err := Store.Prescriptions.GetByAppointment(
 		appointmentID,
-		claims.UserID,
+		userID,
 		claims.IsDoctor,
 	)
 	if err != nil {
-		if err.Error() == "unauthorized access" {
+		if errors.Is(err, models.ErrUnauthorizedAccess) {
 			// log.Printf("Unauthorized access to prescription for appointment %d", appointmentID)
-			http.Error(w, "Unauthorized access", http.StatusForbidden)
+			problem.Write(w, r, problem.CodeForbidden)
 			return
 		}
-		// log.Printf("Error retrieving prescription: %v", err)
-		http.Error(w, "Error retrieving prescription", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error retrieving prescription", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	if !recordAccess(w, r, models.AuditPrescriptionRead, "prescription:"+strconv.Itoa(prescription.ID), prescription.PatientID) {
 		return
 	}
 
@@ -441,24 +383,25 @@ func GetPrescriptionByAppointment(w http.ResponseWriter, r *http.Request) {
 func GetPrescriptionWithName(w http.ResponseWriter, r *http.Request) {
 	claims, ok := r.Context().Value(utils.UserClaimsKey).(*utils.Claims)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Write(w, r, problem.CodeUnauthenticated)
 		return
 	}
 
 	vars := mux.Vars(r)
 	prescriptionID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid prescription ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidPrescriptionID)
 		return
 	}
 
-	prescription, err := models.GetPrescriptionWithName(config.DB, prescriptionID, claims.IsDoctor)
+	prescription, err := Store.Prescriptions.GetWithName(prescriptionID, claims.IsDoctor)
 	if err != nil {
-		if err.Error() == "unauthorized access" {
-			http.Error(w, "Unauthorized access", http.StatusForbidden)
+		if errors.Is(err, models.ErrUnauthorizedAccess) {
+			problem.Write(w, r, problem.CodeForbidden)
 			return
 		}
-		http.Error(w, "Error retrieving prescription", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error retrieving prescription", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -476,7 +419,7 @@ func GetPrescriptionsByPatientNameAndDateHandler(w http.ResponseWriter, r *http.
 	// Validate that at least one parameter is provided
 	if patientName == "" && date == "" {
 		// log.Printf("No search parameters provided")
-		http.Error(w, "At least one search parameter (patientName or date) is required", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodePrescriptionSearchParams)
 		return
 	}
 
@@ -499,62 +442,86 @@ func GetPrescriptionsByPatientNameAndDateHandler(w http.ResponseWriter, r *http.
 			gregorianDate, err = utils.SolarToGregorian(date[0:]) // Remove the first digit
 			if err != nil {
 				// log.Printf("Error converting Hijri date to Gregorian: %v", err)
-				http.Error(w, "Invalid Hijri date format", http.StatusBadRequest)
+				problem.Write(w, r, problem.CodeInvalidDate)
 				return
 			}
 		case '2': // Gregorian date
 			gregorianDate, err = time.Parse("2006-01-02", date[0:]) // Remove the first digit
 			if err != nil {
 				// log.Printf("Error parsing Gregorian date: %v", err)
-				http.Error(w, "Invalid Gregorian date format", http.StatusBadRequest)
+				problem.Write(w, r, problem.CodeInvalidDate)
 				return
 			}
 		default:
 			// log.Printf("Invalid date format: first digit must be 1 (Hijri) or 2 (Gregorian)")
-			http.Error(w, "Invalid date format: first digit must be 1 (Hijri) or 2 (Gregorian)", http.StatusBadRequest)
+			problem.Write(w, r, problem.CodeInvalidDate)
 			return
 		}
 	}
 
+	// Doctors search the prescriptions of their own appointments, patients
+	// their own and those of their minor dependents.
+	claims, ok := utils.GetUserClaims(r.Context())
+	if !ok {
+		problem.Write(w, r, problem.CodeUnauthenticated)
+		return
+	}
+	var scope models.PrescriptionScope
+	switch claims.Role {
+	case utils.RoleDoctor:
+		scope.DoctorID = claims.UserID
+	case utils.RolePatient:
+		dependents, err := minorDependents(claims.UserID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error listing dependents", "err", err)
+			problem.Write(w, r, problem.CodeInternal)
+			return
+		}
+		scope.PatientIDs = append([]int{claims.UserID}, dependents...)
+	default:
+		problem.Write(w, r, problem.CodeForbidden)
+		return
+	}
+
 	// Fetch prescriptions from the database
-	prescriptions, err := models.GetPrescriptionsByPatientNameAndDate(config.DB, patientName, gregorianDate)
+	prescriptions, err := Store.Prescriptions.Search(patientName, gregorianDate, scope)
 	if err != nil {
-		// log.Printf("Error retrieving prescriptions: %v", err)
-		http.Error(w, "Error retrieving prescriptions", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error retrieving prescriptions", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
+	// Every patient whose prescriptions are returned gets an audit event
+	recorded := make(map[int]bool)
+	for _, p := range prescriptions {
+		if recorded[p.PatientID] {
+			continue
+		}
+		recorded[p.PatientID] = true
+		if !recordAccess(w, r, models.AuditPrescriptionsSearch, "patient:"+strconv.Itoa(p.PatientID)+"/prescriptions", p.PatientID) {
+			return
+		}
+	}
+
 	// Convert the response to include all fields and convert dates
 	var response []models.PrescriptionResponse
 	for _, p := range prescriptions {
 		// Convert Gregorian date to Solar (Hijri) date
 		createdAt, err := time.Parse("2006-01-02", p.CreatedAt)
 		if err != nil {
-			// log.Printf("Error parsing created_at: %v", err)
-			http.Error(w, "Error processing dates", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "error parsing created_at", "err", err)
+			problem.Write(w, r, problem.CodeInternal)
 			return
 		}
 		solarDate := utils.GregorianToSolar(createdAt)
 
 		// Fetch medications for the prescription
-		medRows, err := config.DB.Query("SELECT medicine, frequency FROM medications WHERE prescription_id = ?", p.ID)
+		medications, err := Store.Prescriptions.Medications(p.ID)
 		if err != nil {
-			// log.Printf("Error fetching medications: %v", err)
-			http.Error(w, "Error retrieving medications", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "error fetching medications", "err", err)
+			problem.Write(w, r, problem.CodeInternal)
 			return
 		}
-		defer medRows.Close()
-
-		var medications []models.Medication
-		for medRows.Next() {
-			var med models.Medication
-			if err := medRows.Scan(&med.Medicine, &med.Frequency); err != nil {
-				// log.Printf("Error scanning medication: %v", err)
-				http.Error(w, "Error reading medication", http.StatusInternalServerError)
-				return
-			}
-			medications = append(medications, med)
-		}
 
 		// Build the response
 		prescriptionResponse := models.PrescriptionResponse{
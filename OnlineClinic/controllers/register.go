@@ -3,70 +3,52 @@ package controllers
 
 import (
 	"encoding/json"
+	"log/slog"
 	"net/http"
-	"onlineClinic/config"
 	"onlineClinic/models"
-	"onlineClinic/utils"
-	"strings"
+	"onlineClinic/problem"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 func RegisterPatient(w http.ResponseWriter, r *http.Request) {
-	var patient models.Patient
-	if err := json.NewDecoder(r.Body).Decode(&patient); err != nil {
-		// log.Printf("Error decoding request: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	// Validate required fields
-	if patient.FirstName == "" || patient.LastName == "" ||
-		patient.NationalCode == "" || patient.PhoneNumber == "" ||
-		patient.Password == "" || patient.Gender == "" {
-		http.Error(w, "Required fields missing", http.StatusBadRequest)
-		return
+	var req struct {
+		models.Patient
+		VerificationCode string `json:"verificationCode"` // Sent by RequestRegistrationCode
 	}
-
-	// Validate phone number format
-	if !utils.ValidatePhoneNumber(patient.PhoneNumber) {
-		http.Error(w, "Invalid phone number format", http.StatusBadRequest)
-		return
-	}
-
-	if len(patient.PhoneNumber) != 11 {
-		http.Error(w, "Phone number must be exactly 11 digits", http.StatusBadRequest)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		// log.Printf("Error decoding request: %v", err)
+		problem.Write(w, r, problem.CodeInvalidBody)
 		return
 	}
+	patient := req.Patient
 
-	// Validate national code format
-	if !utils.ValidateNationalCode(patient.NationalCode) {
-		http.Error(w, "Invalid national code format", http.StatusBadRequest)
+	// Validate every field at once
+	if invalid(w, r, validatePatient(&patient, true)) {
 		return
 	}
 
-	// Validate gender
-	if patient.Gender != "man" && patient.Gender != "woman" {
-		http.Error(w, "Gender must be either 'man' or 'woman'", http.StatusBadRequest)
+	// Prove the phone number belongs to the registrant
+	if !verifyPhone(w, r, patient.PhoneNumber, models.VerifyRegistration, req.VerificationCode) {
 		return
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(patient.Password), bcrypt.DefaultCost)
 	if err != nil {
-		// log.Printf("Error hashing password: %v", err)
-		http.Error(w, "Error processing registration", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error hashing password", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 	patient.Password = string(hashedPassword)
 
-	if err := models.CreatePatient(config.DB, &patient); err != nil {
-		// log.Printf("Error creating patient: %v", err)
-		if strings.Contains(err.Error(), "Duplicate entry") {
-			http.Error(w, "Phone number or national code already registered", http.StatusConflict)
+	if err := Store.Patients.Create(&patient); err != nil {
+		if isDuplicateEntry(err) {
+			problem.Write(w, r, problem.CodeAlreadyRegistered)
 			return
 		}
-		http.Error(w, "Error registering patient", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error registering patient", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -74,59 +56,43 @@ func RegisterPatient(w http.ResponseWriter, r *http.Request) {
 }
 
 func RegisterDoctor(w http.ResponseWriter, r *http.Request) {
-	var doctor models.Doctor
-	if err := json.NewDecoder(r.Body).Decode(&doctor); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	// Validate required fields
-	if doctor.FirstName == "" || doctor.LastName == "" ||
-		doctor.NationalCode == "" || doctor.PhoneNumber == "" ||
-		doctor.Password == "" || doctor.Gender == "" ||
-		doctor.MedicalCouncilCode == nil || *doctor.MedicalCouncilCode == "" { // Added validation
-		http.Error(w, "Required fields missing", http.StatusBadRequest)
-		return
+	var req struct {
+		models.Doctor
+		VerificationCode string `json:"verificationCode"` // Sent by RequestRegistrationCode
 	}
-
-	// Validate phone number format
-	if !utils.ValidatePhoneNumber(doctor.PhoneNumber) {
-		http.Error(w, "Invalid phone number format", http.StatusBadRequest)
-		return
-	}
-
-	if len(doctor.PhoneNumber) != 11 {
-		http.Error(w, "Phone number must be exactly 11 digits", http.StatusBadRequest)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.CodeInvalidBody)
 		return
 	}
+	doctor := req.Doctor
 
-	// Validate national code format
-	if !utils.ValidateNationalCode(doctor.NationalCode) {
-		http.Error(w, "Invalid national code format", http.StatusBadRequest)
+	// Validate every field at once
+	if invalid(w, r, validateDoctor(&doctor, true)) {
 		return
 	}
 
-	// Validate gender
-	if doctor.Gender != "man" && doctor.Gender != "woman" {
-		http.Error(w, "Gender must be either 'man' or 'woman'", http.StatusBadRequest)
+	// Prove the phone number belongs to the registrant
+	if !verifyPhone(w, r, doctor.PhoneNumber, models.VerifyRegistration, req.VerificationCode) {
 		return
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(doctor.Password), bcrypt.DefaultCost)
 	if err != nil {
-		http.Error(w, "Error processing registration", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error processing registration", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 	doctor.Password = string(hashedPassword)
 
 	// Create doctor using stored procedure
-	if err := models.CreateDoctor(config.DB, &doctor); err != nil {
-		if strings.Contains(err.Error(), "Duplicate entry") {
-			http.Error(w, "Phone number or national code already registered", http.StatusConflict)
+	if err := Store.Doctors.Create(&doctor); err != nil {
+		if isDuplicateEntry(err) {
+			problem.Write(w, r, problem.CodeAlreadyRegistered)
 			return
 		}
-		http.Error(w, "Error registering doctor", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error registering doctor", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -0,0 +1,147 @@
+// controllers/session.go
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"onlineClinic/config"
+	"onlineClinic/models"
+	"onlineClinic/problem"
+	"onlineClinic/utils"
+	"time"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"` // Seconds until the access token expires
+}
+
+// startSession creates a login session and fills in the role and tokens of
+// resp. It writes the error response itself and returns false on failure.
+func startSession(w http.ResponseWriter, r *http.Request, resp *LoginResponse, userID int, role utils.Role) bool {
+	session, refreshToken, err := Store.Sessions.Create(userID, string(role), r.UserAgent(), utils.ClientIP(r), config.Cfg.RefreshTokenTTL)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating session", "role", role, "user_id", userID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return false
+	}
+
+	token, err := utils.GenerateToken(userID, resp.PhoneNumber, role, session.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error generating token", "role", role, "user_id", userID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return false
+	}
+
+	resp.Role = string(role)
+	resp.Token = token
+	resp.RefreshToken = refreshToken
+	resp.ExpiresIn = int(config.Cfg.AccessTokenTTL / time.Second)
+	return true
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. Each refresh token works once; presenting a used one again
+// ends its session.
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		problem.Write(w, r, problem.CodeInvalidBody)
+		return
+	}
+
+	session, refreshToken, err := Store.Sessions.Rotate(req.RefreshToken)
+	switch {
+	case errors.Is(err, models.ErrRefreshTokenReused):
+		slog.WarnContext(r.Context(), "refresh token reused, session revoked", "session_id", session.ID, "role", session.Role, "user_id", session.UserID)
+		problem.Write(w, r, problem.CodeInvalidRefreshToken)
+		return
+	case errors.Is(err, models.ErrSessionInvalid):
+		problem.Write(w, r, problem.CodeInvalidRefreshToken)
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "error rotating refresh token", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	// The account may have been changed since the session started.
+	var phoneNumber string
+	var deactivatedAt *time.Time
+	roleChanged := false
+	switch utils.Role(session.Role) {
+	case utils.RoleDoctor:
+		var doctor *models.Doctor
+		if doctor, err = Store.Doctors.GetByID(session.UserID); err == nil {
+			phoneNumber = doctor.PhoneNumber
+			deactivatedAt, err = Store.Doctors.DeactivatedAt(session.UserID)
+		}
+	case utils.RolePatient:
+		var patient *models.Patient
+		if patient, err = Store.Patients.GetByID(session.UserID); err == nil {
+			phoneNumber = patient.PhoneNumber
+			deactivatedAt, err = Store.Patients.DeactivatedAt(session.UserID)
+		}
+	default:
+		var staff *models.Staff
+		if staff, err = Store.Staff.GetByID(session.UserID); err == nil {
+			phoneNumber = staff.PhoneNumber
+			roleChanged = staff.Role != session.Role
+			deactivatedAt, err = Store.Staff.DeactivatedAt(session.UserID)
+		}
+	}
+	if err == sql.ErrNoRows || (err == nil && (deactivatedAt != nil || roleChanged)) {
+		if err := Store.Sessions.Revoke(session.ID); err != nil {
+			slog.ErrorContext(r.Context(), "error revoking session", "session_id", session.ID, "err", err)
+		}
+		problem.Write(w, r, problem.CodeInvalidRefreshToken)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error loading account for refresh", "role", session.Role, "user_id", session.UserID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	token, err := utils.GenerateToken(session.UserID, phoneNumber, utils.Role(session.Role), session.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error generating token", "role", session.Role, "user_id", session.UserID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(config.Cfg.AccessTokenTTL / time.Second),
+	})
+}
+
+// Logout ends the session of the access token, which also invalidates its
+// refresh token.
+func Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r.Context())
+	if !ok {
+		problem.Write(w, r, problem.CodeUnauthenticated)
+		return
+	}
+
+	if err := Store.Sessions.Revoke(claims.SessionID); err != nil {
+		slog.ErrorContext(r.Context(), "error revoking session", "session_id", claims.SessionID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out successfully"})
+}
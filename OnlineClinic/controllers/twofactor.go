@@ -0,0 +1,427 @@
+// controllers/twofactor.go
+package controllers
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"image/png"
+	"log/slog"
+	"net/http"
+	"onlineClinic/config"
+	"onlineClinic/models"
+	"onlineClinic/problem"
+	"onlineClinic/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	// totpIssuer names the clinic in authenticator apps.
+	totpIssuer = "Online Clinic"
+
+	// totpPeriod is the RFC 6238 time step, in seconds. Codes of the steps
+	// before and after the current one are accepted too, for clock drift.
+	totpPeriod = 30
+
+	// totpQRSize is the width and height of enrollment QR codes, in pixels.
+	totpQRSize = 256
+
+	// twoFactorChallengeTTL is how long a doctor has to enter the code after
+	// the password.
+	twoFactorChallengeTTL = 5 * time.Minute
+)
+
+// totpOptions are the settings authenticator apps assume when an otpauth
+// URI does not name them.
+var totpOptions = totp.ValidateOpts{Period: totpPeriod, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauthUri"`
+	QRCode     string `json:"qrCode"` // PNG data URL of OtpauthURI
+}
+
+// TwoFactorChallengeResponse is what LoginDoctor returns instead of tokens
+// when a code is needed. The challenge token goes to LoginDoctorTwoFactor.
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	ChallengeToken    string `json:"challengeToken"`
+	ExpiresIn         int    `json:"expiresIn"` // Seconds until the challenge token expires
+	// Setup is set when the policy requires two-factor authentication and
+	// the doctor has not enrolled yet. The first code enables it.
+	Setup *TOTPSetupResponse `json:"setup,omitempty"`
+}
+
+// TwoFactorCodeRequest carries either a code from the authenticator app or
+// a recovery code.
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+	TwoFactorCodeRequest
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// requireSecondFactor writes a challenge instead of tokens if doctor has
+// enabled TOTP or the policy requires it. It reports whether it wrote a
+// response, which may also be an error.
+func requireSecondFactor(w http.ResponseWriter, r *http.Request, doctor *models.Doctor) bool {
+	t, err := Store.TOTP.Get(doctor.ID)
+	if err != nil && err != sql.ErrNoRows {
+		slog.ErrorContext(r.Context(), "error loading doctor TOTP", "doctor_id", doctor.ID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return true
+	}
+
+	resp := TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ExpiresIn:         int(twoFactorChallengeTTL / time.Second),
+	}
+	switch {
+	case err == nil && t.Enabled():
+	case config.Cfg.DoctorTwoFactorRequired:
+		setup, ok := beginTOTPEnrollment(w, r, doctor)
+		if !ok {
+			return true
+		}
+		resp.Setup = setup
+	default:
+		return false
+	}
+
+	resp.ChallengeToken, err = utils.GenerateChallengeToken(doctor.ID, doctor.PhoneNumber, twoFactorChallengeTTL)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error generating challenge token", "doctor_id", doctor.ID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+	return true
+}
+
+// LoginDoctorTwoFactor completes a doctor login that LoginDoctor answered
+// with a challenge. Wrong codes count as failed logins of the account.
+func LoginDoctorTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ChallengeToken == "" {
+		problem.Write(w, r, problem.CodeInvalidBody)
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		problem.Write(w, r, problem.CodeTwoFactorCodeRequired)
+		return
+	}
+
+	challenge, err := utils.VerifyChallengeToken(req.ChallengeToken)
+	if err != nil {
+		slog.WarnContext(r.Context(), "challenge token verification failed", "err", err)
+		problem.Write(w, r, problem.CodeTwoFactorInvalidChallenge)
+		return
+	}
+
+	account := loginAccount(accountDoctor, challenge.PhoneNumber)
+	if !loginAllowed(w, r, account) {
+		return
+	}
+
+	// The account may have been changed since the password was checked.
+	doctor, err := Store.Doctors.GetByID(challenge.UserID)
+	var t *models.DoctorTOTP
+	if err == nil {
+		t, err = Store.TOTP.Get(doctor.ID)
+	}
+	if err == sql.ErrNoRows || (err == nil && doctor.PhoneNumber != challenge.PhoneNumber) {
+		problem.Write(w, r, problem.CodeTwoFactorInvalidChallenge)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error loading doctor for two-factor login", "doctor_id", challenge.UserID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	deactivatedAt, err := Store.Doctors.DeactivatedAt(doctor.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error checking doctor account status", "doctor_id", doctor.ID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+	if deactivatedAt != nil {
+		problem.Write(w, r, problem.CodeAccountDeactivated)
+		return
+	}
+
+	ok, usedRecoveryCode, err := checkSecondFactor(t, req.TwoFactorCodeRequest)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error checking two-factor code", "doctor_id", doctor.ID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+	if !ok {
+		loginFailed(r, account)
+		problem.Write(w, r, problem.CodeTwoFactorInvalidCode)
+		return
+	}
+	loginSucceeded(r, account)
+	if usedRecoveryCode {
+		recordTwoFactorEvent(r, models.AuditTwoFactorRecoveryCode, doctor.ID)
+	}
+
+	// The first code of an enrollment required by the policy enables it.
+	var recoveryCodes []string
+	if !t.Enabled() {
+		if recoveryCodes, err = Store.TOTP.Enable(doctor.ID); err != nil {
+			slog.ErrorContext(r.Context(), "error enabling doctor TOTP", "doctor_id", doctor.ID, "err", err)
+			problem.Write(w, r, problem.CodeInternal)
+			return
+		}
+		recordTwoFactorEvent(r, models.AuditTwoFactorEnabled, doctor.ID)
+	}
+
+	writeDoctorLogin(w, r, doctor, recoveryCodes)
+}
+
+// SetupTOTP starts a TOTP enrollment for the logged-in doctor, replacing
+// one that was not confirmed yet. EnableTOTP confirms it.
+func SetupTOTP(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r.Context())
+	if !ok {
+		problem.Write(w, r, problem.CodeUnauthenticated)
+		return
+	}
+
+	doctor, err := Store.Doctors.GetByID(claims.UserID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error loading doctor", "doctor_id", claims.UserID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	setup, ok := beginTOTPEnrollment(w, r, doctor)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(setup)
+}
+
+// EnableTOTP confirms the enrollment of the logged-in doctor with a code
+// from the authenticator app and returns the recovery codes.
+func EnableTOTP(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r.Context())
+	if !ok {
+		problem.Write(w, r, problem.CodeUnauthenticated)
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		problem.Write(w, r, problem.CodeInvalidBody)
+		return
+	}
+
+	t, err := Store.TOTP.Get(claims.UserID)
+	if err == sql.ErrNoRows {
+		problem.Write(w, r, problem.CodeTwoFactorNotEnrolling)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error loading doctor TOTP", "doctor_id", claims.UserID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+	if t.Enabled() {
+		problem.Write(w, r, problem.CodeTwoFactorAlreadyEnabled)
+		return
+	}
+
+	valid, _, err := checkSecondFactor(t, TwoFactorCodeRequest{Code: req.Code})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error checking two-factor code", "doctor_id", claims.UserID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+	if !valid {
+		problem.Write(w, r, problem.CodeTwoFactorInvalidCode)
+		return
+	}
+
+	recoveryCodes, err := Store.TOTP.Enable(claims.UserID)
+	if err == sql.ErrNoRows {
+		problem.Write(w, r, problem.CodeTwoFactorNotEnrolling)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error enabling doctor TOTP", "doctor_id", claims.UserID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+	recordTwoFactorEvent(r, models.AuditTwoFactorEnabled, claims.UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+// DisableTOTP turns two-factor authentication off for the logged-in doctor
+// after checking a code or recovery code, unless the policy requires it.
+// Wrong codes count as failed logins, so a stolen access token cannot be
+// used to guess them.
+func DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r.Context())
+	if !ok {
+		problem.Write(w, r, problem.CodeUnauthenticated)
+		return
+	}
+	if config.Cfg.DoctorTwoFactorRequired {
+		problem.Write(w, r, problem.CodeTwoFactorRequired)
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		problem.Write(w, r, problem.CodeInvalidBody)
+		return
+	}
+
+	t, err := Store.TOTP.Get(claims.UserID)
+	if err == sql.ErrNoRows || (err == nil && !t.Enabled()) {
+		problem.Write(w, r, problem.CodeTwoFactorNotEnabled)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error loading doctor TOTP", "doctor_id", claims.UserID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	account := loginAccount(accountDoctor, claims.PhoneNumber)
+	if !loginAllowed(w, r, account) {
+		return
+	}
+	valid, _, err := checkSecondFactor(t, req)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error checking two-factor code", "doctor_id", claims.UserID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+	if !valid {
+		loginFailed(r, account)
+		problem.Write(w, r, problem.CodeTwoFactorInvalidCode)
+		return
+	}
+	loginSucceeded(r, account)
+
+	if err := Store.TOTP.Disable(claims.UserID); err != nil {
+		slog.ErrorContext(r.Context(), "error disabling doctor TOTP", "doctor_id", claims.UserID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+	recordTwoFactorEvent(r, models.AuditTwoFactorDisabled, claims.UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// beginTOTPEnrollment generates and stores a new secret for doctor. It
+// writes the error response itself and returns false on failure.
+func beginTOTPEnrollment(w http.ResponseWriter, r *http.Request, doctor *models.Doctor) (*TOTPSetupResponse, bool) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: doctor.PhoneNumber,
+		Period:      totpPeriod,
+		Digits:      totpOptions.Digits,
+		Algorithm:   totpOptions.Algorithm,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error generating TOTP secret", "doctor_id", doctor.ID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return nil, false
+	}
+
+	img, err := key.Image(totpQRSize, totpQRSize)
+	var qr bytes.Buffer
+	if err == nil {
+		err = png.Encode(&qr, img)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering TOTP QR code", "doctor_id", doctor.ID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return nil, false
+	}
+
+	if err := Store.TOTP.Begin(doctor.ID, key.Secret()); err != nil {
+		writeError(w, r, err, "error storing TOTP secret")
+		return nil, false
+	}
+
+	return &TOTPSetupResponse{
+		Secret:     key.Secret(),
+		OtpauthURI: key.URL(),
+		QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(qr.Bytes()),
+	}, true
+}
+
+// checkSecondFactor checks the code or, once TOTP is enabled, the recovery
+// code in req. Both work only once. usedRecoveryCode tells which one it was.
+func checkSecondFactor(t *models.DoctorTOTP, req TwoFactorCodeRequest) (ok, usedRecoveryCode bool, err error) {
+	if code := strings.TrimSpace(req.Code); code != "" {
+		step, matched := matchTOTPCode(t.Secret, code, time.Now())
+		if !matched {
+			return false, false, nil
+		}
+		ok, err = Store.TOTP.UseStep(t.DoctorID, step)
+		return ok, false, err
+	}
+	if req.RecoveryCode != "" && t.Enabled() {
+		ok, err = Store.TOTP.UseRecoveryCode(t.DoctorID, req.RecoveryCode)
+		return ok, ok, err
+	}
+	return false, false, nil
+}
+
+// matchTOTPCode returns the time step whose code for secret is code,
+// trying the current step and its neighbours.
+func matchTOTPCode(secret, code string, now time.Time) (int64, bool) {
+	current := now.Unix() / totpPeriod
+	for _, step := range []int64{current, current - 1, current + 1} {
+		want, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), totpOptions)
+		if err == nil && subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// recordTwoFactorEvent writes a change of a doctor's second factor to the
+// audit trail.
+func recordTwoFactorEvent(r *http.Request, action string, doctorID int) {
+	event := &models.AuditEvent{
+		Action:    action,
+		ActorRole: string(utils.RoleDoctor),
+		ActorID:   &doctorID,
+		Subject:   "doctor:" + strconv.Itoa(doctorID),
+		IPAddress: utils.ClientIP(r),
+	}
+	if err := Store.Audit.Record(event); err != nil {
+		slog.ErrorContext(r.Context(), "error recording two-factor change in audit trail", "action", action, "err", err)
+	}
+}
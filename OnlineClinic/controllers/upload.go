@@ -3,67 +3,61 @@ package controllers
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"onlineClinic/config"
+	"onlineClinic/metrics"
+	"onlineClinic/problem"
 	"onlineClinic/utils"
+	"strings"
 )
 
 const (
 	MaxUploadSize = 10 << 20 // 10 MB
 )
 
-func setCORSHeaders(w http.ResponseWriter, origin string) {
-	w.Header().Set("Access-Control-Allow-Origin", origin)
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Origin, Accept")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-}
-
 func UploadProfilePhoto(w http.ResponseWriter, r *http.Request) {
 	// log.Println("Starting profile photo upload...")
 
 	// Set CORS headers for all responses
-	setCORSHeaders(w, "https://kashan-clininc.liara.run")
+	utils.SetCORSHeaders(w, r)
 
 	claims, ok := utils.GetUserClaims(r.Context())
 	if !ok {
 		// log.Println("No claims found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Write(w, r, problem.CodeUnauthenticated)
 		return
 	}
 
 	if err := r.ParseMultipartForm(MaxUploadSize); err != nil {
 		// log.Printf("Error parsing multipart form: %v", err)
-		http.Error(w, "File too large or invalid form data", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidForm)
 		return
 	}
 
 	file, header, err := r.FormFile("photo")
 	if err != nil {
 		// log.Printf("Error getting photo from form: %v", err)
-		http.Error(w, "Error retrieving file", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeFileMissing)
 		return
 	}
 	defer file.Close()
 
 	filePath, err := utils.SaveFile(file, header, claims.UserID, "profile", claims.IsDoctor)
 	if err != nil {
-		// log.Printf("Error saving file: %v", err)
-		http.Error(w, fmt.Sprintf("Error saving file: %v", err), http.StatusBadRequest)
+		writeError(w, r, err, "error saving profile photo")
 		return
 	}
+	metrics.UploadBytes.WithLabelValues("profile").Add(float64(header.Size))
 
-	var updateQuery string
 	if claims.IsDoctor {
-		updateQuery = "UPDATE doctors SET profile_photo_path = ? WHERE id = ?"
+		err = Store.Doctors.UpdatePhoto(claims.UserID, filePath)
 	} else {
-		updateQuery = "UPDATE patients SET profile_photo_path = ? WHERE id = ?"
+		err = Store.Patients.UpdatePhoto(claims.UserID, filePath)
 	}
-
-	_, err = config.DB.Exec(updateQuery, filePath, claims.UserID)
 	if err != nil {
-		// log.Printf("Error updating database: %v", err)
-		http.Error(w, "Error updating profile photo", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error updating database", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 
@@ -75,8 +69,8 @@ func UploadProfilePhoto(w http.ResponseWriter, r *http.Request) {
 		"message": "Profile photo uploaded successfully",
 		"path":    filePath,
 	}); err != nil {
-		// log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error encoding response", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 }
@@ -85,37 +79,37 @@ func UploadChatFile(w http.ResponseWriter, r *http.Request) {
 	// log.Println("Starting chat file upload...")
 
 	// Set CORS headers for all responses
-	// setCORSHeaders(w, "https://kashan-clininc.liara.run")
+	// utils.SetCORSHeaders(w, r)
 
 	claims, ok := utils.GetUserClaims(r.Context())
 	if !ok {
 		// log.Println("No claims found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Write(w, r, problem.CodeUnauthenticated)
 		return
 	}
 
 	if err := r.ParseMultipartForm(MaxUploadSize); err != nil {
 		// log.Printf("Error parsing multipart form: %v", err)
-		http.Error(w, "File too large or invalid form data", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeInvalidForm)
 		return
 	}
 
 	file, header, err := r.FormFile("file")
 	if err != nil {
 		// log.Printf("Error getting file from form: %v", err)
-		http.Error(w, "Error retrieving file", http.StatusBadRequest)
+		problem.Write(w, r, problem.CodeFileMissing)
 		return
 	}
 	defer file.Close()
 
 	filePath, err := utils.SaveChatFile(file, header, claims.UserID, claims.IsDoctor)
 	if err != nil {
-		// log.Printf("Error saving file: %v", err)
-		http.Error(w, fmt.Sprintf("Error saving file: %v", err), http.StatusBadRequest)
+		writeError(w, r, err, "error saving chat file")
 		return
 	}
+	metrics.UploadBytes.WithLabelValues("chat").Add(float64(header.Size))
 
-	baseURL := "https://online-clinic.liara.run/uploads"
+	baseURL := strings.TrimRight(config.Cfg.BaseURL, "/") + "/uploads"
 	fullURL := fmt.Sprintf("%s/%s", baseURL, filePath)
 
 	response := map[string]string{
@@ -126,8 +120,8 @@ func UploadChatFile(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		// log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error encoding response", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
 		return
 	}
 }
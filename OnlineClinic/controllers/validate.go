@@ -0,0 +1,127 @@
+// controllers/validate.go
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"onlineClinic/models"
+	"onlineClinic/problem"
+	"onlineClinic/utils"
+	"onlineClinic/validation"
+	"strconv"
+	"strings"
+)
+
+// invalid writes err, the result of a validation, as a problem listing
+// the failing fields and reports whether there was an error.
+func invalid(w http.ResponseWriter, r *http.Request, err error) bool {
+	if err == nil {
+		return false
+	}
+	var fields validation.Errors
+	if errors.As(err, &fields) {
+		problem.WriteValidation(w, r, fields)
+	} else {
+		problem.WriteDetail(w, r, problem.CodeValidationFailed, err.Error())
+	}
+	return true
+}
+
+// validateAccount checks the fields patients and doctors share. The
+// national code and password are only required on registration.
+func validateAccount(v *validation.Validator, firstName, lastName, nationalCode, gender, phone, password string, registering bool) {
+	v.Name("firstName", firstName)
+	v.Name("lastName", lastName)
+	if registering || nationalCode != "" {
+		v.NationalCode("nationalCode", nationalCode)
+	}
+	if registering || gender != "" {
+		v.Gender("gender", gender)
+	}
+	v.Mobile("phoneNumber", phone)
+	if registering {
+		validatePassword(v, "password", password)
+	}
+}
+
+// validatePassword checks a password being set, on registration or as a
+// new password later.
+func validatePassword(v *validation.Validator, field, password string) {
+	v.Required(field, password)
+	// bcrypt ignores everything past 72 bytes.
+	v.Check(len(password) <= 72, field, "must be at most 72 bytes")
+}
+
+func validatePatient(p *models.Patient, registering bool) error {
+	var v validation.Validator
+	validateAccount(&v, p.FirstName, p.LastName, p.NationalCode, p.Gender, p.PhoneNumber, p.Password, registering)
+	v.Age("age", p.Age, validation.MinPatientAge, validation.MaxPatientAge)
+	optionalMaxLength(&v, "job", p.Job, 100)
+	optionalMaxLength(&v, "education", p.Education, 100)
+	optionalMaxLength(&v, "address", p.Address, 255)
+	return v.Err()
+}
+
+func validateDoctor(d *models.Doctor, registering bool) error {
+	var v validation.Validator
+	validateAccount(&v, d.FirstName, d.LastName, d.NationalCode, d.Gender, d.PhoneNumber, d.Password, registering)
+	v.Age("age", d.Age, validation.MinDoctorAge, validation.MaxDoctorAge)
+	if d.MedicalCouncilCode == nil {
+		v.Required("medicalCouncilCode", "")
+	} else {
+		v.Required("medicalCouncilCode", *d.MedicalCouncilCode)
+		v.MaxLength("medicalCouncilCode", *d.MedicalCouncilCode, 20)
+	}
+	optionalMaxLength(&v, "education", d.Education, 100)
+	optionalMaxLength(&v, "address", d.Address, 255)
+	return v.Err()
+}
+
+func validateAppointmentRequest(req *models.AppointmentRequest) error {
+	var v validation.Validator
+	positiveID(&v, "doctorId", req.DoctorID)
+	positiveID(&v, "patientId", req.PatientID)
+	v.Check(req.Type == "online" || req.Type == "آنلاین" || req.Type == "in-person", "type", "must be 'online' or 'in-person'")
+	v.Required("date", req.Date)
+	v.Check(utils.IsSolarDateValid(req.Date), "date", "must be a Solar date such as 1403-08-23")
+	v.Required("time", req.Time)
+	v.Check(clockTime(req.Time), "time", "must be a time such as 12:00")
+	return v.Err()
+}
+
+func validatePrescriptionRequest(req *models.PrescriptionRequest) error {
+	var v validation.Validator
+	v.Check(req.AppointmentID > 0, "appointmentId", "is required")
+	v.Check(len(req.Medications) > 0, "medications", "must list at least one medicine")
+	for i, m := range req.Medications {
+		field := "medications[" + strconv.Itoa(i) + "]"
+		v.Required(field+".medicine", m.Medicine)
+		v.MaxLength(field+".medicine", m.Medicine, 255)
+		v.MaxLength(field+".frequency", m.Frequency, 255)
+	}
+	v.MaxLength("instructions", req.Instructions, 2000)
+	return v.Err()
+}
+
+func optionalMaxLength(v *validation.Validator, field string, value *string, max int) {
+	if value != nil {
+		v.MaxLength(field, *value, max)
+	}
+}
+
+func positiveID(v *validation.Validator, field, value string) {
+	v.Required(field, value)
+	id, err := strconv.Atoi(value)
+	v.Check(err == nil && id > 0, field, "must be a positive number")
+}
+
+// clockTime reports whether s is a time of day as HH:MM.
+func clockTime(s string) bool {
+	hour, minute, ok := strings.Cut(s, ":")
+	if !ok || len(hour) != 2 || len(minute) != 2 {
+		return false
+	}
+	h, err1 := strconv.Atoi(hour)
+	m, err2 := strconv.Atoi(minute)
+	return err1 == nil && err2 == nil && h >= 0 && h < 24 && m >= 0 && m < 60
+}
@@ -0,0 +1,136 @@
+// controllers/verification.go
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"onlineClinic/config"
+	"onlineClinic/models"
+	"onlineClinic/problem"
+	"onlineClinic/services"
+	"onlineClinic/utils"
+	"onlineClinic/validation"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// verificationCooldown is how long a phone number has to wait for
+	// another code, which keeps the code endpoints from sending SMS floods.
+	verificationCooldown = time.Minute
+
+	// verificationMaxAttempts is how often a code may be entered wrong
+	// before a new one must be requested.
+	verificationMaxAttempts = 5
+)
+
+// SMS delivers verification codes; it is set by main.
+var SMS services.SMSSender
+
+type VerificationCodeRequest struct {
+	PhoneNumber string `json:"phoneNumber"`
+}
+
+// RequestRegistrationCode sends the code that RegisterPatient and
+// RegisterDoctor require to the phone number being registered.
+func RequestRegistrationCode(w http.ResponseWriter, r *http.Request) {
+	var req VerificationCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.CodeInvalidBody)
+		return
+	}
+	req.PhoneNumber = strings.TrimSpace(req.PhoneNumber)
+	var v validation.Validator
+	v.Mobile("phoneNumber", req.PhoneNumber)
+	if invalid(w, r, v.Err()) {
+		return
+	}
+
+	sendVerificationCode(w, r, req.PhoneNumber, models.VerifyRegistration)
+}
+
+// RequestPhoneChangeCode sends a code to the new phone number of the
+// logged-in doctor or patient. The code is then passed to the profile
+// update that changes the number.
+func RequestPhoneChangeCode(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r.Context())
+	if !ok {
+		problem.Write(w, r, problem.CodeUnauthenticated)
+		return
+	}
+
+	var req VerificationCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.CodeInvalidBody)
+		return
+	}
+	req.PhoneNumber = strings.TrimSpace(req.PhoneNumber)
+	var v validation.Validator
+	v.Mobile("phoneNumber", req.PhoneNumber)
+	if invalid(w, r, v.Err()) {
+		return
+	}
+	if req.PhoneNumber == claims.PhoneNumber {
+		problem.Write(w, r, problem.CodePhoneUnchanged)
+		return
+	}
+
+	sendVerificationCode(w, r, req.PhoneNumber, models.VerifyPhoneChange(string(claims.Role), claims.UserID))
+}
+
+// sendVerificationCode issues a code for phoneNumber and purpose, sends it
+// by SMS and writes the response.
+func sendVerificationCode(w http.ResponseWriter, r *http.Request, phoneNumber, purpose string) {
+	err := issueVerificationCode(r, phoneNumber, purpose)
+	if errors.Is(err, models.ErrVerificationCooldown) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(verificationCooldown/time.Second)))
+		problem.Write(w, r, problem.CodeVerificationCooldown)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error sending verification code", "purpose", purpose, "err", err)
+		problem.Write(w, r, problem.CodeVerificationSendFailed)
+		return
+	}
+	writeCodeSent(w)
+}
+
+// issueVerificationCode issues a code for phoneNumber and purpose and sends
+// it by SMS.
+func issueVerificationCode(r *http.Request, phoneNumber, purpose string) error {
+	code, err := Store.Verifications.Issue(phoneNumber, purpose, config.Cfg.VerificationCodeTTL, verificationCooldown)
+	if err != nil {
+		return err
+	}
+	text := fmt.Sprintf("Your OnlineClinic verification code is %s. It expires in %d minutes.",
+		code, int(config.Cfg.VerificationCodeTTL/time.Minute))
+	return SMS.SendSMS(r.Context(), phoneNumber, text)
+}
+
+func writeCodeSent(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"message":   "Verification code sent",
+		"expiresIn": int(config.Cfg.VerificationCodeTTL / time.Second),
+	})
+}
+
+// verifyPhone checks and uses up a code sent to phoneNumber for purpose. It
+// writes the error response itself and returns false if the code is wrong.
+func verifyPhone(w http.ResponseWriter, r *http.Request, phoneNumber, purpose, code string) bool {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		problem.Write(w, r, problem.CodeVerificationRequired)
+		return false
+	}
+
+	if err := Store.Verifications.Verify(phoneNumber, purpose, code, verificationMaxAttempts); err != nil {
+		writeError(w, r, err, "error checking verification code")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,153 @@
+// controllers/ws_auth.go
+package controllers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"onlineClinic/config"
+	"onlineClinic/models"
+	"onlineClinic/problem"
+	"onlineClinic/utils"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/websocket"
+)
+
+// Browsers cannot set headers on a WebSocket handshake, so besides tickets
+// /ws accepts the access token as a subprotocol: a client offers
+// wsSubprotocol and "bearer.<token>", and the server only ever selects
+// wsSubprotocol, so the token is not echoed back.
+const (
+	wsSubprotocol = "onlineclinic"
+	wsBearer      = "bearer."
+)
+
+type WSTicketResponse struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// IssueWSTicket returns a ticket that opens the chat WebSocket once, as
+// /ws?ticket=..., within the configured ws_ticket_ttl. The connection lasts
+// no longer than the access token the ticket was issued for.
+func IssueWSTicket(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r.Context())
+	if !ok {
+		problem.Write(w, r, problem.CodeUnauthenticated)
+		return
+	}
+
+	ticket, err := Store.WSTickets.Issue(&models.WSTicket{
+		SessionID:      claims.SessionID,
+		UserID:         claims.UserID,
+		Role:           string(claims.Role),
+		PhoneNumber:    claims.PhoneNumber,
+		TokenExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}, config.Cfg.WSTicketTTL)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error issuing websocket ticket", "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(WSTicketResponse{
+		Ticket:    ticket,
+		ExpiresAt: time.Now().Add(config.Cfg.WSTicketTTL).UTC().Truncate(time.Second),
+	})
+}
+
+// checkWsOrigin lets browsers connect only from the configured CORS
+// origins. Clients that are not browsers send no Origin and are let through;
+// they still have to authenticate.
+func checkWsOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || utils.IsAllowedOrigin(origin)
+}
+
+// authenticateWs returns the claims of a WebSocket handshake, from a ticket
+// or a bearer subprotocol. It writes a problem and returns false if there
+// are none or they are not valid.
+func authenticateWs(w http.ResponseWriter, r *http.Request) (*utils.Claims, bool) {
+	if ticket := r.URL.Query().Get("ticket"); ticket != "" {
+		return redeemWsTicket(w, r, ticket)
+	}
+
+	if token, ok := wsBearerToken(r); ok {
+		claims, err := utils.VerifyToken(token)
+		if err != nil {
+			slog.WarnContext(r.Context(), "websocket token verification failed", "err", err)
+			problem.Write(w, r, problem.CodeInvalidToken)
+			return nil, false
+		}
+		return claims, true
+	}
+
+	// Tokens in the URL end up in access logs and browser history.
+	if r.URL.Query().Has("token") {
+		problem.WriteDetail(w, r, problem.CodeUnauthenticated,
+			"Access tokens are not accepted in the URL; use a ticket from POST /api/ws/ticket or the "+wsSubprotocol+" subprotocol")
+		return nil, false
+	}
+	problem.Write(w, r, problem.CodeUnauthenticated)
+	return nil, false
+}
+
+// redeemWsTicket uses up ticket and returns the claims of the access token
+// it was issued for, which must not have expired or been revoked since.
+func redeemWsTicket(w http.ResponseWriter, r *http.Request, ticket string) (*utils.Claims, bool) {
+	t, err := Store.WSTickets.Redeem(ticket)
+	if err != nil {
+		writeError(w, r, err, "error redeeming websocket ticket")
+		return nil, false
+	}
+
+	if !time.Now().Before(t.TokenExpiresAt) {
+		problem.Write(w, r, problem.CodeInvalidToken)
+		return nil, false
+	}
+	revoked, err := utils.Revocations.Revoked(t.SessionID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error checking session", "session_id", t.SessionID, "err", err)
+		problem.Write(w, r, problem.CodeInternal)
+		return nil, false
+	}
+	if revoked {
+		problem.Write(w, r, problem.CodeInvalidToken)
+		return nil, false
+	}
+
+	role := utils.Role(t.Role)
+	return &utils.Claims{
+		UserID:      t.UserID,
+		PhoneNumber: t.PhoneNumber,
+		IsDoctor:    role == utils.RoleDoctor,
+		IsPatient:   role == utils.RolePatient,
+		SessionID:   t.SessionID,
+		Role:        role,
+		Permissions: utils.PermissionsOf(role),
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: t.TokenExpiresAt.Unix(),
+		},
+	}, true
+}
+
+// wsBearerToken returns the access token offered as a "bearer." subprotocol
+// next to wsSubprotocol.
+func wsBearerToken(r *http.Request) (string, bool) {
+	var token string
+	offered := false
+	for _, protocol := range websocket.Subprotocols(r) {
+		switch {
+		case protocol == wsSubprotocol:
+			offered = true
+		case strings.HasPrefix(protocol, wsBearer):
+			token = strings.TrimPrefix(protocol, wsBearer)
+		}
+	}
+	return token, offered && token != ""
+}
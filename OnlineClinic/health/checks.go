@@ -0,0 +1,49 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"onlineClinic/migrations"
+)
+
+// Database checks that db answers a ping.
+func Database(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// Writable checks that a file can be created in dir.
+func Writable(dir string) Check {
+	return func(ctx context.Context) error {
+		f, err := os.CreateTemp(dir, ".healthcheck-*")
+		if err != nil {
+			return err
+		}
+		name := f.Name()
+		f.Close()
+		return os.Remove(name)
+	}
+}
+
+// Migrations checks that every embedded migration for driver has been
+// applied to db.
+func Migrations(driver string, db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		m, err := migrations.NewForDriver(driver, db)
+		if err != nil {
+			return err
+		}
+		pending, err := m.Pending()
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending migration(s), first is %04d_%s", len(pending), pending[0].Version, pending[0].Name)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,109 @@
+// Package health serves the liveness (/healthz) and readiness (/readyz)
+// endpoints.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Check reports a problem with one dependency of the server.
+type Check func(ctx context.Context) error
+
+// Result is the outcome of one check.
+type Result struct {
+	Status     string  `json:"status"` // "ok" or "fail"
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Report is the JSON body served by a Checker.
+type Report struct {
+	Status string            `json:"status"` // "ok" only if every check passed
+	Checks map[string]Result `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs a set of named checks, each bounded by Timeout.
+type Checker struct {
+	Timeout time.Duration
+	checks  []namedCheck
+}
+
+// New returns a Checker without checks.
+func New(timeout time.Duration) *Checker {
+	return &Checker{Timeout: timeout}
+}
+
+// Add registers check under name.
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Run runs every check concurrently and reports the results. A check that
+// does not return within Timeout fails, even if it ignores its context.
+func (c *Checker) Run(ctx context.Context) Report {
+	type outcome struct {
+		name   string
+		result Result
+	}
+
+	results := make(chan outcome, len(c.checks))
+	for _, nc := range c.checks {
+		go func(nc namedCheck) {
+			results <- outcome{name: nc.name, result: c.run(ctx, nc.check)}
+		}(nc)
+	}
+
+	report := Report{Status: "ok", Checks: make(map[string]Result, len(c.checks))}
+	for range c.checks {
+		o := <-results
+		if o.result.Status != "ok" {
+			report.Status = "fail"
+		}
+		report.Checks[o.name] = o.result
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() { errc <- check(ctx) }()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{Status: "ok", DurationMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// ServeHTTP runs the checks and answers 200 if all of them passed and 503
+// otherwise, so the instance only receives traffic once it is ready.
+func (c *Checker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := c.Run(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
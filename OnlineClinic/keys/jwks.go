@@ -0,0 +1,69 @@
+package keys
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// jwksMaxAge is how long verifiers may cache the key set.
+const jwksMaxAge = 5 * time.Minute
+
+// JWK is the public part of one key (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns every key that may have signed an unexpired token, and the
+// next key before it starts signing.
+func (r *Ring) JWKS() JWKS {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	set := JWKS{Keys: make([]JWK, 0, len(r.keys))}
+	for _, k := range r.keys {
+		set.Keys = append(set.Keys, JWK{
+			KeyType:   "RSA",
+			Use:       "sig",
+			Algorithm: "RS256",
+			KeyID:     k.ID,
+			Modulus:   encodeInt(k.private.N),
+			Exponent:  encodeInt(big.NewInt(int64(k.private.E))),
+		})
+	}
+	return set
+}
+
+// ServeHTTP serves the key set as JSON.
+func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(jwksMaxAge/time.Second)))
+	json.NewEncoder(w).Encode(r.JWKS())
+}
+
+// thumbprint returns the RFC 7638 SHA-256 thumbprint of key.
+func thumbprint(key *rsa.PublicKey) string {
+	// The members must be in lexicographic order without whitespace.
+	canonical := `{"e":"` + encodeInt(big.NewInt(int64(key.E))) + `","kty":"RSA","n":"` + encodeInt(key.N) + `"}`
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func encodeInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
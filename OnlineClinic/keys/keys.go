@@ -0,0 +1,272 @@
+// Package keys manages the RSA keys that sign access tokens (RS256). Keys
+// live as PEM files in one directory, which several server instances may
+// share. A new key is generated every rotation period; the replaced key is
+// still published for verification until the last token it signed has
+// expired, and is then deleted.
+package keys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// keyBits is the size of generated RSA keys.
+	keyBits = 2048
+
+	// activationDelay is how long a new key is only published before it
+	// signs tokens, so that verifiers caching the JWKS learn it first. It
+	// must exceed jwksMaxAge plus reloadInterval.
+	activationDelay = 10 * time.Minute
+
+	// reloadInterval is how often Run rereads the directory, picking up keys
+	// created by other instances, and rotates when due.
+	reloadInterval = time.Minute
+
+	// createdHeader is the PEM header that records when a key was generated.
+	// Keys without it use the file's modification time.
+	createdHeader = "Created"
+)
+
+// Key is one signing key.
+type Key struct {
+	ID      string // RFC 7638 thumbprint, used as the kid header
+	Created time.Time
+	private *rsa.PrivateKey
+	path    string
+}
+
+// Ring holds the current keys. It is safe for concurrent use.
+type Ring struct {
+	dir      string
+	rotation time.Duration
+	retain   time.Duration
+
+	mu   sync.RWMutex
+	keys []*Key // oldest first
+}
+
+// Open loads the keys in dir, creating the directory and a first key if
+// needed. A new key is generated once the newest is older than rotation;
+// replaced keys are kept for retain after they stop signing, which must be
+// at least the access token lifetime.
+func Open(dir string, rotation, retain time.Duration) (*Ring, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating key directory: %w", err)
+	}
+	r := &Ring{dir: dir, rotation: rotation, retain: retain}
+	if err := r.Rotate(time.Now()); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Signing returns the key that signs new tokens: the newest key that has
+// been published for activationDelay, or the newest key if none has.
+func (r *Ring) Signing() (kid string, key *rsa.PrivateKey) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	k := r.signing(time.Now())
+	return k.ID, k.private
+}
+
+func (r *Ring) signing(now time.Time) *Key {
+	for i := len(r.keys) - 1; i >= 0; i-- {
+		if !now.Before(r.keys[i].Created.Add(activationDelay)) {
+			return r.keys[i]
+		}
+	}
+	return r.keys[len(r.keys)-1]
+}
+
+// PublicKey returns the verification key with the given kid.
+func (r *Ring) PublicKey(kid string) (*rsa.PublicKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, k := range r.keys {
+		if k.ID == kid {
+			return &k.private.PublicKey, true
+		}
+	}
+	return nil, false
+}
+
+// Rotate rereads the directory, generates a new key if the newest is due
+// for rotation and deletes keys that no longer verify any unexpired token.
+// On error the previous keys stay in use.
+func (r *Ring) Rotate(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys, err := load(r.dir)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 || !now.Before(keys[len(keys)-1].Created.Add(r.rotation)) {
+		k, err := generate(r.dir, now)
+		if err != nil {
+			return err
+		}
+		slog.Info("generated token signing key", "kid", k.ID, "active_from", now.Add(activationDelay))
+		keys = append(keys, k)
+	}
+
+	// A key stops signing when its successor activates, and its last token
+	// expires retain later.
+	kept := keys[:0]
+	for i, k := range keys {
+		if i < len(keys)-1 && !now.Before(keys[i+1].Created.Add(activationDelay+r.retain)) {
+			if err := os.Remove(k.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("deleting retired key %s: %w", k.ID, err)
+			}
+			slog.Info("deleted retired token signing key", "kid", k.ID)
+			continue
+		}
+		kept = append(kept, k)
+	}
+
+	r.keys = kept
+	return nil
+}
+
+// Run calls Rotate every reloadInterval until ctx is done.
+func (r *Ring) Run(ctx context.Context) {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := r.Rotate(now); err != nil {
+				slog.Error("rotating token signing keys", "err", err)
+			}
+		}
+	}
+}
+
+// load reads every *.pem file in dir, oldest key first.
+func load(dir string) ([]*Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*Key
+	for _, path := range paths {
+		k, err := loadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // Retired by another instance meanwhile
+		}
+		if err != nil {
+			return nil, fmt.Errorf("loading key %s: %w", path, err)
+		}
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Created.Before(keys[j].Created) })
+	return keys, nil
+}
+
+func loadFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var private *rsa.PrivateKey
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		var parsed any
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err == nil {
+			var ok bool
+			if private, ok = parsed.(*rsa.PrivateKey); !ok {
+				err = fmt.Errorf("unsupported key type %T, only RSA keys are supported", parsed)
+			}
+		}
+	default:
+		err = fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if private.N.BitLen() < keyBits {
+		return nil, fmt.Errorf("RSA key has %d bits, at least %d are required", private.N.BitLen(), keyBits)
+	}
+
+	var created time.Time
+	if raw, ok := block.Headers[createdHeader]; ok {
+		if created, err = time.Parse(time.RFC3339, raw); err != nil {
+			return nil, fmt.Errorf("invalid %s header: %w", createdHeader, err)
+		}
+	} else {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		created = info.ModTime()
+	}
+
+	return &Key{ID: thumbprint(&private.PublicKey), Created: created, private: private, path: path}, nil
+}
+
+// generate creates a new key in dir. The file is written under a temporary
+// name and renamed, so other instances never read a partial key.
+func generate(dir string, now time.Time) (*Key, error) {
+	private, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, fmt.Errorf("generating key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Key{ID: thumbprint(&private.PublicKey), Created: now.UTC().Truncate(time.Second), private: private}
+	k.path = filepath.Join(dir, k.Created.Format("20060102T150405Z")+"-"+k.ID[:8]+".pem")
+
+	tmp, err := os.CreateTemp(dir, ".key-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	block := &pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: map[string]string{createdHeader: k.Created.Format(time.RFC3339)},
+		Bytes:   der,
+	}
+	if err := pem.Encode(tmp, block); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), k.path); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
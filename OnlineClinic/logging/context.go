@@ -0,0 +1,46 @@
+package logging
+
+import "context"
+
+type contextKey struct{}
+
+// requestInfo is shared by every context derived from one request, so that
+// the user set by the auth middleware also appears on the access log line
+// written by Middleware.
+type requestInfo struct {
+	id     string
+	userID int
+	role   string
+}
+
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	if ctx == nil {
+		return nil
+	}
+	info, _ := ctx.Value(contextKey{}).(*requestInfo)
+	return info
+}
+
+// WithRequestID returns a context that logs the given request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, &requestInfo{id: id})
+}
+
+// RequestID returns the request ID stored in ctx, if any.
+func RequestID(ctx context.Context) string {
+	if info := requestInfoFrom(ctx); info != nil {
+		return info.id
+	}
+	return ""
+}
+
+// WithUser records the authenticated user on ctx. Inside a request the user is
+// added to the request's shared info; elsewhere a new context is returned.
+func WithUser(ctx context.Context, userID int, role string) context.Context {
+	if info := requestInfoFrom(ctx); info != nil {
+		info.userID = userID
+		info.role = role
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, &requestInfo{userID: userID, role: role})
+}
@@ -0,0 +1,77 @@
+// Package logging configures the application's log/slog logger. Every record
+// logged with a request context carries the request ID and, once the request
+// is authenticated, the user ID and role. Sensitive attributes are redacted.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// Setup builds the logger for the given level (debug, info, warn, error) and
+// format (text or json), installs it as the slog and standard log default,
+// and returns it.
+func Setup(level, format string, w io.Writer) (*slog.Logger, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl, ReplaceAttr: redactAttr}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+
+	logger := slog.New(&contextHandler{Handler: h})
+	slog.SetDefault(logger)
+	// slog.SetDefault routes the standard log package through the handler;
+	// its output must not carry a second timestamp.
+	log.SetFlags(0)
+	return logger, nil
+}
+
+// ParseLevel converts a level name to a slog.Level.
+func ParseLevel(level string) (slog.Level, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", level)
+	}
+	return lvl, nil
+}
+
+// contextHandler adds the request ID, user ID and role stored in the context
+// to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if info := requestInfoFrom(ctx); info != nil {
+		if info.id != "" {
+			r.AddAttrs(slog.String("request_id", info.id))
+		}
+		if info.userID != 0 {
+			r.AddAttrs(slog.Int("user_id", info.userID), slog.String("role", info.role))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
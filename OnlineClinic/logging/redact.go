@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// redacted replaces the value of sensitive attributes.
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are matched against attribute keys lowercased with "_" and
+// "-" removed, so "national_code", "nationalCode" and "NationalCode" all match.
+var sensitiveKeys = []string{
+	"password",
+	"token",
+	"secret",
+	"authorization",
+	"nationalcode",
+	"otp",
+}
+
+// IsSensitive reports whether an attribute or field with this key must not
+// be logged.
+func IsSensitive(key string) bool {
+	k := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	for _, s := range sensitiveKeys {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactAttr is the handlers' ReplaceAttr hook. It also sees attributes
+// nested in groups, including those produced by LogValuer implementations.
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() != slog.KindGroup && IsSensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
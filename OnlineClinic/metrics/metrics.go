@@ -0,0 +1,103 @@
+// Package metrics exposes the server's Prometheus metrics on /metrics of
+// the admin listener at metrics_addr.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "clinic"
+
+// Registry holds every metric of the server. It is separate from the
+// prometheus default registry so that only these metrics are exported.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests counts finished requests by mux route template.
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	// HTTPDuration observes request latency by mux route template.
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// HubClients is the number of WebSocket clients registered with the Hub.
+	HubClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ws_clients",
+		Help:      "WebSocket clients connected to the chat hub.",
+	})
+
+	// WSMessagesIn counts messages read from WebSocket clients.
+	WSMessagesIn = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ws_messages_received_total",
+		Help:      "Messages received from WebSocket clients.",
+	})
+
+	// WSMessagesOut counts messages written to WebSocket clients.
+	WSMessagesOut = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ws_messages_sent_total",
+		Help:      "Messages written to WebSocket clients.",
+	})
+
+	// WSSendsDropped counts broadcasts dropped because a client's send
+	// buffer was full; such clients are disconnected.
+	WSSendsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ws_sends_dropped_total",
+		Help:      "Broadcasts dropped because a client's send buffer was full.",
+	})
+
+	// Bookings counts appointment booking attempts by result.
+	Bookings = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "appointment_bookings_total",
+		Help:      "Appointment booking attempts by result (ok, time_not_available, time_in_past, error).",
+	}, []string{"result"})
+
+	// UploadBytes counts the size of accepted uploads by kind (profile, chat).
+	UploadBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_bytes_total",
+		Help:      "Bytes of uploaded files by kind.",
+	}, []string{"kind"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		HubClients,
+		WSMessagesIn,
+		WSMessagesOut,
+		WSSendsDropped,
+		Bookings,
+		UploadBytes,
+	)
+}
+
+// RegisterDB exports the connection pool statistics of db.
+func RegisterDB(db *sql.DB, dbName string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// Handler serves the registered metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
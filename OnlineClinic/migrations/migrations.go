@@ -0,0 +1,314 @@
+// Package migrations applies the numbered, embedded schema migrations that
+// replace the old hand-maintained SQL dumps.
+//
+// Each migration is a pair of files named NNNN_description.up.sql and
+// NNNN_description.down.sql. Applied versions are recorded in the
+// schema_migrations table so that a migration is never run twice.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MySQL and SQLite hold the embedded migrations for each supported driver.
+// Both directories use the same version numbers so that a schema change is
+// written once per dialect under the same name.
+var (
+	//go:embed mysql/*.sql
+	MySQL embed.FS
+
+	//go:embed sqlite/*.sql
+	SQLite embed.FS
+)
+
+// SourceDir returns where `migrate create` writes new migration files for
+// driver, relative to the OnlineClinic module root.
+func SourceDir(driver string) string {
+	if driver == "sqlite3" {
+		return "migrations/sqlite"
+	}
+	return "migrations/mysql"
+}
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a single numbered schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies migrations from a file system to a database.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// New loads the migrations under dir in fsys and returns a Migrator for db.
+func New(db *sql.DB, fsys fs.FS, dir string) (*Migrator, error) {
+	migrations, err := Load(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// NewMySQL returns a Migrator for the embedded MySQL migrations.
+func NewMySQL(db *sql.DB) (*Migrator, error) {
+	return New(db, MySQL, "mysql")
+}
+
+// NewSQLite returns a Migrator for the embedded SQLite migrations.
+func NewSQLite(db *sql.DB) (*Migrator, error) {
+	return New(db, SQLite, "sqlite")
+}
+
+// NewForDriver returns a Migrator for the embedded migrations of driver
+// ("mysql" or "sqlite3").
+func NewForDriver(driver string, db *sql.DB) (*Migrator, error) {
+	switch driver {
+	case "mysql":
+		return NewMySQL(db)
+	case "sqlite3":
+		return NewSQLite(db)
+	default:
+		return nil, fmt.Errorf("no migrations for database driver %q", driver)
+	}
+}
+
+// Load reads and pairs every migration file under dir, sorted by version.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %v", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q (expected NNNN_name.up.sql or NNNN_name.down.sql)", entry.Name())
+		}
+
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		body, err := fs.ReadFile(fsys, dir+"/"+entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %s: %v", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up file", m.Version, m.Name)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// ensureTable creates the tracking table if it does not exist yet.
+func (m *Migrator) ensureTable() error {
+	_, err := m.DB.Exec(`
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version BIGINT NOT NULL PRIMARY KEY,
+            name VARCHAR(255) NOT NULL,
+            applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+        )`)
+	if err != nil {
+		return fmt.Errorf("creating schema_migrations table: %v", err)
+	}
+	return nil
+}
+
+// applied returns the applied versions and when they were applied.
+func (m *Migrator) applied() (map[int64]time.Time, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("reading schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Status lists every known migration with its applied time, if any.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		s := Status{Migration: migration}
+		if at, ok := applied[migration.Version]; ok {
+			at := at
+			s.AppliedAt = &at
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied yet.
+func (m *Migrator) Pending() ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			pending = append(pending, s.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration in order and returns the ones applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range pending {
+		if err := m.run(migration.Up); err != nil {
+			return done, fmt.Errorf("applying %04d_%s: %v", migration.Version, migration.Name, err)
+		}
+		if _, err := m.DB.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", migration.Version, migration.Name); err != nil {
+			return done, fmt.Errorf("recording %04d_%s: %v", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down rolls back the last steps applied migrations, newest first.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	if steps < 1 {
+		return nil, errors.New("steps must be at least 1")
+	}
+
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(statuses) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := statuses[i]
+		if migration.AppliedAt == nil {
+			continue
+		}
+		if err := m.run(migration.Down); err != nil {
+			return done, fmt.Errorf("rolling back %04d_%s: %v", migration.Version, migration.Name, err)
+		}
+		if _, err := m.DB.Exec("DELETE FROM schema_migrations WHERE version = ?", migration.Version); err != nil {
+			return done, fmt.Errorf("unrecording %04d_%s: %v", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration.Migration)
+	}
+	return done, nil
+}
+
+// run executes every statement of a migration script in order.
+func (m *Migrator) run(script string) error {
+	for i, stmt := range SplitStatements(script) {
+		if _, err := m.DB.Exec(stmt); err != nil {
+			return fmt.Errorf("statement %d: %v\n%s", i+1, err, stmt)
+		}
+	}
+	return nil
+}
+
+// Create writes an empty up/down pair for a new migration in dir, numbered
+// after the highest existing version, and returns the paths written.
+func Create(dir, name string) ([]string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(name, "_")
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return nil, errors.New("migration name is required")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	existing, err := Load(os.DirFS(dir), ".")
+	if err != nil {
+		return nil, err
+	}
+
+	next := int64(1)
+	if len(existing) > 0 {
+		next = existing[len(existing)-1].Version + 1
+	}
+
+	var paths []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", next, name, direction))
+		body := fmt.Sprintf("-- %04d_%s (%s)\n", next, name, direction)
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,94 @@
+-- Initial tables, taken from the former config/new_schema.sql dump.
+-- Every table is created only if missing so this migration can be applied
+-- to a database that was set up from the old dumps without losing data.
+
+CREATE TABLE IF NOT EXISTS doctors (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    first_name VARCHAR(50) NOT NULL,
+    last_name VARCHAR(50) NOT NULL,
+    national_code CHAR(10) NOT NULL UNIQUE,
+    gender ENUM('man', 'woman') NOT NULL,
+    phone_number CHAR(11) NOT NULL UNIQUE,
+    password VARCHAR(255) NOT NULL,
+    age INT NULL,
+    education VARCHAR(100) NULL,
+    address TEXT NULL,
+    profile_photo_path VARCHAR(255) NULL,
+    medical_council_code VARCHAR(64) NULL
+) AUTO_INCREMENT = 1;
+
+CREATE TABLE IF NOT EXISTS patients (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    first_name VARCHAR(50) NOT NULL,
+    last_name VARCHAR(50) NOT NULL,
+    national_code CHAR(10) NOT NULL UNIQUE,
+    gender ENUM('man', 'woman') NOT NULL,
+    phone_number CHAR(11) NOT NULL UNIQUE,
+    password VARCHAR(255) NOT NULL,
+    age INT,
+    job VARCHAR(100),
+    education VARCHAR(100),
+    address TEXT,
+    profile_photo_path VARCHAR(255)
+) AUTO_INCREMENT = 1000000;
+
+CREATE TABLE IF NOT EXISTS appointments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    patient_id INT NOT NULL,
+    doctor_id INT NOT NULL,
+    start_time DATETIME NOT NULL,
+    end_time DATETIME NOT NULL,
+    visit_type ENUM('online', 'in-person') NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (patient_id) REFERENCES patients(id),
+    FOREIGN KEY (doctor_id) REFERENCES doctors(id)
+);
+
+CREATE TABLE IF NOT EXISTS prescriptions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    appointment_id INT NOT NULL,
+    instructions TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_appointment UNIQUE (appointment_id),
+    FOREIGN KEY (appointment_id) REFERENCES appointments(id)
+);
+
+CREATE TABLE IF NOT EXISTS medications (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    prescription_id INT NOT NULL,
+    medicine VARCHAR(255) NOT NULL,
+    frequency VARCHAR(255) NOT NULL,
+    FOREIGN KEY (prescription_id) REFERENCES prescriptions(id)
+);
+
+CREATE TABLE IF NOT EXISTS chats (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    sender_id INT NOT NULL,
+    receiver_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    chat_id INT NOT NULL,
+    sender_id INT NOT NULL,
+    receiver_id INT NOT NULL,
+    text TEXT NOT NULL,
+    time VARCHAR(10) NOT NULL,
+    replied_message TEXT NULL,
+    replied_message_id INT NULL,
+    date VARCHAR(10) NOT NULL,
+    attached_file_path VARCHAR(255),
+    is_read BOOLEAN DEFAULT FALSE,
+    FOREIGN KEY (chat_id) REFERENCES chats(id),
+    FOREIGN KEY (replied_message_id) REFERENCES messages(id)
+);
+
+CREATE TABLE IF NOT EXISTS doctor_availability (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    doctor_id INT NOT NULL,
+    start_time DATETIME NOT NULL,
+    end_time DATETIME NOT NULL,
+    type ENUM('online', 'in-person') NOT NULL,
+    FOREIGN KEY (doctor_id) REFERENCES doctors(id)
+);
@@ -0,0 +1,25 @@
+-- Drops every procedure created by 0002.
+
+DROP PROCEDURE IF EXISTS GetAllChats;
+DROP PROCEDURE IF EXISTS GetChatHistory;
+DROP PROCEDURE IF EXISTS AddMessage;
+DROP PROCEDURE IF EXISTS CreateChat;
+DROP PROCEDURE IF EXISTS GetPatientPrescriptionsSecure;
+DROP PROCEDURE IF EXISTS GetPrescriptionByAppointmentSecure;
+DROP PROCEDURE IF EXISTS delete_unreserved_availability;
+DROP PROCEDURE IF EXISTS get_available_slots;
+DROP PROCEDURE IF EXISTS insert_availability_slot;
+DROP PROCEDURE IF EXISTS check_availability_overlap;
+DROP PROCEDURE IF EXISTS ValidateParticipants;
+DROP PROCEDURE IF EXISTS GetAllDoctors;
+DROP PROCEDURE IF EXISTS GetAllPatients;
+DROP PROCEDURE IF EXISTS GetDoctorById;
+DROP PROCEDURE IF EXISTS GetPatientById;
+DROP PROCEDURE IF EXISTS GetDoctorByPhone;
+DROP PROCEDURE IF EXISTS GetPatientByPhone;
+DROP PROCEDURE IF EXISTS UpdateDoctorPassword;
+DROP PROCEDURE IF EXISTS UpdateDoctor;
+DROP PROCEDURE IF EXISTS UpdatePatientPassword;
+DROP PROCEDURE IF EXISTS UpdatePatient;
+DROP PROCEDURE IF EXISTS AddPatient;
+DROP PROCEDURE IF EXISTS AddDoctor;
@@ -1,118 +1,14 @@
-CREATE DATABASE IF NOT EXISTS OnlineClinic;
-
-USE OnlineClinic;
-
--- Drop existing tables in correct order
-DROP TABLE IF EXISTS appointments;
-DROP TABLE IF EXISTS prescriptions;
-DROP TABLE IF EXISTS messages;
-DROP TABLE IF EXISTS chats;
-DROP TABLE IF EXISTS doctor_availability;
-DROP TABLE IF EXISTS patients;
-DROP TABLE IF EXISTS doctors;
-
-
-CREATE TABLE doctors (
-    id INT AUTO_INCREMENT PRIMARY KEY,
-    first_name VARCHAR(50) NOT NULL,
-    last_name VARCHAR(50) NOT NULL,
-    national_code CHAR(10) NOT NULL UNIQUE,
-    gender ENUM('man', 'woman') NOT NULL,
-    phone_number CHAR(11) NOT NULL UNIQUE,
-    password VARCHAR(255) NOT NULL,
-    age INT NULL,
-    education VARCHAR(100) NULL,
-    address TEXT NULL,
-    profile_photo_path VARCHAR(255) NULL,
-    medical_council_code VARCHAR(64) NULL -- Added new field
-) AUTO_INCREMENT = 1;
-
-
-CREATE TABLE patients (
-    id INT AUTO_INCREMENT PRIMARY KEY,
-    first_name VARCHAR(50) NOT NULL,
-    last_name VARCHAR(50) NOT NULL,
-    national_code CHAR(10) NOT NULL UNIQUE,
-    gender ENUM('man', 'woman') NOT NULL,
-    phone_number CHAR(11) NOT NULL UNIQUE,
-    password VARCHAR(255) NOT NULL,
-    age INT,
-    job VARCHAR(100),
-    education VARCHAR(100),
-    address TEXT,
-    profile_photo_path VARCHAR(255)
-) AUTO_INCREMENT = 1000000;
-
-
--- Modified appointments table
-CREATE TABLE appointments (
-    id INT AUTO_INCREMENT PRIMARY KEY,
-    patient_id INT NOT NULL,
-    doctor_id INT NOT NULL,
-    start_time DATETIME NOT NULL,
-    end_time DATETIME NOT NULL,
-    visit_type ENUM('online', 'in-person') NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (patient_id) REFERENCES patients(id),
-    FOREIGN KEY (doctor_id) REFERENCES doctors(id)
-);
-
--- Modified prescriptions table structure
-CREATE TABLE prescriptions (
-    id INT AUTO_INCREMENT PRIMARY KEY,
-    appointment_id INT NOT NULL,
-    instructions TEXT,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (appointment_id) REFERENCES appointments(id)
-);
-ALTER TABLE prescriptions ADD CONSTRAINT unique_appointment UNIQUE (appointment_id);
-
--- New medications table
-CREATE TABLE medications (
-    id INT AUTO_INCREMENT PRIMARY KEY,
-    prescription_id INT NOT NULL,
-    medicine VARCHAR(255) NOT NULL,
-    frequency VARCHAR(255) NOT NULL,
-    FOREIGN KEY (prescription_id) REFERENCES prescriptions(id)
-);
-
-CREATE TABLE IF NOT EXISTS chats (
-    id INT AUTO_INCREMENT PRIMARY KEY,
-    sender_id INT NOT NULL,
-    receiver_id INT NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);
-
-CREATE TABLE IF NOT EXISTS messages (
-    id INT AUTO_INCREMENT PRIMARY KEY,
-    chat_id INT NOT NULL,
-    sender_id INT NOT NULL,
-    receiver_id INT NOT NULL, -- Add this column if missing
-    text TEXT NOT NULL,
-    time VARCHAR(10) NOT NULL,
-    replied_message TEXT NULL,
-    replied_message_id INT NULL,
-    date VARCHAR(10) NOT NULL,
-    attached_file_path VARCHAR(255),
-    is_read BOOLEAN DEFAULT FALSE,
-    FOREIGN KEY (chat_id) REFERENCES chats(id),
-    FOREIGN KEY (replied_message_id) REFERENCES messages(id)
-);
-
-CREATE TABLE doctor_availability (
-    id INT AUTO_INCREMENT PRIMARY KEY,
-    doctor_id INT NOT NULL,
-    start_time DATETIME NOT NULL,
-    end_time DATETIME NOT NULL,
-    type ENUM('online', 'in-person') NOT NULL,
-    FOREIGN KEY (doctor_id) REFERENCES doctors(id)
-);
-
-
-
+-- Stored procedures used by the models package, taken from the former
+-- config/new_schema.sql dump. Each one is dropped first so this migration
+-- also brings databases created from the old dumps up to date.
+--
+-- AddPrescription, GetPatientPrescriptions, GetDoctorPrescriptions and
+-- GetChatMessages are not carried over: they referenced columns that no
+-- longer exist (prescription_text, messages.type) and nothing calls them.
 
 DELIMITER //
 
+DROP PROCEDURE IF EXISTS AddDoctor //
 CREATE PROCEDURE AddDoctor(
     IN p_first_name VARCHAR(50),
     IN p_last_name VARCHAR(50),
@@ -133,7 +29,7 @@ BEGIN
     );
 END //
 
--- Modified AddPatient procedure
+DROP PROCEDURE IF EXISTS AddPatient //
 CREATE PROCEDURE AddPatient(
     IN p_first_name VARCHAR(50),
     IN p_last_name VARCHAR(50),
@@ -160,8 +56,7 @@ BEGIN
     );
 END //
 
-
--- Modified UpdatePatient procedure
+DROP PROCEDURE IF EXISTS UpdatePatient //
 CREATE PROCEDURE UpdatePatient(
     IN p_id INT,
     IN p_first_name VARCHAR(50),
@@ -191,7 +86,7 @@ BEGIN
     WHERE id = p_id;
 END //
 
--- Add separate procedure for password updates
+DROP PROCEDURE IF EXISTS UpdatePatientPassword //
 CREATE PROCEDURE UpdatePatientPassword(
     IN p_id INT,
     IN p_password VARCHAR(255)
@@ -202,9 +97,7 @@ BEGIN
     WHERE id = p_id;
 END //
 
-
--- Modified UpdateDoctor procedure
--- Modified UpdateDoctor procedure to include medical_council_code
+DROP PROCEDURE IF EXISTS UpdateDoctor //
 CREATE PROCEDURE UpdateDoctor(
     IN p_id INT,
     IN p_first_name VARCHAR(50),
@@ -234,7 +127,7 @@ BEGIN
     WHERE id = p_id;
 END //
 
--- Create a separate procedure for password updates
+DROP PROCEDURE IF EXISTS UpdateDoctorPassword //
 CREATE PROCEDURE UpdateDoctorPassword(
     IN p_id INT,
     IN p_password VARCHAR(255)
@@ -245,7 +138,7 @@ BEGIN
     WHERE id = p_id;
 END //
 
-
+DROP PROCEDURE IF EXISTS GetPatientByPhone //
 CREATE PROCEDURE GetPatientByPhone(
     IN p_phone_number CHAR(11)
 )
@@ -257,6 +150,7 @@ BEGIN
     WHERE phone_number = p_phone_number;
 END //
 
+DROP PROCEDURE IF EXISTS GetDoctorByPhone //
 CREATE PROCEDURE GetDoctorByPhone(
     IN p_phone_number CHAR(11)
 )
@@ -268,6 +162,7 @@ BEGIN
     WHERE phone_number = p_phone_number;
 END //
 
+DROP PROCEDURE IF EXISTS GetPatientById //
 CREATE PROCEDURE GetPatientById(
     IN p_id INT
 )
@@ -279,6 +174,7 @@ BEGIN
     WHERE id = p_id;
 END //
 
+DROP PROCEDURE IF EXISTS GetDoctorById //
 CREATE PROCEDURE GetDoctorById(
     IN p_id INT
 )
@@ -290,6 +186,7 @@ BEGIN
     WHERE id = p_id;
 END //
 
+DROP PROCEDURE IF EXISTS GetAllPatients //
 CREATE PROCEDURE GetAllPatients()
 BEGIN
     SELECT 
@@ -298,6 +195,7 @@ BEGIN
     FROM patients;
 END //
 
+DROP PROCEDURE IF EXISTS GetAllDoctors //
 CREATE PROCEDURE GetAllDoctors()
 BEGIN
     SELECT 
@@ -306,67 +204,7 @@ BEGIN
     FROM doctors;
 END //
 
--- Keep existing procedures that don't need changes
-CREATE PROCEDURE AddPrescription(
-    IN p_patient_id INT,
-    IN p_doctor_id INT,
-    IN p_prescription_text TEXT
-)
-BEGIN
-    INSERT INTO prescriptions (patient_id, doctor_id, prescription_text) 
-    VALUES (p_patient_id, p_doctor_id, p_prescription_text);
-END //
-
-CREATE PROCEDURE GetPatientPrescriptions(
-    IN p_id INT
-)
-BEGIN
-    SELECT 
-        p.id, p.patient_id, p.doctor_id, p.prescription_text, 
-        DATE_FORMAT(p.created_at, '%Y-%m-%d %H:%i:%s') as created_at,
-        CONCAT(d.first_name, ' ', d.last_name) as doctor_name
-    FROM prescriptions p
-    LEFT JOIN doctors d ON p.doctor_id = d.id
-    WHERE p.patient_id = p_id;
-END //
-
-CREATE PROCEDURE GetDoctorPrescriptions(
-    IN d_id INT
-)
-BEGIN
-    SELECT 
-        p.id, p.patient_id, p.doctor_id, p.prescription_text, p.created_at,
-        CONCAT(pt.first_name, ' ', pt.last_name) as patient_name
-    FROM prescriptions p
-    JOIN patients pt ON p.patient_id = pt.id
-    WHERE p.doctor_id = d_id
-    ORDER BY p.created_at DESC;
-END //
-
-CREATE PROCEDURE GetChatMessages(
-    IN p_doctor_id INT,
-    IN p_patient_id INT
-)
-BEGIN
-    SELECT 
-        c.id,
-        c.sender_id,
-        c.receiver_id,
-        c.created_at,
-        m.id as message_id,
-        m.type,
-        m.message,
-        m.path,
-        m.date
-    FROM chats c
-    LEFT JOIN messages m ON c.id = m.chat_id
-    WHERE (
-        (c.sender_id = p_doctor_id AND c.receiver_id = p_patient_id) OR
-        (c.sender_id = p_patient_id AND c.receiver_id = p_doctor_id)
-    )
-    ORDER BY c.created_at ASC, m.date ASC;
-END //
-
+DROP PROCEDURE IF EXISTS ValidateParticipants //
 CREATE PROCEDURE ValidateParticipants(
     IN p_doctor_id INT,
     IN p_patient_id INT,
@@ -382,12 +220,7 @@ BEGIN
     SET is_valid = doctor_exists AND patient_exists;
 END //
 
-DELIMITER ;
-
-
--- Procedure to check for overlapping availability slots
-DELIMITER //
-
+DROP PROCEDURE IF EXISTS check_availability_overlap //
 CREATE PROCEDURE check_availability_overlap(
     IN p_doctor_id INT,
     IN p_start_time DATETIME,
@@ -409,7 +242,7 @@ BEGIN
     SET p_has_overlap = (v_count > 0);
 END //
 
--- Procedure to insert availability slot with overlap check
+DROP PROCEDURE IF EXISTS insert_availability_slot //
 CREATE PROCEDURE insert_availability_slot(
     IN p_doctor_id INT,
     IN p_start_time DATETIME,
@@ -440,28 +273,7 @@ BEGIN
     COMMIT;
 END //
 
--- Procedure to get available slots for a doctor within a date range
-CREATE PROCEDURE get_available_slots(
-    IN p_doctor_id INT,
-    IN p_start_date DATE,
-    IN p_end_date DATE
-)
-BEGIN
-    SELECT id, doctor_id, start_time, end_time, type
-    FROM doctor_availability
-    WHERE doctor_id = p_doctor_id
-    AND DATE(start_time) >= p_start_date
-    AND DATE(end_time) <= p_end_date
-    ORDER BY start_time;
-END //
-
-DELIMITER ;
-
-
-DELIMITER //
-
 DROP PROCEDURE IF EXISTS get_available_slots //
-
 CREATE PROCEDURE get_available_slots(
     IN p_doctor_id INT,
     IN p_start_date DATETIME,
@@ -483,11 +295,7 @@ BEGIN
     ORDER BY start_time ASC;
 END //
 
-DELIMITER ;
-
-
-DELIMITER //
-
+DROP PROCEDURE IF EXISTS delete_unreserved_availability //
 CREATE PROCEDURE delete_unreserved_availability(
     IN p_doctor_id INT,
     IN p_visit_type VARCHAR(20),
@@ -508,13 +316,7 @@ BEGIN
         AND start_time > NOW();
 END //
 
-DELIMITER ;
-
-
-DELIMITER //
-
--- Procedure to get prescription by appointment with access control
-
+DROP PROCEDURE IF EXISTS GetPrescriptionByAppointmentSecure //
 CREATE PROCEDURE GetPrescriptionByAppointmentSecure(
     IN p_appointment_id INT,
     IN p_user_id INT,
@@ -547,8 +349,7 @@ BEGIN
     );
 END //
 
-
--- Procedure to get patient prescriptions with access control
+DROP PROCEDURE IF EXISTS GetPatientPrescriptionsSecure //
 CREATE PROCEDURE GetPatientPrescriptionsSecure(
     IN p_patient_id INT,
     IN p_user_id INT,
@@ -578,10 +379,7 @@ BEGIN
     );
 END //
 
-DELIMITER ;
-
-DELIMITER //
-
+DROP PROCEDURE IF EXISTS CreateChat //
 CREATE PROCEDURE CreateChat(
     IN p_sender_id INT,
     IN p_receiver_id INT,
@@ -610,11 +408,7 @@ BEGIN
     SELECT p_chat_id AS chat_id;
 END //
 
-DELIMITER ;
-
-
-DELIMITER //
-
+DROP PROCEDURE IF EXISTS AddMessage //
 CREATE PROCEDURE AddMessage(
     IN p_chat_id INT,
     IN p_sender_id INT,
@@ -654,11 +448,7 @@ BEGIN
     );
 END //
 
-DELIMITER ;
-
-
-DELIMITER //
-
+DROP PROCEDURE IF EXISTS GetChatHistory //
 CREATE PROCEDURE GetChatHistory(
     IN p_user_id INT,
     IN p_receiver_id INT
@@ -698,54 +488,7 @@ BEGIN
     AND m.is_read = FALSE;
 END //
 
-DELIMITER ;
-
-DELIMITER //
-
--- Function to convert Gregorian date to Solar date
-CREATE FUNCTION GregorianToSolar(gregorian_date DATE) RETURNS DATE
-DETERMINISTIC
-BEGIN
-    DECLARE solar_date DATE;
-    -- Implement the conversion logic from Gregorian to Solar (Hijri) date
-    -- This is a placeholder, you need to implement the actual conversion logic
-    SET solar_date = DATE_SUB(gregorian_date, INTERVAL 579 DAY); -- Example conversion
-    RETURN solar_date;
-END //
-
--- Function to get the current Solar year
-CREATE FUNCTION SolarYear() RETURNS INT
-DETERMINISTIC
-BEGIN
-    DECLARE solar_year INT;
-    SET solar_year = YEAR(GregorianToSolar(CURDATE()));
-    RETURN solar_year;
-END //
-
--- Function to get the current Solar date
-CREATE FUNCTION SolarDate() RETURNS DATE
-DETERMINISTIC
-BEGIN
-    RETURN GregorianToSolar(CURDATE());
-END //
-
-DELIMITER ;
-
-
-DELIMITER //
-
-CREATE FUNCTION GetCurrentSolarDate() RETURNS VARCHAR(10)
-DETERMINISTIC
-BEGIN
-    DECLARE solarDate VARCHAR(10);
-    SET solarDate = DATE_FORMAT(NOW(), '%Y-%m-%d'); -- Replace with Solar date conversion logic
-    RETURN solarDate;
-END //
-
-DELIMITER ;
-
-DELIMITER //
-
+DROP PROCEDURE IF EXISTS GetAllChats //
 CREATE PROCEDURE GetAllChats(
     IN p_user_id INT
 )
@@ -804,4 +547,4 @@ BEGIN
     WHERE c.sender_id = p_user_id OR c.receiver_id = p_user_id;
 END //
 
-DELIMITER ;
\ No newline at end of file
+DELIMITER ;
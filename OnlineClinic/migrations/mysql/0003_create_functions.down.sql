@@ -0,0 +1,6 @@
+-- Drops every function created by 0003.
+
+DROP FUNCTION IF EXISTS GetCurrentSolarDate;
+DROP FUNCTION IF EXISTS SolarDate;
+DROP FUNCTION IF EXISTS SolarYear;
+DROP FUNCTION IF EXISTS GregorianToSolar;
@@ -0,0 +1,42 @@
+-- Stored functions for Solar date handling, taken from the former
+-- config/new_schema.sql dump.
+
+DELIMITER //
+
+DROP FUNCTION IF EXISTS GregorianToSolar //
+CREATE FUNCTION GregorianToSolar(gregorian_date DATE) RETURNS DATE
+DETERMINISTIC
+BEGIN
+    DECLARE solar_date DATE;
+    -- Implement the conversion logic from Gregorian to Solar (Hijri) date
+    -- This is a placeholder, you need to implement the actual conversion logic
+    SET solar_date = DATE_SUB(gregorian_date, INTERVAL 579 DAY); -- Example conversion
+    RETURN solar_date;
+END //
+
+DROP FUNCTION IF EXISTS SolarYear //
+CREATE FUNCTION SolarYear() RETURNS INT
+DETERMINISTIC
+BEGIN
+    DECLARE solar_year INT;
+    SET solar_year = YEAR(GregorianToSolar(CURDATE()));
+    RETURN solar_year;
+END //
+
+DROP FUNCTION IF EXISTS SolarDate //
+CREATE FUNCTION SolarDate() RETURNS DATE
+DETERMINISTIC
+BEGIN
+    RETURN GregorianToSolar(CURDATE());
+END //
+
+DROP FUNCTION IF EXISTS GetCurrentSolarDate //
+CREATE FUNCTION GetCurrentSolarDate() RETURNS VARCHAR(10)
+DETERMINISTIC
+BEGIN
+    DECLARE solarDate VARCHAR(10);
+    SET solarDate = DATE_FORMAT(NOW(), '%Y-%m-%d'); -- Replace with Solar date conversion logic
+    RETURN solarDate;
+END //
+
+DELIMITER ;
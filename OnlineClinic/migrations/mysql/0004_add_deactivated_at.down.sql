@@ -0,0 +1,2 @@
+ALTER TABLE patients DROP COLUMN deactivated_at;
+ALTER TABLE doctors DROP COLUMN deactivated_at;
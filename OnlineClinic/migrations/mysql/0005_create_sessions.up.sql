@@ -0,0 +1,20 @@
+-- One row per login. The refresh token is rotated on every use and only its
+-- SHA-256 hash is stored; the previous hash is kept to detect a replayed
+-- refresh token, which revokes the session. Access tokens carry the session
+-- ID, so revoking a session invalidates them too.
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id CHAR(32) NOT NULL PRIMARY KEY,
+    user_id INT NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    refresh_token_hash CHAR(64) NOT NULL UNIQUE,
+    previous_token_hash CHAR(64) NULL,
+    user_agent VARCHAR(255) NULL,
+    ip_address VARCHAR(64) NULL,
+    created_at DATETIME NOT NULL,
+    last_used_at DATETIME NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME NULL,
+    INDEX idx_sessions_user (user_id, role),
+    INDEX idx_sessions_previous_token (previous_token_hash)
+);
@@ -0,0 +1,14 @@
+-- Clinic staff: receptionists manage doctors' schedules, admins also manage
+-- accounts. Staff IDs start at 500000 so they do not collide with doctor or
+-- patient IDs.
+
+CREATE TABLE IF NOT EXISTS staff (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    first_name VARCHAR(50) NOT NULL,
+    last_name VARCHAR(50) NOT NULL,
+    phone_number CHAR(11) NOT NULL UNIQUE,
+    password VARCHAR(255) NOT NULL,
+    role ENUM('receptionist', 'admin') NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deactivated_at TIMESTAMP NULL DEFAULT NULL
+) AUTO_INCREMENT = 500000;
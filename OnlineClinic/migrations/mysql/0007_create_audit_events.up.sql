@@ -0,0 +1,16 @@
+-- Security-relevant events, such as account lockouts. Rows are only ever
+-- inserted. actor_* name the account that acted, when known; subject names
+-- what the event is about, e.g. the phone number a lockout applies to.
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    actor_role VARCHAR(16) NULL,
+    actor_id INT NULL,
+    subject VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(64) NULL,
+    detail TEXT NULL,
+    INDEX idx_audit_events_created (created_at),
+    INDEX idx_audit_events_action (action, created_at)
+);
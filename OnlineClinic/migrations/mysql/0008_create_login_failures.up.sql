@@ -0,0 +1,10 @@
+-- Failed login attempts per account and per client IP, shared by all server
+-- instances. A row is deleted when its account logs in successfully, and
+-- purged once its failures are too old to count.
+
+CREATE TABLE IF NOT EXISTS login_failures (
+    throttle_key VARCHAR(128) NOT NULL PRIMARY KEY,
+    failures INT NOT NULL,
+    last_failure DATETIME NOT NULL,
+    INDEX idx_login_failures_last (last_failure)
+);
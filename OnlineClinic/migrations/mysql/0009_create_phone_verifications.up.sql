@@ -0,0 +1,15 @@
+-- One-time codes sent by SMS to prove control of a phone number, at signup
+-- and when an account changes its number. Only a SHA-256 hash of each code
+-- is stored. A phone number has at most one open code per purpose; the row
+-- is deleted once the code is used.
+
+CREATE TABLE IF NOT EXISTS phone_verifications (
+    phone_number VARCHAR(11) NOT NULL,
+    purpose VARCHAR(64) NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    PRIMARY KEY (phone_number, purpose),
+    INDEX idx_phone_verifications_expires (expires_at)
+);
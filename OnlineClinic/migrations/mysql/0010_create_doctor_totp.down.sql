@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS doctor_recovery_codes;
+DROP TABLE IF EXISTS doctor_totp;
@@ -0,0 +1,23 @@
+-- TOTP (RFC 6238) second factor of doctors. A row with enabled_at NULL is
+-- an enrollment that has not been confirmed with a code yet. The secret has
+-- to be readable to check codes, so it is stored as is; last_used_step keeps
+-- a code from being used twice. Recovery codes are stored as SHA-256 hashes
+-- and each works once.
+
+CREATE TABLE IF NOT EXISTS doctor_totp (
+    doctor_id INT NOT NULL PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL,
+    created_at DATETIME NOT NULL,
+    enabled_at DATETIME NULL,
+    last_used_step BIGINT NULL,
+    FOREIGN KEY (doctor_id) REFERENCES doctors(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS doctor_recovery_codes (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    doctor_id INT NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used_at DATETIME NULL,
+    INDEX idx_doctor_recovery_codes_doctor (doctor_id),
+    FOREIGN KEY (doctor_id) REFERENCES doctors(id) ON DELETE CASCADE
+);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS audit_chain;
+DROP INDEX idx_audit_events_patient ON audit_events;
+ALTER TABLE audit_events DROP COLUMN hash;
+ALTER TABLE audit_events DROP COLUMN prev_hash;
+ALTER TABLE audit_events DROP COLUMN patient_id;
@@ -0,0 +1,17 @@
+-- Audit events become tamper-evident: each row stores the hash of the row
+-- before it (prev_hash) and a SHA-256 hash over its own fields and
+-- prev_hash. audit_chain holds the newest hash, so deleting rows from the
+-- end is detected too. Events recorded before this migration stay unhashed.
+-- patient_id names the patient whose medical data an event concerns.
+
+ALTER TABLE audit_events ADD COLUMN patient_id INT NULL;
+ALTER TABLE audit_events ADD COLUMN prev_hash CHAR(64) NULL;
+ALTER TABLE audit_events ADD COLUMN hash CHAR(64) NULL;
+CREATE INDEX idx_audit_events_patient ON audit_events (patient_id, created_at);
+
+CREATE TABLE IF NOT EXISTS audit_chain (
+    id INT NOT NULL PRIMARY KEY,
+    last_hash CHAR(64) NOT NULL
+);
+
+INSERT INTO audit_chain (id, last_hash) VALUES (1, '');
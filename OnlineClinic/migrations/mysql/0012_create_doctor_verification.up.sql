@@ -0,0 +1,35 @@
+-- Doctors are listed, bookable and may write prescriptions only once an
+-- admin has approved their license documents. verification_status is one of
+-- pending, approved, rejected and suspended; every change is kept in
+-- doctor_verification_reviews. Doctors registered before this migration are
+-- approved. Documents are stored outside the public upload directory.
+
+ALTER TABLE doctors ADD COLUMN verification_status VARCHAR(16) NOT NULL DEFAULT 'pending';
+UPDATE doctors SET verification_status = 'approved';
+CREATE INDEX idx_doctors_verification_status ON doctors (verification_status);
+
+CREATE TABLE IF NOT EXISTS doctor_documents (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    doctor_id INT NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(64) NOT NULL,
+    size BIGINT NOT NULL,
+    path VARCHAR(255) NOT NULL,
+    uploaded_at DATETIME NOT NULL,
+    INDEX idx_doctor_documents_doctor (doctor_id),
+    FOREIGN KEY (doctor_id) REFERENCES doctors(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS doctor_verification_reviews (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    doctor_id INT NOT NULL,
+    from_status VARCHAR(16) NOT NULL,
+    to_status VARCHAR(16) NOT NULL,
+    reviewer_role VARCHAR(16) NULL,
+    reviewer_id INT NULL,
+    notes TEXT NULL,
+    created_at DATETIME NOT NULL,
+    INDEX idx_doctor_verification_reviews_doctor (doctor_id, created_at),
+    FOREIGN KEY (doctor_id) REFERENCES doctors(id) ON DELETE CASCADE
+);
@@ -0,0 +1,91 @@
+-- Chats go back to the account IDs. Principals of accounts created before
+-- 0013 have the same ID as the account, so only newer ones change; those
+-- may collide again, which is why 0013 exists.
+
+ALTER TABLE messages DROP FOREIGN KEY fk_messages_sender, DROP FOREIGN KEY fk_messages_receiver;
+ALTER TABLE chats DROP FOREIGN KEY fk_chats_sender, DROP FOREIGN KEY fk_chats_receiver;
+
+UPDATE chats c JOIN doctors d ON d.principal_id = c.sender_id SET c.sender_id = d.id;
+UPDATE chats c JOIN patients p ON p.principal_id = c.sender_id SET c.sender_id = p.id;
+UPDATE chats c JOIN doctors d ON d.principal_id = c.receiver_id SET c.receiver_id = d.id;
+UPDATE chats c JOIN patients p ON p.principal_id = c.receiver_id SET c.receiver_id = p.id;
+UPDATE messages m JOIN doctors d ON d.principal_id = m.sender_id SET m.sender_id = d.id;
+UPDATE messages m JOIN patients p ON p.principal_id = m.sender_id SET m.sender_id = p.id;
+UPDATE messages m JOIN doctors d ON d.principal_id = m.receiver_id SET m.receiver_id = d.id;
+UPDATE messages m JOIN patients p ON p.principal_id = m.receiver_id SET m.receiver_id = p.id;
+
+DROP TRIGGER IF EXISTS doctors_principal;
+DROP TRIGGER IF EXISTS patients_principal;
+DROP TRIGGER IF EXISTS staff_principal;
+
+ALTER TABLE doctors DROP FOREIGN KEY fk_doctors_principal;
+ALTER TABLE doctors DROP INDEX uq_doctors_principal, DROP COLUMN principal_id;
+ALTER TABLE patients DROP FOREIGN KEY fk_patients_principal;
+ALTER TABLE patients DROP INDEX uq_patients_principal, DROP COLUMN principal_id;
+ALTER TABLE staff DROP FOREIGN KEY fk_staff_principal;
+ALTER TABLE staff DROP INDEX uq_staff_principal, DROP COLUMN principal_id;
+
+DROP TABLE IF EXISTS principals;
+
+DELIMITER //
+
+DROP PROCEDURE IF EXISTS GetAllChats //
+CREATE PROCEDURE GetAllChats(
+    IN p_user_id INT
+)
+BEGIN
+    SELECT 
+        c.id AS chat_id,
+        CASE 
+            WHEN c.sender_id = p_user_id THEN c.receiver_id
+            ELSE c.sender_id
+        END AS other_user_id,
+        CASE 
+            WHEN c.sender_id = p_user_id THEN (
+                SELECT CONCAT(first_name, ' ', last_name) 
+                FROM patients 
+                WHERE id = c.receiver_id
+                UNION ALL
+                SELECT CONCAT(first_name, ' ', last_name) 
+                FROM doctors 
+                WHERE id = c.receiver_id
+                LIMIT 1
+            )
+            ELSE (
+                SELECT CONCAT(first_name, ' ', last_name) 
+                FROM patients 
+                WHERE id = c.sender_id
+                UNION ALL
+                SELECT CONCAT(first_name, ' ', last_name) 
+                FROM doctors 
+                WHERE id = c.sender_id
+                LIMIT 1
+            )
+        END AS other_user_name,
+        CASE 
+            WHEN c.sender_id = p_user_id THEN (
+                SELECT profile_photo_path 
+                FROM patients 
+                WHERE id = c.receiver_id
+                UNION ALL
+                SELECT profile_photo_path 
+                FROM doctors 
+                WHERE id = c.receiver_id
+                LIMIT 1
+            )
+            ELSE (
+                SELECT profile_photo_path 
+                FROM patients 
+                WHERE id = c.sender_id
+                UNION ALL
+                SELECT profile_photo_path 
+                FROM doctors 
+                WHERE id = c.sender_id
+                LIMIT 1
+            )
+        END AS other_user_image
+    FROM chats c
+    WHERE c.sender_id = p_user_id OR c.receiver_id = p_user_id;
+END //
+
+DELIMITER ;
@@ -0,0 +1,116 @@
+-- Every doctor, patient and staff account becomes a principal: one row in
+-- principals with a typed role and an ID from a single sequence, which the
+-- profile references through principal_id. Chats and messages refer to
+-- principals instead of guessing from the ID range which table an ID is
+-- from.
+--
+-- The IDs of existing accounts never overlapped (doctors from 1, staff from
+-- 500000, patients from 1000000), so they are kept as their principal IDs
+-- and existing chats stay valid. Chat participants whose account has been
+-- deleted are given a principal too, typed by that range for the last time.
+-- New accounts get their principal from the triggers below.
+
+CREATE TABLE IF NOT EXISTS principals (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    role ENUM('patient', 'doctor', 'staff') NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO principals (id, role) SELECT id, 'doctor' FROM doctors;
+INSERT INTO principals (id, role) SELECT id, 'staff' FROM staff;
+INSERT INTO principals (id, role) SELECT id, 'patient' FROM patients;
+
+INSERT INTO principals (id, role)
+SELECT u.id, IF(u.id >= 1000000, 'patient', 'doctor')
+FROM (
+    SELECT sender_id AS id FROM chats
+    UNION SELECT receiver_id FROM chats
+    UNION SELECT sender_id FROM messages
+    UNION SELECT receiver_id FROM messages
+) u
+WHERE NOT EXISTS (SELECT 1 FROM principals p WHERE p.id = u.id);
+
+ALTER TABLE doctors ADD COLUMN principal_id INT NULL;
+ALTER TABLE patients ADD COLUMN principal_id INT NULL;
+ALTER TABLE staff ADD COLUMN principal_id INT NULL;
+
+UPDATE doctors SET principal_id = id;
+UPDATE patients SET principal_id = id;
+UPDATE staff SET principal_id = id;
+
+ALTER TABLE doctors MODIFY principal_id INT NOT NULL,
+    ADD CONSTRAINT uq_doctors_principal UNIQUE (principal_id),
+    ADD CONSTRAINT fk_doctors_principal FOREIGN KEY (principal_id) REFERENCES principals(id);
+ALTER TABLE patients MODIFY principal_id INT NOT NULL,
+    ADD CONSTRAINT uq_patients_principal UNIQUE (principal_id),
+    ADD CONSTRAINT fk_patients_principal FOREIGN KEY (principal_id) REFERENCES principals(id);
+ALTER TABLE staff MODIFY principal_id INT NOT NULL,
+    ADD CONSTRAINT uq_staff_principal UNIQUE (principal_id),
+    ADD CONSTRAINT fk_staff_principal FOREIGN KEY (principal_id) REFERENCES principals(id);
+
+ALTER TABLE chats
+    ADD CONSTRAINT fk_chats_sender FOREIGN KEY (sender_id) REFERENCES principals(id),
+    ADD CONSTRAINT fk_chats_receiver FOREIGN KEY (receiver_id) REFERENCES principals(id);
+ALTER TABLE messages
+    ADD CONSTRAINT fk_messages_sender FOREIGN KEY (sender_id) REFERENCES principals(id),
+    ADD CONSTRAINT fk_messages_receiver FOREIGN KEY (receiver_id) REFERENCES principals(id);
+
+DELIMITER //
+
+DROP TRIGGER IF EXISTS doctors_principal //
+CREATE TRIGGER doctors_principal BEFORE INSERT ON doctors
+FOR EACH ROW
+BEGIN
+    IF NEW.principal_id IS NULL OR NEW.principal_id = 0 THEN
+        INSERT INTO principals (role) VALUES ('doctor');
+        SET NEW.principal_id = LAST_INSERT_ID();
+    END IF;
+END //
+
+DROP TRIGGER IF EXISTS patients_principal //
+CREATE TRIGGER patients_principal BEFORE INSERT ON patients
+FOR EACH ROW
+BEGIN
+    IF NEW.principal_id IS NULL OR NEW.principal_id = 0 THEN
+        INSERT INTO principals (role) VALUES ('patient');
+        SET NEW.principal_id = LAST_INSERT_ID();
+    END IF;
+END //
+
+DROP TRIGGER IF EXISTS staff_principal //
+CREATE TRIGGER staff_principal BEFORE INSERT ON staff
+FOR EACH ROW
+BEGIN
+    IF NEW.principal_id IS NULL OR NEW.principal_id = 0 THEN
+        INSERT INTO principals (role) VALUES ('staff');
+        SET NEW.principal_id = LAST_INSERT_ID();
+    END IF;
+END //
+
+-- GetAllChats looks the other participant up through principals.
+DROP PROCEDURE IF EXISTS GetAllChats //
+CREATE PROCEDURE GetAllChats(
+    IN p_principal_id INT
+)
+BEGIN
+    SELECT
+        c.id AS chat_id,
+        o.id AS other_principal_id,
+        o.role AS other_role,
+        CASE
+            WHEN d.id IS NOT NULL THEN CONCAT(d.first_name, ' ', d.last_name)
+            WHEN p.id IS NOT NULL THEN CONCAT(p.first_name, ' ', p.last_name)
+        END AS other_user_name,
+        CASE
+            WHEN d.id IS NOT NULL THEN d.profile_photo_path
+            WHEN p.id IS NOT NULL THEN p.profile_photo_path
+        END AS other_user_image
+    FROM chats c
+    JOIN principals o
+        ON o.id = IF(c.sender_id = p_principal_id, c.receiver_id, c.sender_id)
+    LEFT JOIN doctors d ON d.principal_id = o.id
+    LEFT JOIN patients p ON p.principal_id = o.id
+    WHERE c.sender_id = p_principal_id OR c.receiver_id = p_principal_id;
+END //
+
+DELIMITER ;
@@ -0,0 +1,18 @@
+-- Single-use tickets for opening the chat WebSocket, so browsers need not
+-- put their access token in the URL. A ticket is issued for an access token
+-- and stands in for it: it belongs to the token's session and the
+-- connection it opens ends when the token would have expired. Only the
+-- SHA-256 hash of a ticket is stored, and redeeming it deletes the row.
+
+CREATE TABLE IF NOT EXISTS ws_tickets (
+    ticket_hash CHAR(64) NOT NULL PRIMARY KEY,
+    session_id CHAR(32) NOT NULL,
+    user_id INT NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    phone_number CHAR(11) NOT NULL,
+    token_expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    INDEX idx_ws_tickets_expires (expires_at),
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
@@ -0,0 +1,13 @@
+-- Dependents are patients managed by a guardian patient, typically their
+-- children. They have their own national code, birth date and records, but
+-- no phone number or password until the account is handed over to them
+-- once they come of age; handing over clears guardian_id. A guardian
+-- cannot be deleted while they have dependents, who would otherwise be
+-- left with no one able to log in as or manage them.
+
+ALTER TABLE patients
+    MODIFY phone_number CHAR(11) NULL,
+    MODIFY password VARCHAR(255) NULL,
+    ADD COLUMN guardian_id INT NULL,
+    ADD COLUMN birth_date DATE NULL,
+    ADD CONSTRAINT fk_patients_guardian FOREIGN KEY (guardian_id) REFERENCES patients(id) ON DELETE RESTRICT;
@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"strings"
+)
+
+// SplitStatements splits a SQL script into individual statements the same
+// way the mysql command-line client does: statements end with the current
+// delimiter, and a `DELIMITER xx` line changes it so that stored procedure
+// bodies containing semicolons can be written naturally. Comment-only lines
+// outside a statement are dropped.
+func SplitStatements(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+		delimiter  = ";"
+	)
+
+	flush := func() {
+		stmt := strings.TrimSpace(current.String())
+		current.Reset()
+		if stmt != "" {
+			statements = append(statements, stmt)
+		}
+	}
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if fields := strings.Fields(trimmed); len(fields) == 2 && strings.EqualFold(fields[0], "DELIMITER") {
+			flush()
+			delimiter = fields[1]
+			continue
+		}
+
+		if current.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "--")) {
+			continue
+		}
+
+		if strings.HasSuffix(trimmed, delimiter) {
+			current.WriteString(strings.TrimSuffix(strings.TrimRight(line, " \t\r"), delimiter))
+			flush()
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+	}
+	flush()
+
+	return statements
+}
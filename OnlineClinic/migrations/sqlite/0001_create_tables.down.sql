@@ -0,0 +1,10 @@
+-- Drops every table created by 0001, children before parents.
+
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS chats;
+DROP TABLE IF EXISTS medications;
+DROP TABLE IF EXISTS prescriptions;
+DROP TABLE IF EXISTS appointments;
+DROP TABLE IF EXISTS doctor_availability;
+DROP TABLE IF EXISTS patients;
+DROP TABLE IF EXISTS doctors;
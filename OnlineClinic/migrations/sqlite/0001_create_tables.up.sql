@@ -0,0 +1,98 @@
+-- SQLite version of mysql/0001_create_tables. ENUM columns become TEXT with
+-- a CHECK constraint, and the patients sequence is primed so patient IDs
+-- start at 1000000 like they do on MySQL and never collide with doctor IDs.
+
+CREATE TABLE IF NOT EXISTS doctors (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    first_name VARCHAR(50) NOT NULL,
+    last_name VARCHAR(50) NOT NULL,
+    national_code CHAR(10) NOT NULL UNIQUE,
+    gender TEXT NOT NULL CHECK (gender IN ('man', 'woman')),
+    phone_number CHAR(11) NOT NULL UNIQUE,
+    password VARCHAR(255) NOT NULL,
+    age INT NULL,
+    education VARCHAR(100) NULL,
+    address TEXT NULL,
+    profile_photo_path VARCHAR(255) NULL,
+    medical_council_code VARCHAR(64) NULL
+);
+
+CREATE TABLE IF NOT EXISTS patients (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    first_name VARCHAR(50) NOT NULL,
+    last_name VARCHAR(50) NOT NULL,
+    national_code CHAR(10) NOT NULL UNIQUE,
+    gender TEXT NOT NULL CHECK (gender IN ('man', 'woman')),
+    phone_number CHAR(11) NOT NULL UNIQUE,
+    password VARCHAR(255) NOT NULL,
+    age INT,
+    job VARCHAR(100),
+    education VARCHAR(100),
+    address TEXT,
+    profile_photo_path VARCHAR(255)
+);
+
+INSERT INTO sqlite_sequence (name, seq)
+SELECT 'patients', 999999
+WHERE NOT EXISTS (SELECT 1 FROM sqlite_sequence WHERE name = 'patients');
+
+CREATE TABLE IF NOT EXISTS appointments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    patient_id INT NOT NULL,
+    doctor_id INT NOT NULL,
+    start_time DATETIME NOT NULL,
+    end_time DATETIME NOT NULL,
+    visit_type TEXT NOT NULL CHECK (visit_type IN ('online', 'in-person')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (patient_id) REFERENCES patients(id),
+    FOREIGN KEY (doctor_id) REFERENCES doctors(id)
+);
+
+CREATE TABLE IF NOT EXISTS prescriptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    appointment_id INT NOT NULL,
+    instructions TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_appointment UNIQUE (appointment_id),
+    FOREIGN KEY (appointment_id) REFERENCES appointments(id)
+);
+
+CREATE TABLE IF NOT EXISTS medications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    prescription_id INT NOT NULL,
+    medicine VARCHAR(255) NOT NULL,
+    frequency VARCHAR(255) NOT NULL,
+    FOREIGN KEY (prescription_id) REFERENCES prescriptions(id)
+);
+
+CREATE TABLE IF NOT EXISTS chats (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sender_id INT NOT NULL,
+    receiver_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_id INT NOT NULL,
+    sender_id INT NOT NULL,
+    receiver_id INT NOT NULL,
+    text TEXT NOT NULL,
+    time VARCHAR(10) NOT NULL,
+    replied_message TEXT NULL,
+    replied_message_id INT NULL,
+    date VARCHAR(10) NOT NULL,
+    attached_file_path VARCHAR(255),
+    is_read BOOLEAN DEFAULT FALSE,
+    FOREIGN KEY (chat_id) REFERENCES chats(id),
+    FOREIGN KEY (replied_message_id) REFERENCES messages(id)
+);
+
+CREATE TABLE IF NOT EXISTS doctor_availability (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    doctor_id INT NOT NULL,
+    start_time DATETIME NOT NULL,
+    end_time DATETIME NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('online', 'in-person')),
+    FOREIGN KEY (doctor_id) REFERENCES doctors(id)
+);
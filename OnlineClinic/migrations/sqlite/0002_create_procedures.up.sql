@@ -0,0 +1,3 @@
+-- SQLite has no stored procedures. The logic of mysql/0002 lives in the
+-- SQLite repositories in models/sqlite_store.go; this file only keeps the
+-- version numbers of both dialects in step.
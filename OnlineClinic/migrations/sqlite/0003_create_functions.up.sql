@@ -0,0 +1,3 @@
+-- SQLite has no stored functions. Solar dates are converted in Go by the
+-- utils package; this file only keeps the version numbers of both dialects
+-- in step.
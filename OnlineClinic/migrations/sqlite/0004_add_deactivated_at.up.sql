@@ -0,0 +1,4 @@
+-- Deactivated accounts keep their data but can no longer log in.
+
+ALTER TABLE doctors ADD COLUMN deactivated_at TIMESTAMP NULL;
+ALTER TABLE patients ADD COLUMN deactivated_at TIMESTAMP NULL;
@@ -0,0 +1,18 @@
+-- SQLite version of mysql/0005_create_sessions.
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id CHAR(32) NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    refresh_token_hash CHAR(64) NOT NULL UNIQUE,
+    previous_token_hash CHAR(64) NULL,
+    user_agent VARCHAR(255) NULL,
+    ip_address VARCHAR(64) NULL,
+    created_at DATETIME NOT NULL,
+    last_used_at DATETIME NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions (user_id, role);
+CREATE INDEX IF NOT EXISTS idx_sessions_previous_token ON sessions (previous_token_hash);
@@ -0,0 +1,17 @@
+-- SQLite version of mysql/0006_create_staff. The sequence is primed so staff
+-- IDs start at 500000 like they do on MySQL.
+
+CREATE TABLE IF NOT EXISTS staff (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    first_name VARCHAR(50) NOT NULL,
+    last_name VARCHAR(50) NOT NULL,
+    phone_number CHAR(11) NOT NULL UNIQUE,
+    password VARCHAR(255) NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('receptionist', 'admin')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deactivated_at TIMESTAMP NULL
+);
+
+INSERT INTO sqlite_sequence (name, seq)
+SELECT 'staff', 499999
+WHERE NOT EXISTS (SELECT 1 FROM sqlite_sequence WHERE name = 'staff');
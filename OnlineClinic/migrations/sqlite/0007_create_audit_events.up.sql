@@ -0,0 +1,15 @@
+-- SQLite version of mysql/0007_create_audit_events.
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    actor_role VARCHAR(16) NULL,
+    actor_id INTEGER NULL,
+    subject VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(64) NULL,
+    detail TEXT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created ON audit_events (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action, created_at);
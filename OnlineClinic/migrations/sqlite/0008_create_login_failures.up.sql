@@ -0,0 +1,9 @@
+-- SQLite version of mysql/0008_create_login_failures.
+
+CREATE TABLE IF NOT EXISTS login_failures (
+    throttle_key VARCHAR(128) NOT NULL PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_failures_last ON login_failures (last_failure);
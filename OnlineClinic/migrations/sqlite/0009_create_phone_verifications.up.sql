@@ -0,0 +1,13 @@
+-- SQLite version of mysql/0009_create_phone_verifications.
+
+CREATE TABLE IF NOT EXISTS phone_verifications (
+    phone_number VARCHAR(11) NOT NULL,
+    purpose VARCHAR(64) NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    PRIMARY KEY (phone_number, purpose)
+);
+
+CREATE INDEX IF NOT EXISTS idx_phone_verifications_expires ON phone_verifications (expires_at);
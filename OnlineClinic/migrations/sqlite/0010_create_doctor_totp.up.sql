@@ -0,0 +1,20 @@
+-- SQLite version of mysql/0010_create_doctor_totp.
+
+CREATE TABLE IF NOT EXISTS doctor_totp (
+    doctor_id INTEGER NOT NULL PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL,
+    created_at DATETIME NOT NULL,
+    enabled_at DATETIME NULL,
+    last_used_step BIGINT NULL,
+    FOREIGN KEY (doctor_id) REFERENCES doctors(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS doctor_recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    doctor_id INTEGER NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used_at DATETIME NULL,
+    FOREIGN KEY (doctor_id) REFERENCES doctors(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_doctor_recovery_codes_doctor ON doctor_recovery_codes (doctor_id);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS audit_chain;
+DROP INDEX IF EXISTS idx_audit_events_patient;
+ALTER TABLE audit_events DROP COLUMN hash;
+ALTER TABLE audit_events DROP COLUMN prev_hash;
+ALTER TABLE audit_events DROP COLUMN patient_id;
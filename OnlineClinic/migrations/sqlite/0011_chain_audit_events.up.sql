@@ -0,0 +1,13 @@
+-- SQLite version of mysql/0011_chain_audit_events.
+
+ALTER TABLE audit_events ADD COLUMN patient_id INTEGER NULL;
+ALTER TABLE audit_events ADD COLUMN prev_hash CHAR(64) NULL;
+ALTER TABLE audit_events ADD COLUMN hash CHAR(64) NULL;
+CREATE INDEX IF NOT EXISTS idx_audit_events_patient ON audit_events (patient_id, created_at);
+
+CREATE TABLE IF NOT EXISTS audit_chain (
+    id INTEGER NOT NULL PRIMARY KEY,
+    last_hash CHAR(64) NOT NULL
+);
+
+INSERT INTO audit_chain (id, last_hash) VALUES (1, '');
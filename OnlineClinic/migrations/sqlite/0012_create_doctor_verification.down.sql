@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS doctor_verification_reviews;
+DROP TABLE IF EXISTS doctor_documents;
+DROP INDEX IF EXISTS idx_doctors_verification_status;
+ALTER TABLE doctors DROP COLUMN verification_status;
@@ -0,0 +1,33 @@
+-- SQLite version of mysql/0012_create_doctor_verification.
+
+ALTER TABLE doctors ADD COLUMN verification_status VARCHAR(16) NOT NULL DEFAULT 'pending';
+UPDATE doctors SET verification_status = 'approved';
+CREATE INDEX IF NOT EXISTS idx_doctors_verification_status ON doctors (verification_status);
+
+CREATE TABLE IF NOT EXISTS doctor_documents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    doctor_id INTEGER NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(64) NOT NULL,
+    size BIGINT NOT NULL,
+    path VARCHAR(255) NOT NULL,
+    uploaded_at DATETIME NOT NULL,
+    FOREIGN KEY (doctor_id) REFERENCES doctors(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_doctor_documents_doctor ON doctor_documents (doctor_id);
+
+CREATE TABLE IF NOT EXISTS doctor_verification_reviews (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    doctor_id INTEGER NOT NULL,
+    from_status VARCHAR(16) NOT NULL,
+    to_status VARCHAR(16) NOT NULL,
+    reviewer_role VARCHAR(16) NULL,
+    reviewer_id INTEGER NULL,
+    notes TEXT NULL,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (doctor_id) REFERENCES doctors(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_doctor_verification_reviews_doctor ON doctor_verification_reviews (doctor_id, created_at);
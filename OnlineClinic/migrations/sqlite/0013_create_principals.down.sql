@@ -0,0 +1,68 @@
+-- SQLite version of mysql/0013_create_principals.down: chats and messages
+-- are rebuilt without the principal foreign keys and go back to account IDs.
+
+DROP TRIGGER IF EXISTS doctors_principal;
+DROP TRIGGER IF EXISTS patients_principal;
+DROP TRIGGER IF EXISTS staff_principal;
+
+ALTER TABLE messages RENAME TO messages_old;
+ALTER TABLE chats RENAME TO chats_old;
+
+CREATE TABLE chats (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sender_id INT NOT NULL,
+    receiver_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_id INT NOT NULL,
+    sender_id INT NOT NULL,
+    receiver_id INT NOT NULL,
+    text TEXT NOT NULL,
+    time VARCHAR(10) NOT NULL,
+    replied_message TEXT NULL,
+    replied_message_id INT NULL,
+    date VARCHAR(10) NOT NULL,
+    attached_file_path VARCHAR(255),
+    is_read BOOLEAN DEFAULT FALSE,
+    FOREIGN KEY (chat_id) REFERENCES chats(id),
+    FOREIGN KEY (replied_message_id) REFERENCES messages(id)
+);
+
+INSERT INTO chats (id, sender_id, receiver_id, created_at)
+SELECT
+    id,
+    COALESCE((SELECT id FROM doctors WHERE principal_id = c.sender_id),
+             (SELECT id FROM patients WHERE principal_id = c.sender_id), c.sender_id),
+    COALESCE((SELECT id FROM doctors WHERE principal_id = c.receiver_id),
+             (SELECT id FROM patients WHERE principal_id = c.receiver_id), c.receiver_id),
+    created_at
+FROM chats_old c ORDER BY id;
+
+INSERT INTO messages (
+    id, chat_id, sender_id, receiver_id, text, time, replied_message,
+    replied_message_id, date, attached_file_path, is_read
+)
+SELECT
+    id, chat_id,
+    COALESCE((SELECT id FROM doctors WHERE principal_id = m.sender_id),
+             (SELECT id FROM patients WHERE principal_id = m.sender_id), m.sender_id),
+    COALESCE((SELECT id FROM doctors WHERE principal_id = m.receiver_id),
+             (SELECT id FROM patients WHERE principal_id = m.receiver_id), m.receiver_id),
+    text, time, replied_message,
+    replied_message_id, date, attached_file_path, is_read
+FROM messages_old m ORDER BY id;
+
+DROP TABLE messages_old;
+DROP TABLE chats_old;
+
+DROP INDEX IF EXISTS uq_doctors_principal;
+DROP INDEX IF EXISTS uq_patients_principal;
+DROP INDEX IF EXISTS uq_staff_principal;
+ALTER TABLE doctors DROP COLUMN principal_id;
+ALTER TABLE patients DROP COLUMN principal_id;
+ALTER TABLE staff DROP COLUMN principal_id;
+
+DROP TABLE IF EXISTS principals;
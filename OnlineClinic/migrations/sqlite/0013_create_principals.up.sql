@@ -0,0 +1,105 @@
+-- SQLite version of mysql/0013_create_principals. Foreign keys cannot be
+-- added to existing tables, so chats and messages are rebuilt, and the
+-- triggers set principal_id after the insert instead of before.
+
+CREATE TABLE IF NOT EXISTS principals (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    role TEXT NOT NULL CHECK (role IN ('patient', 'doctor', 'staff')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO principals (id, role) SELECT id, 'doctor' FROM doctors;
+INSERT INTO principals (id, role) SELECT id, 'staff' FROM staff;
+INSERT INTO principals (id, role) SELECT id, 'patient' FROM patients;
+
+INSERT INTO principals (id, role)
+SELECT u.id, CASE WHEN u.id >= 1000000 THEN 'patient' ELSE 'doctor' END
+FROM (
+    SELECT sender_id AS id FROM chats
+    UNION SELECT receiver_id FROM chats
+    UNION SELECT sender_id FROM messages
+    UNION SELECT receiver_id FROM messages
+) u
+WHERE NOT EXISTS (SELECT 1 FROM principals p WHERE p.id = u.id);
+
+ALTER TABLE doctors ADD COLUMN principal_id INTEGER NULL REFERENCES principals(id);
+ALTER TABLE patients ADD COLUMN principal_id INTEGER NULL REFERENCES principals(id);
+ALTER TABLE staff ADD COLUMN principal_id INTEGER NULL REFERENCES principals(id);
+
+UPDATE doctors SET principal_id = id;
+UPDATE patients SET principal_id = id;
+UPDATE staff SET principal_id = id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_doctors_principal ON doctors (principal_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_patients_principal ON patients (principal_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_staff_principal ON staff (principal_id);
+
+ALTER TABLE messages RENAME TO messages_old;
+ALTER TABLE chats RENAME TO chats_old;
+
+CREATE TABLE chats (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sender_id INT NOT NULL,
+    receiver_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (sender_id) REFERENCES principals(id),
+    FOREIGN KEY (receiver_id) REFERENCES principals(id)
+);
+
+CREATE TABLE messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_id INT NOT NULL,
+    sender_id INT NOT NULL,
+    receiver_id INT NOT NULL,
+    text TEXT NOT NULL,
+    time VARCHAR(10) NOT NULL,
+    replied_message TEXT NULL,
+    replied_message_id INT NULL,
+    date VARCHAR(10) NOT NULL,
+    attached_file_path VARCHAR(255),
+    is_read BOOLEAN DEFAULT FALSE,
+    FOREIGN KEY (chat_id) REFERENCES chats(id),
+    FOREIGN KEY (replied_message_id) REFERENCES messages(id),
+    FOREIGN KEY (sender_id) REFERENCES principals(id),
+    FOREIGN KEY (receiver_id) REFERENCES principals(id)
+);
+
+INSERT INTO chats (id, sender_id, receiver_id, created_at)
+SELECT id, sender_id, receiver_id, created_at FROM chats_old ORDER BY id;
+
+INSERT INTO messages (
+    id, chat_id, sender_id, receiver_id, text, time, replied_message,
+    replied_message_id, date, attached_file_path, is_read
+)
+SELECT
+    id, chat_id, sender_id, receiver_id, text, time, replied_message,
+    replied_message_id, date, attached_file_path, is_read
+FROM messages_old ORDER BY id;
+
+DROP TABLE messages_old;
+DROP TABLE chats_old;
+
+DELIMITER //
+
+CREATE TRIGGER IF NOT EXISTS doctors_principal AFTER INSERT ON doctors
+FOR EACH ROW WHEN NEW.principal_id IS NULL
+BEGIN
+    INSERT INTO principals (role) VALUES ('doctor');
+    UPDATE doctors SET principal_id = last_insert_rowid() WHERE id = NEW.id;
+END //
+
+CREATE TRIGGER IF NOT EXISTS patients_principal AFTER INSERT ON patients
+FOR EACH ROW WHEN NEW.principal_id IS NULL
+BEGIN
+    INSERT INTO principals (role) VALUES ('patient');
+    UPDATE patients SET principal_id = last_insert_rowid() WHERE id = NEW.id;
+END //
+
+CREATE TRIGGER IF NOT EXISTS staff_principal AFTER INSERT ON staff
+FOR EACH ROW WHEN NEW.principal_id IS NULL
+BEGIN
+    INSERT INTO principals (role) VALUES ('staff');
+    UPDATE staff SET principal_id = last_insert_rowid() WHERE id = NEW.id;
+END //
+
+DELIMITER ;
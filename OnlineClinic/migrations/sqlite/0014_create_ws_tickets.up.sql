@@ -0,0 +1,15 @@
+-- SQLite version of mysql/0014_create_ws_tickets.
+
+CREATE TABLE IF NOT EXISTS ws_tickets (
+    ticket_hash CHAR(64) NOT NULL PRIMARY KEY,
+    session_id CHAR(32) NOT NULL,
+    user_id INTEGER NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    phone_number CHAR(11) NOT NULL,
+    token_expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_ws_tickets_expires ON ws_tickets (expires_at);
@@ -0,0 +1,53 @@
+-- SQLite version of mysql/0015_create_dependents.down, rebuilding patients
+-- the same way as the up migration. The copy fails while there are
+-- dependents without a phone number, which rolls the rebuild back.
+
+DELIMITER //
+
+PRAGMA foreign_keys = OFF;
+BEGIN;
+
+CREATE TABLE patients_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    first_name VARCHAR(50) NOT NULL,
+    last_name VARCHAR(50) NOT NULL,
+    national_code CHAR(10) NOT NULL UNIQUE,
+    gender TEXT NOT NULL CHECK (gender IN ('man', 'woman')),
+    phone_number CHAR(11) NOT NULL UNIQUE,
+    password VARCHAR(255) NOT NULL,
+    age INT,
+    job VARCHAR(100),
+    education VARCHAR(100),
+    address TEXT,
+    profile_photo_path VARCHAR(255),
+    deactivated_at TIMESTAMP NULL,
+    principal_id INTEGER NULL REFERENCES principals(id)
+);
+
+INSERT INTO patients_old (
+    id, first_name, last_name, national_code, gender, phone_number, password,
+    age, job, education, address, profile_photo_path, deactivated_at, principal_id
+)
+SELECT
+    id, first_name, last_name, national_code, gender, phone_number, COALESCE(password, ''),
+    age, job, education, address, profile_photo_path, deactivated_at, principal_id
+FROM patients ORDER BY id;
+
+DELETE FROM sqlite_sequence WHERE name = 'patients_old';
+INSERT INTO sqlite_sequence (name, seq)
+SELECT 'patients_old', seq FROM sqlite_sequence WHERE name = 'patients';
+
+DROP TABLE patients;
+ALTER TABLE patients_old RENAME TO patients;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_patients_principal ON patients (principal_id);
+
+CREATE TRIGGER IF NOT EXISTS patients_principal AFTER INSERT ON patients
+FOR EACH ROW WHEN NEW.principal_id IS NULL
+BEGIN
+    INSERT INTO principals (role) VALUES ('patient');
+    UPDATE patients SET principal_id = last_insert_rowid() WHERE id = NEW.id;
+END;
+
+COMMIT;
+PRAGMA foreign_keys = ON //
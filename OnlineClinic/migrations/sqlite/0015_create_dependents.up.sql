@@ -0,0 +1,61 @@
+-- SQLite version of mysql/0015_create_dependents. Columns cannot be made
+-- nullable in place, so patients is rebuilt. Foreign key checks are turned
+-- off for the rebuild, as appointments keep referring to patients while it
+-- is dropped and recreated, and the pragma only takes effect outside a
+-- transaction on the same connection, which is why the steps run as one
+-- statement.
+
+DELIMITER //
+
+PRAGMA foreign_keys = OFF;
+BEGIN;
+
+CREATE TABLE patients_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    first_name VARCHAR(50) NOT NULL,
+    last_name VARCHAR(50) NOT NULL,
+    national_code CHAR(10) NOT NULL UNIQUE,
+    gender TEXT NOT NULL CHECK (gender IN ('man', 'woman')),
+    phone_number CHAR(11) NULL UNIQUE,
+    password VARCHAR(255) NULL,
+    age INT,
+    job VARCHAR(100),
+    education VARCHAR(100),
+    address TEXT,
+    profile_photo_path VARCHAR(255),
+    deactivated_at TIMESTAMP NULL,
+    principal_id INTEGER NULL REFERENCES principals(id),
+    guardian_id INTEGER NULL REFERENCES patients(id) ON DELETE RESTRICT,
+    birth_date DATE NULL
+);
+
+INSERT INTO patients_new (
+    id, first_name, last_name, national_code, gender, phone_number, password,
+    age, job, education, address, profile_photo_path, deactivated_at, principal_id
+)
+SELECT
+    id, first_name, last_name, national_code, gender, phone_number, password,
+    age, job, education, address, profile_photo_path, deactivated_at, principal_id
+FROM patients ORDER BY id;
+
+-- Keep patient IDs counting from where they were, not from the highest
+-- remaining one.
+DELETE FROM sqlite_sequence WHERE name = 'patients_new';
+INSERT INTO sqlite_sequence (name, seq)
+SELECT 'patients_new', seq FROM sqlite_sequence WHERE name = 'patients';
+
+DROP TABLE patients;
+ALTER TABLE patients_new RENAME TO patients;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_patients_principal ON patients (principal_id);
+CREATE INDEX IF NOT EXISTS idx_patients_guardian ON patients (guardian_id);
+
+CREATE TRIGGER IF NOT EXISTS patients_principal AFTER INSERT ON patients
+FOR EACH ROW WHEN NEW.principal_id IS NULL
+BEGIN
+    INSERT INTO principals (role) VALUES ('patient');
+    UPDATE patients SET principal_id = last_insert_rowid() WHERE id = NEW.id;
+END;
+
+COMMIT;
+PRAGMA foreign_keys = ON //
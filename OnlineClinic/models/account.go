@@ -0,0 +1,49 @@
+// models/account.go
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// setAccountActive clears or sets deactivated_at of the account id in table
+// ("doctors", "patients" or "staff"). Deactivating an account twice keeps
+// the first time and ends the account's sessions, which were started under
+// role. It returns sql.ErrNoRows if there is no such account.
+func setAccountActive(db *sql.DB, table, role string, id int, active bool) error {
+	query := "UPDATE " + table + " SET deactivated_at = COALESCE(deactivated_at, CURRENT_TIMESTAMP) WHERE id = ?"
+	if active {
+		query = "UPDATE " + table + " SET deactivated_at = NULL WHERE id = ?"
+	}
+	if _, err := db.Exec(query, id); err != nil {
+		return err
+	}
+
+	// RowsAffected is 0 for an unchanged row on MySQL, so check existence apart.
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM "+table+" WHERE id = ?)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	if !active {
+		_, err := revokeUserSessions(db, id, role)
+		return err
+	}
+	return nil
+}
+
+// accountDeactivatedAt returns when the account id in table was
+// deactivated, or nil if it is active.
+func accountDeactivatedAt(db *sql.DB, table string, id int) (*time.Time, error) {
+	var deactivatedAt sql.NullTime
+	err := db.QueryRow("SELECT deactivated_at FROM "+table+" WHERE id = ?", id).Scan(&deactivatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if !deactivatedAt.Valid {
+		return nil, nil
+	}
+	return &deactivatedAt.Time, nil
+}
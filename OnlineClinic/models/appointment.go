@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"onlineClinic/utils"
 	"sort"
 	"strconv"
@@ -12,16 +11,17 @@ import (
 )
 
 type Appointment struct {
-	ID         int       `json:"id"`
-	PatientID  int       `json:"patientId"`
-	DoctorID   int       `json:"doctorId"`
-	StartTime  time.Time `json:"startTime"`
-	EndTime    time.Time `json:"endTime"`
-	VisitType  string    `json:"visitType"` // 'online' or 'in-person'
-	CreatedAt  time.Time `json:"createdAt"`
-	DoctorName string    `json:"name,omitempty"` // For GET responses
-	Date       string    `json:"date,omitempty"` // For GET responses
-	Time       string    `json:"time,omitempty"` // For GET responses
+	ID          int       `json:"id"`
+	PatientID   int       `json:"patientId"`
+	DoctorID    int       `json:"doctorId"`
+	StartTime   time.Time `json:"startTime"`
+	EndTime     time.Time `json:"endTime"`
+	VisitType   string    `json:"visitType"` // 'online' or 'in-person'
+	CreatedAt   time.Time `json:"createdAt"`
+	DoctorName  string    `json:"name,omitempty"`        // For GET responses
+	PatientName string    `json:"patientName,omitempty"` // For doctor-side GET responses
+	Date        string    `json:"date,omitempty"`        // For GET responses
+	Time        string    `json:"time,omitempty"`        // For GET responses
 }
 
 type AppointmentResponse struct {
@@ -43,46 +43,37 @@ type AppointmentRequest struct {
 }
 
 var (
-	ErrTimeNotAvailable = errors.New("selected time slot is not available")
-	ErrInvalidTimeSlot  = errors.New("invalid time slot")
+	ErrTimeNotAvailable    = errors.New("selected time slot is not available")
+	ErrAppointmentNotFound = errors.New("appointment not found")
+	ErrInvalidTimeSlot     = errors.New("invalid time slot")
 	// ErrTimeInPast is returned when the requested appointment time is in the past.
 	ErrTimeInPast = fmt.Errorf("cannot book appointment for a time in the past")
 )
 
 func (ar *AppointmentRequest) ToAppointment() (*Appointment, error) {
-	log.Printf("Converting appointment request to appointment: %+v", ar)
-
 	// Convert IDs from string to int
 	doctorID, err := strconv.Atoi(ar.DoctorID)
 	if err != nil {
-		log.Printf("Invalid doctor ID format: %v", err)
 		return nil, errors.New("invalid doctor ID format")
 	}
 
 	patientID, err := strconv.Atoi(ar.PatientID)
 	if err != nil {
-		log.Printf("Invalid patient ID format: %v", err)
 		return nil, errors.New("invalid patient ID format")
 	}
 
 	// Convert Hijri date to Gregorian
 	gregorianDate, err := utils.SolarToGregorian(ar.Date) // Convert Hijri to Gregorian
 	if err != nil {
-		log.Printf("Error converting Hijri date to Gregorian: %v", err)
 		return nil, fmt.Errorf("invalid date format: %v", err)
 	}
 
-	log.Printf("Converted Hijri date %s to Gregorian: %v", ar.Date, gregorianDate)
-
 	// Parse the combined date and time (server is in Tehran/Iran, no location needed)
 	startTime, err := time.Parse("2006-01-02 15:04", gregorianDate.Format("2006-01-02")+" "+ar.Time)
 	if err != nil {
-		log.Printf("Error parsing date and time: %v", err)
 		return nil, fmt.Errorf("invalid date or time format: %v", err)
 	}
 
-	log.Printf("Parsed start time: %v", startTime)
-
 	// Calculate end time (15 minutes after start time)
 	endTime := startTime.Add(15 * time.Minute)
 
@@ -100,16 +91,12 @@ func (ar *AppointmentRequest) ToAppointment() (*Appointment, error) {
 		VisitType: visitType,
 	}
 
-	log.Printf("Converted to appointment: %+v", appointment)
 	return appointment, nil
 }
 
 func CreateAppointment(db *sql.DB, req *AppointmentRequest) error {
-	log.Printf("Processing appointment request: %+v", req)
-
 	appointment, err := req.ToAppointment()
 	if err != nil {
-		log.Printf("Error converting request to appointment: %v", err)
 		return err
 	}
 
@@ -125,15 +112,10 @@ func CreateAppointment(db *sql.DB, req *AppointmentRequest) error {
         )`
 	err = db.QueryRow(query, appointment.DoctorID, appointment.StartTime, appointment.EndTime, appointment.VisitType).Scan(&available)
 	if err != nil {
-		log.Printf("Error executing availability query: %v", err)
-		log.Printf("Query: %s, Params: doctorID=%d, startTime=%v, endTime=%v, visitType=%s", query, appointment.DoctorID, appointment.StartTime, appointment.EndTime, appointment.VisitType)
-		return err
+		return fmt.Errorf("checking slot of doctor %d at %s: %w", appointment.DoctorID, appointment.StartTime.Format("2006-01-02 15:04"), err)
 	}
 
-	log.Printf("Availability check result: slot available=%v", available)
-
 	if !available {
-		log.Printf("Time slot not available for doctor %d at %v", appointment.DoctorID, appointment.StartTime)
 		return ErrTimeNotAvailable
 	}
 
@@ -144,8 +126,7 @@ func CreateAppointment(db *sql.DB, req *AppointmentRequest) error {
 	// Start transaction
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("Error starting transaction: %v", err)
-		return err
+		return fmt.Errorf("starting booking transaction: %w", err)
 	}
 	defer tx.Rollback()
 
@@ -161,15 +142,13 @@ func CreateAppointment(db *sql.DB, req *AppointmentRequest) error {
 		appointment.VisitType,
 	)
 	if err != nil {
-		log.Printf("Error inserting appointment: %v", err)
-		return err
+		return fmt.Errorf("inserting appointment: %w", err)
 	}
 
 	// Get the appointment ID
 	appointmentID, err := result.LastInsertId()
 	if err != nil {
-		log.Printf("Error getting last insert ID: %v", err)
-		return err
+		return fmt.Errorf("reading appointment id: %w", err)
 	}
 
 	// Create a new prescription
@@ -180,8 +159,7 @@ func CreateAppointment(db *sql.DB, req *AppointmentRequest) error {
 	}
 
 	if err := CreatePrescriptionDB(tx, prescription); err != nil {
-		log.Printf("Error creating prescription: %v", err)
-		return err
+		return fmt.Errorf("creating prescription for appointment %d: %w", appointmentID, err)
 	}
 
 	// Delete the availability slot
@@ -197,24 +175,18 @@ func CreateAppointment(db *sql.DB, req *AppointmentRequest) error {
 		appointment.VisitType,
 	)
 	if err != nil {
-		log.Printf("Error deleting availability slot: %v", err)
-		return err
+		return fmt.Errorf("removing booked slot: %w", err)
 	}
 
 	if err = tx.Commit(); err != nil {
-		log.Printf("Error committing transaction: %v", err)
-		return err
+		return fmt.Errorf("committing booking: %w", err)
 	}
 
-	log.Printf("Successfully created appointment %d and associated prescription", appointmentID)
 	return nil
 }
 
 func GetPatientTwoNearestAppointments(db *sql.DB, patientID int) ([]AppointmentResponse, error) {
-	log.Printf("Getting two nearest appointments for patient %d", patientID)
-
 	now := time.Now()
-	log.Printf("Current server time: %v", now.Format("2006-01-02 15:04:05"))
 
 	// Step 1: Fetch all recent and future appointments
 	query := `
@@ -233,7 +205,6 @@ func GetPatientTwoNearestAppointments(db *sql.DB, patientID int) ([]AppointmentR
 
 	rows, err := db.Query(query, patientID, now.Add(-15*time.Minute))
 	if err != nil {
-		log.Printf("Error querying appointments: %v", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -251,14 +222,12 @@ func GetPatientTwoNearestAppointments(db *sql.DB, patientID int) ([]AppointmentR
 			&appt.DoctorName,
 		)
 		if err != nil {
-			log.Printf("Error scanning appointment: %v", err)
 			return nil, err
 		}
 		appointments = append(appointments, appt)
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating rows: %v", err)
 		return nil, err
 	}
 
@@ -319,15 +288,11 @@ func GetPatientTwoNearestAppointments(db *sql.DB, patientID int) ([]AppointmentR
 		})
 	}
 
-	log.Printf("Returning %d nearest appointments for patient %d", len(result), patientID)
 	return result, nil
 }
 
 func GetDoctorTwoNearestAppointments(db *sql.DB, doctorID int) ([]AppointmentResponse, error) {
-	log.Printf("Getting two nearest appointments for doctor %d", doctorID)
-
 	now := time.Now()
-	log.Printf("Current server time: %v", now.Format("2006-01-02 15:04:05"))
 
 	// Step 1: Fetch all recent and future appointments
 	query := `
@@ -346,7 +311,6 @@ func GetDoctorTwoNearestAppointments(db *sql.DB, doctorID int) ([]AppointmentRes
 
 	rows, err := db.Query(query, doctorID, now.Add(-15*time.Minute))
 	if err != nil {
-		log.Printf("Error querying appointments: %v", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -364,14 +328,12 @@ func GetDoctorTwoNearestAppointments(db *sql.DB, doctorID int) ([]AppointmentRes
 			&appt.DoctorName,
 		)
 		if err != nil {
-			log.Printf("Error scanning appointment: %v", err)
 			return nil, err
 		}
 		appointments = append(appointments, appt)
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating rows: %v", err)
 		return nil, err
 	}
 
@@ -432,7 +394,6 @@ func GetDoctorTwoNearestAppointments(db *sql.DB, doctorID int) ([]AppointmentRes
 		})
 	}
 
-	log.Printf("Returning %d nearest appointments for doctor %d", len(result), doctorID)
 	return result, nil
 }
 
@@ -468,7 +429,7 @@ func GetAppointmentById(db *sql.DB, id int) (*Appointment, error) {
 
 	if err == sql.ErrNoRows {
 		// log.Printf("No appointment found with ID: %d", id)
-		return nil, errors.New("appointment not found")
+		return nil, ErrAppointmentNotFound
 	}
 	if err != nil {
 		// log.Printf("Error querying appointment: %v", err)
@@ -507,14 +468,145 @@ func GetAppointmentById(db *sql.DB, id int) (*Appointment, error) {
 	return &appointment, nil
 }
 
-func DeleteUnreservedAvailability(db *sql.DB, doctorID int, visitType string) error {
+// GetPatientAppointments retrieves every appointment of a patient with the
+// doctor's name, ordered by start time
+func GetPatientAppointments(db *sql.DB, patientID int) ([]Appointment, error) {
+	query := `
+        SELECT 
+            a.id,
+            a.doctor_id,
+            a.patient_id,
+            a.visit_type,
+            a.start_time,
+            a.end_time,
+            CONCAT(d.first_name, ' ', d.last_name) AS doctor_name
+        FROM appointments a
+        JOIN doctors d ON a.doctor_id = d.id
+        WHERE a.patient_id = ?
+        ORDER BY a.start_time ASC`
+
+	rows, err := db.Query(query, patientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var appointments []Appointment
+	for rows.Next() {
+		var appt Appointment
+		err := rows.Scan(
+			&appt.ID,
+			&appt.DoctorID,
+			&appt.PatientID,
+			&appt.VisitType,
+			&appt.StartTime,
+			&appt.EndTime,
+			&appt.DoctorName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		appointments = append(appointments, appt)
+	}
+
+	return appointments, rows.Err()
+}
+
+// GetDoctorAppointments retrieves every appointment of a doctor with the
+// patient's name, ordered by start time
+func GetDoctorAppointments(db *sql.DB, doctorID int) ([]Appointment, error) {
+	query := `
+        SELECT 
+            a.id,
+            a.doctor_id,
+            a.patient_id,
+            a.visit_type,
+            a.start_time,
+            a.end_time,
+            CONCAT(p.first_name, ' ', p.last_name) AS patient_name
+        FROM appointments a
+        JOIN patients p ON a.patient_id = p.id
+        WHERE a.doctor_id = ?
+        ORDER BY a.start_time ASC`
+
+	rows, err := db.Query(query, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var appointments []Appointment
+	for rows.Next() {
+		var appt Appointment
+		err := rows.Scan(
+			&appt.ID,
+			&appt.DoctorID,
+			&appt.PatientID,
+			&appt.VisitType,
+			&appt.StartTime,
+			&appt.EndTime,
+			&appt.PatientName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		appointments = append(appointments, appt)
+	}
+
+	return appointments, rows.Err()
+}
+
+// DoctorHasPatient reports whether the patient has ever had an appointment
+// with the doctor
+func DoctorHasPatient(db *sql.DB, doctorID, patientID int) (bool, error) {
+	query := `
+        SELECT EXISTS (
+            SELECT 1
+            FROM appointments
+            WHERE doctor_id = ? AND patient_id = ?
+        )`
+	var exists bool
+	err := db.QueryRow(query, doctorID, patientID).Scan(&exists)
+	return exists, err
+}
+
+// GetPatientDoctorIDs returns the doctors the patient has ever had an
+// appointment with
+func GetPatientDoctorIDs(db *sql.DB, patientID int) ([]int, error) {
+	rows, err := db.Query("SELECT DISTINCT doctor_id FROM appointments WHERE patient_id = ?", patientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var doctorIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		doctorIDs = append(doctorIDs, id)
+	}
+	return doctorIDs, rows.Err()
+}
+
+// GetAppointmentParticipants returns the patient and doctor of an appointment,
+// or sql.ErrNoRows if it does not exist
+func GetAppointmentParticipants(db *sql.DB, id int) (patientID, doctorID int, err error) {
+	err = db.QueryRow("SELECT patient_id, doctor_id FROM appointments WHERE id = ?", id).Scan(&patientID, &doctorID)
+	return patientID, doctorID, err
+}
+
+// DeleteUnreservedAvailability removes the doctor's future slots of the given
+// visit type and returns how many were deleted
+func DeleteUnreservedAvailability(db *sql.DB, doctorID int, visitType string) (int, error) {
 	// log.Printf("Starting DeleteUnreservedAvailability for doctor %d and type %s", doctorID, visitType)
 
 	// Start transaction
 	tx, err := db.Begin()
 	if err != nil {
 		// log.Printf("Error starting transaction: %v", err)
-		return fmt.Errorf("failed to start transaction: %v", err)
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
 	}
 	defer func() {
 		if err != nil {
@@ -532,14 +624,14 @@ func DeleteUnreservedAvailability(db *sql.DB, doctorID int, visitType string) er
 		doctorID, visitType)
 	if err != nil {
 		// log.Printf("Error calling delete procedure: %v", err)
-		return fmt.Errorf("failed to delete availability slots: %v", err)
+		return 0, fmt.Errorf("failed to delete availability slots: %v", err)
 	}
 
 	// Get the output parameter value
 	err = tx.QueryRow("SELECT @deleted_count").Scan(&deletedCount)
 	if err != nil {
 		// log.Printf("Error getting deleted count: %v", err)
-		return fmt.Errorf("failed to get deleted count: %v", err)
+		return 0, fmt.Errorf("failed to get deleted count: %v", err)
 	}
 
 	// log.Printf("Procedure reports %d slots deleted", deletedCount)
@@ -547,11 +639,11 @@ func DeleteUnreservedAvailability(db *sql.DB, doctorID int, visitType string) er
 	// Commit transaction
 	if err = tx.Commit(); err != nil {
 		// log.Printf("Error committing transaction: %v", err)
-		return fmt.Errorf("failed to commit transaction: %v", err)
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
 	// log.Printf("Successfully completed DeleteUnreservedAvailability for doctor %d. Deleted %d slots", doctorID, deletedCount)
-	return nil
+	return deletedCount, nil
 }
 
 func DeleteAppointment(db *sql.DB, id int) error {
@@ -580,6 +672,9 @@ func DeleteAppointment(db *sql.DB, id int) error {
                visit_type 
         FROM appointments 
         WHERE id = ?`, id).Scan(&doctorID, &startTime, &endTime, &visitType)
+	if err == sql.ErrNoRows {
+		return ErrAppointmentNotFound
+	}
 	if err != nil {
 		// log.Printf("Error getting appointment details: %v", err)
 		return err
@@ -619,7 +714,7 @@ func DeleteAppointment(db *sql.DB, id int) error {
 	}
 	if rowsAffected == 0 {
 		// log.Printf("No appointment found with ID: %d", id)
-		return errors.New("appointment not found")
+		return ErrAppointmentNotFound
 	}
 
 	// Restore the availability slot
@@ -640,3 +735,63 @@ func DeleteAppointment(db *sql.DB, id int) error {
 	// log.Printf("Successfully deleted appointment %d and restored availability slot", id)
 	return nil
 }
+
+// ReassignAppointment moves an appointment to another doctor at the same
+// time and visit type. The new doctor's matching slot is taken and the old
+// doctor's slot is given back, as if the patient had cancelled and rebooked.
+func ReassignAppointment(db *sql.DB, id, doctorID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		oldDoctorID int
+		start, end  time.Time
+		visitType   string
+	)
+	err = tx.QueryRow(`
+        SELECT doctor_id, start_time, end_time, visit_type
+        FROM appointments
+        WHERE id = ?`, id).Scan(&oldDoctorID, &start, &end, &visitType)
+	if err == sql.ErrNoRows {
+		return ErrAppointmentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if oldDoctorID == doctorID {
+		return nil
+	}
+	start, end = start.UTC(), end.UTC()
+
+	// Take the new doctor's slot
+	result, err := tx.Exec(`
+        DELETE FROM doctor_availability
+        WHERE doctor_id = ? AND start_time = ? AND end_time = ? AND type = ?`,
+		doctorID, start, end, visitType)
+	if err != nil {
+		return fmt.Errorf("taking slot of doctor %d: %w", doctorID, err)
+	}
+	if taken, err := result.RowsAffected(); err != nil {
+		return err
+	} else if taken == 0 {
+		return ErrTimeNotAvailable
+	}
+
+	if _, err := tx.Exec("UPDATE appointments SET doctor_id = ? WHERE id = ?", doctorID, id); err != nil {
+		return fmt.Errorf("reassigning appointment: %w", err)
+	}
+
+	// Give the old doctor's slot back
+	_, err = tx.Exec(`
+        INSERT INTO doctor_availability (doctor_id, start_time, end_time, type)
+        VALUES (?, ?, ?, ?)`,
+		oldDoctorID, start, end, visitType)
+	if err != nil {
+		return fmt.Errorf("restoring slot of doctor %d: %w", oldDoctorID, err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,295 @@
+// models/audit.go
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Audit actions.
+const (
+	AuditLoginLockout  = "login.lockout"
+	AuditPasswordReset = "password.reset"
+
+	AuditTwoFactorEnabled      = "2fa.enabled"
+	AuditTwoFactorDisabled     = "2fa.disabled"
+	AuditTwoFactorRecoveryCode = "2fa.recovery_code"
+
+	AuditDoctorVerification = "doctor.verification"
+
+	// Reads of a patient's medical data. These events carry PatientID.
+	AuditPatientInfoRead     = "patient_info.read"
+	AuditPrescriptionsRead   = "prescriptions.read"
+	AuditPrescriptionRead    = "prescription.read"
+	AuditAppointmentsRead    = "appointments.read"
+	AuditChatHistoryRead     = "chat_history.read"
+	AuditPrescriptionsSearch = "prescriptions.search"
+)
+
+// recordRetries is how often Record retries when other events were appended
+// to the chain concurrently.
+const recordRetries = 10
+
+// AuditEvent is one entry of the audit trail.
+type AuditEvent struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	Action    string    `json:"action"`
+	ActorRole string    `json:"actorRole,omitempty"`
+	ActorID   *int      `json:"actorId,omitempty"`
+	Subject   string    `json:"subject"` // The resource the event is about
+	PatientID *int      `json:"patientId,omitempty"`
+	IPAddress string    `json:"ipAddress,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	PrevHash  string    `json:"prevHash,omitempty"`
+	Hash      string    `json:"hash,omitempty"` // Empty for events recorded before hash chaining
+}
+
+// computeHash returns the chain hash of e following prevHash. The fields
+// are encoded as a JSON array, so that no two events encode alike.
+func (e *AuditEvent) computeHash(prevHash string) string {
+	encoded, _ := json.Marshal([]any{
+		prevHash, e.CreatedAt.UTC().Format(time.RFC3339), e.Action, e.ActorRole, e.ActorID,
+		e.Subject, e.PatientID, e.IPAddress, e.Detail,
+	})
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditFilter selects audit events. Zero fields match every event.
+type AuditFilter struct {
+	Action    string
+	ActorRole string
+	ActorID   int
+	PatientID int
+	Since     time.Time
+	Until     time.Time
+	BeforeID  int64 // Only events older than this one, for paging
+	Limit     int
+}
+
+// AuditChainReport is the result of checking the hash chain.
+type AuditChainReport struct {
+	Events    int64  `json:"events"`
+	Unchained int64  `json:"unchained"` // Events recorded before hash chaining
+	Head      string `json:"head"`
+	Intact    bool   `json:"intact"`
+	BrokenAt  int64  `json:"brokenAt,omitempty"` // ID of the first event that does not match
+	Problem   string `json:"problem,omitempty"`
+}
+
+// AuditRepository appends to and reads the audit trail. Events are never
+// changed or deleted, and each is chained to the one before by its hash.
+type AuditRepository interface {
+	Record(event *AuditEvent) error
+	List(filter AuditFilter) ([]AuditEvent, error)
+	Each(filter AuditFilter, fn func(AuditEvent) error) error
+	Verify() (*AuditChainReport, error)
+}
+
+// sqlAudit implements AuditRepository with SQL that runs on MySQL and SQLite.
+type sqlAudit struct{ db *sql.DB }
+
+// Record appends event to the chain, setting its ID, hashes and, if unset,
+// its time.
+func (r *sqlAudit) Record(event *AuditEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	event.CreatedAt = event.CreatedAt.UTC().Truncate(time.Second)
+
+	for attempt := 0; attempt < recordRetries; attempt++ {
+		appended, err := r.append(event)
+		if err != nil || appended {
+			return err
+		}
+	}
+	return errors.New("audit chain is too busy")
+}
+
+// append links event to the current head of the chain. It returns false if
+// another event was appended meanwhile.
+func (r *sqlAudit) append(event *AuditEvent) (bool, error) {
+	var head string
+	if err := r.db.QueryRow("SELECT last_hash FROM audit_chain WHERE id = 1").Scan(&head); err != nil {
+		return false, err
+	}
+	event.PrevHash = head
+	event.Hash = event.computeHash(head)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Moving the head locks it, so events are inserted in chain order.
+	result, err := tx.Exec("UPDATE audit_chain SET last_hash = ? WHERE id = 1 AND last_hash = ?", event.Hash, head)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	result, err = tx.Exec(`
+        INSERT INTO audit_events (created_at, action, actor_role, actor_id, subject, patient_id, ip_address, detail, prev_hash, hash)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.CreatedAt, event.Action, nullString(event.ActorRole), event.ActorID, event.Subject,
+		event.PatientID, nullString(event.IPAddress), nullString(event.Detail), event.PrevHash, event.Hash)
+	if err != nil {
+		return false, err
+	}
+	if event.ID, err = result.LastInsertId(); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// List returns the events matching filter, newest first.
+func (r *sqlAudit) List(filter AuditFilter) ([]AuditEvent, error) {
+	var events []AuditEvent
+	err := r.Each(filter, func(e AuditEvent) error {
+		events = append(events, e)
+		return nil
+	})
+	return events, err
+}
+
+// Each calls fn for the events matching filter, newest first, without
+// loading them all at once.
+func (r *sqlAudit) Each(filter AuditFilter, fn func(AuditEvent) error) error {
+	var where []string
+	var args []any
+	if filter.Action != "" {
+		where, args = append(where, "action = ?"), append(args, filter.Action)
+	}
+	if filter.ActorRole != "" {
+		where, args = append(where, "actor_role = ?"), append(args, filter.ActorRole)
+	}
+	if filter.ActorID != 0 {
+		where, args = append(where, "actor_id = ?"), append(args, filter.ActorID)
+	}
+	if filter.PatientID != 0 {
+		where, args = append(where, "patient_id = ?"), append(args, filter.PatientID)
+	}
+	if !filter.Since.IsZero() {
+		where, args = append(where, "created_at >= ?"), append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		where, args = append(where, "created_at < ?"), append(args, filter.Until.UTC())
+	}
+	if filter.BeforeID != 0 {
+		where, args = append(where, "id < ?"), append(args, filter.BeforeID)
+	}
+
+	query := auditColumns
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query, args = query+" LIMIT ?", append(args, filter.Limit)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Verify recomputes the hash chain from the oldest event on and reports the
+// first event that was changed, inserted or removed.
+func (r *sqlAudit) Verify() (*AuditChainReport, error) {
+	var report AuditChainReport
+	if err := r.db.QueryRow("SELECT last_hash FROM audit_chain WHERE id = 1").Scan(&report.Head); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(auditColumns + " ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prev, chained := "", false
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		report.Events++
+		if report.BrokenAt != 0 {
+			continue
+		}
+
+		switch {
+		case e.Hash == "" && !chained:
+			report.Unchained++
+			continue
+		case e.Hash == "":
+			report.BrokenAt, report.Problem = e.ID, "event has no hash"
+		case e.PrevHash != prev:
+			report.BrokenAt, report.Problem = e.ID, "event does not follow the one before it"
+		case e.computeHash(e.PrevHash) != e.Hash:
+			report.BrokenAt, report.Problem = e.ID, "event was changed"
+		}
+		chained, prev = true, e.Hash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if report.BrokenAt == 0 && prev != report.Head {
+		report.Problem = "newest events are missing"
+	}
+	report.Intact = report.Problem == ""
+	return &report, nil
+}
+
+const auditColumns = `
+        SELECT id, created_at, action, actor_role, actor_id, subject, patient_id, ip_address, detail, prev_hash, hash
+        FROM audit_events`
+
+func scanAuditEvent(rows *sql.Rows) (AuditEvent, error) {
+	var e AuditEvent
+	var actorRole, ip, detail, prevHash, hash sql.NullString
+	var actorID, patientID sql.NullInt64
+	err := rows.Scan(&e.ID, &e.CreatedAt, &e.Action, &actorRole, &actorID, &e.Subject, &patientID, &ip, &detail, &prevHash, &hash)
+	if err != nil {
+		return e, err
+	}
+	e.ActorRole, e.IPAddress, e.Detail = actorRole.String, ip.String, detail.String
+	e.PrevHash, e.Hash = prevHash.String, hash.String
+	if actorID.Valid {
+		id := int(actorID.Int64)
+		e.ActorID = &id
+	}
+	if patientID.Valid {
+		id := int(patientID.Int64)
+		e.PatientID = &id
+	}
+	return e, nil
+}
+
+// nullString stores an empty string as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -138,6 +138,12 @@ func GetChatHistory(db *sql.DB, userID, receiverID int) ([]Chat, error) {
 	}
 	defer rows.Close()
 
+	return scanChatHistory(rows)
+}
+
+// scanChatHistory reads the rows produced by the GetChatHistory procedure or
+// its SQLite equivalent.
+func scanChatHistory(rows *sql.Rows) ([]Chat, error) {
 	var chats []Chat
 	for rows.Next() {
 		var chat Chat
@@ -290,7 +296,7 @@ func ChatExists(db *sql.DB, senderID, receiverID int) (int, error) {
 	return chatID, nil
 }
 
-// GetAllChats retrieves all chats for a user (doctor or patient)
+// GetAllChats retrieves all chats of a principal (doctor or patient)
 func GetAllChats(db *sql.DB, userID int) ([]map[string]interface{}, error) {
 	rows, err := db.Query("CALL GetAllChats(?)", userID)
 	if err != nil {
@@ -299,13 +305,20 @@ func GetAllChats(db *sql.DB, userID int) ([]map[string]interface{}, error) {
 	}
 	defer rows.Close()
 
+	return scanChatList(rows)
+}
+
+// scanChatList reads the rows produced by the GetAllChats procedure or its
+// SQLite equivalent.
+func scanChatList(rows *sql.Rows) ([]map[string]interface{}, error) {
 	var chats []map[string]interface{}
 
 	for rows.Next() {
 		var chatID, otherUserID int
+		var otherRole string
 		var otherUserName, otherUserImage sql.NullString
 
-		err := rows.Scan(&chatID, &otherUserID, &otherUserName, &otherUserImage)
+		err := rows.Scan(&chatID, &otherUserID, &otherRole, &otherUserName, &otherUserImage)
 		if err != nil {
 			// log.Printf("Error scanning row: %v", err)
 			return nil, err
@@ -313,7 +326,8 @@ func GetAllChats(db *sql.DB, userID int) ([]map[string]interface{}, error) {
 
 		chat := map[string]interface{}{
 			"chat_id": chatID,
-			"id":      otherUserID,
+			"id":      otherUserID, // Principal ID
+			"role":    otherRole,
 			"name":    otherUserName.String,  // Use .String to handle NULL values
 			"image":   otherUserImage.String, // Use .String to handle NULL values
 		}
@@ -329,33 +343,36 @@ func GetAllChats(db *sql.DB, userID int) ([]map[string]interface{}, error) {
 	return chats, nil
 }
 
+// GetUnreadChats returns the chats of principal userID that have messages
+// it has not read, with the principal it chats with.
 func GetUnreadChats(db *sql.DB, userID int) ([]map[string]interface{}, error) {
 	query := `
-        SELECT 
+        SELECT
             c.id AS chat_id,
-            CASE 
-                WHEN c.sender_id = ? THEN c.receiver_id
-                ELSE c.sender_id
-            END AS other_user_id,
-            COALESCE(
-                (SELECT CONCAT(p.first_name, ' ', p.last_name) FROM patients p WHERE p.id = CASE WHEN c.sender_id = ? THEN c.receiver_id ELSE c.sender_id END),
-                (SELECT CONCAT(d.first_name, ' ', d.last_name) FROM doctors d WHERE d.id = CASE WHEN c.sender_id = ? THEN c.receiver_id ELSE c.sender_id END),
-                'Unknown'
-            ) AS full_name,
-            COALESCE(
-                (SELECT p.profile_photo_path FROM patients p WHERE p.id = CASE WHEN c.sender_id = ? THEN c.receiver_id ELSE c.sender_id END),
-                (SELECT d.profile_photo_path FROM doctors d WHERE d.id = CASE WHEN c.sender_id = ? THEN c.receiver_id ELSE c.sender_id END),
-                ''
-            ) AS profile_photo_path
+            o.id AS other_user_id,
+            o.role AS other_role,
+            CASE
+                WHEN d.id IS NOT NULL THEN CONCAT(d.first_name, ' ', d.last_name)
+                WHEN p.id IS NOT NULL THEN CONCAT(p.first_name, ' ', p.last_name)
+                ELSE 'Unknown'
+            END AS full_name,
+            COALESCE(CASE
+                WHEN d.id IS NOT NULL THEN d.profile_photo_path
+                WHEN p.id IS NOT NULL THEN p.profile_photo_path
+            END, '') AS profile_photo_path
         FROM chats c
-        JOIN messages m ON c.id = m.chat_id
+        JOIN principals o
+            ON o.id = CASE WHEN c.sender_id = ? THEN c.receiver_id ELSE c.sender_id END
+        LEFT JOIN doctors d ON d.principal_id = o.id
+        LEFT JOIN patients p ON p.principal_id = o.id
         WHERE (c.sender_id = ? OR c.receiver_id = ?)
-        AND m.sender_id != ?
-        AND m.is_read = FALSE
-        GROUP BY c.id
+        AND EXISTS (
+            SELECT 1 FROM messages m
+            WHERE m.chat_id = c.id AND m.sender_id != ? AND m.is_read = FALSE
+        )
     `
 
-	rows, err := db.Query(query, userID, userID, userID, userID, userID, userID, userID, userID)
+	rows, err := db.Query(query, userID, userID, userID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %v", err)
 	}
@@ -364,9 +381,10 @@ func GetUnreadChats(db *sql.DB, userID int) ([]map[string]interface{}, error) {
 	var unreadChats []map[string]interface{}
 	for rows.Next() {
 		var chatID, otherUserID int
-		var fullName, profilePhotoPath string
+		var otherRole string
+		var fullName, profilePhotoPath sql.NullString
 
-		err := rows.Scan(&chatID, &otherUserID, &fullName, &profilePhotoPath)
+		err := rows.Scan(&chatID, &otherUserID, &otherRole, &fullName, &profilePhotoPath)
 		if err != nil {
 			return nil, fmt.Errorf("scan error: %v", err)
 		}
@@ -374,10 +392,11 @@ func GetUnreadChats(db *sql.DB, userID int) ([]map[string]interface{}, error) {
 		unreadChats = append(unreadChats, map[string]interface{}{
 			"chat_id":            chatID,
 			"other_user_id":      otherUserID,
-			"full_name":          fullName,
-			"profile_photo_path": profilePhotoPath,
+			"other_role":         otherRole,
+			"full_name":          fullName.String,
+			"profile_photo_path": profilePhotoPath.String,
 		})
 	}
 
-	return unreadChats, nil
+	return unreadChats, rows.Err()
 }
@@ -0,0 +1,112 @@
+// models/consistency.go
+package models
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Inconsistency is a problem in the appointments and availability tables
+// that the booking code should never have let happen.
+type Inconsistency struct {
+	Kind          string `json:"kind"`
+	Message       string `json:"message"`
+	AppointmentID int    `json:"appointmentId,omitempty"`
+	SlotID        int    `json:"slotId,omitempty"`
+	DoctorID      int    `json:"doctorId,omitempty"`
+	PatientID     int    `json:"patientId,omitempty"`
+}
+
+// consistencyChecks select doctor_id, patient_id, appointment_id, slot_id
+// and a description of each problem. The SQL is valid on MySQL and on
+// SQLite 3.44 or later, which added CONCAT.
+var consistencyChecks = []struct {
+	kind  string
+	query string
+}{
+	{
+		// Booking deletes the slot, so a remaining one can be booked twice.
+		kind: "booked_slot_offered",
+		query: `
+            SELECT a.doctor_id, a.patient_id, a.id, s.id, 'slot is still offered for a booked appointment'
+            FROM appointments a
+            JOIN doctor_availability s
+              ON s.doctor_id = a.doctor_id AND s.start_time = a.start_time AND s.type = a.visit_type
+            ORDER BY a.id`,
+	},
+	{
+		kind: "doctor_double_booked",
+		query: `
+            SELECT a.doctor_id, b.patient_id, b.id, 0, CONCAT('overlaps appointment ', a.id, ' of the same doctor')
+            FROM appointments a
+            JOIN appointments b
+              ON b.doctor_id = a.doctor_id AND a.id < b.id
+             AND a.start_time < b.end_time AND b.start_time < a.end_time
+            ORDER BY b.id`,
+	},
+	{
+		kind: "patient_double_booked",
+		query: `
+            SELECT b.doctor_id, a.patient_id, b.id, 0, CONCAT('overlaps appointment ', a.id, ' of the same patient')
+            FROM appointments a
+            JOIN appointments b
+              ON b.patient_id = a.patient_id AND a.id < b.id
+             AND a.start_time < b.end_time AND b.start_time < a.end_time
+            ORDER BY b.id`,
+	},
+	{
+		kind: "missing_prescription",
+		query: `
+            SELECT a.doctor_id, a.patient_id, a.id, 0, 'appointment has no prescription'
+            FROM appointments a
+            LEFT JOIN prescriptions p ON p.appointment_id = a.id
+            WHERE p.id IS NULL
+            ORDER BY a.id`,
+	},
+	{
+		kind: "invalid_slot",
+		query: `
+            SELECT doctor_id, 0, 0, id, 'slot ends before it starts'
+            FROM doctor_availability
+            WHERE end_time <= start_time
+            ORDER BY id`,
+	},
+	{
+		// Overlapping slots, e.g. online and in-person at once, let two
+		// patients book the doctor for the same time.
+		kind: "overlapping_slots",
+		query: `
+            SELECT a.doctor_id, 0, 0, b.id, CONCAT('overlaps slot ', a.id, ' of the same doctor')
+            FROM doctor_availability a
+            JOIN doctor_availability b
+              ON b.doctor_id = a.doctor_id AND a.id < b.id
+             AND a.start_time < b.end_time AND b.start_time < a.end_time
+            ORDER BY b.id`,
+	},
+}
+
+// CheckConsistency runs every consistency check and returns the problems
+// found, grouped by kind.
+func CheckConsistency(db *sql.DB) ([]Inconsistency, error) {
+	var problems []Inconsistency
+	for _, check := range consistencyChecks {
+		rows, err := db.Query(check.query)
+		if err != nil {
+			return nil, fmt.Errorf("checking %s: %w", check.kind, err)
+		}
+		for rows.Next() {
+			p := Inconsistency{Kind: check.kind}
+			if err := rows.Scan(&p.DoctorID, &p.PatientID, &p.AppointmentID, &p.SlotID, &p.Message); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("checking %s: %w", check.kind, err)
+			}
+			problems = append(problems, p)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("checking %s: %w", check.kind, err)
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,148 @@
+// models/dependent.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// AgeOfMajority is the age, in years, at which a guardian stops acting for
+// a dependent and the account can be handed over to them.
+const AgeOfMajority = 18
+
+var (
+	ErrDependentNotFound  = errors.New("dependent not found")
+	ErrDependentMinor     = errors.New("dependent has not come of age")
+	ErrGuardianDependents = errors.New("patient is the guardian of dependents")
+)
+
+// Dependent is a patient, usually a child, whose account is managed by a
+// guardian patient. It has no phone number or password of its own until it
+// is handed over, but its appointments, prescriptions and chats are its own.
+type Dependent struct {
+	ID           int       `json:"id,string"`
+	PrincipalID  int       `json:"principalId"` // Read-only; identifies the dependent in chats
+	GuardianID   int       `json:"guardianId,string"`
+	FirstName    string    `json:"firstName"`
+	LastName     string    `json:"lastName"`
+	NationalCode string    `json:"nationalCode"`
+	Gender       string    `json:"gender"`
+	BirthDate    time.Time `json:"-"` // Midnight UTC of the Gregorian birth date
+}
+
+// AgeAt returns the age of d in whole years on the date of t.
+func (d *Dependent) AgeAt(t time.Time) int {
+	birth := d.BirthDate
+	age := t.Year() - birth.Year()
+	if t.Month() < birth.Month() || (t.Month() == birth.Month() && t.Day() < birth.Day()) {
+		age--
+	}
+	if age < 0 {
+		return 0
+	}
+	return age
+}
+
+// IsMinor reports whether d has not come of age at t.
+func (d *Dependent) IsMinor(t time.Time) bool {
+	return d.AgeAt(t) < AgeOfMajority
+}
+
+// DependentRepository stores dependents. They are rows of patients with a
+// guardian, so every patient query sees them too.
+type DependentRepository interface {
+	Create(dependent *Dependent) error
+	Get(id int) (*Dependent, error)
+	List(guardianID int) ([]Dependent, error)
+	HandOver(id int, phoneNumber string) error
+}
+
+// sqlDependents implements DependentRepository with SQL that runs on MySQL
+// and SQLite.
+type sqlDependents struct{ db *sql.DB }
+
+// Create adds dependent under its guardian and sets its ID and principal
+// ID. The age column is filled in for views that do not know birth dates.
+func (r *sqlDependents) Create(d *Dependent) error {
+	result, err := r.db.Exec(`
+        INSERT INTO patients (
+            first_name, last_name, national_code, gender, age, guardian_id, birth_date
+        ) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		d.FirstName, d.LastName, d.NationalCode, d.Gender, d.AgeAt(time.Now()), d.GuardianID, d.BirthDate)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	d.ID = int(id)
+	return r.db.QueryRow("SELECT principal_id FROM patients WHERE id = ?", d.ID).Scan(&d.PrincipalID)
+}
+
+// Get returns the dependent id, or ErrDependentNotFound if it is not a
+// patient with a guardian.
+func (r *sqlDependents) Get(id int) (*Dependent, error) {
+	var d Dependent
+	err := r.db.QueryRow(`
+        SELECT id, principal_id, guardian_id, first_name, last_name, national_code, gender, birth_date
+        FROM patients WHERE id = ? AND guardian_id IS NOT NULL`, id).
+		Scan(&d.ID, &d.PrincipalID, &d.GuardianID, &d.FirstName, &d.LastName, &d.NationalCode, &d.Gender, &d.BirthDate)
+	if err == sql.ErrNoRows {
+		return nil, ErrDependentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// List returns the dependents of guardianID, oldest first.
+func (r *sqlDependents) List(guardianID int) ([]Dependent, error) {
+	rows, err := r.db.Query(`
+        SELECT id, principal_id, guardian_id, first_name, last_name, national_code, gender, birth_date
+        FROM patients WHERE guardian_id = ?
+        ORDER BY birth_date, id`, guardianID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dependents := []Dependent{}
+	for rows.Next() {
+		var d Dependent
+		if err := rows.Scan(&d.ID, &d.PrincipalID, &d.GuardianID, &d.FirstName, &d.LastName, &d.NationalCode, &d.Gender, &d.BirthDate); err != nil {
+			return nil, err
+		}
+		dependents = append(dependents, d)
+	}
+	return dependents, rows.Err()
+}
+
+// HandOver makes the dependent id a patient of its own, who logs in with
+// phoneNumber once they have set a password. Its guardian loses access to
+// it. It fails with ErrDependentMinor if the dependent has not come of age.
+func (r *sqlDependents) HandOver(id int, phoneNumber string) error {
+	d, err := r.Get(id)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if d.IsMinor(now) {
+		return ErrDependentMinor
+	}
+
+	result, err := r.db.Exec(`
+        UPDATE patients SET phone_number = ?, guardian_id = NULL, age = ?
+        WHERE id = ? AND guardian_id IS NOT NULL`, phoneNumber, d.AgeAt(now), id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n != 1 {
+		return ErrDependentNotFound
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// models/dependent_test.go
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeleteGuardian(t *testing.T) {
+	store := newTestStore(t)
+
+	guardian := &Patient{FirstName: "Guardian", LastName: "One", NationalCode: "0000000011", Gender: "woman", PhoneNumber: "09120000201", Password: "x"}
+	if err := store.Patients.Create(guardian); err != nil {
+		t.Fatal(err)
+	}
+	guardian, err := store.Patients.GetByPhone("09120000201")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dependent := &Dependent{
+		GuardianID:   guardian.ID,
+		FirstName:    "Minor",
+		LastName:     "One",
+		NationalCode: "0000000012",
+		Gender:       "man",
+		BirthDate:    time.Date(time.Now().Year()-5, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := store.Dependents.Create(dependent); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Patients.Delete(guardian.ID); !errors.Is(err, ErrGuardianDependents) {
+		t.Fatalf("Delete(guardian) = %v, want ErrGuardianDependents", err)
+	}
+
+	// The foreign key holds even for deletes that skip the check.
+	db := store.Patients.(*sqlitePatients).db
+	if _, err := db.Exec("DELETE FROM patients WHERE id = ?", guardian.ID); err == nil {
+		t.Fatal("deleting a guardian with dependents succeeded")
+	}
+	if d, err := store.Dependents.Get(dependent.ID); err != nil || d.GuardianID != guardian.ID {
+		t.Fatalf("dependent after delete = %+v, %v; want it kept under its guardian", d, err)
+	}
+}
@@ -4,6 +4,7 @@ package models
 import (
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -16,6 +17,7 @@ type SQLExecutor interface {
 
 type Doctor struct {
 	ID                 int     `json:"id"`
+	PrincipalID        int     `json:"principalId"` // Read-only; identifies the doctor in chats
 	FirstName          string  `json:"firstName"`
 	LastName           string  `json:"lastName"`
 	NationalCode       string  `json:"nationalCode"`
@@ -27,6 +29,12 @@ type Doctor struct {
 	Address            *string `json:"address,omitempty"`
 	ProfilePhotoPath   *string `json:"image,omitempty"`
 	MedicalCouncilCode *string `json:"medicalCouncilCode,omitempty"` // Added new field
+	VerificationStatus string  `json:"verificationStatus,omitempty"` // Read-only; changed through the verification endpoints
+}
+
+// LogValue keeps the password and national code out of logs.
+func (d Doctor) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("id", d.ID))
 }
 
 type DoctorPrescription struct {
@@ -41,20 +49,23 @@ type DoctorPrescription struct {
 }
 
 type DoctorSearchResult struct {
-	ID        int    `json:"id,string"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	Image     string `json:"image,omitempty"`
-	Address   string `json:"address,omitempty"`
+	ID          int    `json:"id,string"`
+	PrincipalID int    `json:"principalId"`
+	FirstName   string `json:"firstName"`
+	LastName    string `json:"lastName"`
+	Image       string `json:"image,omitempty"`
+	Address     string `json:"address,omitempty"`
 }
 
+// SearchDoctors finds approved doctors whose name contains searchTerm.
 func SearchDoctors(db *sql.DB, searchTerm string) ([]DoctorSearchResult, error) {
 	query := `
-        SELECT id, first_name, last_name, profile_photo_path, address
+        SELECT id, principal_id, first_name, last_name, profile_photo_path, address
         FROM doctors
-        WHERE CONCAT(first_name, ' ', last_name) LIKE ?
+        WHERE verification_status = 'approved'
+        AND (CONCAT(first_name, ' ', last_name) LIKE ?
         OR first_name LIKE ?
-        OR last_name LIKE ?
+        OR last_name LIKE ?)
     `
 
 	// Add wildcards for partial matching
@@ -73,6 +84,7 @@ func SearchDoctors(db *sql.DB, searchTerm string) ([]DoctorSearchResult, error)
 
 		err := rows.Scan(
 			&result.ID,
+			&result.PrincipalID,
 			&result.FirstName,
 			&result.LastName,
 			&image,
@@ -186,13 +198,14 @@ func GetDoctorById(db *sql.DB, id int) (*Doctor, error) {
 	var age sql.NullInt64
 	var education, address, profilePhotoPath, medicalCouncilCode sql.NullString
 
-	query := `SELECT id, first_name, last_name, national_code, gender, 
+	query := `SELECT id, principal_id, first_name, last_name, national_code, gender, 
         phone_number, password, age, education, address,
-        profile_photo_path, medical_council_code 
+        profile_photo_path, medical_council_code, verification_status
         FROM doctors WHERE id = ?`
 
 	err := db.QueryRow(query, id).Scan(
 		&doctor.ID,
+		&doctor.PrincipalID,
 		&doctor.FirstName,
 		&doctor.LastName,
 		&doctor.NationalCode,
@@ -204,6 +217,7 @@ func GetDoctorById(db *sql.DB, id int) (*Doctor, error) {
 		&address,
 		&profilePhotoPath,
 		&medicalCouncilCode,
+		&doctor.VerificationStatus,
 	)
 	if err != nil {
 		return nil, err
@@ -230,6 +244,16 @@ func GetDoctorById(db *sql.DB, id int) (*Doctor, error) {
 	return &doctor, nil
 }
 
+// GetDoctorByPhone retrieves a doctor by their phone number
+func GetDoctorByPhone(db *sql.DB, phoneNumber string) (*Doctor, error) {
+	var id int
+	err := db.QueryRow("SELECT id FROM doctors WHERE phone_number = ?", phoneNumber).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	return GetDoctorById(db, id)
+}
+
 func UpdateDoctor(executor SQLExecutor, doctor *Doctor) error {
 	_, err := executor.Exec(`CALL UpdateDoctor(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		doctor.ID,
@@ -266,7 +290,10 @@ func UpdateDoctorPassword(executor SQLExecutor, doctorID int, hashedPassword str
 // DeleteDoctor removes a doctor from the database
 func DeleteDoctor(db *sql.DB, id int) error {
 	query := "DELETE FROM doctors WHERE id = ?"
-	_, err := db.Exec(query, id)
+	if _, err := db.Exec(query, id); err != nil {
+		return err
+	}
+	_, err := revokeUserSessions(db, id, "doctor")
 	return err
 }
 
@@ -276,9 +303,9 @@ func GetAllDoctors(db *sql.DB) ([]Doctor, error) {
 
 	// Query to retrieve all doctors, including ProfilePhotoPath and Address
 	query := `
-        SELECT id, first_name, last_name, national_code, gender, 
+        SELECT id, principal_id, first_name, last_name, national_code, gender, 
                phone_number, password, age, education, address,
-               profile_photo_path 
+               profile_photo_path, verification_status
         FROM doctors`
 
 	rows, err := db.Query(query)
@@ -295,6 +322,7 @@ func GetAllDoctors(db *sql.DB) ([]Doctor, error) {
 
 		err := rows.Scan(
 			&doctor.ID,
+			&doctor.PrincipalID,
 			&doctor.FirstName,
 			&doctor.LastName,
 			&doctor.NationalCode,
@@ -305,6 +333,7 @@ func GetAllDoctors(db *sql.DB) ([]Doctor, error) {
 			&education,
 			&address,
 			&profilePhotoPath,
+			&doctor.VerificationStatus,
 		)
 		if err != nil {
 			return nil, err
@@ -361,6 +390,23 @@ func DeleteDoctorAvailability(db *sql.DB, slotID, doctorID int) error {
 	return nil
 }
 
+// GetAvailabilitySlotDoctor returns the doctor an availability slot belongs
+// to, or ErrAvailabilityNotFound if it does not exist
+func GetAvailabilitySlotDoctor(db *sql.DB, slotID int) (int, error) {
+	var doctorID int
+	err := db.QueryRow("SELECT doctor_id FROM doctor_availability WHERE id = ?", slotID).Scan(&doctorID)
+	if err == sql.ErrNoRows {
+		return 0, ErrAvailabilityNotFound
+	}
+	return doctorID, err
+}
+
+func UpdateDoctorPhoto(db *sql.DB, id int, path string) error {
+	query := "UPDATE doctors SET profile_photo_path = ? WHERE id = ?"
+	_, err := db.Exec(query, path, id)
+	return err
+}
+
 func DeleteDoctorPhoto(db *sql.DB, id int) error {
 	query := "UPDATE doctors SET profile_photo_path = NULL WHERE id = ?"
 	_, err := db.Exec(query, id)
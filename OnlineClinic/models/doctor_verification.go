@@ -0,0 +1,264 @@
+// models/doctor_verification.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Verification statuses of a doctor. Only approved doctors are listed,
+// bookable and may set availability or write prescriptions.
+const (
+	DoctorPending   = "pending"
+	DoctorApproved  = "approved"
+	DoctorRejected  = "rejected"
+	DoctorSuspended = "suspended"
+)
+
+// doctorTransitions lists the statuses each status may change to. A
+// rejected doctor resubmits, and an approved doctor goes back to pending
+// when their medical council code changes.
+var doctorTransitions = map[string][]string{
+	DoctorPending:   {DoctorApproved, DoctorRejected},
+	DoctorRejected:  {DoctorPending},
+	DoctorApproved:  {DoctorSuspended, DoctorPending},
+	DoctorSuspended: {DoctorApproved, DoctorRejected},
+}
+
+// CanTransition reports whether a doctor's status may change from from to to.
+func CanTransition(from, to string) bool {
+	for _, allowed := range doctorTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrInvalidTransition is returned for a status change the state machine
+// does not allow, or when the status was changed concurrently.
+var ErrInvalidTransition = errors.New("invalid verification status change")
+
+// Kinds of doctor documents.
+const (
+	DocumentLicense        = "license"
+	DocumentCouncilCard    = "medical_council_card"
+	DocumentNationalIDCard = "national_id_card"
+	DocumentOther          = "other"
+)
+
+// IsDocumentKind reports whether kind is one of the document kinds.
+func IsDocumentKind(kind string) bool {
+	switch kind {
+	case DocumentLicense, DocumentCouncilCard, DocumentNationalIDCard, DocumentOther:
+		return true
+	}
+	return false
+}
+
+// DoctorDocument is a file a doctor uploaded to prove their credentials.
+type DoctorDocument struct {
+	ID          int64     `json:"id"`
+	DoctorID    int       `json:"doctorId"`
+	Kind        string    `json:"kind"`
+	FileName    string    `json:"fileName"` // As uploaded
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Path        string    `json:"-"` // Relative to the document directory
+	UploadedAt  time.Time `json:"uploadedAt"`
+}
+
+// DoctorReview is one change of a doctor's verification status.
+type DoctorReview struct {
+	ID           int64     `json:"id"`
+	DoctorID     int       `json:"doctorId"`
+	FromStatus   string    `json:"fromStatus"`
+	ToStatus     string    `json:"toStatus"`
+	ReviewerRole string    `json:"reviewerRole,omitempty"`
+	ReviewerID   *int      `json:"reviewerId,omitempty"`
+	Notes        string    `json:"notes,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// DoctorVerificationSummary is one doctor in the review queue.
+type DoctorVerificationSummary struct {
+	DoctorID           int     `json:"doctorId"`
+	FirstName          string  `json:"firstName"`
+	LastName           string  `json:"lastName"`
+	PhoneNumber        string  `json:"phoneNumber"`
+	MedicalCouncilCode *string `json:"medicalCouncilCode,omitempty"`
+	Status             string  `json:"status"`
+	Documents          int     `json:"documents"`
+}
+
+// DoctorVerificationRepository stores the verification status, documents
+// and reviews of doctors.
+type DoctorVerificationRepository interface {
+	Status(doctorID int) (string, error)
+	Transition(review *DoctorReview) error
+	Reviews(doctorID int) ([]DoctorReview, error)
+	ListByStatus(status string) ([]DoctorVerificationSummary, error)
+	AddDocument(doc *DoctorDocument) error
+	Documents(doctorID int) ([]DoctorDocument, error)
+	Document(doctorID int, id int64) (*DoctorDocument, error)
+}
+
+// sqlDoctorVerification implements DoctorVerificationRepository with SQL
+// that runs on MySQL and SQLite.
+type sqlDoctorVerification struct{ db *sql.DB }
+
+// Status returns the verification status of a doctor, or sql.ErrNoRows if
+// the doctor does not exist.
+func (r *sqlDoctorVerification) Status(doctorID int) (string, error) {
+	var status string
+	err := r.db.QueryRow("SELECT verification_status FROM doctors WHERE id = ?", doctorID).Scan(&status)
+	return status, err
+}
+
+// Transition changes the status of review.DoctorID from review.FromStatus
+// to review.ToStatus and records the review, setting its ID and time. It
+// returns ErrInvalidTransition if the change is not allowed or the status
+// is no longer FromStatus.
+func (r *sqlDoctorVerification) Transition(review *DoctorReview) error {
+	if !CanTransition(review.FromStatus, review.ToStatus) {
+		return ErrInvalidTransition
+	}
+	review.CreatedAt = time.Now().UTC().Truncate(time.Second)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE doctors SET verification_status = ? WHERE id = ? AND verification_status = ?",
+		review.ToStatus, review.DoctorID, review.FromStatus)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrInvalidTransition
+	}
+
+	result, err = tx.Exec(`
+        INSERT INTO doctor_verification_reviews (doctor_id, from_status, to_status, reviewer_role, reviewer_id, notes, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		review.DoctorID, review.FromStatus, review.ToStatus, nullString(review.ReviewerRole), review.ReviewerID,
+		nullString(review.Notes), review.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if review.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Reviews returns the status changes of a doctor, oldest first.
+func (r *sqlDoctorVerification) Reviews(doctorID int) ([]DoctorReview, error) {
+	rows, err := r.db.Query(`
+        SELECT id, doctor_id, from_status, to_status, reviewer_role, reviewer_id, notes, created_at
+        FROM doctor_verification_reviews
+        WHERE doctor_id = ?
+        ORDER BY id`, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reviews []DoctorReview
+	for rows.Next() {
+		var rv DoctorReview
+		var reviewerRole, notes sql.NullString
+		var reviewerID sql.NullInt64
+		if err := rows.Scan(&rv.ID, &rv.DoctorID, &rv.FromStatus, &rv.ToStatus, &reviewerRole, &reviewerID, &notes, &rv.CreatedAt); err != nil {
+			return nil, err
+		}
+		rv.ReviewerRole, rv.Notes = reviewerRole.String, notes.String
+		if reviewerID.Valid {
+			id := int(reviewerID.Int64)
+			rv.ReviewerID = &id
+		}
+		reviews = append(reviews, rv)
+	}
+	return reviews, rows.Err()
+}
+
+// ListByStatus returns the doctors with the given status, longest waiting
+// first, with the number of documents each has uploaded.
+func (r *sqlDoctorVerification) ListByStatus(status string) ([]DoctorVerificationSummary, error) {
+	rows, err := r.db.Query(`
+        SELECT d.id, d.first_name, d.last_name, d.phone_number, d.medical_council_code, d.verification_status,
+               (SELECT COUNT(*) FROM doctor_documents dd WHERE dd.doctor_id = d.id)
+        FROM doctors d
+        WHERE d.verification_status = ?
+        ORDER BY d.id`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var doctors []DoctorVerificationSummary
+	for rows.Next() {
+		var s DoctorVerificationSummary
+		var code sql.NullString
+		if err := rows.Scan(&s.DoctorID, &s.FirstName, &s.LastName, &s.PhoneNumber, &code, &s.Status, &s.Documents); err != nil {
+			return nil, err
+		}
+		if code.Valid {
+			s.MedicalCouncilCode = &code.String
+		}
+		doctors = append(doctors, s)
+	}
+	return doctors, rows.Err()
+}
+
+// AddDocument records an uploaded document, setting its ID and time.
+func (r *sqlDoctorVerification) AddDocument(doc *DoctorDocument) error {
+	doc.UploadedAt = time.Now().UTC().Truncate(time.Second)
+	result, err := r.db.Exec(`
+        INSERT INTO doctor_documents (doctor_id, kind, file_name, content_type, size, path, uploaded_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		doc.DoctorID, doc.Kind, doc.FileName, doc.ContentType, doc.Size, doc.Path, doc.UploadedAt)
+	if err != nil {
+		return err
+	}
+	doc.ID, err = result.LastInsertId()
+	return err
+}
+
+const doctorDocumentColumns = "SELECT id, doctor_id, kind, file_name, content_type, size, path, uploaded_at FROM doctor_documents"
+
+// Documents returns the documents of a doctor, oldest first.
+func (r *sqlDoctorVerification) Documents(doctorID int) ([]DoctorDocument, error) {
+	rows, err := r.db.Query(doctorDocumentColumns+" WHERE doctor_id = ? ORDER BY id", doctorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []DoctorDocument
+	for rows.Next() {
+		var d DoctorDocument
+		if err := rows.Scan(&d.ID, &d.DoctorID, &d.Kind, &d.FileName, &d.ContentType, &d.Size, &d.Path, &d.UploadedAt); err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+// Document returns one document of a doctor, or sql.ErrNoRows if the doctor
+// has no document with that ID.
+func (r *sqlDoctorVerification) Document(doctorID int, id int64) (*DoctorDocument, error) {
+	var d DoctorDocument
+	err := r.db.QueryRow(doctorDocumentColumns+" WHERE id = ? AND doctor_id = ?", id, doctorID).
+		Scan(&d.ID, &d.DoctorID, &d.Kind, &d.FileName, &d.ContentType, &d.Size, &d.Path, &d.UploadedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
@@ -0,0 +1,223 @@
+// models/mysql_store.go
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// NewMySQLStore returns repositories that use the MySQL schema, including
+// its stored procedures.
+func NewMySQLStore(db *sql.DB) *Store {
+	return &Store{
+		DB:                 db,
+		Driver:             "mysql",
+		Doctors:            &mysqlDoctors{db: db},
+		Patients:           &mysqlPatients{db: db},
+		Availability:       &mysqlAvailability{db: db},
+		Appointments:       &mysqlAppointments{db: db},
+		Prescriptions:      &mysqlPrescriptions{db: db},
+		Chats:              &mysqlChats{db: db},
+		Sessions:           &sqlSessions{db: db},
+		Staff:              &sqlStaff{db: db},
+		Audit:              &sqlAudit{db: db},
+		Verifications:      &sqlPhoneVerifications{db: db},
+		TOTP:               &sqlTOTP{db: db},
+		DoctorVerification: &sqlDoctorVerification{db: db},
+		Principals:         &sqlPrincipals{db: db},
+		WSTickets:          &sqlWSTickets{db: db},
+		Dependents:         &sqlDependents{db: db},
+	}
+}
+
+type mysqlDoctors struct{ db *sql.DB }
+
+func (r *mysqlDoctors) Create(doctor *Doctor) error     { return CreateDoctor(r.db, doctor) }
+func (r *mysqlDoctors) GetByID(id int) (*Doctor, error) { return GetDoctorById(r.db, id) }
+func (r *mysqlDoctors) GetAll() ([]Doctor, error)       { return GetAllDoctors(r.db) }
+func (r *mysqlDoctors) Update(doctor *Doctor) error     { return UpdateDoctor(r.db, doctor) }
+func (r *mysqlDoctors) UpdatePhoto(id int, path string) error {
+	return UpdateDoctorPhoto(r.db, id, path)
+}
+func (r *mysqlDoctors) DeletePhoto(id int) error { return DeleteDoctorPhoto(r.db, id) }
+func (r *mysqlDoctors) Delete(id int) error      { return DeleteDoctor(r.db, id) }
+
+func (r *mysqlDoctors) GetByPhone(phoneNumber string) (*Doctor, error) {
+	return GetDoctorByPhone(r.db, phoneNumber)
+}
+
+func (r *mysqlDoctors) Search(term string) ([]DoctorSearchResult, error) {
+	return SearchDoctors(r.db, term)
+}
+
+func (r *mysqlDoctors) UpdatePassword(id int, newPassword string) error {
+	return UpdateDoctorPassword(r.db, id, newPassword)
+}
+
+func (r *mysqlDoctors) Prescriptions(doctorID int) ([]DoctorPrescription, error) {
+	return GetDoctorPrescriptions(r.db, doctorID)
+}
+
+func (r *mysqlDoctors) SetActive(id int, active bool) error {
+	return setAccountActive(r.db, "doctors", "doctor", id, active)
+}
+
+func (r *mysqlDoctors) DeactivatedAt(id int) (*time.Time, error) {
+	return accountDeactivatedAt(r.db, "doctors", id)
+}
+
+type mysqlPatients struct{ db *sql.DB }
+
+func (r *mysqlPatients) Create(patient *Patient) error    { return CreatePatient(r.db, patient) }
+func (r *mysqlPatients) GetByID(id int) (*Patient, error) { return GetPatientById(r.db, id) }
+func (r *mysqlPatients) GetAll() ([]Patient, error)       { return GetAllPatients(r.db) }
+func (r *mysqlPatients) Update(patient *Patient) error    { return UpdatePatient(r.db, patient) }
+func (r *mysqlPatients) UpdatePhoto(id int, path string) error {
+	return UpdatePatientPhoto(r.db, id, path)
+}
+func (r *mysqlPatients) DeletePhoto(id int) error { return DeletePatientPhoto(r.db, id) }
+func (r *mysqlPatients) Delete(id int) error      { return DeletePatient(r.db, id) }
+
+func (r *mysqlPatients) GetByPhone(phoneNumber string) (*Patient, error) {
+	return GetPatientByPhone(r.db, phoneNumber)
+}
+
+func (r *mysqlPatients) UpdatePassword(id int, newPassword string) error {
+	return UpdatePatientPassword(r.db, id, newPassword)
+}
+
+func (r *mysqlPatients) SetActive(id int, active bool) error {
+	return setAccountActive(r.db, "patients", "patient", id, active)
+}
+
+func (r *mysqlPatients) DeactivatedAt(id int) (*time.Time, error) {
+	return accountDeactivatedAt(r.db, "patients", id)
+}
+
+type mysqlAvailability struct{ db *sql.DB }
+
+func (r *mysqlAvailability) Set(doctorID int, req *AvailabilityRequest) error {
+	return SetDoctorAvailability(r.db, doctorID, req)
+}
+
+func (r *mysqlAvailability) List(doctorID int, visitType string) ([]AvailabilitySlot, error) {
+	return GetDoctorAvailability(r.db, doctorID, visitType)
+}
+
+func (r *mysqlAvailability) Delete(slotID, doctorID int) error {
+	return DeleteDoctorAvailability(r.db, slotID, doctorID)
+}
+
+func (r *mysqlAvailability) SlotDoctor(slotID int) (int, error) {
+	return GetAvailabilitySlotDoctor(r.db, slotID)
+}
+
+func (r *mysqlAvailability) DeleteUnreserved(doctorID int, visitType string) (int, error) {
+	return DeleteUnreservedAvailability(r.db, doctorID, visitType)
+}
+
+func (r *mysqlAvailability) PurgeBefore(before time.Time) (int, error) {
+	return PurgeAvailabilityBefore(r.db, before)
+}
+
+type mysqlAppointments struct{ db *sql.DB }
+
+func (r *mysqlAppointments) Create(req *AppointmentRequest) error {
+	return CreateAppointment(r.db, req)
+}
+func (r *mysqlAppointments) GetByID(id int) (*Appointment, error) {
+	return GetAppointmentById(r.db, id)
+}
+func (r *mysqlAppointments) Delete(id int) error { return DeleteAppointment(r.db, id) }
+
+func (r *mysqlAppointments) ListByPatient(patientID int) ([]Appointment, error) {
+	return GetPatientAppointments(r.db, patientID)
+}
+
+func (r *mysqlAppointments) ListByDoctor(doctorID int) ([]Appointment, error) {
+	return GetDoctorAppointments(r.db, doctorID)
+}
+
+func (r *mysqlAppointments) PatientNearest(patientID int) ([]AppointmentResponse, error) {
+	return GetPatientTwoNearestAppointments(r.db, patientID)
+}
+
+func (r *mysqlAppointments) DoctorNearest(doctorID int) ([]AppointmentResponse, error) {
+	return GetDoctorTwoNearestAppointments(r.db, doctorID)
+}
+
+func (r *mysqlAppointments) DoctorHasPatient(doctorID, patientID int) (bool, error) {
+	return DoctorHasPatient(r.db, doctorID, patientID)
+}
+
+func (r *mysqlAppointments) PatientDoctors(patientID int) ([]int, error) {
+	return GetPatientDoctorIDs(r.db, patientID)
+}
+
+func (r *mysqlAppointments) Participants(id int) (int, int, error) {
+	return GetAppointmentParticipants(r.db, id)
+}
+
+func (r *mysqlAppointments) Reassign(id, doctorID int) error {
+	return ReassignAppointment(r.db, id, doctorID)
+}
+
+type mysqlPrescriptions struct{ db *sql.DB }
+
+func (r *mysqlPrescriptions) GetByAppointment(appointmentID, userID int, isDoctor bool) (*PrescriptionResponse, error) {
+	return GetPrescriptionByAppointment(r.db, appointmentID, userID, isDoctor)
+}
+
+func (r *mysqlPrescriptions) GetWithName(prescriptionID int, isDoctor bool) (*PrescriptionResponse, error) {
+	return GetPrescriptionWithName(r.db, prescriptionID, isDoctor)
+}
+
+func (r *mysqlPrescriptions) ListByPatient(patientID, userID int, isDoctor bool) ([]PrescriptionResponse, error) {
+	return GetPatientPrescriptions(r.db, patientID, userID, isDoctor)
+}
+
+func (r *mysqlPrescriptions) ListByDoctor(doctorID int) ([]PrescriptionResponse, error) {
+	return GetPrescriptionsByDoctor(r.db, doctorID)
+}
+
+func (r *mysqlPrescriptions) ListAll() ([]PrescriptionResponse, error) {
+	return GetAllPrescriptions(r.db)
+}
+
+func (r *mysqlPrescriptions) Search(patientName string, date time.Time, scope PrescriptionScope) ([]PrescriptionWithDetails, error) {
+	return GetPrescriptionsByPatientNameAndDate(r.db, patientName, date, scope)
+}
+
+func (r *mysqlPrescriptions) Medications(prescriptionID int) ([]Medication, error) {
+	return GetPrescriptionMedications(r.db, prescriptionID)
+}
+
+func (r *mysqlPrescriptions) Update(prescription *Prescription) error {
+	return UpdatePrescriptionDB(r.db, prescription)
+}
+
+type mysqlChats struct{ db *sql.DB }
+
+func (r *mysqlChats) Create(senderID, receiverID int) (int, error) {
+	return CreateChat(r.db, senderID, receiverID)
+}
+
+func (r *mysqlChats) Exists(senderID, receiverID int) (int, error) {
+	return ChatExists(r.db, senderID, receiverID)
+}
+
+func (r *mysqlChats) AddMessage(chatID, senderID, receiverID int, text, timeStr, repliedMessage string, repliedMessageID *int, hijriDate string, attachedFile *File, isRead bool) error {
+	return AddMessage(r.db, chatID, senderID, receiverID, text, timeStr, repliedMessage, repliedMessageID, hijriDate, attachedFile, isRead)
+}
+
+func (r *mysqlChats) History(userID, receiverID int) ([]Chat, error) {
+	return GetChatHistory(r.db, userID, receiverID)
+}
+
+func (r *mysqlChats) List(userID int) ([]map[string]interface{}, error) {
+	return GetAllChats(r.db, userID)
+}
+
+func (r *mysqlChats) Unread(userID int) ([]map[string]interface{}, error) {
+	return GetUnreadChats(r.db, userID)
+}
@@ -4,12 +4,14 @@ package models
 import (
 	"database/sql"
 	"fmt"
+	"log/slog"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 type Patient struct {
 	ID               int     `json:"id,string"`
+	PrincipalID      int     `json:"principalId"` // Read-only; identifies the patient in chats
 	FirstName        string  `json:"firstName"`
 	LastName         string  `json:"lastName"`
 	NationalCode     string  `json:"nationalCode"`
@@ -23,6 +25,11 @@ type Patient struct {
 	ProfilePhotoPath *string `json:"profilePhotoPath,omitempty"` // Use pointer for nullable profile_photo_path
 }
 
+// LogValue keeps the password and national code out of logs.
+func (p Patient) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("id", p.ID))
+}
+
 func CreatePatient(db *sql.DB, patient *Patient) error {
 	_, err := db.Exec(`CALL AddPatient(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		patient.FirstName,
@@ -75,8 +82,8 @@ func UpdatePatientPassword(executor SQLExecutor, patientID int, hashedPassword s
 
 func GetAllPatients(db *sql.DB) ([]Patient, error) {
 	var patients []Patient
-	query := `SELECT id, first_name, last_name, national_code, gender, 
-        phone_number, password, age, job, education, address,
+	query := `SELECT id, principal_id, first_name, last_name, national_code, gender, 
+        COALESCE(phone_number, ''), COALESCE(password, ''), age, job, education, address,
         profile_photo_path FROM patients`
 
 	rows, err := db.Query(query)
@@ -89,6 +96,7 @@ func GetAllPatients(db *sql.DB) ([]Patient, error) {
 		var patient Patient
 		err := rows.Scan(
 			&patient.ID,
+			&patient.PrincipalID,
 			&patient.FirstName,
 			&patient.LastName,
 			&patient.NationalCode,
@@ -111,13 +119,14 @@ func GetAllPatients(db *sql.DB) ([]Patient, error) {
 
 func GetPatientByPhone(db *sql.DB, phoneNumber string) (*Patient, error) {
 	var patient Patient
-	query := `SELECT id, first_name, last_name, national_code, gender, 
-        phone_number, password, age, job, education, address,
+	query := `SELECT id, principal_id, first_name, last_name, national_code, gender, 
+        COALESCE(phone_number, ''), COALESCE(password, ''), age, job, education, address,
         profile_photo_path 
         FROM patients WHERE phone_number = ?`
 
 	err := db.QueryRow(query, phoneNumber).Scan(
 		&patient.ID,
+		&patient.PrincipalID,
 		&patient.FirstName,
 		&patient.LastName,
 		&patient.NationalCode,
@@ -136,9 +145,29 @@ func GetPatientByPhone(db *sql.DB, phoneNumber string) (*Patient, error) {
 	return &patient, nil
 }
 
+// DeletePatient deletes a patient and ends their sessions. It fails with
+// ErrGuardianDependents while the patient is the guardian of dependents,
+// who would be left with no one able to log in as or manage them.
 func DeletePatient(db *sql.DB, id int) error {
+	var dependents int
+	if err := db.QueryRow("SELECT COUNT(*) FROM patients WHERE guardian_id = ?", id).Scan(&dependents); err != nil {
+		return err
+	}
+	if dependents > 0 {
+		return ErrGuardianDependents
+	}
+
 	query := "DELETE FROM patients WHERE id = ?"
-	_, err := db.Exec(query, id)
+	if _, err := db.Exec(query, id); err != nil {
+		return err
+	}
+	_, err := revokeUserSessions(db, id, "patient")
+	return err
+}
+
+func UpdatePatientPhoto(db *sql.DB, id int, path string) error {
+	query := "UPDATE patients SET profile_photo_path = ? WHERE id = ?"
+	_, err := db.Exec(query, path, id)
 	return err
 }
 
@@ -153,13 +182,14 @@ func GetPatientById(db *sql.DB, id int) (*Patient, error) {
 	var age sql.NullInt64
 	var job, education, address, profilePhotoPath sql.NullString
 
-	query := `SELECT id, first_name, last_name, national_code, gender, 
-        phone_number, password, age, job, education, address,
+	query := `SELECT id, principal_id, first_name, last_name, national_code, gender, 
+        COALESCE(phone_number, ''), COALESCE(password, ''), age, job, education, address,
         profile_photo_path 
         FROM patients WHERE id = ?`
 
 	err := db.QueryRow(query, id).Scan(
 		&patient.ID,
+		&patient.PrincipalID,
 		&patient.FirstName,
 		&patient.LastName,
 		&patient.NationalCode,
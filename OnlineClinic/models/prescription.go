@@ -3,11 +3,17 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"onlineClinic/utils"
+	"strings"
 	"time"
 )
 
+// ErrUnauthorizedAccess is returned when the user may not see or change
+// the prescriptions asked for.
+var ErrUnauthorizedAccess = errors.New("unauthorized access")
+
 type Medication struct {
 	Medicine  string `json:"medicine"`
 	Frequency string `json:"frequency"`
@@ -116,6 +122,24 @@ func GetPrescriptionByAppointment(db *sql.DB, appointmentID, userID int, isDocto
 	// log.Printf("Fetching prescription for appointment ID: %d", appointmentID)
 
 	// First verify access
+	if err := authorizeAppointmentPrescription(db, appointmentID, userID, isDoctor); err != nil {
+		return nil, err
+	}
+
+	// Use the stored procedure for secure access
+	rows, err := db.Query("CALL GetPrescriptionByAppointmentSecure(?, ?, ?)",
+		appointmentID, userID, isDoctor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPrescriptionByAppointment(rows)
+}
+
+// authorizeAppointmentPrescription checks that the user is the doctor or the
+// patient of the appointment.
+func authorizeAppointmentPrescription(db *sql.DB, appointmentID, userID int, isDoctor bool) error {
 	var authorized bool
 	var query string
 	if isDoctor {
@@ -131,7 +155,7 @@ func GetPrescriptionByAppointment(db *sql.DB, appointmentID, userID int, isDocto
             ))`
 		err := db.QueryRow(query, appointmentID, userID, appointmentID).Scan(&authorized)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	} else {
 		// For patients, keep the existing logic
@@ -140,22 +164,19 @@ func GetPrescriptionByAppointment(db *sql.DB, appointmentID, userID int, isDocto
             WHERE id = ? AND patient_id = ?)`
 		err := db.QueryRow(query, appointmentID, userID).Scan(&authorized)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
 
 	if !authorized {
-		return nil, fmt.Errorf("unauthorized access")
-	}
-
-	// Use the stored procedure for secure access
-	rows, err := db.Query("CALL GetPrescriptionByAppointmentSecure(?, ?, ?)",
-		appointmentID, userID, isDoctor)
-	if err != nil {
-		return nil, err
+		return ErrUnauthorizedAccess
 	}
-	defer rows.Close()
+	return nil
+}
 
+// scanPrescriptionByAppointment reads the rows produced by the
+// GetPrescriptionByAppointmentSecure procedure or its SQLite equivalent.
+func scanPrescriptionByAppointment(rows *sql.Rows) (*PrescriptionResponse, error) {
 	var prescription PrescriptionResponse
 	var medications []Medication
 	var createdAtStr string // Use a string to temporarily store the created_at value
@@ -209,8 +230,26 @@ func GetPatientPrescriptions(db *sql.DB, patientID, userID int, isDoctor bool) (
 	// log.Printf("Fetching prescriptions for patient ID: %d", patientID)
 
 	// First verify access
+	if err := authorizePatientPrescriptions(db, patientID, userID, isDoctor); err != nil {
+		return nil, err
+	}
+
+	// Use the stored procedure for secure access
+	rows, err := db.Query("CALL GetPatientPrescriptionsSecure(?, ?, ?)",
+		patientID, userID, isDoctor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPatientPrescriptions(db, rows)
+}
+
+// authorizePatientPrescriptions checks that the user is the patient or a
+// doctor who has treated them.
+func authorizePatientPrescriptions(db *sql.DB, patientID, userID int, isDoctor bool) error {
 	if !isDoctor && patientID != userID {
-		return nil, fmt.Errorf("unauthorized access")
+		return ErrUnauthorizedAccess
 	}
 
 	if isDoctor {
@@ -221,21 +260,19 @@ func GetPatientPrescriptions(db *sql.DB, patientID, userID int, isDoctor bool) (
             WHERE doctor_id = ? AND patient_id = ?)`
 		err := db.QueryRow(query, userID, patientID).Scan(&authorized)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if !authorized {
-			return nil, fmt.Errorf("unauthorized access")
+			return ErrUnauthorizedAccess
 		}
 	}
+	return nil
+}
 
-	// Use the stored procedure for secure access
-	rows, err := db.Query("CALL GetPatientPrescriptionsSecure(?, ?, ?)",
-		patientID, userID, isDoctor)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
+// scanPatientPrescriptions reads the rows produced by the
+// GetPatientPrescriptionsSecure procedure or its SQLite equivalent and loads
+// the medications of each prescription.
+func scanPatientPrescriptions(db *sql.DB, rows *sql.Rows) ([]PrescriptionResponse, error) {
 	var prescriptions []PrescriptionResponse
 	for rows.Next() {
 		var prescription PrescriptionResponse
@@ -451,6 +488,12 @@ func GetPrescriptionsByDoctor(db *sql.DB, doctorID int) ([]PrescriptionResponse,
 	}
 	defer rows.Close()
 
+	return scanDoctorPrescriptions(db, rows)
+}
+
+// scanDoctorPrescriptions reads prescription rows with a YYYY-MM-DD
+// created_at and loads the medications of each prescription.
+func scanDoctorPrescriptions(db *sql.DB, rows *sql.Rows) ([]PrescriptionResponse, error) {
 	var prescriptions []PrescriptionResponse
 	for rows.Next() {
 		var p PrescriptionResponse
@@ -509,7 +552,7 @@ func GetPrescriptionsByDoctor(db *sql.DB, doctorID int) ([]PrescriptionResponse,
 		prescriptions = append(prescriptions, p)
 	}
 
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		// log.Printf("Error after scanning rows: %v", err)
 		return nil, err
 	}
@@ -518,7 +561,30 @@ func GetPrescriptionsByDoctor(db *sql.DB, doctorID int) ([]PrescriptionResponse,
 }
 
 // GetPrescriptionsByPatientNameAndDate retrieves prescriptions based on patient name and/or date
-func GetPrescriptionsByPatientNameAndDate(db *sql.DB, patientName string, date time.Time) ([]PrescriptionWithDetails, error) {
+// PrescriptionScope limits a prescription search to the appointments of
+// DoctorID, if set, or else to those of PatientIDs. An empty scope matches
+// nothing.
+type PrescriptionScope struct {
+	DoctorID   int
+	PatientIDs []int
+}
+
+// where returns the condition of s on appointments a, and its arguments.
+func (s PrescriptionScope) where() (string, []interface{}) {
+	if s.DoctorID > 0 {
+		return " AND a.doctor_id = ?", []interface{}{s.DoctorID}
+	}
+	if len(s.PatientIDs) == 0 {
+		return " AND 1 = 0", nil
+	}
+	args := make([]interface{}, len(s.PatientIDs))
+	for i, id := range s.PatientIDs {
+		args[i] = id
+	}
+	return " AND a.patient_id IN (?" + strings.Repeat(", ?", len(s.PatientIDs)-1) + ")", args
+}
+
+func GetPrescriptionsByPatientNameAndDate(db *sql.DB, patientName string, date time.Time, scope PrescriptionScope) ([]PrescriptionWithDetails, error) {
 	// Base query with full name search
 	query := `
         SELECT 
@@ -543,6 +609,10 @@ func GetPrescriptionsByPatientNameAndDate(db *sql.DB, patientName string, date t
 		args = append(args, date.Format("2006-01-02"))
 	}
 
+	scopeWhere, scopeArgs := scope.where()
+	query += scopeWhere
+	args = append(args, scopeArgs...)
+
 	// Execute the query
 	rows, err := db.Query(query, args...)
 	if err != nil {
@@ -552,6 +622,12 @@ func GetPrescriptionsByPatientNameAndDate(db *sql.DB, patientName string, date t
 
 	// log.Printf("Query: %s, Args: %v", query, args)
 
+	return scanPrescriptionDetails(rows)
+}
+
+// scanPrescriptionDetails reads prescription search rows with a
+// YYYY-MM-DD HH:MM:SS created_at.
+func scanPrescriptionDetails(rows *sql.Rows) ([]PrescriptionWithDetails, error) {
 	var prescriptions []PrescriptionWithDetails
 	for rows.Next() {
 		var p PrescriptionWithDetails
@@ -584,3 +660,85 @@ func GetPrescriptionsByPatientNameAndDate(db *sql.DB, patientName string, date t
 
 	return prescriptions, nil
 }
+
+// GetPrescriptionMedications retrieves the medications of a prescription
+func GetPrescriptionMedications(db *sql.DB, prescriptionID int) ([]Medication, error) {
+	rows, err := db.Query("SELECT medicine, frequency FROM medications WHERE prescription_id = ?", prescriptionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var medications []Medication
+	for rows.Next() {
+		var med Medication
+		if err := rows.Scan(&med.Medicine, &med.Frequency); err != nil {
+			return nil, err
+		}
+		medications = append(medications, med)
+	}
+	return medications, rows.Err()
+}
+
+// GetAllPrescriptions retrieves every prescription, newest first
+func GetAllPrescriptions(db *sql.DB) ([]PrescriptionResponse, error) {
+	rows, err := db.Query(`
+        SELECT 
+            p.id,
+            p.appointment_id,
+            a.doctor_id,
+            a.patient_id,
+            p.instructions,
+            p.created_at,
+            a.visit_type
+        FROM prescriptions p
+        JOIN appointments a ON p.appointment_id = a.id
+        ORDER BY p.created_at DESC
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prescriptions []PrescriptionResponse
+	for rows.Next() {
+		var p PrescriptionResponse
+		var createdAt time.Time
+
+		err := rows.Scan(
+			&p.ID,
+			&p.AppointmentID,
+			&p.DoctorID,
+			&p.PatientID,
+			&p.Instructions,
+			&createdAt,
+			&p.VisitType,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Convert Gregorian date to Hijri date
+		p.CreatedAt = utils.GregorianToSolar(createdAt)
+
+		if p.VisitType == "online" {
+			p.VisitType = "آنلاین"
+		}
+
+		prescriptions = append(prescriptions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Fetch medications once the prescription rows are closed
+	for i := range prescriptions {
+		medications, err := GetPrescriptionMedications(db, prescriptions[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		prescriptions[i].Medications = medications
+	}
+
+	return prescriptions, nil
+}
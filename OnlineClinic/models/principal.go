@@ -0,0 +1,73 @@
+// models/principal.go
+package models
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Principal roles. Receptionists and admins are both staff principals.
+const (
+	PrincipalPatient = "patient"
+	PrincipalDoctor  = "doctor"
+	PrincipalStaff   = "staff"
+)
+
+// principalTables names the profile table of each principal role.
+var principalTables = map[string]string{
+	PrincipalPatient: "patients",
+	PrincipalDoctor:  "doctors",
+	PrincipalStaff:   "staff",
+}
+
+// Principal is the identity behind a doctor, patient or staff account.
+// Principal IDs come from one sequence, so unlike account IDs they never
+// collide between roles. Chats and messages refer to principals.
+type Principal struct {
+	ID        int    `json:"id"`
+	Role      string `json:"role"`
+	AccountID int    `json:"accountId"` // ID of the doctor, patient or staff profile; 0 if it was deleted
+}
+
+// PrincipalRepository resolves principals and the accounts they belong to.
+// Principals are created by the database together with their account.
+type PrincipalRepository interface {
+	Get(id int) (*Principal, error)
+	ForAccount(role string, accountID int) (int, error)
+}
+
+// sqlPrincipals implements PrincipalRepository with SQL that runs on MySQL
+// and SQLite.
+type sqlPrincipals struct{ db *sql.DB }
+
+// Get returns principal id, or sql.ErrNoRows if there is none.
+func (r *sqlPrincipals) Get(id int) (*Principal, error) {
+	var p Principal
+	var accountID sql.NullInt64
+	err := r.db.QueryRow(`
+        SELECT p.id, p.role, CASE p.role
+            WHEN 'doctor' THEN (SELECT id FROM doctors WHERE principal_id = p.id)
+            WHEN 'patient' THEN (SELECT id FROM patients WHERE principal_id = p.id)
+            WHEN 'staff' THEN (SELECT id FROM staff WHERE principal_id = p.id)
+        END
+        FROM principals p
+        WHERE p.id = ?`, id).Scan(&p.ID, &p.Role, &accountID)
+	if err != nil {
+		return nil, err
+	}
+	p.AccountID = int(accountID.Int64)
+	return &p, nil
+}
+
+// ForAccount returns the principal ID of the account accountID with role
+// (one of the Principal roles), or sql.ErrNoRows if there is no such
+// account.
+func (r *sqlPrincipals) ForAccount(role string, accountID int) (int, error) {
+	table, ok := principalTables[role]
+	if !ok {
+		return 0, fmt.Errorf("unknown principal role %q", role)
+	}
+	var id int
+	err := r.db.QueryRow("SELECT principal_id FROM "+table+" WHERE id = ?", accountID).Scan(&id)
+	return id, err
+}
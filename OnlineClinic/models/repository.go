@@ -0,0 +1,123 @@
+// models/repository.go
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DoctorRepository stores doctor accounts and profiles.
+type DoctorRepository interface {
+	Create(doctor *Doctor) error
+	GetByID(id int) (*Doctor, error)
+	GetByPhone(phoneNumber string) (*Doctor, error)
+	GetAll() ([]Doctor, error)
+	Search(term string) ([]DoctorSearchResult, error)
+	Update(doctor *Doctor) error
+	UpdatePassword(id int, newPassword string) error
+	UpdatePhoto(id int, path string) error
+	DeletePhoto(id int) error
+	Delete(id int) error
+	Prescriptions(doctorID int) ([]DoctorPrescription, error)
+	SetActive(id int, active bool) error
+	DeactivatedAt(id int) (*time.Time, error)
+}
+
+// PatientRepository stores patient accounts and profiles.
+type PatientRepository interface {
+	Create(patient *Patient) error
+	GetByID(id int) (*Patient, error)
+	GetByPhone(phoneNumber string) (*Patient, error)
+	GetAll() ([]Patient, error)
+	Update(patient *Patient) error
+	UpdatePassword(id int, newPassword string) error
+	UpdatePhoto(id int, path string) error
+	DeletePhoto(id int) error
+	Delete(id int) error
+	SetActive(id int, active bool) error
+	DeactivatedAt(id int) (*time.Time, error)
+}
+
+// AvailabilityRepository stores the bookable time slots of doctors.
+type AvailabilityRepository interface {
+	Set(doctorID int, req *AvailabilityRequest) error
+	List(doctorID int, visitType string) ([]AvailabilitySlot, error)
+	Delete(slotID, doctorID int) error
+	SlotDoctor(slotID int) (int, error)
+	DeleteUnreserved(doctorID int, visitType string) (int, error)
+	PurgeBefore(before time.Time) (int, error)
+}
+
+// AppointmentRepository books and lists appointments.
+type AppointmentRepository interface {
+	Create(req *AppointmentRequest) error
+	GetByID(id int) (*Appointment, error)
+	Delete(id int) error
+	ListByPatient(patientID int) ([]Appointment, error)
+	ListByDoctor(doctorID int) ([]Appointment, error)
+	PatientNearest(patientID int) ([]AppointmentResponse, error)
+	DoctorNearest(doctorID int) ([]AppointmentResponse, error)
+	DoctorHasPatient(doctorID, patientID int) (bool, error)
+	PatientDoctors(patientID int) ([]int, error)
+	Participants(id int) (patientID, doctorID int, err error)
+	Reassign(id, doctorID int) error
+}
+
+// PrescriptionRepository reads and updates prescriptions. Prescriptions are
+// created together with their appointment.
+type PrescriptionRepository interface {
+	GetByAppointment(appointmentID, userID int, isDoctor bool) (*PrescriptionResponse, error)
+	GetWithName(prescriptionID int, isDoctor bool) (*PrescriptionResponse, error)
+	ListByPatient(patientID, userID int, isDoctor bool) ([]PrescriptionResponse, error)
+	ListByDoctor(doctorID int) ([]PrescriptionResponse, error)
+	ListAll() ([]PrescriptionResponse, error)
+	Search(patientName string, date time.Time, scope PrescriptionScope) ([]PrescriptionWithDetails, error)
+	Medications(prescriptionID int) ([]Medication, error)
+	Update(prescription *Prescription) error
+}
+
+// ChatRepository stores chats between doctors and patients and their
+// messages. Participants are principal IDs.
+type ChatRepository interface {
+	Create(senderID, receiverID int) (int, error)
+	Exists(senderID, receiverID int) (int, error)
+	AddMessage(chatID, senderID, receiverID int, text, timeStr, repliedMessage string, repliedMessageID *int, hijriDate string, attachedFile *File, isRead bool) error
+	History(userID, receiverID int) ([]Chat, error)
+	List(userID int) ([]map[string]interface{}, error)
+	Unread(userID int) ([]map[string]interface{}, error)
+}
+
+// Store groups the repositories backed by one database.
+type Store struct {
+	DB                 *sql.DB
+	Driver             string
+	Doctors            DoctorRepository
+	Patients           PatientRepository
+	Availability       AvailabilityRepository
+	Appointments       AppointmentRepository
+	Prescriptions      PrescriptionRepository
+	Chats              ChatRepository
+	Sessions           SessionRepository
+	Staff              StaffRepository
+	Audit              AuditRepository
+	Verifications      PhoneVerificationRepository
+	TOTP               TOTPRepository
+	DoctorVerification DoctorVerificationRepository
+	Principals         PrincipalRepository
+	WSTickets          WSTicketRepository
+	Dependents         DependentRepository
+}
+
+// NewStore returns the repositories for the given database driver
+// ("mysql" or "sqlite3").
+func NewStore(driver string, db *sql.DB) (*Store, error) {
+	switch driver {
+	case "mysql":
+		return NewMySQLStore(db), nil
+	case "sqlite3":
+		return NewSQLiteStore(db), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
@@ -0,0 +1,242 @@
+// models/session.go
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Session is one login of a doctor or patient. Its refresh token is only
+// known to the client; the database keeps a hash of it.
+type Session struct {
+	ID         string
+	UserID     int
+	Role       string // "doctor" or "patient"
+	UserAgent  string
+	IPAddress  string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+}
+
+var (
+	// ErrSessionInvalid is returned for an unknown, expired or revoked
+	// refresh token.
+	ErrSessionInvalid = errors.New("session is invalid, expired or revoked")
+
+	// ErrRefreshTokenReused is returned when an already rotated refresh token
+	// is presented again. The session is revoked, since either the client or
+	// an attacker holds a stolen token.
+	ErrRefreshTokenReused = errors.New("refresh token was already used")
+)
+
+// SessionRepository stores login sessions and their refresh tokens.
+type SessionRepository interface {
+	Create(userID int, role, userAgent, ipAddress string, ttl time.Duration) (*Session, string, error)
+	Rotate(refreshToken string) (*Session, string, error)
+	Revoke(id string) error
+	RevokeUser(userID int, role string) (int, error)
+	Revoked(id string) (bool, error)
+	DeleteExpired(before time.Time) (int, error)
+}
+
+// sqlSessions implements SessionRepository with SQL that runs on MySQL and
+// SQLite. Times are written in UTC from Go, not with NOW(), so both
+// databases store the same values.
+type sqlSessions struct{ db *sql.DB }
+
+// Create starts a session and returns it with its first refresh token.
+func (r *sqlSessions) Create(userID int, role, userAgent, ipAddress string, ttl time.Duration) (*Session, string, error) {
+	id, err := randomBytes(16)
+	if err != nil {
+		return nil, "", err
+	}
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	session := &Session{
+		ID:        hex.EncodeToString(id),
+		UserID:    userID,
+		Role:      role,
+		UserAgent: userAgent,
+		IPAddress: ipAddress,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	_, err = r.db.Exec(`
+        INSERT INTO sessions (
+            id, user_id, role, refresh_token_hash, user_agent, ip_address, created_at, expires_at
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.ID, userID, role, hashToken(refreshToken), userAgent, ipAddress, now, session.ExpiresAt)
+	if err != nil {
+		return nil, "", fmt.Errorf("creating session: %w", err)
+	}
+	return session, refreshToken, nil
+}
+
+// Rotate exchanges a refresh token for a new one. The session keeps its
+// original expiry, so a stolen token cannot extend it indefinitely. With
+// ErrRefreshTokenReused it returns the session it revoked.
+func (r *sqlSessions) Rotate(refreshToken string) (*Session, string, error) {
+	hash := hashToken(refreshToken)
+
+	session, err := r.scan(r.db.QueryRow(sessionColumns+" WHERE refresh_token_hash = ?", hash))
+	if err == sql.ErrNoRows {
+		// A rotated token coming back means it was copied.
+		reused, err := r.scan(r.db.QueryRow(sessionColumns+" WHERE previous_token_hash = ?", hash))
+		if err == sql.ErrNoRows {
+			return nil, "", ErrSessionInvalid
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if err := r.Revoke(reused.ID); err != nil {
+			return nil, "", err
+		}
+		return reused, "", ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if session.RevokedAt != nil || !now.Before(session.ExpiresAt) {
+		return nil, "", ErrSessionInvalid
+	}
+
+	newToken, err := newRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The hash condition makes concurrent rotations of one token lose the race
+	// instead of both succeeding. The loser presented a token that is no
+	// longer current, so it is treated like any other reuse.
+	result, err := r.db.Exec(`
+        UPDATE sessions
+        SET refresh_token_hash = ?, previous_token_hash = ?, last_used_at = ?
+        WHERE id = ? AND refresh_token_hash = ?`,
+		hashToken(newToken), hash, now, session.ID, hash)
+	if err != nil {
+		return nil, "", fmt.Errorf("rotating refresh token: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, "", err
+	} else if n == 0 {
+		if err := r.Revoke(session.ID); err != nil {
+			return nil, "", err
+		}
+		return session, "", ErrRefreshTokenReused
+	}
+
+	session.LastUsedAt = &now
+	return session, newToken, nil
+}
+
+// Revoke ends one session. Revoking it again is not an error.
+func (r *sqlSessions) Revoke(id string) error {
+	_, err := r.db.Exec("UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL",
+		time.Now().UTC().Truncate(time.Second), id)
+	return err
+}
+
+// RevokeUser ends every session of a user and returns how many were open.
+func (r *sqlSessions) RevokeUser(userID int, role string) (int, error) {
+	return revokeUserSessions(r.db, userID, role)
+}
+
+// Revoked reports whether access tokens of the session must be rejected:
+// it was revoked, has expired or does not exist.
+func (r *sqlSessions) Revoked(id string) (bool, error) {
+	var expiresAt time.Time
+	var revokedAt sql.NullTime
+	err := r.db.QueryRow("SELECT expires_at, revoked_at FROM sessions WHERE id = ?", id).Scan(&expiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return revokedAt.Valid || !time.Now().Before(expiresAt), nil
+}
+
+// DeleteExpired removes sessions that expired before the given time.
+func (r *sqlSessions) DeleteExpired(before time.Time) (int, error) {
+	result, err := r.db.Exec("DELETE FROM sessions WHERE expires_at < ?", before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+const sessionColumns = `
+        SELECT id, user_id, role, user_agent, ip_address, created_at, last_used_at, expires_at, revoked_at
+        FROM sessions`
+
+func (r *sqlSessions) scan(row *sql.Row) (*Session, error) {
+	var s Session
+	var userAgent, ipAddress sql.NullString
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.UserID, &s.Role, &userAgent, &ipAddress, &s.CreatedAt, &lastUsedAt, &s.ExpiresAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	s.UserAgent = userAgent.String
+	s.IPAddress = ipAddress.String
+	if lastUsedAt.Valid {
+		s.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		s.RevokedAt = &revokedAt.Time
+	}
+	return &s, nil
+}
+
+// revokeUserSessions ends every open session of a user. It is also called
+// when an account is deleted or deactivated.
+func revokeUserSessions(db *sql.DB, userID int, role string) (int, error) {
+	result, err := db.Exec("UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND role = ? AND revoked_at IS NULL",
+		time.Now().UTC().Truncate(time.Second), userID, role)
+	if err != nil {
+		return 0, fmt.Errorf("revoking sessions of %s %d: %w", role, userID, err)
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// newRefreshToken returns 32 random bytes, base64url encoded.
+func newRefreshToken() (string, error) {
+	b, err := randomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,89 @@
+// models/session_test.go
+package models
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRotateConcurrently(t *testing.T) {
+	store := newTestStore(t)
+
+	for i := 0; i < 20; i++ {
+		created, token, err := store.Sessions.Create(1, "patient", "test", "127.0.0.1", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		type result struct {
+			session *Session
+			token   string
+			err     error
+		}
+		results := make([]result, 2)
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for j := range results {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				<-start
+				s, tok, err := store.Sessions.Rotate(token)
+				results[j] = result{s, tok, err}
+			}(j)
+		}
+		close(start)
+		wg.Wait()
+
+		// One rotation wins; the other is a reuse, whether it lost the
+		// UPDATE or read the session after it, and ends the session.
+		won, reused := 0, 0
+		for _, r := range results {
+			switch {
+			case r.err == nil:
+				won++
+			case errors.Is(r.err, ErrRefreshTokenReused):
+				reused++
+				if r.session == nil || r.session.ID != created.ID {
+					t.Fatalf("reuse returned session %+v, want %s", r.session, created.ID)
+				}
+			default:
+				t.Fatalf("Rotate: %v", r.err)
+			}
+		}
+		if won != 1 || reused != 1 {
+			t.Fatalf("got %d rotations and %d reuses, want 1 and 1", won, reused)
+		}
+
+		revoked, err := store.Sessions.Revoked(created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !revoked {
+			t.Fatal("session is still valid after a reused refresh token")
+		}
+	}
+}
+
+func TestRotateReuseRevokes(t *testing.T) {
+	store := newTestStore(t)
+
+	created, token, err := store.Sessions.Create(1, "doctor", "test", "127.0.0.1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, next, err := store.Sessions.Rotate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, _, err := store.Sessions.Rotate(token)
+	if !errors.Is(err, ErrRefreshTokenReused) || session == nil || session.ID != created.ID {
+		t.Fatalf("Rotate(old token) = %+v, %v; want the session and ErrRefreshTokenReused", session, err)
+	}
+	if _, _, err := store.Sessions.Rotate(next); !errors.Is(err, ErrSessionInvalid) {
+		t.Fatalf("Rotate(current token) after reuse = %v, want ErrSessionInvalid", err)
+	}
+}
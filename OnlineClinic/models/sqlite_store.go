@@ -0,0 +1,589 @@
+// models/sqlite_store.go
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"onlineClinic/utils"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// NewSQLiteStore returns repositories for a SQLite database created by the
+// migrations in migrations/sqlite. SQLite has no stored procedures, so the
+// procedure logic of the MySQL schema is implemented here in Go. Queries
+// that are portable SQL are inherited from the MySQL repositories.
+func NewSQLiteStore(db *sql.DB) *Store {
+	return &Store{
+		DB:                 db,
+		Driver:             "sqlite3",
+		Doctors:            &sqliteDoctors{mysqlDoctors{db: db}},
+		Patients:           &sqlitePatients{mysqlPatients{db: db}},
+		Availability:       &sqliteAvailability{mysqlAvailability{db: db}},
+		Appointments:       &sqliteAppointments{mysqlAppointments{db: db}},
+		Prescriptions:      &sqlitePrescriptions{mysqlPrescriptions{db: db}},
+		Chats:              &sqliteChats{mysqlChats{db: db}},
+		Sessions:           &sqlSessions{db: db},
+		Staff:              &sqlStaff{db: db},
+		Audit:              &sqlAudit{db: db},
+		Verifications:      &sqlPhoneVerifications{db: db},
+		TOTP:               &sqlTOTP{db: db},
+		DoctorVerification: &sqlDoctorVerification{db: db},
+		Principals:         &sqlPrincipals{db: db},
+		WSTickets:          &sqlWSTickets{db: db},
+		Dependents:         &sqlDependents{db: db},
+	}
+}
+
+// sqliteNow returns the current wall-clock time labelled as UTC. Slot and
+// appointment times are stored that way, and it is what MySQL's NOW()
+// compares them against.
+func sqliteNow() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second(), 0, time.UTC)
+}
+
+type sqliteDoctors struct{ mysqlDoctors }
+
+// Create replaces the AddDoctor procedure.
+func (r *sqliteDoctors) Create(doctor *Doctor) error {
+	_, err := r.db.Exec(`
+        INSERT INTO doctors (
+            first_name, last_name, national_code, gender,
+            phone_number, password, medical_council_code
+        ) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		doctor.FirstName,
+		doctor.LastName,
+		doctor.NationalCode,
+		doctor.Gender,
+		doctor.PhoneNumber,
+		doctor.Password,
+		doctor.MedicalCouncilCode,
+	)
+	return err
+}
+
+// Update replaces the UpdateDoctor procedure.
+func (r *sqliteDoctors) Update(doctor *Doctor) error {
+	_, err := r.db.Exec(`
+        UPDATE doctors
+        SET first_name = ?, last_name = ?, national_code = ?, gender = ?,
+            phone_number = ?, age = ?, education = ?, address = ?,
+            profile_photo_path = ?, medical_council_code = ?
+        WHERE id = ?`,
+		doctor.FirstName,
+		doctor.LastName,
+		doctor.NationalCode,
+		doctor.Gender,
+		doctor.PhoneNumber,
+		doctor.Age,
+		doctor.Education,
+		doctor.Address,
+		doctor.ProfilePhotoPath,
+		doctor.MedicalCouncilCode,
+		doctor.ID,
+	)
+	return err
+}
+
+// UpdatePassword replaces the UpdateDoctorPassword procedure.
+func (r *sqliteDoctors) UpdatePassword(id int, newPassword string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	_, err = r.db.Exec("UPDATE doctors SET password = ? WHERE id = ?", string(hashedPassword), id)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %v", err)
+	}
+	return nil
+}
+
+type sqlitePatients struct{ mysqlPatients }
+
+// Create replaces the AddPatient procedure.
+func (r *sqlitePatients) Create(patient *Patient) error {
+	_, err := r.db.Exec(`
+        INSERT INTO patients (
+            first_name, last_name, national_code, gender,
+            phone_number, password, age,
+            job, education, address, profile_photo_path
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		patient.FirstName,
+		patient.LastName,
+		patient.NationalCode,
+		patient.Gender,
+		patient.PhoneNumber,
+		patient.Password,
+		patient.Age,
+		patient.Job,
+		patient.Education,
+		patient.Address,
+		patient.ProfilePhotoPath,
+	)
+	return err
+}
+
+// Update replaces the UpdatePatient procedure.
+func (r *sqlitePatients) Update(patient *Patient) error {
+	_, err := r.db.Exec(`
+        UPDATE patients
+        SET first_name = ?, last_name = ?, national_code = ?, gender = ?,
+            phone_number = ?, age = ?, job = ?, education = ?, address = ?,
+            profile_photo_path = ?
+        WHERE id = ?`,
+		patient.FirstName,
+		patient.LastName,
+		patient.NationalCode,
+		patient.Gender,
+		patient.PhoneNumber,
+		patient.Age,
+		patient.Job,
+		patient.Education,
+		patient.Address,
+		patient.ProfilePhotoPath,
+		patient.ID,
+	)
+	return err
+}
+
+// UpdatePassword replaces the UpdatePatientPassword procedure.
+func (r *sqlitePatients) UpdatePassword(id int, newPassword string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	_, err = r.db.Exec("UPDATE patients SET password = ? WHERE id = ?", string(hashedPassword), id)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %v", err)
+	}
+	return nil
+}
+
+type sqliteAvailability struct{ mysqlAvailability }
+
+func (r *sqliteAvailability) Delete(slotID, doctorID int) error {
+	result, err := r.db.Exec(
+		"DELETE FROM doctor_availability WHERE id = ? AND doctor_id = ? AND start_time > ?",
+		slotID,
+		doctorID,
+		sqliteNow(),
+	)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrAvailabilityNotFound
+	}
+
+	return nil
+}
+
+// DeleteUnreserved replaces the delete_unreserved_availability procedure.
+func (r *sqliteAvailability) DeleteUnreserved(doctorID int, visitType string) (int, error) {
+	result, err := r.db.Exec(`
+        DELETE FROM doctor_availability
+        WHERE doctor_id = ?
+            AND type = ?
+            AND start_time > ?`,
+		doctorID, visitType, sqliteNow())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete availability slots: %v", err)
+	}
+
+	deletedCount, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get deleted count: %v", err)
+	}
+	return int(deletedCount), nil
+}
+
+type sqliteAppointments struct{ mysqlAppointments }
+
+func (r *sqliteAppointments) GetByID(id int) (*Appointment, error) {
+	var appointment Appointment
+	var createdAt sql.NullTime
+
+	err := r.db.QueryRow(`
+        SELECT id, patient_id, doctor_id, start_time, end_time, visit_type, created_at
+        FROM appointments
+        WHERE id = ?`, id).Scan(
+		&appointment.ID,
+		&appointment.PatientID,
+		&appointment.DoctorID,
+		&appointment.StartTime,
+		&appointment.EndTime,
+		&appointment.VisitType,
+		&createdAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrAppointmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	appointment.CreatedAt = createdAt.Time
+	return &appointment, nil
+}
+
+func (r *sqliteAppointments) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		doctorID  int
+		start     time.Time
+		end       time.Time
+		visitType string
+	)
+	err = tx.QueryRow(`
+        SELECT doctor_id, start_time, end_time, visit_type
+        FROM appointments
+        WHERE id = ?`, id).Scan(&doctorID, &start, &end, &visitType)
+	if err == sql.ErrNoRows {
+		return ErrAppointmentNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	// Delete the prescription and its medications first (foreign keys are enforced)
+	_, err = tx.Exec(`
+        DELETE FROM medications
+        WHERE prescription_id IN (SELECT id FROM prescriptions WHERE appointment_id = ?)`, id)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec("DELETE FROM prescriptions WHERE appointment_id = ?", id); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM appointments WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrAppointmentNotFound
+	}
+
+	// Restore the availability slot
+	_, err = tx.Exec(`
+        INSERT INTO doctor_availability (doctor_id, start_time, end_time, type)
+        VALUES (?, ?, ?, ?)`,
+		doctorID, start.UTC(), end.UTC(), visitType)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type sqlitePrescriptions struct{ mysqlPrescriptions }
+
+// GetByAppointment replaces the GetPrescriptionByAppointmentSecure procedure.
+func (r *sqlitePrescriptions) GetByAppointment(appointmentID, userID int, isDoctor bool) (*PrescriptionResponse, error) {
+	if err := authorizeAppointmentPrescription(r.db, appointmentID, userID, isDoctor); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+        SELECT
+            p.id,
+            a.doctor_id,
+            a.patient_id,
+            p.appointment_id,
+            a.visit_type,
+            strftime('%Y-%m-%d', p.created_at) AS created_at,
+            p.instructions,
+            CASE
+                WHEN ?3 THEN CONCAT(pt.first_name, ' ', pt.last_name)
+                ELSE CONCAT(d.first_name, ' ', d.last_name)
+            END AS name,
+            m.medicine,
+            m.frequency
+        FROM prescriptions p
+        JOIN appointments a ON p.appointment_id = a.id
+        JOIN doctors d ON a.doctor_id = d.id
+        JOIN patients pt ON a.patient_id = pt.id
+        LEFT JOIN medications m ON p.id = m.prescription_id
+        WHERE p.appointment_id = ?1
+        AND (
+            (?3 AND a.doctor_id = ?2) OR
+            (NOT ?3 AND a.patient_id = ?2)
+        )`, appointmentID, userID, isDoctor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPrescriptionByAppointment(rows)
+}
+
+// ListByPatient replaces the GetPatientPrescriptionsSecure procedure.
+func (r *sqlitePrescriptions) ListByPatient(patientID, userID int, isDoctor bool) ([]PrescriptionResponse, error) {
+	if err := authorizePatientPrescriptions(r.db, patientID, userID, isDoctor); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+        SELECT
+            p.id,
+            a.doctor_id,
+            a.patient_id,
+            p.appointment_id,
+            a.visit_type,
+            strftime('%Y-%m-%d', p.created_at) AS created_at,
+            p.instructions,
+            CASE
+                WHEN ?3 THEN CONCAT(pt.first_name, ' ', pt.last_name)
+                ELSE CONCAT(d.first_name, ' ', d.last_name)
+            END AS name
+        FROM prescriptions p
+        JOIN appointments a ON p.appointment_id = a.id
+        JOIN doctors d ON a.doctor_id = d.id
+        JOIN patients pt ON a.patient_id = pt.id
+        WHERE a.patient_id = ?1
+        AND (
+            (?3 AND a.doctor_id = ?2) OR
+            (NOT ?3 AND a.patient_id = ?2)
+        )`, patientID, userID, isDoctor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPatientPrescriptions(r.db, rows)
+}
+
+func (r *sqlitePrescriptions) ListByDoctor(doctorID int) ([]PrescriptionResponse, error) {
+	rows, err := r.db.Query(`
+        SELECT
+            p.id,
+            p.appointment_id,
+            a.doctor_id,
+            a.patient_id,
+            p.instructions,
+            strftime('%Y-%m-%d', p.created_at) AS created_at,
+            a.visit_type,
+            CONCAT(pt.first_name, ' ', pt.last_name) AS patient_name
+        FROM prescriptions p
+        JOIN appointments a ON p.appointment_id = a.id
+        JOIN patients pt ON a.patient_id = pt.id
+        WHERE a.doctor_id = ?
+        ORDER BY p.created_at DESC`, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanDoctorPrescriptions(r.db, rows)
+}
+
+func (r *sqlitePrescriptions) Search(patientName string, date time.Time, scope PrescriptionScope) ([]PrescriptionWithDetails, error) {
+	query := `
+        SELECT
+            p.id,
+            a.patient_id,
+            a.doctor_id,
+            p.appointment_id,
+            p.instructions,
+            strftime('%Y-%m-%d %H:%M:%S', p.created_at) AS created_at,
+            a.visit_type,
+            CONCAT(pt.first_name, ' ', pt.last_name) AS patient_name
+        FROM prescriptions p
+        JOIN appointments a ON p.appointment_id = a.id
+        JOIN patients pt ON a.patient_id = pt.id
+        WHERE CONCAT(pt.first_name, ' ', pt.last_name) LIKE ?`
+	args := []interface{}{"%" + patientName + "%"}
+
+	if !date.IsZero() {
+		query += " AND DATE(p.created_at) = ?"
+		args = append(args, date.Format("2006-01-02"))
+	}
+	scopeWhere, scopeArgs := scope.where()
+	query += scopeWhere
+	args = append(args, scopeArgs...)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPrescriptionDetails(rows)
+}
+
+type sqliteChats struct{ mysqlChats }
+
+// Create replaces the CreateChat procedure: it returns the existing chat
+// between the two users or creates one.
+func (r *sqliteChats) Create(senderID, receiverID int) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var chatID int
+	err = tx.QueryRow(`
+        SELECT id FROM chats
+        WHERE (sender_id = ?1 AND receiver_id = ?2)
+           OR (sender_id = ?2 AND receiver_id = ?1)
+        LIMIT 1`, senderID, receiverID).Scan(&chatID)
+	if err == nil {
+		return chatID, tx.Commit()
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	result, err := tx.Exec("INSERT INTO chats (sender_id, receiver_id) VALUES (?, ?)", senderID, receiverID)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), tx.Commit()
+}
+
+// AddMessage replaces the AddMessage procedure.
+func (r *sqliteChats) AddMessage(chatID, senderID, receiverID int, text, timeStr, repliedMessage string, repliedMessageID *int, hijriDate string, attachedFile *File, isRead bool) error {
+	gregorianDate, err := utils.SolarToGregorian(hijriDate)
+	if err != nil {
+		return fmt.Errorf("error converting Hijri date to Gregorian: %v", err)
+	}
+
+	existingChatID, err := ChatExists(r.db, senderID, receiverID)
+	if err != nil {
+		return fmt.Errorf("error checking for existing chat: %v", err)
+	}
+	if existingChatID == 0 {
+		return fmt.Errorf("no chat exists between sender %d and receiver %d", senderID, receiverID)
+	}
+	if chatID != 0 && chatID != existingChatID {
+		return fmt.Errorf("invalid chat ID: provided chat ID %d does not match existing chat ID %d", chatID, existingChatID)
+	}
+
+	var filePath sql.NullString
+	if attachedFile != nil {
+		filePath.String = attachedFile.URL
+		filePath.Valid = true
+	}
+
+	_, err = r.db.Exec(`
+        INSERT INTO messages (
+            chat_id, sender_id, receiver_id, text, time,
+            replied_message, replied_message_id, date, attached_file_path, is_read
+        ) VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, 0), ?, ?, ?)`,
+		existingChatID,
+		senderID,
+		receiverID,
+		text,
+		timeStr,
+		repliedMessage,
+		repliedMessageID,
+		gregorianDate.Format("2006-01-02"),
+		filePath,
+		isRead,
+	)
+	return err
+}
+
+// History replaces the GetChatHistory procedure: it returns the messages
+// between the two users and marks the ones sent to userID as read.
+func (r *sqliteChats) History(userID, receiverID int) ([]Chat, error) {
+	rows, err := r.db.Query(`
+        SELECT
+            c.id AS chat_id,
+            c.sender_id,
+            c.receiver_id,
+            strftime('%Y-%m-%d %H:%M:%S', c.created_at) AS chat_created_at,
+            COALESCE(m.id, 0) AS message_id,
+            COALESCE(m.text, '') AS text,
+            COALESCE(m.time, '') AS time,
+            COALESCE(m.replied_message, '') AS replied_message,
+            COALESCE(m.replied_message_id, 0) AS replied_message_id,
+            COALESCE(m.date, '') AS date,
+            COALESCE(m.sender_id, 0) AS message_sender_id,
+            COALESCE(m.receiver_id, 0) AS message_receiver_id,
+            COALESCE(m.attached_file_path, '') AS attached_file_path,
+            COALESCE(m.is_read, FALSE) AS is_read
+        FROM chats c
+        LEFT JOIN messages m ON c.id = m.chat_id
+        WHERE (c.sender_id = ?1 AND c.receiver_id = ?2)
+           OR (c.sender_id = ?2 AND c.receiver_id = ?1)
+        ORDER BY c.created_at ASC, m.id ASC`, userID, receiverID)
+	if err != nil {
+		return nil, err
+	}
+	chats, err := scanChatHistory(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.db.Exec(`
+        UPDATE messages
+        SET is_read = TRUE
+        WHERE chat_id IN (
+            SELECT id FROM chats
+            WHERE (sender_id = ?1 AND receiver_id = ?2)
+               OR (sender_id = ?2 AND receiver_id = ?1)
+        )
+        AND sender_id != ?1
+        AND is_read = FALSE`, userID, receiverID)
+	if err != nil {
+		return nil, err
+	}
+
+	return chats, nil
+}
+
+// List replaces the GetAllChats procedure.
+func (r *sqliteChats) List(userID int) ([]map[string]interface{}, error) {
+	rows, err := r.db.Query(`
+        SELECT
+            c.id AS chat_id,
+            o.id AS other_principal_id,
+            o.role AS other_role,
+            CASE
+                WHEN d.id IS NOT NULL THEN CONCAT(d.first_name, ' ', d.last_name)
+                WHEN p.id IS NOT NULL THEN CONCAT(p.first_name, ' ', p.last_name)
+            END AS other_user_name,
+            CASE
+                WHEN d.id IS NOT NULL THEN d.profile_photo_path
+                WHEN p.id IS NOT NULL THEN p.profile_photo_path
+            END AS other_user_image
+        FROM chats c
+        JOIN principals o
+            ON o.id = CASE WHEN c.sender_id = ?1 THEN c.receiver_id ELSE c.sender_id END
+        LEFT JOIN doctors d ON d.principal_id = o.id
+        LEFT JOIN patients p ON p.principal_id = o.id
+        WHERE c.sender_id = ?1 OR c.receiver_id = ?1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanChatList(rows)
+}
@@ -0,0 +1,120 @@
+// models/staff.go
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Staff roles.
+const (
+	StaffReceptionist = "receptionist"
+	StaffAdmin        = "admin"
+)
+
+// Staff is a receptionist or clinic admin account.
+type Staff struct {
+	ID          int       `json:"id"`
+	FirstName   string    `json:"firstName"`
+	LastName    string    `json:"lastName"`
+	PhoneNumber string    `json:"phoneNumber"`
+	Password    string    `json:"-"` // bcrypt hash
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// StaffRepository stores staff accounts.
+type StaffRepository interface {
+	Create(staff *Staff) error
+	GetByID(id int) (*Staff, error)
+	GetByPhone(phoneNumber string) (*Staff, error)
+	GetAll() ([]Staff, error)
+	UpdatePassword(id int, newPassword string) error
+	SetActive(id int, active bool) error
+	DeactivatedAt(id int) (*time.Time, error)
+}
+
+// sqlStaff implements StaffRepository with SQL that runs on MySQL and SQLite.
+type sqlStaff struct{ db *sql.DB }
+
+// Create inserts staff, whose Password must already be hashed, and sets its ID.
+func (r *sqlStaff) Create(staff *Staff) error {
+	if staff.Role != StaffReceptionist && staff.Role != StaffAdmin {
+		return fmt.Errorf("invalid staff role %q", staff.Role)
+	}
+	result, err := r.db.Exec(
+		"INSERT INTO staff (first_name, last_name, phone_number, password, role) VALUES (?, ?, ?, ?, ?)",
+		staff.FirstName, staff.LastName, staff.PhoneNumber, staff.Password, staff.Role)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	staff.ID = int(id)
+	return nil
+}
+
+const staffColumns = "SELECT id, first_name, last_name, phone_number, password, role, created_at FROM staff"
+
+func (r *sqlStaff) GetByID(id int) (*Staff, error) {
+	return r.scan(r.db.QueryRow(staffColumns+" WHERE id = ?", id))
+}
+
+func (r *sqlStaff) GetByPhone(phoneNumber string) (*Staff, error) {
+	return r.scan(r.db.QueryRow(staffColumns+" WHERE phone_number = ?", phoneNumber))
+}
+
+func (r *sqlStaff) GetAll() ([]Staff, error) {
+	rows, err := r.db.Query(staffColumns + " ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var staff []Staff
+	for rows.Next() {
+		var s Staff
+		if err := rows.Scan(&s.ID, &s.FirstName, &s.LastName, &s.PhoneNumber, &s.Password, &s.Role, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		staff = append(staff, s)
+	}
+	return staff, rows.Err()
+}
+
+// UpdatePassword hashes newPassword and stores it.
+func (r *sqlStaff) UpdatePassword(id int, newPassword string) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+	_, err = r.db.Exec("UPDATE staff SET password = ? WHERE id = ?", string(hashed), id)
+	return err
+}
+
+// SetActive deactivates or reactivates a staff account. Deactivating ends
+// its sessions.
+func (r *sqlStaff) SetActive(id int, active bool) error {
+	staff, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	return setAccountActive(r.db, "staff", staff.Role, id, active)
+}
+
+func (r *sqlStaff) DeactivatedAt(id int) (*time.Time, error) {
+	return accountDeactivatedAt(r.db, "staff", id)
+}
+
+func (r *sqlStaff) scan(row *sql.Row) (*Staff, error) {
+	var s Staff
+	if err := row.Scan(&s.ID, &s.FirstName, &s.LastName, &s.PhoneNumber, &s.Password, &s.Role, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,33 @@
+// models/store_test.go
+package models
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"onlineClinic/migrations"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestStore returns a Store on a fresh, fully migrated SQLite database
+// that is removed when the test ends.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "clinic.db") + "?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000"
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrations.NewSQLite(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	return NewSQLiteStore(db)
+}
@@ -0,0 +1,195 @@
+// models/totp.go
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+)
+
+// recoveryCodeCount is how many recovery codes enabling TOTP issues.
+const recoveryCodeCount = 10
+
+// ErrTOTPAlreadyEnabled is returned when starting an enrollment for a
+// doctor whose TOTP is already enabled. It must be disabled first.
+var ErrTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+
+// DoctorTOTP is the TOTP secret of a doctor. EnabledAt is nil until the
+// doctor has confirmed the enrollment with a code.
+type DoctorTOTP struct {
+	DoctorID     int
+	Secret       string // base32, as in the otpauth URI
+	CreatedAt    time.Time
+	EnabledAt    *time.Time
+	LastUsedStep *int64
+}
+
+// Enabled reports whether the doctor has to enter codes to log in.
+func (t *DoctorTOTP) Enabled() bool {
+	return t.EnabledAt != nil
+}
+
+// TOTPRepository stores the TOTP secrets and recovery codes of doctors.
+type TOTPRepository interface {
+	Get(doctorID int) (*DoctorTOTP, error)
+	Begin(doctorID int, secret string) error
+	Enable(doctorID int) ([]string, error)
+	Disable(doctorID int) error
+	UseStep(doctorID int, step int64) (bool, error)
+	UseRecoveryCode(doctorID int, code string) (bool, error)
+}
+
+// sqlTOTP implements TOTPRepository with SQL that runs on MySQL and SQLite.
+type sqlTOTP struct{ db *sql.DB }
+
+// Get returns the TOTP secret of a doctor, or sql.ErrNoRows if there is none.
+func (r *sqlTOTP) Get(doctorID int) (*DoctorTOTP, error) {
+	var t DoctorTOTP
+	var enabledAt sql.NullTime
+	var lastUsedStep sql.NullInt64
+	err := r.db.QueryRow("SELECT doctor_id, secret, created_at, enabled_at, last_used_step FROM doctor_totp WHERE doctor_id = ?", doctorID).
+		Scan(&t.DoctorID, &t.Secret, &t.CreatedAt, &enabledAt, &lastUsedStep)
+	if err != nil {
+		return nil, err
+	}
+	if enabledAt.Valid {
+		t.EnabledAt = &enabledAt.Time
+	}
+	if lastUsedStep.Valid {
+		t.LastUsedStep = &lastUsedStep.Int64
+	}
+	return &t, nil
+}
+
+// Begin starts an enrollment with secret, replacing one that was not
+// confirmed yet.
+func (r *sqlTOTP) Begin(doctorID int, secret string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var enabledAt sql.NullTime
+	err = tx.QueryRow("SELECT enabled_at FROM doctor_totp WHERE doctor_id = ?", doctorID).Scan(&enabledAt)
+	switch {
+	case err == nil && enabledAt.Valid:
+		return ErrTOTPAlreadyEnabled
+	case err != nil && err != sql.ErrNoRows:
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM doctor_totp WHERE doctor_id = ?", doctorID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO doctor_totp (doctor_id, secret, created_at) VALUES (?, ?, ?)",
+		doctorID, secret, time.Now().UTC().Truncate(time.Second)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Enable confirms the pending enrollment of a doctor and returns new
+// recovery codes, replacing any earlier ones. Only their hashes are kept.
+// It returns sql.ErrNoRows if no enrollment is pending.
+func (r *sqlTOTP) Enable(doctorID int) ([]string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE doctor_totp SET enabled_at = ? WHERE doctor_id = ? AND enabled_at IS NULL",
+		time.Now().UTC().Truncate(time.Second), doctorID)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec("DELETE FROM doctor_recovery_codes WHERE doctor_id = ?", doctorID); err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("INSERT INTO doctor_recovery_codes (doctor_id, code_hash) VALUES (?, ?)",
+			doctorID, hashToken(normalizeRecoveryCode(code))); err != nil {
+			return nil, err
+		}
+		codes[i] = code
+	}
+	return codes, tx.Commit()
+}
+
+// Disable deletes the TOTP secret and recovery codes of a doctor.
+func (r *sqlTOTP) Disable(doctorID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM doctor_recovery_codes WHERE doctor_id = ?", doctorID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM doctor_totp WHERE doctor_id = ?", doctorID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseStep records that a code of the given time step was accepted. It
+// returns false if a code of that or a later step was accepted before, so
+// that an intercepted code cannot be replayed.
+func (r *sqlTOTP) UseStep(doctorID int, step int64) (bool, error) {
+	result, err := r.db.Exec(`
+        UPDATE doctor_totp SET last_used_step = ?
+        WHERE doctor_id = ? AND (last_used_step IS NULL OR last_used_step < ?)`,
+		step, doctorID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// UseRecoveryCode uses up a recovery code of a doctor. It returns false if
+// the code is wrong or was used before.
+func (r *sqlTOTP) UseRecoveryCode(doctorID int, code string) (bool, error) {
+	result, err := r.db.Exec(`
+        UPDATE doctor_recovery_codes SET used_at = ?
+        WHERE doctor_id = ? AND code_hash = ? AND used_at IS NULL`,
+		time.Now().UTC().Truncate(time.Second), doctorID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// newRecoveryCode returns a random code such as "k3j9q-x7m2p".
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+	return s[:5] + "-" + s[5:], nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes, so codes work
+// however they were copied.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,168 @@
+// models/verification.go
+package models
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Verification purposes. A code only verifies the purpose it was issued for.
+const (
+	VerifyRegistration = "register"
+)
+
+// VerifyPasswordReset is the purpose of a code that resets the password of
+// the account of the given kind ("doctor", "patient" or "staff") that has
+// the phone number.
+func VerifyPasswordReset(kind string) string {
+	return "password_reset:" + kind
+}
+
+// VerifyPhoneChange is the purpose of a code that lets the given account
+// change its phone number.
+func VerifyPhoneChange(role string, id int) string {
+	return fmt.Sprintf("phone_change:%s:%d", role, id)
+}
+
+var (
+	// ErrVerificationCooldown is returned when a new code is requested too
+	// soon after the previous one.
+	ErrVerificationCooldown = errors.New("a verification code was sent recently")
+
+	// ErrVerificationCodeInvalid is returned for a wrong, expired or already
+	// used code.
+	ErrVerificationCodeInvalid = errors.New("verification code is invalid or expired")
+
+	// ErrVerificationAttempts is returned once a code has been guessed wrong
+	// too often. A new code must be requested.
+	ErrVerificationAttempts = errors.New("too many wrong verification codes")
+)
+
+// PhoneVerificationRepository issues and checks one-time codes sent to
+// phone numbers.
+type PhoneVerificationRepository interface {
+	Issue(phoneNumber, purpose string, ttl, cooldown time.Duration) (string, error)
+	Verify(phoneNumber, purpose, code string, maxAttempts int) error
+	DeleteExpired(before time.Time) (int, error)
+}
+
+// sqlPhoneVerifications implements PhoneVerificationRepository with SQL that
+// runs on MySQL and SQLite.
+type sqlPhoneVerifications struct{ db *sql.DB }
+
+// Issue returns a new six-digit code for phoneNumber and purpose, replacing
+// any earlier one. It fails with ErrVerificationCooldown if the earlier code
+// was issued less than cooldown ago.
+func (r *sqlPhoneVerifications) Issue(phoneNumber, purpose string, ttl, cooldown time.Duration) (string, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	var createdAt time.Time
+	err := r.db.QueryRow("SELECT created_at FROM phone_verifications WHERE phone_number = ? AND purpose = ?",
+		phoneNumber, purpose).Scan(&createdAt)
+	switch {
+	case err == nil && now.Before(createdAt.Add(cooldown)):
+		return "", ErrVerificationCooldown
+	case err != nil && err != sql.ErrNoRows:
+		return "", err
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM phone_verifications WHERE phone_number = ? AND purpose = ?", phoneNumber, purpose); err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(`
+        INSERT INTO phone_verifications (phone_number, purpose, code_hash, attempts, created_at, expires_at)
+        VALUES (?, ?, ?, 0, ?, ?)`,
+		phoneNumber, purpose, hashCode(phoneNumber, purpose, code), now, now.Add(ttl))
+	if err != nil {
+		return "", err
+	}
+	return code, tx.Commit()
+}
+
+// Verify checks code and uses it up if it is right. Every check counts as
+// an attempt, and after maxAttempts the code stops working.
+func (r *sqlPhoneVerifications) Verify(phoneNumber, purpose, code string, maxAttempts int) error {
+	now := time.Now().UTC()
+
+	// Count the attempt first, so concurrent guesses cannot exceed the limit.
+	result, err := r.db.Exec(`
+        UPDATE phone_verifications SET attempts = attempts + 1
+        WHERE phone_number = ? AND purpose = ? AND attempts < ? AND expires_at > ?`,
+		phoneNumber, purpose, maxAttempts, now)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		var attempts int
+		err := r.db.QueryRow("SELECT attempts FROM phone_verifications WHERE phone_number = ? AND purpose = ? AND expires_at > ?",
+			phoneNumber, purpose, now).Scan(&attempts)
+		if err == nil && attempts >= maxAttempts {
+			return ErrVerificationAttempts
+		}
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		return ErrVerificationCodeInvalid
+	}
+
+	var stored string
+	err = r.db.QueryRow("SELECT code_hash FROM phone_verifications WHERE phone_number = ? AND purpose = ?",
+		phoneNumber, purpose).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return ErrVerificationCodeInvalid
+	}
+	if err != nil {
+		return err
+	}
+	hash := hashCode(phoneNumber, purpose, code)
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) != 1 {
+		return ErrVerificationCodeInvalid
+	}
+
+	// Only one request can delete the row, so a code works once.
+	result, err = r.db.Exec("DELETE FROM phone_verifications WHERE phone_number = ? AND purpose = ? AND code_hash = ?",
+		phoneNumber, purpose, hash)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrVerificationCodeInvalid
+	}
+	return nil
+}
+
+// DeleteExpired deletes codes that expired before before.
+func (r *sqlPhoneVerifications) DeleteExpired(before time.Time) (int, error) {
+	result, err := r.db.Exec("DELETE FROM phone_verifications WHERE expires_at < ?", before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// hashCode binds a code to its phone number and purpose before hashing.
+func hashCode(phoneNumber, purpose, code string) string {
+	return hashToken(purpose + ":" + phoneNumber + ":" + code)
+}
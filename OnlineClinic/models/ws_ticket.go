@@ -0,0 +1,97 @@
+// models/ws_ticket.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrWSTicketInvalid is returned for an unknown, expired or already used
+// WebSocket ticket.
+var ErrWSTicketInvalid = errors.New("websocket ticket is invalid, expired or already used")
+
+// WSTicket stands in for an access token when opening the chat WebSocket.
+// It holds what the connection needs of the token.
+type WSTicket struct {
+	SessionID      string
+	UserID         int
+	Role           string
+	PhoneNumber    string
+	TokenExpiresAt time.Time // The connection ends then
+}
+
+// WSTicketRepository issues and redeems single-use WebSocket tickets.
+type WSTicketRepository interface {
+	Issue(ticket *WSTicket, ttl time.Duration) (string, error)
+	Redeem(ticket string) (*WSTicket, error)
+	DeleteExpired(before time.Time) (int, error)
+}
+
+// sqlWSTickets implements WSTicketRepository with SQL that runs on MySQL
+// and SQLite.
+type sqlWSTickets struct{ db *sql.DB }
+
+// Issue stores t and returns the ticket that redeems it within ttl.
+func (r *sqlWSTickets) Issue(t *WSTicket, ttl time.Duration) (string, error) {
+	ticket, err := newRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err = r.db.Exec(`
+        INSERT INTO ws_tickets (
+            ticket_hash, session_id, user_id, role, phone_number, token_expires_at, created_at, expires_at
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		hashToken(ticket), t.SessionID, t.UserID, t.Role, t.PhoneNumber, t.TokenExpiresAt.UTC(), now, now.Add(ttl))
+	if err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// Redeem uses up ticket and returns what it was issued for. It fails with
+// ErrWSTicketInvalid if the ticket is unknown, expired or was redeemed
+// before.
+func (r *sqlWSTickets) Redeem(ticket string) (*WSTicket, error) {
+	hash := hashToken(ticket)
+
+	var t WSTicket
+	var expiresAt time.Time
+	err := r.db.QueryRow(`
+        SELECT session_id, user_id, role, phone_number, token_expires_at, expires_at
+        FROM ws_tickets WHERE ticket_hash = ?`, hash).
+		Scan(&t.SessionID, &t.UserID, &t.Role, &t.PhoneNumber, &t.TokenExpiresAt, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrWSTicketInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Whoever deletes the row redeemed the ticket.
+	result, err := r.db.Exec("DELETE FROM ws_tickets WHERE ticket_hash = ?", hash)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n != 1 {
+		return nil, ErrWSTicketInvalid
+	}
+
+	if !time.Now().Before(expiresAt) {
+		return nil, ErrWSTicketInvalid
+	}
+	return &t, nil
+}
+
+// DeleteExpired removes tickets that expired before the given time.
+func (r *sqlWSTickets) DeleteExpired(before time.Time) (int, error) {
+	result, err := r.db.Exec("DELETE FROM ws_tickets WHERE expires_at < ?", before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,197 @@
+package problem
+
+import "net/http"
+
+// Code identifies a kind of problem. Codes are part of the API: clients
+// match on them, so they are never renamed or reused.
+type Code string
+
+// General problems.
+const (
+	CodeInternal         Code = "internal"
+	CodeNotFound         Code = "not_found"
+	CodeMethodNotAllowed Code = "method_not_allowed"
+	CodeInvalidBody      Code = "request.invalid_body"
+	CodeInvalidID        Code = "request.invalid_id"
+	CodeInvalidQuery     Code = "request.invalid_query"
+	CodeValidationFailed Code = "validation.failed"
+)
+
+// Authentication and authorization.
+const (
+	CodeUnauthenticated     Code = "auth.unauthenticated"
+	CodeInvalidToken        Code = "auth.invalid_token"
+	CodeInvalidRefreshToken Code = "auth.invalid_refresh_token"
+	CodeForbidden           Code = "auth.forbidden"
+	CodeInvalidCredentials  Code = "auth.invalid_credentials"
+	CodeInvalidRole         Code = "auth.invalid_role"
+	CodeAccountDeactivated  Code = "auth.account_deactivated"
+	CodeTooManyLogins       Code = "auth.too_many_attempts"
+	CodeInvalidWSTicket     Code = "auth.invalid_ws_ticket"
+	CodeOriginNotAllowed    Code = "auth.origin_not_allowed"
+
+	CodeTwoFactorRequired         Code = "two_factor.required"
+	CodeTwoFactorCodeRequired     Code = "two_factor.code_required"
+	CodeTwoFactorInvalidCode      Code = "two_factor.invalid_code"
+	CodeTwoFactorInvalidChallenge Code = "two_factor.invalid_challenge"
+	CodeTwoFactorAlreadyEnabled   Code = "two_factor.already_enabled"
+	CodeTwoFactorNotEnabled       Code = "two_factor.not_enabled"
+	CodeTwoFactorNotEnrolling     Code = "two_factor.not_enrolling"
+)
+
+// Accounts, phone numbers and passwords.
+const (
+	CodeAlreadyRegistered      Code = "account.already_registered"
+	CodePhoneTaken             Code = "account.phone_taken"
+	CodeInvalidPhone           Code = "phone.invalid"
+	CodePhoneRequired          Code = "phone.required"
+	CodePhoneUnchanged         Code = "phone.unchanged"
+	CodeVerificationRequired   Code = "verification.code_required"
+	CodeVerificationInvalid    Code = "verification.invalid_code"
+	CodeVerificationAttempts   Code = "verification.too_many_attempts"
+	CodeVerificationCooldown   Code = "verification.resend_too_soon"
+	CodeVerificationSendFailed Code = "verification.send_failed"
+
+	CodeDependentNotFound  Code = "dependent.not_found"
+	CodeDependentMinor     Code = "dependent.minor"
+	CodeDependentNested    Code = "dependent.nested"
+	CodeGuardianDependents Code = "dependent.guardian_has_dependents"
+)
+
+// Doctors and their verification.
+const (
+	CodeInvalidDoctorID     Code = "doctor.invalid_id"
+	CodeDoctorNotFound      Code = "doctor.not_found"
+	CodeDoctorNotApproved   Code = "doctor.not_approved"
+	CodeDoctorNoDocuments   Code = "doctor.no_documents"
+	CodeDoctorInvalidStatus Code = "doctor.invalid_status"
+	CodeDoctorStatusChange  Code = "doctor.invalid_status_change"
+	CodeDoctorReviewNotes   Code = "doctor.review_notes_required"
+	CodeDoctorNotRejected   Code = "doctor.not_rejected"
+	CodeDoctorNoNewDocument Code = "doctor.no_new_document"
+	CodeInvalidDocumentID   Code = "document.invalid_id"
+	CodeInvalidDocumentKind Code = "document.invalid_kind"
+	CodeDocumentNotFound    Code = "document.not_found"
+	CodeSearchTermRequired  Code = "search.term_required"
+	CodeInvalidPatientID    Code = "patient.invalid_id"
+	CodeInvalidSlotID       Code = "availability.invalid_slot_id"
+	CodeSlotNotFound        Code = "availability.slot_not_found"
+	CodeInvalidTimeRange    Code = "availability.invalid_range"
+)
+
+// Appointments and prescriptions.
+const (
+	CodeInvalidAppointmentID     Code = "appointment.invalid_id"
+	CodeSlotUnavailable          Code = "appointment.slot_unavailable"
+	CodeInvalidSlot              Code = "appointment.invalid_slot"
+	CodeAppointmentInPast        Code = "appointment.in_past"
+	CodeInvalidVisitType         Code = "appointment.invalid_visit_type"
+	CodeInvalidDate              Code = "date.invalid"
+	CodeInvalidPrescriptionID    Code = "prescription.invalid_id"
+	CodePrescriptionExists       Code = "prescription.already_exists"
+	CodePrescriptionIncomplete   Code = "prescription.incomplete"
+	CodePrescriptionSearchParams Code = "prescription.search_params_required"
+)
+
+// Chat and uploads.
+const (
+	CodeInvalidReceiverID   Code = "chat.invalid_receiver_id"
+	CodeChatParticipants    Code = "chat.invalid_participants"
+	CodeChatPeerNotFound    Code = "chat.participant_not_found"
+	CodeChatSendFailed      Code = "chat.send_failed" // Sent over the WebSocket, not as a response
+	CodeShuttingDown        Code = "server.shutting_down"
+	CodeInvalidForm         Code = "upload.invalid_form"
+	CodeFileMissing         Code = "upload.file_missing"
+	CodeFileTooLarge        Code = "upload.too_large"
+	CodeUnsupportedFileType Code = "upload.unsupported_type"
+)
+
+// definition is the status and translated titles of a code.
+type definition struct {
+	status int
+	en, fa string
+}
+
+var catalog = map[Code]definition{
+	CodeInternal:         {http.StatusInternalServerError, "Something went wrong on our side", "خطایی در سرور رخ داد"},
+	CodeNotFound:         {http.StatusNotFound, "Not found", "یافت نشد"},
+	CodeMethodNotAllowed: {http.StatusMethodNotAllowed, "Method not allowed", "این روش درخواست مجاز نیست"},
+	CodeInvalidBody:      {http.StatusBadRequest, "Invalid request body", "بدنه درخواست نامعتبر است"},
+	CodeInvalidID:        {http.StatusBadRequest, "Invalid ID", "شناسه نامعتبر است"},
+	CodeInvalidQuery:     {http.StatusBadRequest, "Invalid query parameter", "پارامتر جستجو نامعتبر است"},
+	CodeValidationFailed: {http.StatusBadRequest, "Some fields are invalid", "برخی از فیلدها نامعتبر هستند"},
+
+	CodeUnauthenticated:     {http.StatusUnauthorized, "Authentication required", "ابتدا وارد شوید"},
+	CodeInvalidToken:        {http.StatusUnauthorized, "Invalid or expired token", "توکن نامعتبر یا منقضی است"},
+	CodeInvalidRefreshToken: {http.StatusUnauthorized, "Invalid or expired refresh token", "توکن تمدید نامعتبر یا منقضی است"},
+	CodeForbidden:           {http.StatusForbidden, "You are not allowed to do this", "اجازه انجام این کار را ندارید"},
+	CodeInvalidCredentials:  {http.StatusUnauthorized, "Invalid phone number or password", "شماره تلفن یا رمز عبور نادرست است"},
+	CodeInvalidRole:         {http.StatusBadRequest, "Role must be patient, doctor or staff", "نقش باید بیمار، پزشک یا کارمند باشد"},
+	CodeAccountDeactivated:  {http.StatusForbidden, "Account is deactivated", "حساب کاربری غیرفعال شده است"},
+	CodeTooManyLogins:       {http.StatusTooManyRequests, "Too many failed login attempts, try again later", "تلاش‌های ناموفق زیادی برای ورود انجام شده، بعداً دوباره تلاش کنید"},
+	CodeInvalidWSTicket:     {http.StatusUnauthorized, "Invalid, expired or already used WebSocket ticket", "بلیت وب‌سوکت نامعتبر، منقضی یا قبلاً استفاده شده است"},
+	CodeOriginNotAllowed:    {http.StatusForbidden, "Requests from this origin are not allowed", "درخواست از این مبدأ مجاز نیست"},
+
+	CodeTwoFactorRequired:         {http.StatusForbidden, "Two-factor authentication is required for doctors", "ورود دومرحله‌ای برای پزشکان الزامی است"},
+	CodeTwoFactorCodeRequired:     {http.StatusBadRequest, "Code or recovery code is required", "کد یا کد بازیابی الزامی است"},
+	CodeTwoFactorInvalidCode:      {http.StatusBadRequest, "Invalid code", "کد نادرست است"},
+	CodeTwoFactorInvalidChallenge: {http.StatusUnauthorized, "Invalid or expired challenge, log in again", "مرحله ورود منقضی شده، دوباره وارد شوید"},
+	CodeTwoFactorAlreadyEnabled:   {http.StatusConflict, "Two-factor authentication is already enabled", "ورود دومرحله‌ای از قبل فعال است"},
+	CodeTwoFactorNotEnabled:       {http.StatusConflict, "Two-factor authentication is not enabled", "ورود دومرحله‌ای فعال نیست"},
+	CodeTwoFactorNotEnrolling:     {http.StatusConflict, "No two-factor enrollment in progress", "فعال‌سازی ورود دومرحله‌ای آغاز نشده است"},
+
+	CodeAlreadyRegistered:      {http.StatusConflict, "Phone number or national code already registered", "شماره تلفن یا کد ملی قبلاً ثبت شده است"},
+	CodePhoneTaken:             {http.StatusConflict, "Phone number already exists", "این شماره تلفن قبلاً ثبت شده است"},
+	CodeInvalidPhone:           {http.StatusBadRequest, "Invalid phone number format", "قالب شماره تلفن نامعتبر است"},
+	CodePhoneRequired:          {http.StatusBadRequest, "Phone number required", "شماره تلفن الزامی است"},
+	CodePhoneUnchanged:         {http.StatusBadRequest, "Phone number is unchanged", "شماره تلفن تغییری نکرده است"},
+	CodeVerificationRequired:   {http.StatusBadRequest, "Verification code required", "کد تأیید الزامی است"},
+	CodeVerificationInvalid:    {http.StatusBadRequest, "Invalid or expired verification code", "کد تأیید نادرست یا منقضی است"},
+	CodeVerificationAttempts:   {http.StatusTooManyRequests, "Too many wrong codes, request a new one", "کدهای نادرست زیادی وارد شده، کد جدید درخواست کنید"},
+	CodeVerificationCooldown:   {http.StatusTooManyRequests, "A code was sent recently, try again later", "کدی به‌تازگی ارسال شده، بعداً دوباره تلاش کنید"},
+	CodeVerificationSendFailed: {http.StatusServiceUnavailable, "Could not send the verification code, try again later", "ارسال کد تأیید ممکن نشد، بعداً دوباره تلاش کنید"},
+
+	CodeDependentNotFound:  {http.StatusNotFound, "Dependent not found", "فرد تحت تکفل یافت نشد"},
+	CodeDependentMinor:     {http.StatusConflict, "The dependent has not come of age yet", "فرد تحت تکفل هنوز به سن قانونی نرسیده است"},
+	CodeDependentNested:    {http.StatusConflict, "A dependent cannot have dependents", "فرد تحت تکفل نمی‌تواند افراد تحت تکفل داشته باشد"},
+	CodeGuardianDependents: {http.StatusConflict, "A patient with dependents cannot be deleted", "بیماری که فرد تحت تکفل دارد قابل حذف نیست"},
+
+	CodeInvalidDoctorID:     {http.StatusBadRequest, "Invalid doctor ID", "شناسه پزشک نامعتبر است"},
+	CodeDoctorNotFound:      {http.StatusNotFound, "Doctor not found", "پزشک یافت نشد"},
+	CodeDoctorNotApproved:   {http.StatusForbidden, "Doctor's credentials have not been approved", "مدارک پزشک هنوز تأیید نشده است"},
+	CodeDoctorNoDocuments:   {http.StatusConflict, "Doctor has not uploaded any documents", "پزشک هیچ مدرکی بارگذاری نکرده است"},
+	CodeDoctorInvalidStatus: {http.StatusBadRequest, "Status must be pending, approved, rejected or suspended", "وضعیت باید در انتظار، تأییدشده، ردشده یا تعلیق‌شده باشد"},
+	CodeDoctorStatusChange:  {http.StatusConflict, "This verification status change is not allowed", "این تغییر وضعیت تأیید مجاز نیست"},
+	CodeDoctorReviewNotes:   {http.StatusBadRequest, "Notes are required to reject or suspend a doctor", "برای رد یا تعلیق پزشک، توضیحات الزامی است"},
+	CodeDoctorNotRejected:   {http.StatusConflict, "Only rejected applications can be resubmitted", "فقط درخواست‌های ردشده را می‌توان دوباره ارسال کرد"},
+	CodeDoctorNoNewDocument: {http.StatusConflict, "Upload a new document before resubmitting", "پیش از ارسال دوباره، مدرک جدیدی بارگذاری کنید"},
+	CodeInvalidDocumentID:   {http.StatusBadRequest, "Invalid document ID", "شناسه مدرک نامعتبر است"},
+	CodeInvalidDocumentKind: {http.StatusBadRequest, "Invalid document kind", "نوع مدرک نامعتبر است"},
+	CodeDocumentNotFound:    {http.StatusNotFound, "Document not found", "مدرک یافت نشد"},
+	CodeSearchTermRequired:  {http.StatusBadRequest, "Search term cannot be empty", "عبارت جستجو نمی‌تواند خالی باشد"},
+	CodeInvalidPatientID:    {http.StatusBadRequest, "Invalid patient ID", "شناسه بیمار نامعتبر است"},
+	CodeInvalidSlotID:       {http.StatusBadRequest, "Invalid slot ID", "شناسه نوبت خالی نامعتبر است"},
+	CodeSlotNotFound:        {http.StatusNotFound, "Availability slot not found", "زمان حضور یافت نشد"},
+	CodeInvalidTimeRange:    {http.StatusBadRequest, "The end must be after the start", "زمان پایان باید بعد از زمان شروع باشد"},
+
+	CodeInvalidAppointmentID:     {http.StatusBadRequest, "Invalid appointment ID", "شناسه نوبت نامعتبر است"},
+	CodeSlotUnavailable:          {http.StatusConflict, "Selected time slot is not available", "زمان انتخاب‌شده در دسترس نیست"},
+	CodeInvalidSlot:              {http.StatusBadRequest, "Invalid time slot", "بازه زمانی نامعتبر است"},
+	CodeAppointmentInPast:        {http.StatusBadRequest, "Cannot book an appointment in the past", "رزرو نوبت در گذشته ممکن نیست"},
+	CodeInvalidVisitType:         {http.StatusBadRequest, "Visit type must be 'online' or 'in-person'", "نوع ویزیت باید آنلاین یا حضوری باشد"},
+	CodeInvalidDate:              {http.StatusBadRequest, "Invalid date", "تاریخ نامعتبر است"},
+	CodeInvalidPrescriptionID:    {http.StatusBadRequest, "Invalid prescription ID", "شناسه نسخه نامعتبر است"},
+	CodePrescriptionExists:       {http.StatusConflict, "Only one prescription is allowed per appointment", "برای هر نوبت فقط یک نسخه مجاز است"},
+	CodePrescriptionIncomplete:   {http.StatusBadRequest, "Appointment ID, instructions, and medications are required", "شناسه نوبت، دستورات و داروها الزامی هستند"},
+	CodePrescriptionSearchParams: {http.StatusBadRequest, "At least one search parameter (patientName or date) is required", "حداقل یکی از پارامترهای جستجو (نام بیمار یا تاریخ) الزامی است"},
+
+	CodeInvalidReceiverID:   {http.StatusBadRequest, "Invalid receiver ID", "شناسه گیرنده نامعتبر است"},
+	CodeChatParticipants:    {http.StatusBadRequest, "Exactly two participants are required", "گفتگو باید دقیقاً دو شرکت‌کننده داشته باشد"},
+	CodeChatPeerNotFound:    {http.StatusNotFound, "No doctor or patient has this principal ID", "پزشک یا بیماری با این شناسه وجود ندارد"},
+	CodeChatSendFailed:      {http.StatusInternalServerError, "Failed to send message", "ارسال پیام ناموفق بود"},
+	CodeShuttingDown:        {http.StatusServiceUnavailable, "Server is shutting down", "سرور در حال خاموش شدن است"},
+	CodeInvalidForm:         {http.StatusBadRequest, "File too large or invalid form data", "فایل بیش از حد بزرگ یا فرم نامعتبر است"},
+	CodeFileMissing:         {http.StatusBadRequest, "No file was uploaded", "فایلی بارگذاری نشده است"},
+	CodeFileTooLarge:        {http.StatusRequestEntityTooLarge, "File is too large", "حجم فایل بیش از حد مجاز است"},
+	CodeUnsupportedFileType: {http.StatusUnsupportedMediaType, "Unsupported file type", "نوع فایل پشتیبانی نمی‌شود"},
+}
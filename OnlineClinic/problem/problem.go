@@ -0,0 +1,123 @@
+// Package problem writes API errors as RFC 7807 problem details
+// (application/problem+json). Every problem carries a stable Code that
+// clients match on instead of the message; the title is translated into the
+// language the client prefers in Accept-Language.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"onlineClinic/logging"
+	"onlineClinic/validation"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type of problem responses.
+const ContentType = "application/problem+json"
+
+// typePrefix turns a Code into the problem type URI.
+const typePrefix = "urn:onlineclinic:problem:"
+
+// Languages the titles are translated into. English is the default.
+const (
+	English = "en"
+	Persian = "fa"
+)
+
+// Details is the body of a problem response.
+type Details struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"` // About this occurrence, in English
+	Instance  string            `json:"instance,omitempty"`
+	Code      Code              `json:"code"`
+	RequestID string            `json:"requestId,omitempty"`
+	Errors    validation.Errors `json:"errors,omitempty"` // For CodeValidationFailed
+
+	lang string // Of Title
+}
+
+// New returns the details of code for request r. An unknown code becomes
+// CodeInternal.
+func New(r *http.Request, code Code) *Details {
+	def, ok := catalog[code]
+	if !ok {
+		code, def = CodeInternal, catalog[CodeInternal]
+	}
+	lang, title := Language(r), def.en
+	if lang == Persian {
+		title = def.fa
+	}
+	return &Details{
+		Type:      typePrefix + string(code),
+		Title:     title,
+		Status:    def.status,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: logging.RequestID(r.Context()),
+		lang:      lang,
+	}
+}
+
+// Write writes the problem code as the response.
+func Write(w http.ResponseWriter, r *http.Request, code Code) {
+	New(r, code).Write(w)
+}
+
+// WriteDetail writes the problem code with detail about this occurrence.
+// detail must not contain internal errors.
+func WriteDetail(w http.ResponseWriter, r *http.Request, code Code, detail string) {
+	d := New(r, code)
+	d.Detail = detail
+	d.Write(w)
+}
+
+// WriteValidation writes the failing fields of a request.
+func WriteValidation(w http.ResponseWriter, r *http.Request, errs validation.Errors) {
+	d := New(r, CodeValidationFailed)
+	d.Errors = errs
+	d.Write(w)
+}
+
+// Write writes d as the response.
+func (d *Details) Write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Content-Language", d.lang)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(d.Status)
+	json.NewEncoder(w).Encode(d)
+}
+
+// Status returns the HTTP status of code.
+func Status(code Code) int {
+	if def, ok := catalog[code]; ok {
+		return def.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Language returns the supported language r prefers most, going by the
+// quality values of its Accept-Language header.
+func Language(r *http.Request) string {
+	best, bestQ := English, 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if lang != English && lang != Persian {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
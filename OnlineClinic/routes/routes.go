@@ -2,9 +2,14 @@ package routes
 
 import (
 	"net/http"
+	"onlineClinic/config"
 	"onlineClinic/controllers"
+	"onlineClinic/health"
+	"onlineClinic/keys"
+	"onlineClinic/logging"
+	"onlineClinic/metrics"
+	"onlineClinic/problem"
 	"onlineClinic/utils"
-	"os"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
@@ -12,13 +17,28 @@ import (
 
 var Hub *controllers.Hub
 
+// Keys are the token signing keys published at /.well-known/jwks.json. main
+// sets them before calling SetupRoutes.
+var Keys *keys.Ring
+
 func SetupRoutes(router *mux.Router) http.Handler {
+	// Count every matched route under its template. The metrics themselves
+	// are served on metrics_addr, not here.
+	router.Use(metrics.Middleware)
+
 	// Public routes
 	router.HandleFunc("/api/login/patient", controllers.LoginPatient).Methods("POST")
 	router.HandleFunc("/api/login/doctor", controllers.LoginDoctor).Methods("POST")
+	router.HandleFunc("/api/login/doctor/2fa", controllers.LoginDoctorTwoFactor).Methods("POST")
+	router.HandleFunc("/api/login/staff", controllers.LoginStaff).Methods("POST")
 	router.HandleFunc("/api/register/patient", controllers.RegisterPatient).Methods("POST")
 	router.HandleFunc("/api/register/doctor", controllers.RegisterDoctor).Methods("POST")
-	if os.Getenv("ENV") != "production" {
+	router.HandleFunc("/api/register/code", controllers.RequestRegistrationCode).Methods("POST")
+	router.HandleFunc("/api/token/refresh", controllers.RefreshToken).Methods("POST")
+	router.HandleFunc("/api/password/forgot", controllers.ForgotPassword).Methods("POST")
+	router.HandleFunc("/api/password/reset", controllers.ResetPassword).Methods("POST")
+	router.Handle("/.well-known/jwks.json", Keys).Methods("GET")
+	if !config.Cfg.IsProduction() {
 		router.HandleFunc("/api/debug/verify-hash", controllers.VerifyStoredHash).Methods("GET")
 	}
 
@@ -26,73 +46,139 @@ func SetupRoutes(router *mux.Router) http.Handler {
 	api := router.PathPrefix("/api").Subrouter()
 	api.Use(utils.AuthMiddleware)
 
+	api.HandleFunc("/logout", controllers.Logout).Methods("POST")
+
+	// Every route below names the permission it needs. Routes taking an
+	// {id}, {appointmentId} or {slotId} also resolve who owns it, so patients
+	// and doctors reach only their own data (doctors also that of their
+	// patients, and guardians that of their minor dependents) while staff
+	// with ScopeAny reach everyone's.
+	doctor := utils.DoctorInPath("id")
+	patient := utils.PatientInPath("id")
+	patientOrGuardian := controllers.PatientOrGuardian("id")
+	careTeam := controllers.PatientCareTeam("id")
+	appointment := controllers.AppointmentInPath("id")
+	allow := utils.Authorize
+
+	// Account routes
+	api.HandleFunc("/phone/code", allow(utils.PermPhoneChange, nil)(controllers.RequestPhoneChangeCode)).Methods("POST")
+	api.HandleFunc("/2fa/totp/setup", allow(utils.PermTwoFactor, nil)(controllers.SetupTOTP)).Methods("POST")
+	api.HandleFunc("/2fa/totp/enable", allow(utils.PermTwoFactor, nil)(controllers.EnableTOTP)).Methods("POST")
+	api.HandleFunc("/2fa/totp/disable", allow(utils.PermTwoFactor, nil)(controllers.DisableTOTP)).Methods("POST")
+
+	// Audit routes
+	api.HandleFunc("/audit", allow(utils.PermAuditRead, nil)(controllers.ListAuditEvents)).Methods("GET")
+	api.HandleFunc("/audit/verify", allow(utils.PermAuditRead, nil)(controllers.VerifyAuditChain)).Methods("GET")
+
 	// Doctor routes
-	api.HandleFunc("/allDoctors/search", controllers.SearchDoctors).Methods("POST")
-	api.HandleFunc("/doctors/{id}", utils.DoctorAuthMiddleware(controllers.GetDoctorProfile)).Methods("GET")
-	api.HandleFunc("/doctors/{id}", utils.DoctorAuthMiddleware(controllers.UpdateDoctorProfile)).Methods("PUT")
-	api.HandleFunc("/doctors/{id}", utils.DoctorAuthMiddleware(controllers.DeleteDoctorProfile)).Methods("DELETE")
-	api.HandleFunc("/doc/password", utils.DoctorAuthMiddleware(controllers.UpdateDoctorPassword)).Methods("PUT")
-	api.HandleFunc("/doctors", controllers.GetAllDoctors).Methods("GET")
-	api.HandleFunc("/doctors/{id}/2nearestAppointments", utils.DoctorAuthMiddleware(controllers.GetDoctorTwoNearestAppointments)).Methods("GET")
-	api.HandleFunc("/doctors/{id}/photo", utils.DoctorAuthMiddleware(controllers.DeleteDoctorProfilePhoto)).Methods("DELETE")
+	api.HandleFunc("/allDoctors/search", allow(utils.PermDoctorsList, nil)(controllers.SearchDoctors)).Methods("POST")
+	api.HandleFunc("/doctors/{id}", allow(utils.PermDoctorProfileRead, doctor)(controllers.GetDoctorProfile)).Methods("GET")
+	api.HandleFunc("/doctors/{id}", allow(utils.PermDoctorProfileWrite, doctor)(controllers.UpdateDoctorProfile)).Methods("PUT")
+	api.HandleFunc("/doctors/{id}", allow(utils.PermDoctorDelete, doctor)(controllers.DeleteDoctorProfile)).Methods("DELETE")
+	api.HandleFunc("/doc/password", allow(utils.PermDoctorPassword, nil)(controllers.UpdateDoctorPassword)).Methods("PUT")
+	api.HandleFunc("/doctors", allow(utils.PermDoctorsList, nil)(controllers.GetAllDoctors)).Methods("GET")
+	api.HandleFunc("/doctors/{id}/2nearestAppointments", allow(utils.PermDoctorAppointmentsRead, doctor)(controllers.GetDoctorTwoNearestAppointments)).Methods("GET")
+	api.HandleFunc("/doctors/{id}/photo", allow(utils.PermDoctorProfileWrite, doctor)(controllers.DeleteDoctorProfilePhoto)).Methods("DELETE")
+
+	// Doctor verification
+	api.HandleFunc("/doctors/{id}/documents", allow(utils.PermDoctorDocumentsWrite, doctor)(controllers.UploadDoctorDocument)).Methods("POST")
+	api.HandleFunc("/doctors/{id}/documents/{documentId}", allow(utils.PermDoctorVerificationRead, doctor)(controllers.DownloadDoctorDocument)).Methods("GET")
+	api.HandleFunc("/doctors/{id}/verification", allow(utils.PermDoctorVerificationRead, doctor)(controllers.GetDoctorVerification)).Methods("GET")
+	api.HandleFunc("/doctors/{id}/verification", allow(utils.PermDoctorVerify, doctor)(controllers.ReviewDoctor)).Methods("POST")
+	api.HandleFunc("/doctors/{id}/verification/submit", allow(utils.PermDoctorDocumentsWrite, doctor)(controllers.SubmitDoctorVerification)).Methods("POST")
+	api.HandleFunc("/doctor-verifications", allow(utils.PermDoctorVerify, nil)(controllers.ListDoctorVerifications)).Methods("GET")
 
 	// Doctor Availability Management
-	api.HandleFunc("/doctors/{id}/availability", utils.DoctorOrPatientAuthMiddleware(controllers.GetDoctorAvailability)).Methods("GET")
-	api.HandleFunc("/doctors/{id}/availability", utils.DoctorAuthMiddleware(controllers.SetDoctorAvailability)).Methods("POST")
-	api.HandleFunc("/doctors/{id}/availability/{slotId}", utils.DoctorAuthMiddleware(controllers.DeleteDoctorAvailability)).Methods("DELETE")
+	api.HandleFunc("/doctors/{id}/availability", allow(utils.PermAvailabilityRead, doctor)(controllers.GetDoctorAvailability)).Methods("GET")
+	api.HandleFunc("/doctors/{id}/availability", allow(utils.PermAvailabilityWrite, doctor)(controllers.SetDoctorAvailability)).Methods("POST")
+	api.HandleFunc("/doctors/{id}/availability/{slotId}", allow(utils.PermAvailabilityWrite, controllers.SlotInPath("id", "slotId"))(controllers.DeleteDoctorAvailability)).Methods("DELETE")
 
 	// Patient routes
-	api.HandleFunc("/pnt/password", utils.PatientAuthMiddleware(controllers.UpdatePatientPassword)).Methods("PUT")
-	api.HandleFunc("/patients/{id}", utils.PatientAuthMiddleware(controllers.GetPatientProfile)).Methods("GET")
-	api.HandleFunc("/patient/{id}", utils.DoctorOrPatientAuthMiddleware(controllers.GetPatientInfo)).Methods("GET")
-	api.HandleFunc("/patients/{id}", utils.PatientAuthMiddleware(controllers.UpdatePatientProfile)).Methods("PUT")
-	api.HandleFunc("/patients/{id}", utils.PatientAuthMiddleware(controllers.DeletePatientProfile)).Methods("DELETE")
-	api.HandleFunc("/patients/{id}/photo", utils.PatientAuthMiddleware(controllers.DeletePatientProfilePhoto)).Methods("DELETE")
+	api.HandleFunc("/pnt/password", allow(utils.PermPatientPassword, nil)(controllers.UpdatePatientPassword)).Methods("PUT")
+	api.HandleFunc("/patients/{id}", allow(utils.PermPatientProfileRead, patientOrGuardian)(controllers.GetPatientProfile)).Methods("GET")
+	api.HandleFunc("/patient/{id}", allow(utils.PermPatientInfoRead, careTeam)(controllers.GetPatientInfo)).Methods("GET")
+	api.HandleFunc("/patients/{id}", allow(utils.PermPatientProfileWrite, patient)(controllers.UpdatePatientProfile)).Methods("PUT")
+	api.HandleFunc("/patients/{id}", allow(utils.PermPatientDelete, patient)(controllers.DeletePatientProfile)).Methods("DELETE")
+	api.HandleFunc("/patients/{id}/photo", allow(utils.PermPatientProfileWrite, patient)(controllers.DeletePatientProfilePhoto)).Methods("DELETE")
+	api.HandleFunc("/patients/{id}/access-log", allow(utils.PermAccessLogRead, patientOrGuardian)(controllers.GetPatientAccessLog)).Methods("GET")
+
+	// Dependents. Guardians hand the account over once the dependent comes
+	// of age, though by then they no longer reach the dependent's records.
+	guardian := controllers.DependentGuardian("id")
+	api.HandleFunc("/patients/{id}/dependents", allow(utils.PermDependentsManage, patient)(controllers.ListDependents)).Methods("GET")
+	api.HandleFunc("/patients/{id}/dependents", allow(utils.PermDependentsManage, patient)(controllers.AddDependent)).Methods("POST")
+	api.HandleFunc("/dependents/{id}/handover/code", allow(utils.PermDependentsManage, guardian)(controllers.RequestHandoverCode)).Methods("POST")
+	api.HandleFunc("/dependents/{id}/handover", allow(utils.PermDependentsManage, guardian)(controllers.HandOverDependent)).Methods("POST")
 
 	// Appointment routes
-	api.HandleFunc("/appointments", utils.PatientAuthMiddleware(controllers.CreateAppointment)).Methods("POST")
-	api.HandleFunc("/patients/{id}/2nearestAppointments", utils.PatientAuthMiddleware(controllers.GetPatientTwoNearestAppointments)).Methods("GET")
-	api.HandleFunc("/patients/{id}/appointments", utils.DoctorOrPatientAuthMiddleware(controllers.GetPatientAppointments)).Methods("GET")
-	api.HandleFunc("/appointments/{id}", utils.DoctorOrPatientAuthMiddleware(controllers.DeleteAppointment)).Methods("DELETE")
-	api.HandleFunc("/doctors/{id}/appointments", utils.DoctorAuthMiddleware(controllers.GetDoctorAppointments)).Methods("GET")
-	api.HandleFunc("/patients/{id}/all_appointments", utils.DoctorOrPatientAuthMiddleware(controllers.GetPatientAllAppointments)).Methods("GET")
-	api.HandleFunc("/doctors/{id}/all_appointments", utils.DoctorAuthMiddleware(controllers.GetDoctorAllAppointments)).Methods("GET")
-	api.HandleFunc("/doctor/unreservedAvailableTimes", utils.DoctorAuthMiddleware(controllers.DeleteUnreservedAvailability)).Methods("DELETE")
+	api.HandleFunc("/appointments", allow(utils.PermAppointmentBook, nil)(controllers.CreateAppointment)).Methods("POST")
+	api.HandleFunc("/patients/{id}/2nearestAppointments", allow(utils.PermPatientAppointmentsRead, patientOrGuardian)(controllers.GetPatientTwoNearestAppointments)).Methods("GET")
+	api.HandleFunc("/patients/{id}/appointments", allow(utils.PermPatientAppointmentsRead, careTeam)(controllers.GetPatientAppointments)).Methods("GET")
+	api.HandleFunc("/appointments/{id}", allow(utils.PermAppointmentCancel, appointment)(controllers.DeleteAppointment)).Methods("DELETE")
+	api.HandleFunc("/doctors/{id}/appointments", allow(utils.PermDoctorAppointmentsRead, doctor)(controllers.GetDoctorAppointments)).Methods("GET")
+	api.HandleFunc("/patients/{id}/all_appointments", allow(utils.PermPatientAppointmentsRead, careTeam)(controllers.GetPatientAllAppointments)).Methods("GET")
+	api.HandleFunc("/doctors/{id}/all_appointments", allow(utils.PermDoctorAppointmentsRead, doctor)(controllers.GetDoctorAllAppointments)).Methods("GET")
+	api.HandleFunc("/doctor/unreservedAvailableTimes", allow(utils.PermAvailabilityClearOwn, nil)(controllers.DeleteUnreservedAvailability)).Methods("DELETE")
 	//api.HandleFunc("/prescriptions", utils.DoctorAuthMiddleware(controllers.CreatePrescription)).Methods("POST")
-	api.HandleFunc("/prescriptions/search", utils.DoctorOrPatientAuthMiddleware(controllers.GetPrescriptionsByPatientNameAndDateHandler)).Methods("GET")
-	api.HandleFunc("/prescriptions/patient/{id}", utils.DoctorOrPatientAuthMiddleware(controllers.GetPrescriptionsByPatient)).Methods("GET")
-	api.HandleFunc("/prescriptions/doctor/{id}", utils.DoctorAuthMiddleware(controllers.GetPrescriptionsByDoctor)).Methods("GET")
-	api.HandleFunc("/prescriptions/{appointmentId}", utils.DoctorOrPatientAuthMiddleware(controllers.GetPrescriptionByAppointment)).Methods("GET")
-	api.HandleFunc("/prescriptions", utils.DoctorAuthMiddleware(controllers.UpdatePrescription)).Methods("PUT")
+	api.HandleFunc("/prescriptions/search", allow(utils.PermPrescriptionRead, nil)(controllers.GetPrescriptionsByPatientNameAndDateHandler)).Methods("GET")
+	api.HandleFunc("/prescriptions/patient/{id}", allow(utils.PermPrescriptionRead, careTeam)(controllers.GetPrescriptionsByPatient)).Methods("GET")
+	api.HandleFunc("/prescriptions/doctor/{id}", allow(utils.PermDoctorPrescriptionsRead, doctor)(controllers.GetPrescriptionsByDoctor)).Methods("GET")
+	api.HandleFunc("/prescriptions/{appointmentId}", allow(utils.PermPrescriptionRead, controllers.AppointmentInPath("appointmentId"))(controllers.GetPrescriptionByAppointment)).Methods("GET")
+	api.HandleFunc("/prescriptions", allow(utils.PermPrescriptionWrite, nil)(controllers.UpdatePrescription)).Methods("PUT")
 
 	// File upload routes
-	api.HandleFunc("/upload/profile", controllers.UploadProfilePhoto).Methods("POST")
+	api.HandleFunc("/upload/profile", allow(utils.PermProfilePhotoUpload, nil)(controllers.UploadProfilePhoto)).Methods("POST")
 
 	// Chat routes
-	api.HandleFunc("/chat", utils.DoctorOrPatientAuthMiddleware(controllers.CreateChat)).Methods("POST")
-	api.HandleFunc("/chat/history", utils.DoctorOrPatientAuthMiddleware(controllers.GetChatHistory)).Methods("GET")
-	api.HandleFunc("/chats/unread", utils.DoctorOrPatientAuthMiddleware(controllers.GetUnreadChats)).Methods("GET")
+	api.HandleFunc("/chat", allow(utils.PermChat, nil)(controllers.CreateChat)).Methods("POST")
+	api.HandleFunc("/chat/history", allow(utils.PermChat, nil)(controllers.GetChatHistory)).Methods("GET")
+	api.HandleFunc("/chats/unread", allow(utils.PermChat, nil)(controllers.GetUnreadChats)).Methods("GET")
 
 	// File upload route
-	api.HandleFunc("/chats", utils.DoctorOrPatientAuthMiddleware(controllers.GetAllChats)).Methods("GET")
-	api.HandleFunc("/upload/chat", controllers.UploadChatFile).Methods("POST")
+	api.HandleFunc("/chats", allow(utils.PermChat, nil)(controllers.GetAllChats)).Methods("GET")
+	api.HandleFunc("/upload/chat", allow(utils.PermChat, nil)(controllers.UploadChatFile)).Methods("POST")
 
-	// WebSocket route (under router, not api)
+	// WebSocket route (under router, not api). Browsers authenticate it with
+	// a single-use ticket.
+	api.HandleFunc("/ws/ticket", allow(utils.PermChat, nil)(controllers.IssueWSTicket)).Methods("POST")
 	Hub = controllers.NewHub()
 	go Hub.Run()
 	router.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		controllers.ServeWs(Hub, w, r)
 	})
 
+	// Health routes. Liveness only fails if the hub loop is wedged, which a
+	// restart fixes; readiness also covers the database and the disk.
+	live := health.New(config.Cfg.HealthTimeout)
+	live.Add("hub", Hub.Ping)
+	router.Handle("/healthz", live).Methods("GET")
+
+	ready := health.New(config.Cfg.HealthTimeout)
+	ready.Add("database", health.Database(config.DB))
+	ready.Add("migrations", health.Migrations(config.Cfg.DBDriver, config.DB))
+	ready.Add("uploads", health.Writable(utils.UploadDir()))
+	ready.Add("documents", health.Writable(config.Cfg.DocumentDir))
+	ready.Add("hub", Hub.Ping)
+	router.Handle("/readyz", ready).Methods("GET")
+
+	// Unknown routes get problem responses like every other error
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, problem.CodeNotFound)
+	})
+	router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, problem.CodeMethodNotAllowed)
+	})
+
 	// CORS middleware
 	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"https://kashan-clininc.liara.run", "https://online-clinic.liara.run", "http://185.142.159.118"},
+		AllowedOrigins:   config.Cfg.CORSOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization", "Origin", "Accept"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "Origin", "Accept", "Accept-Language"},
 		ExposedHeaders:   []string{"Content-Length"},
 		AllowCredentials: true,
-		Debug:            true,
+		Debug:            !config.Cfg.IsProduction(),
 	})
 
-	return c.Handler(router)
+	// Request IDs wrap everything, so CORS preflights and 404s are logged too
+	return logging.Middleware(c.Handler(router))
 }
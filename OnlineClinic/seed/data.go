@@ -0,0 +1,59 @@
+package seed
+
+// Word lists the demo data is drawn from.
+
+var (
+	maleNames = []string{
+		"علی", "محمد", "حسین", "رضا", "مهدی", "امیر", "سعید", "حمید",
+		"مجید", "کاوه", "بهزاد", "فرهاد", "آرش", "پویا", "نیما", "سینا",
+	}
+	femaleNames = []string{
+		"مریم", "زهرا", "فاطمه", "سارا", "نرگس", "لیلا", "مینا", "نازنین",
+		"شیرین", "الهام", "پریسا", "نگار", "هانیه", "آزاده", "سمیرا", "ترانه",
+	}
+	lastNames = []string{
+		"محمدی", "حسینی", "احمدی", "رضایی", "کریمی", "موسوی", "جعفری", "صادقی",
+		"رحیمی", "هاشمی", "نوری", "کاظمی", "قاسمی", "عباسی", "طاهری", "یزدانی",
+	}
+
+	specialties = []string{
+		"متخصص قلب و عروق", "متخصص داخلی", "متخصص اطفال", "متخصص پوست و مو",
+		"متخصص زنان و زایمان", "متخصص مغز و اعصاب", "پزشک عمومی", "متخصص گوش و حلق و بینی",
+	}
+	educations = []string{"دیپلم", "کاردانی", "کارشناسی", "کارشناسی ارشد", "دکتری"}
+	jobs       = []string{"معلم", "مهندس", "کارمند", "دانشجو", "پرستار", "راننده", "آزاد", "بازنشسته"}
+	cities     = []string{"تهران", "اصفهان", "شیراز", "مشهد", "تبریز", "کرج", "قم", "رشت"}
+	streets    = []string{"خیابان انقلاب", "خیابان آزادی", "خیابان ولیعصر", "بلوار کشاورز", "خیابان شریعتی", "خیابان مطهری"}
+
+	medicines = []string{
+		"آموکسی سیلین ۵۰۰", "استامینوفن ۵۰۰", "ایبوپروفن ۴۰۰", "لوزارتان ۲۵",
+		"متفورمین ۵۰۰", "امپرازول ۲۰", "سیتریزین ۱۰", "ویتامین D ۵۰۰۰۰",
+	}
+	frequencies = []string{
+		"هر ۸ ساعت", "هر ۱۲ ساعت", "روزی یک بار", "شب‌ها قبل از خواب", "هفته‌ای یک بار",
+	}
+	instructions = []string{
+		"پس از غذا مصرف شود.",
+		"مصرف مایعات را افزایش دهید و استراحت کافی داشته باشید.",
+		"در صورت بروز حساسیت مصرف را قطع کنید.",
+		"دو هفته دیگر برای پیگیری مراجعه شود.",
+		"فشار خون روزانه اندازه‌گیری و یادداشت شود.",
+	}
+
+	patientLines = []string{
+		"سلام دکتر، وقت بخیر.",
+		"داروها را طبق دستور مصرف می‌کنم.",
+		"سردرد کمتر شده ولی هنوز کامل خوب نشده‌ام.",
+		"آیا لازم است آزمایش خون بدهم؟",
+		"ممنون از راهنمایی شما.",
+		"جواب آزمایش را برایتان می‌فرستم.",
+	}
+	doctorLines = []string{
+		"سلام، حالتان چطور است؟",
+		"لطفاً داروها را تا پایان دوره مصرف کنید.",
+		"اگر علائم ادامه داشت دوباره مراجعه کنید.",
+		"بله، یک آزمایش قند خون ناشتا انجام دهید.",
+		"خواهش می‌کنم، سلامت باشید.",
+		"نتیجه آزمایش طبیعی است.",
+	}
+)
@@ -0,0 +1,441 @@
+// Package seed fills a database with demo doctors, patients, availability,
+// appointments, prescriptions and chats. Every value is drawn from a
+// math/rand source, so the same Options always produce the same dataset.
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"onlineClinic/models"
+	"onlineClinic/utils"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Options controls the size and shape of the generated dataset.
+type Options struct {
+	Seed         int64     // Source of every random choice
+	Doctors      int       // Number of doctors, each with a profile photo
+	Patients     int       // Number of patients
+	Weeks        int       // Weeks of availability per doctor
+	Appointments int       // Booked appointments, each with a prescription
+	Messages     int       // Messages per doctor/patient chat
+	Start        time.Time // First day of availability; must not be in the past
+	Password     string    // Password of every seeded account
+	UploadDir    string    // Root directory for profile photos
+}
+
+// Summary reports what was created.
+type Summary struct {
+	Doctors       int
+	Patients      int
+	Slots         int
+	Appointments  int
+	Prescriptions int
+	Chats         int
+	Messages      int
+
+	DoctorPhones  []string // Login phone numbers, in creation order
+	PatientPhones []string
+}
+
+// Daily hours per visit type. Slots are 15 minutes long.
+var visitHours = []struct {
+	visitType  string
+	start, end string // HH:mm
+}{
+	{"in-person", "09:00", "12:00"},
+	{"online", "17:00", "19:00"},
+}
+
+// workDays is the number of days each weekly date range covers, counted
+// from the weekday of Options.Start.
+const workDays = 5
+
+type seeder struct {
+	store *models.Store
+	opts  Options
+	rng   *rand.Rand
+	hash  string
+
+	phones map[string]bool
+	codes  map[string]bool
+
+	doctors  []*models.Doctor
+	patients []*models.Patient
+	summary  Summary
+}
+
+// Run creates the dataset described by opts through store. It stops at the
+// first error; a unique key error usually means the same seed was already
+// applied to this database.
+func Run(store *models.Store, opts Options) (*Summary, error) {
+	if opts.Appointments > 0 && (opts.Doctors == 0 || opts.Patients == 0 || opts.Weeks == 0) {
+		return nil, errors.New("appointments need at least one doctor, one patient and one week of availability")
+	}
+
+	// One hash for every account keeps seeding fast.
+	hash, err := bcrypt.GenerateFromPassword([]byte(opts.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("hashing password: %v", err)
+	}
+
+	s := &seeder{
+		store:  store,
+		opts:   opts,
+		rng:    rand.New(rand.NewSource(opts.Seed)),
+		hash:   string(hash),
+		phones: make(map[string]bool),
+		codes:  make(map[string]bool),
+	}
+
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"doctors", s.createDoctors},
+		{"patients", s.createPatients},
+		{"availability", s.createAvailability},
+		{"appointments", s.bookAppointments},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			return &s.summary, fmt.Errorf("seeding %s: %w", step.name, err)
+		}
+	}
+	return &s.summary, nil
+}
+
+func (s *seeder) createDoctors() error {
+	for i := 0; i < s.opts.Doctors; i++ {
+		gender, first := s.person()
+		doctor := &models.Doctor{
+			FirstName:          first,
+			LastName:           pick(s.rng, lastNames),
+			NationalCode:       s.nationalCode(),
+			Gender:             gender,
+			PhoneNumber:        s.phone(),
+			Password:           s.hash,
+			MedicalCouncilCode: ptr(strconv.Itoa(10000 + s.rng.Intn(90000))),
+		}
+		if err := s.store.Doctors.Create(doctor); err != nil {
+			return err
+		}
+		created, err := s.store.Doctors.GetByPhone(doctor.PhoneNumber)
+		if err != nil {
+			return err
+		}
+
+		photo, err := s.writePhoto(created.ID)
+		if err != nil {
+			return err
+		}
+		created.Age = ptr(32 + s.rng.Intn(35))
+		created.Education = ptr(pick(s.rng, specialties))
+		created.Address = ptr(s.address())
+		created.ProfilePhotoPath = &photo
+		if err := s.store.Doctors.Update(created); err != nil {
+			return err
+		}
+
+		// New doctors start pending, and only approved ones are listed and
+		// bookable.
+		if err := s.store.DoctorVerification.Transition(&models.DoctorReview{
+			DoctorID:   created.ID,
+			FromStatus: models.DoctorPending,
+			ToStatus:   models.DoctorApproved,
+			Notes:      "Seeded demo account",
+		}); err != nil {
+			return err
+		}
+
+		s.doctors = append(s.doctors, created)
+		s.summary.Doctors++
+		s.summary.DoctorPhones = append(s.summary.DoctorPhones, created.PhoneNumber)
+	}
+	return nil
+}
+
+func (s *seeder) createPatients() error {
+	for i := 0; i < s.opts.Patients; i++ {
+		gender, first := s.person()
+		patient := &models.Patient{
+			FirstName:    first,
+			LastName:     pick(s.rng, lastNames),
+			NationalCode: s.nationalCode(),
+			Gender:       gender,
+			PhoneNumber:  s.phone(),
+			Password:     s.hash,
+			Age:          ptr(5 + s.rng.Intn(75)),
+			Job:          ptr(pick(s.rng, jobs)),
+			Education:    ptr(pick(s.rng, educations)),
+			Address:      ptr(s.address()),
+		}
+		if err := s.store.Patients.Create(patient); err != nil {
+			return err
+		}
+		created, err := s.store.Patients.GetByPhone(patient.PhoneNumber)
+		if err != nil {
+			return err
+		}
+
+		s.patients = append(s.patients, created)
+		s.summary.Patients++
+		s.summary.PatientPhones = append(s.summary.PatientPhones, created.PhoneNumber)
+	}
+	return nil
+}
+
+// createAvailability gives every doctor one Solar-calendar date range of
+// workDays days per week, for each visit type.
+func (s *seeder) createAvailability() error {
+	for _, doctor := range s.doctors {
+		for _, hours := range visitHours {
+			start, _ := time.Parse("15:04", hours.start)
+			end, _ := time.Parse("15:04", hours.end)
+
+			req := &models.AvailabilityRequest{
+				Type:       hours.visitType,
+				TimesRange: []models.TimeRange{{Start: start, End: end}},
+			}
+			for week := 0; week < s.opts.Weeks; week++ {
+				first := s.opts.Start.AddDate(0, 0, 7*week)
+				req.DatesRange = append(req.DatesRange, models.TimeRange{
+					Start: solarDate(first),
+					End:   solarDate(first.AddDate(0, 0, workDays-1)),
+				})
+			}
+
+			if err := s.store.Availability.Set(doctor.ID, req); err != nil {
+				return err
+			}
+			s.summary.Slots += s.opts.Weeks * workDays * int(end.Sub(start)/(15*time.Minute))
+		}
+	}
+	return nil
+}
+
+// bookAppointments books random free slots, fills in the prescription that
+// comes with each appointment and opens a chat between the doctor and the
+// patient.
+func (s *seeder) bookAppointments() error {
+	chats := make(map[[2]int]bool)
+
+	for booked, attempts := 0, 0; booked < s.opts.Appointments; attempts++ {
+		if attempts > 20*s.opts.Appointments {
+			return fmt.Errorf("only %d of %d appointments could be booked; add doctors or weeks", booked, s.opts.Appointments)
+		}
+
+		doctor := s.doctors[s.rng.Intn(len(s.doctors))]
+		patient := s.patients[s.rng.Intn(len(s.patients))]
+		hours := visitHours[s.rng.Intn(len(visitHours))]
+		day := s.opts.Start.AddDate(0, 0, 7*s.rng.Intn(s.opts.Weeks)+s.rng.Intn(workDays))
+		start, _ := time.Parse("15:04", hours.start)
+		end, _ := time.Parse("15:04", hours.end)
+		slot := start.Add(time.Duration(s.rng.Intn(int(end.Sub(start)/(15*time.Minute)))) * 15 * time.Minute)
+
+		req := &models.AppointmentRequest{
+			DoctorID:  strconv.Itoa(doctor.ID),
+			PatientID: strconv.Itoa(patient.ID),
+			Type:      hours.visitType,
+			Date:      utils.GregorianToSolar(day),
+			Time:      slot.Format("15:04"),
+		}
+		err := s.store.Appointments.Create(req)
+		if err == models.ErrTimeNotAvailable {
+			continue // Already booked by an earlier draw
+		}
+		if err != nil {
+			return err
+		}
+		booked++
+		s.summary.Appointments++
+
+		if err := s.prescribe(doctor.ID, patient.ID, req); err != nil {
+			return err
+		}
+
+		pair := [2]int{doctor.ID, patient.ID}
+		if !chats[pair] && s.opts.Messages > 0 {
+			chats[pair] = true
+			if err := s.chat(doctor.PrincipalID, patient.PrincipalID, req.Date); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *seeder) prescribe(doctorID, patientID int, req *models.AppointmentRequest) error {
+	booked, err := req.ToAppointment()
+	if err != nil {
+		return err
+	}
+	appointments, err := s.store.Appointments.ListByPatient(patientID)
+	if err != nil {
+		return err
+	}
+
+	for _, appointment := range appointments {
+		if appointment.DoctorID != doctorID || !appointment.StartTime.Equal(booked.StartTime) {
+			continue
+		}
+		prescription, err := s.store.Prescriptions.GetByAppointment(appointment.ID, doctorID, true)
+		if err != nil {
+			return err
+		}
+
+		var medications []models.Medication
+		for i := 1 + s.rng.Intn(3); i > 0; i-- {
+			medications = append(medications, models.Medication{
+				Medicine:  pick(s.rng, medicines),
+				Frequency: pick(s.rng, frequencies),
+			})
+		}
+		err = s.store.Prescriptions.Update(&models.Prescription{
+			ID:            prescription.ID,
+			AppointmentID: appointment.ID,
+			Instructions:  pick(s.rng, instructions),
+			Medications:   medications,
+		})
+		if err != nil {
+			return err
+		}
+		s.summary.Prescriptions++
+		return nil
+	}
+	return fmt.Errorf("booked appointment of patient %d at %s not found", patientID, booked.StartTime.Format("2006-01-02 15:04"))
+}
+
+// chat writes a conversation dated on the appointment day between the
+// principals doctorID and patientID. The patient opens it and the last two
+// messages are left unread.
+func (s *seeder) chat(doctorID, patientID int, date string) error {
+	chatID, err := s.store.Chats.Create(patientID, doctorID)
+	if err != nil {
+		return err
+	}
+	s.summary.Chats++
+
+	at, _ := time.Parse("15:04", "08:00")
+	for i := 0; i < s.opts.Messages; i++ {
+		sender, receiver, lines := patientID, doctorID, patientLines
+		if i%2 == 1 {
+			sender, receiver, lines = doctorID, patientID, doctorLines
+		}
+		at = at.Add(time.Duration(1+s.rng.Intn(20)) * time.Minute)
+
+		err := s.store.Chats.AddMessage(chatID, sender, receiver, pick(s.rng, lines), at.Format("15:04"),
+			"", nil, date, nil, i < s.opts.Messages-2)
+		if err != nil {
+			return err
+		}
+		s.summary.Messages++
+	}
+	return nil
+}
+
+// writePhoto saves a generated avatar for doctor id and returns its path
+// relative to the upload directory, like utils.SaveFile.
+func (s *seeder) writePhoto(id int) (string, error) {
+	background := color.RGBA{uint8(s.rng.Intn(256)), uint8(s.rng.Intn(256)), uint8(s.rng.Intn(256)), 255}
+	foreground := color.RGBA{255 - background.R, 255 - background.G, 255 - background.B, 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, 128, 128))
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 128; x++ {
+			// A light circle on a solid background
+			dx, dy := x-64, y-52
+			if dx*dx+dy*dy < 28*28 || (y > 88 && dx*dx < (y-70)*(y-70)) {
+				img.Set(x, y, foreground)
+			} else {
+				img.Set(x, y, background)
+			}
+		}
+	}
+
+	relativePath := filepath.ToSlash(filepath.Join("profile", "doctors", strconv.Itoa(id), "seed.png"))
+	fullPath := filepath.Join(s.opts.UploadDir, filepath.FromSlash(relativePath))
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", err
+	}
+
+	f, err := os.Create(fullPath)
+	if err != nil {
+		return "", err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return "", err
+	}
+	return relativePath, f.Close()
+}
+
+func (s *seeder) person() (gender, firstName string) {
+	if s.rng.Intn(2) == 0 {
+		return "man", pick(s.rng, maleNames)
+	}
+	return "woman", pick(s.rng, femaleNames)
+}
+
+func (s *seeder) address() string {
+	return fmt.Sprintf("%s، %s، پلاک %d", pick(s.rng, cities), pick(s.rng, streets), 1+s.rng.Intn(200))
+}
+
+// phone returns an unused mobile number in the 0990 range.
+func (s *seeder) phone() string {
+	for {
+		phone := fmt.Sprintf("0990%07d", s.rng.Intn(10000000))
+		if !s.phones[phone] {
+			s.phones[phone] = true
+			return phone
+		}
+	}
+}
+
+// nationalCode returns an unused national code with a valid check digit.
+func (s *seeder) nationalCode() string {
+	for {
+		digits := make([]byte, 10)
+		sum := 0
+		for i := 0; i < 9; i++ {
+			d := s.rng.Intn(10)
+			digits[i] = byte('0' + d)
+			sum += d * (10 - i)
+		}
+		check := sum % 11
+		if check >= 2 {
+			check = 11 - check
+		}
+		digits[9] = byte('0' + check)
+
+		if code := string(digits); !s.codes[code] {
+			s.codes[code] = true
+			return code
+		}
+	}
+}
+
+// solarDate returns day as a Solar calendar date held in a time.Time, the
+// form models.TimeRange takes dates in.
+func solarDate(day time.Time) time.Time {
+	date, _ := time.Parse("2006-01-02", utils.GregorianToSolar(day))
+	return date
+}
+
+func pick(rng *rand.Rand, items []string) string {
+	return items[rng.Intn(len(items))]
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
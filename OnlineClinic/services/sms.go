@@ -0,0 +1,64 @@
+// services/sms.go
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// SMSSender delivers text messages to phone numbers.
+type SMSSender interface {
+	SendSMS(ctx context.Context, phoneNumber, text string) error
+}
+
+// ConsoleSMS writes messages to a writer, such as stderr, instead of sending
+// them. It is meant for development.
+type ConsoleSMS struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewConsoleSMS(w io.Writer) *ConsoleSMS {
+	return &ConsoleSMS{w: w}
+}
+
+func (s *ConsoleSMS) SendSMS(ctx context.Context, phoneNumber, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := fmt.Fprint(s.w, formatSMS(phoneNumber, text))
+	return err
+}
+
+// FileSMS appends messages to a file instead of sending them, one per line,
+// so that scripts and tests can read the codes.
+type FileSMS struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileSMS(path string) *FileSMS {
+	return &FileSMS{path: path}
+}
+
+func (s *FileSMS) SendSMS(ctx context.Context, phoneNumber, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(formatSMS(phoneNumber, text)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func formatSMS(phoneNumber, text string) string {
+	return fmt.Sprintf("%s SMS to %s: %s\n", time.Now().Format(time.RFC3339), phoneNumber, text)
+}
@@ -0,0 +1,59 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps counters in process memory. Each instance counts on
+// its own and restarts forget every counter, so it suits development and
+// single-instance deployments.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]Entry)}
+}
+
+func (s *MemoryStore) Get(key string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[key], nil
+}
+
+func (s *MemoryStore) Fail(key string, now time.Time, window time.Duration) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entries[key]
+	if e.LastFailure.Before(now.Add(-window)) {
+		e.Failures = 0
+	}
+	e.Failures++
+	e.LastFailure = now
+	s.entries[key] = e
+	return e, nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStore) Purge(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for key, e := range s.entries {
+		if e.LastFailure.Before(before) {
+			delete(s.entries, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
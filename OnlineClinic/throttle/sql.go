@@ -0,0 +1,73 @@
+package throttle
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SQLStore keeps counters in the login_failures table, so all instances
+// using one database share them. The SQL runs on MySQL and SQLite; times are
+// written in UTC from Go and stored to the second.
+type SQLStore struct{ db *sql.DB }
+
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+func (s *SQLStore) Get(key string) (Entry, error) {
+	var e Entry
+	err := s.db.QueryRow("SELECT failures, last_failure FROM login_failures WHERE throttle_key = ?", key).
+		Scan(&e.Failures, &e.LastFailure)
+	if err == sql.ErrNoRows {
+		return Entry{}, nil
+	}
+	return e, err
+}
+
+// Fail increments the counter in one statement, so concurrent failures on
+// other instances are all counted. A key without a row gets one inserted;
+// if another instance inserted it first, the increment is retried.
+func (s *SQLStore) Fail(key string, now time.Time, window time.Duration) (Entry, error) {
+	now = now.UTC().Truncate(time.Second)
+	increment := func() (bool, error) {
+		result, err := s.db.Exec(`
+            UPDATE login_failures
+            SET failures = CASE WHEN last_failure < ? THEN 1 ELSE failures + 1 END,
+                last_failure = ?
+            WHERE throttle_key = ?`,
+			now.Add(-window), now, key)
+		if err != nil {
+			return false, err
+		}
+		n, err := result.RowsAffected()
+		return n > 0, err
+	}
+
+	updated, err := increment()
+	if err != nil {
+		return Entry{}, err
+	}
+	if !updated {
+		_, err := s.db.Exec("INSERT INTO login_failures (throttle_key, failures, last_failure) VALUES (?, 1, ?)", key, now)
+		if err != nil {
+			if updated, _ = increment(); !updated {
+				return Entry{}, err
+			}
+		}
+	}
+	return s.Get(key)
+}
+
+func (s *SQLStore) Reset(key string) error {
+	_, err := s.db.Exec("DELETE FROM login_failures WHERE throttle_key = ?", key)
+	return err
+}
+
+func (s *SQLStore) Purge(before time.Time) (int, error) {
+	result, err := s.db.Exec("DELETE FROM login_failures WHERE last_failure < ?", before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,166 @@
+// Package throttle slows down password guessing. It counts failed login
+// attempts per key, such as an account or a client IP, and blocks a key for
+// a while after each failure: the delay doubles with every failure until
+// the key is locked out. Counters live in a Store, so instances sharing an
+// SQLStore share them too.
+package throttle
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// purgeInterval is how often Run deletes counters that no longer block.
+const purgeInterval = time.Hour
+
+// Entry is the failure counter of one key.
+type Entry struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// Store keeps failure counters. Implementations must be safe for concurrent
+// use and count concurrent failures of one key correctly.
+type Store interface {
+	// Get returns the counter of key, or a zero Entry if it has none.
+	Get(key string) (Entry, error)
+
+	// Fail adds a failure at now to the counter of key and returns it.
+	// Failures before now minus window are forgotten first.
+	Fail(key string, now time.Time, window time.Duration) (Entry, error)
+
+	// Reset deletes the counter of key.
+	Reset(key string) error
+
+	// Purge deletes counters whose last failure is before before and
+	// returns how many it deleted.
+	Purge(before time.Time) (int, error)
+}
+
+// Policy decides how long a key is blocked after its failures.
+type Policy struct {
+	MaxFailures int           // Failures that lock the key out
+	Backoff     time.Duration // Block after the first failure, doubled for each further one; 0 blocks only on lockout
+	Lockout     time.Duration // Block once MaxFailures is reached
+	Window      time.Duration // Failures this far apart start the count over
+}
+
+// BlockedUntil returns when the key with counter e may try again.
+func (p Policy) BlockedUntil(e Entry) time.Time {
+	if e.Failures == 0 {
+		return time.Time{}
+	}
+	if e.Failures >= p.MaxFailures {
+		return e.LastFailure.Add(p.Lockout)
+	}
+	if p.Backoff <= 0 {
+		return time.Time{}
+	}
+
+	delay := p.Backoff
+	for i := 1; i < e.Failures && delay < p.Lockout; i++ {
+		delay *= 2
+	}
+	if delay > p.Lockout {
+		delay = p.Lockout
+	}
+	return e.LastFailure.Add(delay)
+}
+
+// Lockout reports a key that reached its policy's MaxFailures.
+type Lockout struct {
+	Key      string
+	Failures int
+	Until    time.Time
+}
+
+// Limiter throttles login attempts per account and per client IP.
+type Limiter struct {
+	store   Store
+	account Policy
+	ip      Policy
+	now     func() time.Time
+}
+
+// New returns a Limiter that keeps its counters in store.
+func New(store Store, account, ip Policy) *Limiter {
+	return &Limiter{store: store, account: account, ip: ip, now: time.Now}
+}
+
+func accountKey(account string) string { return "account:" + account }
+func ipKey(ip string) string           { return "ip:" + ip }
+
+// Check returns how long an attempt to log in to account from ip has to
+// wait, or 0 if it may go ahead.
+func (l *Limiter) Check(account, ip string) (time.Duration, error) {
+	now := l.now()
+	var wait time.Duration
+	for _, k := range []struct {
+		key    string
+		policy Policy
+	}{{accountKey(account), l.account}, {ipKey(ip), l.ip}} {
+		e, err := l.store.Get(k.key)
+		if err != nil {
+			return 0, err
+		}
+		if d := k.policy.BlockedUntil(e).Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// Fail records a failed attempt to log in to account from ip. It returns
+// the keys this failure locked out.
+func (l *Limiter) Fail(account, ip string) ([]Lockout, error) {
+	now := l.now()
+	var lockouts []Lockout
+	for _, k := range []struct {
+		key    string
+		policy Policy
+	}{{accountKey(account), l.account}, {ipKey(ip), l.ip}} {
+		e, err := l.store.Fail(k.key, now, k.policy.Window)
+		if err != nil {
+			return lockouts, err
+		}
+		if e.Failures >= k.policy.MaxFailures {
+			lockouts = append(lockouts, Lockout{Key: k.key, Failures: e.Failures, Until: k.policy.BlockedUntil(e)})
+		}
+	}
+	return lockouts, nil
+}
+
+// Succeed clears the failures of account after it logged in. The failures
+// of the client IP are kept, as they may belong to other accounts.
+func (l *Limiter) Succeed(account string) error {
+	return l.store.Reset(accountKey(account))
+}
+
+// Run purges counters that no longer block or count, every purgeInterval
+// until ctx is done.
+func (l *Limiter) Run(ctx context.Context) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		keep := l.account.Window
+		for _, d := range []time.Duration{l.account.Lockout, l.ip.Window, l.ip.Lockout} {
+			if d > keep {
+				keep = d
+			}
+		}
+		deleted, err := l.store.Purge(l.now().Add(-keep))
+		if err != nil {
+			slog.Error("purging login failure counters", "err", err)
+		} else if deleted > 0 {
+			slog.Info("purged login failure counters", "deleted", deleted)
+		}
+	}
+}
@@ -2,16 +2,18 @@ package utils
 
 import (
 	"context"
+	"crypto/rsa"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"onlineClinic/config"
-	"strconv"
+	"onlineClinic/logging"
+	"onlineClinic/problem"
 	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
-	"github.com/gorilla/mux"
 )
 
 type Claims struct {
@@ -19,39 +21,72 @@ type Claims struct {
 	PhoneNumber string `json:"phone_number"`
 	IsDoctor    bool   `json:"is_doctor"`
 	IsPatient   bool   `json:"is_patient"`
+	SessionID   string `json:"sid"`
+	Role        Role   `json:"role"`
+	// Permissions are those Policy granted Role when the token was issued.
+	// Other services verifying our tokens can rely on them too.
+	Permissions map[Permission]Scope `json:"permissions"`
 	jwt.StandardClaims
 }
 
+// RevocationChecker reports whether a login session was ended, so that
+// access tokens issued for it stop working before they expire.
+type RevocationChecker interface {
+	Revoked(sessionID string) (bool, error)
+}
+
+// Revocations is consulted by VerifyToken for every token. main sets it
+// once the database is connected.
+var Revocations RevocationChecker
+
+// KeySet holds the RSA keys that sign and verify access tokens.
+type KeySet interface {
+	Signing() (kid string, key *rsa.PrivateKey)
+	PublicKey(kid string) (*rsa.PublicKey, bool)
+}
+
+// Keys signs and verifies every token. main sets it on startup.
+var Keys KeySet
+
 type contextKey string
 
 const UserClaimsKey contextKey = "userClaims"
 
-func GenerateToken(userID int, phoneNumber string, isDoctor bool, isPatient bool) (string, error) {
-	// log.Printf("Generating token - UserID: %d, Phone: %s, IsDoctor: %v, IsPatient: %v", userID, phoneNumber, isDoctor, isPatient)
+// GenerateToken issues an access token for the login session sessionID
+// with the permissions of role.
+func GenerateToken(userID int, phoneNumber string, role Role, sessionID string) (string, error) {
+	// log.Printf("Generating token - UserID: %d, Phone: %s, Role: %s", userID, phoneNumber, role)
 
-	if isDoctor && isPatient {
-		// log.Printf("Warning: User cannot be both doctor and patient - UserID: %d", userID)
+	if _, ok := Policy[role]; !ok {
+		return "", fmt.Errorf("unknown role %q", role)
 	}
 
-	expirationTime := time.Now().Add(24 * time.Hour)
+	expirationTime := time.Now().Add(config.Cfg.AccessTokenTTL)
 	// log.Printf("Token expiration set to: %v", expirationTime)
 
 	claims := Claims{
 		UserID:      userID,
 		PhoneNumber: phoneNumber,
-		IsDoctor:    isDoctor,
-		IsPatient:   isPatient,
+		IsDoctor:    role == RoleDoctor,
+		IsPatient:   role == RolePatient,
+		SessionID:   sessionID,
+		Role:        role,
+		Permissions: PermissionsOf(role),
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: expirationTime.Unix(),
 			IssuedAt:  time.Now().Unix(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// The kid header tells verifiers, including other services reading
+	// /.well-known/jwks.json, which key to check the signature with.
+	kid, key := Keys.Signing()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
 
 	// log.Printf("Token claims created - ExpiresAt: %v, IssuedAt: %v", time.Unix(claims.ExpiresAt, 0), time.Unix(claims.IssuedAt, 0))
 
-	signedToken, err := token.SignedString([]byte(config.Cfg.JWTSecret))
+	signedToken, err := token.SignedString(key)
 	if err != nil {
 		// log.Printf("Failed to sign token for UserID %d: %v", userID, err)
 		return "", fmt.Errorf("failed to sign token: %v", err)
@@ -66,6 +101,20 @@ func GenerateToken(userID int, phoneNumber string, isDoctor bool, isPatient bool
 	return signedToken, nil
 }
 
+// verificationKey returns the public key of the Keys key that signed token.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if token.Method != jwt.SigningMethodRS256 {
+		// log.Printf("Invalid signing method: %v", token.Header["alg"])
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := Keys.PublicKey(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
 func VerifyToken(tokenString string) (*Claims, error) {
 	// log.Print("Starting token verification")
 
@@ -76,13 +125,7 @@ func VerifyToken(tokenString string) (*Claims, error) {
 	// log.Printf("Verifying token: %s", tokenPreview)
 
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			// log.Printf("Invalid signing method: %v", token.Header["alg"])
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(config.Cfg.JWTSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey)
 
 	if err != nil {
 		if ve, ok := err.(*jwt.ValidationError); ok {
@@ -114,11 +157,43 @@ func VerifyToken(tokenString string) (*Claims, error) {
 		return nil, errors.New("token has expired")
 	}
 
+	// Fail closed: a token whose session cannot be checked is rejected.
+	if claims.SessionID == "" {
+		return nil, errors.New("token has no session")
+	}
+	if Revocations == nil {
+		return nil, errors.New("session revocation is not configured")
+	}
+	revoked, err := Revocations.Revoked(claims.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("checking session: %v", err)
+	}
+	if revoked {
+		return nil, errors.New("session has been revoked")
+	}
+
 	return claims, nil
 }
 
-func setCORSHeaders(w http.ResponseWriter, origin string) {
+// IsAllowedOrigin reports whether origin is one of the configured CORS origins.
+func IsAllowedOrigin(origin string) bool {
+	for _, allowed := range config.Cfg.CORSOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetCORSHeaders echoes the request origin back when it is allowed by the
+// configuration.
+func SetCORSHeaders(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" || !IsAllowedOrigin(origin) {
+		return
+	}
 	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Add("Vary", "Origin")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Origin, Accept")
 	w.Header().Set("Access-Control-Allow-Credentials", "true")
@@ -129,7 +204,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		// log.Printf("Processing auth middleware for request: %s %s", r.Method, r.URL.Path)
 
 		// Set CORS headers for all responses
-		setCORSHeaders(w, "https://kashan-clininc.liara.run")
+		SetCORSHeaders(w, r)
 
 		// Handle preflight OPTIONS request
 		if r.Method == http.MethodOptions {
@@ -139,22 +214,22 @@ func AuthMiddleware(next http.Handler) http.Handler {
 
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			// log.Printf("No Authorization header present in request from IP: %s", r.RemoteAddr)
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+			slog.WarnContext(r.Context(), "missing authorization header")
+			problem.Write(w, r, problem.CodeUnauthenticated)
 			return
 		}
 
 		bearerToken := strings.Split(authHeader, " ")
 		if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-			// log.Printf("Invalid token format in request from IP: %s", r.RemoteAddr)
-			http.Error(w, "Invalid token format", http.StatusUnauthorized)
+			slog.WarnContext(r.Context(), "invalid authorization header format")
+			problem.Write(w, r, problem.CodeInvalidToken)
 			return
 		}
 
 		claims, err := VerifyToken(bearerToken[1])
 		if err != nil {
-			// log.Printf("Token verification failed for request from IP %s: %v", r.RemoteAddr, err)
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			slog.WarnContext(r.Context(), "token verification failed", "err", err)
+			problem.Write(w, r, problem.CodeInvalidToken)
 			return
 		}
 
@@ -181,7 +256,9 @@ func IsDoctorOrPatient(ctx context.Context) bool {
 	return claims.IsDoctor || claims.IsPatient
 }
 
+// SetUserClaims stores the claims in ctx and adds the user to its log lines.
 func SetUserClaims(ctx context.Context, claims *Claims) context.Context {
+	ctx = logging.WithUser(ctx, claims.UserID, string(claims.Role))
 	return context.WithValue(ctx, UserClaimsKey, claims)
 }
 
@@ -205,144 +282,3 @@ func IsDoctor(ctx context.Context) bool {
 	}
 	return claims.IsDoctor
 }
-
-func DoctorOrPatientAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// log.Printf("Checking doctor/patient authorization for request: %s %s", r.Method, r.URL.Path)
-
-		// Set CORS headers for all responses
-		setCORSHeaders(w, "https://kashan-clininc.liara.run")
-
-		// Handle preflight OPTIONS request
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		token := r.Header.Get("Authorization")
-		if token == "" {
-			// log.Print("No authorization token provided")
-			http.Error(w, "No authorization token provided", http.StatusUnauthorized)
-			return
-		}
-
-		// Remove 'Bearer ' prefix if present
-		token = strings.TrimPrefix(token, "Bearer ")
-
-		// Verify token and get claims
-		claims, err := VerifyToken(token)
-		if err != nil {
-			// log.Printf("Token verification failed: %v", err)
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		// Check if user is either a doctor or a patient
-		if !claims.IsDoctor && !claims.IsPatient {
-			// log.Printf("Unauthorized access attempt - User ID: %d, IsDoctor: %v, IsPatient: %v", claims.UserID, claims.IsDoctor, claims.IsPatient)
-			http.Error(w, "Unauthorized access", http.StatusForbidden)
-			return
-		}
-
-		// log.Printf("Authorization successful - User ID: %d, IsDoctor: %v, IsPatient: %v", claims.UserID, claims.IsDoctor, claims.IsPatient)
-
-		// Add claims to request context
-		ctx := SetUserClaims(r.Context(), claims)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	}
-}
-
-func PatientAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Set CORS headers for all responses
-		setCORSHeaders(w, "https://kashan-clininc.liara.run")
-
-		// Handle preflight OPTIONS request
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		token := r.Header.Get("Authorization")
-		if token == "" {
-			http.Error(w, "No authorization token provided", http.StatusUnauthorized)
-			return
-		}
-
-		// Remove 'Bearer ' prefix if present
-		token = strings.TrimPrefix(token, "Bearer ")
-
-		// Verify token and get claims
-		claims, err := VerifyToken(token)
-		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		// Check if token is for a patient
-		if claims.IsDoctor {
-			http.Error(w, "Unauthorized access", http.StatusForbidden)
-			return
-		}
-
-		// Add claims to request context
-		ctx := SetUserClaims(r.Context(), claims)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	}
-}
-
-func DoctorAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Set CORS headers for all responses
-		setCORSHeaders(w, "https://kashan-clininc.liara.run")
-
-		// Handle preflight OPTIONS request
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		token := r.Header.Get("Authorization")
-		if token == "" {
-			http.Error(w, "No authorization token provided", http.StatusUnauthorized)
-			return
-		}
-
-		// Remove 'Bearer ' prefix if present
-		token = strings.TrimPrefix(token, "Bearer ")
-
-		// Verify token and get claims
-		claims, err := VerifyToken(token)
-		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		// Check if token is for a doctor
-		if !claims.IsDoctor {
-			http.Error(w, "Unauthorized access", http.StatusForbidden)
-			return
-		}
-
-		// Check resource ownership for paths containing doctor ID
-		vars := mux.Vars(r)
-		if doctorID, exists := vars["id"]; exists {
-			requestedID, err := strconv.Atoi(doctorID)
-			if err != nil {
-				http.Error(w, "Invalid doctor ID", http.StatusBadRequest)
-				return
-			}
-
-			// Verify the authenticated doctor is modifying their own availability
-			if claims.UserID != requestedID {
-				// log.Printf("Unauthorized attempt: Doctor %d trying to modify Doctor %d's availability", claims.UserID, requestedID)
-				http.Error(w, "Unauthorized: Can only modify own availability", http.StatusForbidden)
-				return
-			}
-		}
-
-		// Add claims to request context
-		ctx := SetUserClaims(r.Context(), claims)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	}
-}
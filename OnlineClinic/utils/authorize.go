@@ -0,0 +1,262 @@
+package utils
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"onlineClinic/problem"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Role is the kind of account a token was issued to.
+type Role string
+
+const (
+	RolePatient      Role = "patient"
+	RoleDoctor       Role = "doctor"
+	RoleReceptionist Role = "receptionist"
+	RoleAdmin        Role = "admin"
+)
+
+// Permission names one action on one kind of resource.
+type Permission string
+
+const (
+	PermDoctorsList             Permission = "doctors.list"
+	PermDoctorProfileRead       Permission = "doctor_profile.read"
+	PermDoctorProfileWrite      Permission = "doctor_profile.write"
+	PermDoctorDelete            Permission = "doctor.delete"
+	PermDoctorPassword          Permission = "doctor.password"
+	PermDoctorAppointmentsRead  Permission = "doctor_appointments.read"
+	PermDoctorPrescriptionsRead Permission = "doctor_prescriptions.read"
+	PermAvailabilityRead        Permission = "availability.read"
+	PermAvailabilityWrite       Permission = "availability.write"
+	PermAvailabilityClearOwn    Permission = "availability.clear_own"
+	PermPatientProfileRead      Permission = "patient_profile.read"
+	PermPatientInfoRead         Permission = "patient_info.read"
+	PermPatientProfileWrite     Permission = "patient_profile.write"
+	PermPatientDelete           Permission = "patient.delete"
+	PermPatientPassword         Permission = "patient.password"
+	PermPatientAppointmentsRead Permission = "patient_appointments.read"
+	PermAppointmentBook         Permission = "appointment.book"
+	PermAppointmentCancel       Permission = "appointment.cancel"
+	PermPrescriptionRead        Permission = "prescription.read"
+	PermPrescriptionWrite       Permission = "prescription.write"
+	PermProfilePhotoUpload      Permission = "profile_photo.upload"
+	PermChat                    Permission = "chat.use"
+	PermPhoneChange             Permission = "phone.change"
+	PermTwoFactor               Permission = "two_factor.manage"
+	PermAccessLogRead           Permission = "access_log.read"
+	PermAuditRead               Permission = "audit.read"
+	PermDoctorDocumentsWrite    Permission = "doctor_documents.write"
+	PermDoctorVerificationRead  Permission = "doctor_verification.read"
+	PermDoctorVerify            Permission = "doctor.verify"
+	PermDependentsManage        Permission = "dependents.manage"
+)
+
+// Scope limits a granted permission.
+type Scope string
+
+const (
+	// ScopeOwn allows the action only on resources the user owns or is
+	// related to, as decided by the route's ResourceResolver. Routes without
+	// a resolver act on the user's own account, or on a resource named in the
+	// request body that their handler checks.
+	ScopeOwn Scope = "own"
+
+	// ScopeAny allows the action on every resource.
+	ScopeAny Scope = "any"
+)
+
+// Policy grants permissions to roles. Tokens carry the permissions of their
+// role, so a change here applies once the user's access token is renewed.
+var Policy = map[Role]map[Permission]Scope{
+	RolePatient: {
+		PermDoctorsList:             ScopeAny,
+		PermAvailabilityRead:        ScopeAny,
+		PermPatientProfileRead:      ScopeOwn,
+		PermPatientInfoRead:         ScopeOwn,
+		PermPatientProfileWrite:     ScopeOwn,
+		PermPatientDelete:           ScopeOwn,
+		PermPatientPassword:         ScopeOwn,
+		PermPatientAppointmentsRead: ScopeOwn,
+		PermAppointmentBook:         ScopeOwn,
+		PermAppointmentCancel:       ScopeOwn,
+		PermPrescriptionRead:        ScopeOwn,
+		PermProfilePhotoUpload:      ScopeOwn,
+		PermChat:                    ScopeOwn,
+		PermPhoneChange:             ScopeOwn,
+		PermAccessLogRead:           ScopeOwn,
+		PermDependentsManage:        ScopeOwn, // Their own dependents
+	},
+	RoleDoctor: {
+		PermDoctorsList:             ScopeAny,
+		PermDoctorProfileRead:       ScopeOwn,
+		PermDoctorProfileWrite:      ScopeOwn,
+		PermDoctorDelete:            ScopeOwn,
+		PermDoctorPassword:          ScopeOwn,
+		PermDoctorAppointmentsRead:  ScopeOwn,
+		PermDoctorPrescriptionsRead: ScopeOwn,
+		PermAvailabilityRead:        ScopeAny,
+		PermAvailabilityWrite:       ScopeOwn,
+		PermAvailabilityClearOwn:    ScopeOwn,
+		PermPatientInfoRead:         ScopeOwn, // Patients they have appointments with
+		PermPatientAppointmentsRead: ScopeOwn, // Patients they have appointments with
+		PermAppointmentCancel:       ScopeOwn,
+		PermPrescriptionRead:        ScopeOwn,
+		PermPrescriptionWrite:       ScopeOwn,
+		PermProfilePhotoUpload:      ScopeOwn,
+		PermChat:                    ScopeOwn,
+		PermPhoneChange:             ScopeOwn,
+		PermTwoFactor:               ScopeOwn,
+		PermDoctorDocumentsWrite:    ScopeOwn,
+		PermDoctorVerificationRead:  ScopeOwn,
+	},
+	RoleReceptionist: {
+		PermDoctorsList:            ScopeAny,
+		PermDoctorProfileRead:      ScopeAny,
+		PermDoctorAppointmentsRead: ScopeAny,
+		PermAvailabilityRead:       ScopeAny,
+		PermAvailabilityWrite:      ScopeAny,
+		PermPatientProfileRead:     ScopeAny,
+		PermPatientInfoRead:        ScopeAny,
+		PermAppointmentCancel:      ScopeAny,
+	},
+	RoleAdmin: {
+		PermDoctorsList:            ScopeAny,
+		PermDoctorProfileRead:      ScopeAny,
+		PermDoctorProfileWrite:     ScopeAny,
+		PermDoctorDelete:           ScopeAny,
+		PermDoctorAppointmentsRead: ScopeAny,
+		PermAvailabilityRead:       ScopeAny,
+		PermAvailabilityWrite:      ScopeAny,
+		PermPatientProfileRead:     ScopeAny,
+		PermPatientInfoRead:        ScopeAny,
+		PermPatientProfileWrite:    ScopeAny,
+		PermPatientDelete:          ScopeAny,
+		PermAppointmentCancel:      ScopeAny,
+		PermAccessLogRead:          ScopeAny,
+		PermAuditRead:              ScopeAny,
+		PermDoctorVerificationRead: ScopeAny,
+		PermDoctorVerify:           ScopeAny,
+		PermDependentsManage:       ScopeAny,
+	},
+}
+
+// PermissionsOf returns a copy of the permissions Policy grants role.
+func PermissionsOf(role Role) map[Permission]Scope {
+	granted := make(map[Permission]Scope, len(Policy[role]))
+	for p, s := range Policy[role] {
+		granted[p] = s
+	}
+	return granted
+}
+
+// Principal identifies one account.
+type Principal struct {
+	Role Role
+	ID   int
+}
+
+var (
+	// ErrInvalidResourceID is returned by a ResourceResolver for a malformed
+	// ID in the request.
+	ErrInvalidResourceID = errors.New("invalid resource ID")
+
+	// ErrResourceNotFound is returned by a ResourceResolver when the
+	// resource does not exist.
+	ErrResourceNotFound = errors.New("resource not found")
+)
+
+// ResourceResolver returns the accounts that own the resource a request
+// targets.
+type ResourceResolver func(r *http.Request) ([]Principal, error)
+
+// DoctorInPath resolves the doctor whose ID is the path variable name.
+func DoctorInPath(name string) ResourceResolver {
+	return func(r *http.Request) ([]Principal, error) {
+		id, err := PathID(r, name)
+		if err != nil {
+			return nil, err
+		}
+		return []Principal{{Role: RoleDoctor, ID: id}}, nil
+	}
+}
+
+// PatientInPath resolves the patient whose ID is the path variable name.
+func PatientInPath(name string) ResourceResolver {
+	return func(r *http.Request) ([]Principal, error) {
+		id, err := PathID(r, name)
+		if err != nil {
+			return nil, err
+		}
+		return []Principal{{Role: RolePatient, ID: id}}, nil
+	}
+}
+
+// PathID parses the path variable name as an ID, returning
+// ErrInvalidResourceID if it is not one.
+func PathID(r *http.Request, name string) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidResourceID
+	}
+	return id, nil
+}
+
+// Authorize lets a request through if its token grants permission. For a
+// permission with ScopeOwn, the user must also be one of the owners that
+// resolve returns. It must run after AuthMiddleware.
+func Authorize(permission Permission, resolve ResourceResolver) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := GetUserClaims(r.Context())
+			if !ok {
+				problem.Write(w, r, problem.CodeUnauthenticated)
+				return
+			}
+
+			scope, granted := claims.Permissions[permission]
+			if !granted {
+				slog.WarnContext(r.Context(), "permission denied", "permission", permission)
+				problem.Write(w, r, problem.CodeForbidden)
+				return
+			}
+
+			if scope == ScopeOwn && resolve != nil {
+				owners, err := resolve(r)
+				switch {
+				case errors.Is(err, ErrInvalidResourceID):
+					problem.Write(w, r, problem.CodeInvalidID)
+					return
+				case errors.Is(err, ErrResourceNotFound):
+					problem.Write(w, r, problem.CodeNotFound)
+					return
+				case err != nil:
+					slog.ErrorContext(r.Context(), "error resolving resource owner", "permission", permission, "err", err)
+					problem.Write(w, r, problem.CodeInternal)
+					return
+				}
+				if !claims.Is(owners...) {
+					slog.WarnContext(r.Context(), "access to another user's resource denied", "permission", permission, "owners", owners)
+					problem.Write(w, r, problem.CodeForbidden)
+					return
+				}
+			}
+
+			next(w, r)
+		}
+	}
+}
+
+// Is reports whether the claims belong to one of the principals.
+func (c *Claims) Is(principals ...Principal) bool {
+	for _, p := range principals {
+		if p.Role == c.Role && p.ID == c.UserID {
+			return true
+		}
+	}
+	return false
+}
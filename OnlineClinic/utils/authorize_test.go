@@ -0,0 +1,65 @@
+// utils/authorize_test.go
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAuthorize(t *testing.T) {
+	patient := &Claims{UserID: 1, Role: RolePatient, Permissions: PermissionsOf(RolePatient)}
+	doctor := &Claims{UserID: 1, Role: RoleDoctor, Permissions: PermissionsOf(RoleDoctor)}
+	admin := &Claims{UserID: 1, Role: RoleAdmin, Permissions: PermissionsOf(RoleAdmin)}
+	failing := func(err error) ResourceResolver {
+		return func(*http.Request) ([]Principal, error) { return nil, err }
+	}
+
+	tests := []struct {
+		name       string
+		claims     *Claims
+		permission Permission
+		resolve    ResourceResolver
+		id         string
+		want       int
+	}{
+		{"no claims", nil, PermPatientProfileRead, PatientInPath("id"), "1", http.StatusUnauthorized},
+		{"own patient", patient, PermPatientProfileRead, PatientInPath("id"), "1", http.StatusNoContent},
+		{"other patient", patient, PermPatientProfileRead, PatientInPath("id"), "2", http.StatusForbidden},
+		{"other patient update", patient, PermPatientProfileWrite, PatientInPath("id"), "2", http.StatusForbidden},
+		{"other patient delete", patient, PermPatientDelete, PatientInPath("id"), "2", http.StatusForbidden},
+		{"patient without permission", patient, PermPrescriptionWrite, nil, "1", http.StatusForbidden},
+		{"doctor with the same ID as the patient", doctor, PermPatientProfileRead, PatientInPath("id"), "1", http.StatusForbidden},
+		{"own doctor", doctor, PermAvailabilityWrite, DoctorInPath("id"), "1", http.StatusNoContent},
+		{"other doctor", doctor, PermAvailabilityWrite, DoctorInPath("id"), "2", http.StatusForbidden},
+		{"patient as doctor", patient, PermAvailabilityRead, DoctorInPath("id"), "1", http.StatusNoContent},
+		{"any scope skips the resolver", admin, PermPatientDelete, failing(errors.New("not called")), "2", http.StatusNoContent},
+		{"invalid ID", patient, PermPatientProfileRead, PatientInPath("id"), "x", http.StatusBadRequest},
+		{"zero ID", patient, PermPatientProfileRead, PatientInPath("id"), "0", http.StatusBadRequest},
+		{"unknown resource", patient, PermAppointmentCancel, failing(ErrResourceNotFound), "1", http.StatusNotFound},
+		{"resolver error", patient, PermAppointmentCancel, failing(errors.New("db down")), "1", http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Authorize(tt.permission, tt.resolve)(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			r := httptest.NewRequest("GET", "/resource/"+tt.id, nil)
+			r = mux.SetURLVars(r, map[string]string{"id": tt.id})
+			if tt.claims != nil {
+				r = r.WithContext(SetUserClaims(r.Context(), tt.claims))
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
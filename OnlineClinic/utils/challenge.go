@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// challengePurpose marks challenge tokens, so that no other token signed by
+// Keys can be exchanged for a session.
+const challengePurpose = "2fa"
+
+// ChallengeClaims are carried by the token a doctor gets for a correct
+// password while a second factor is still missing. It has no session, so
+// VerifyToken and AuthMiddleware reject it.
+type ChallengeClaims struct {
+	UserID      int    `json:"user_id"`
+	PhoneNumber string `json:"phone_number"`
+	Purpose     string `json:"purpose"`
+	jwt.StandardClaims
+}
+
+// GenerateChallengeToken issues a challenge token for userID that expires
+// after ttl.
+func GenerateChallengeToken(userID int, phoneNumber string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := ChallengeClaims{
+		UserID:      userID,
+		PhoneNumber: phoneNumber,
+		Purpose:     challengePurpose,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: now.Add(ttl).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+	}
+
+	kid, key := Keys.Signing()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signedToken, err := token.SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %v", err)
+	}
+	return signedToken, nil
+}
+
+// VerifyChallengeToken checks a token issued by GenerateChallengeToken.
+func VerifyChallengeToken(tokenString string) (*ChallengeClaims, error) {
+	claims := &ChallengeClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid challenge token: %v", err)
+	}
+	if !token.Valid || claims.Purpose != challengePurpose || claims.ExpiresAt == 0 {
+		return nil, errors.New("invalid challenge token")
+	}
+	return claims, nil
+}
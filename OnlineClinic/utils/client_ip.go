@@ -0,0 +1,66 @@
+// utils/client_ip.go
+package utils
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies are the reverse proxies whose X-Forwarded-For and
+// X-Real-IP headers ClientIP believes. main sets them from the
+// trusted_proxies setting; with none, the headers are ignored.
+var TrustedProxies []netip.Prefix
+
+// ClientIP returns the IP address of the client, without the port. That is
+// the peer, unless the peer is a trusted proxy: then it is the rightmost
+// address in X-Forwarded-For that is not a trusted proxy, or X-Real-IP.
+// Addresses left of that were set by the client and could be forged.
+func ClientIP(r *http.Request) string {
+	peer := remoteIP(r.RemoteAddr)
+	addr, err := netip.ParseAddr(peer)
+	if err != nil || !isTrustedProxy(addr) {
+		return peer
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		client := peer
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			client = hop.Unmap().String()
+			if !isTrustedProxy(hop) {
+				break
+			}
+		}
+		return client
+	}
+
+	if realIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return realIP.Unmap().String()
+	}
+	return peer
+}
+
+// remoteIP strips the port from an http.Request RemoteAddr.
+func remoteIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return strings.TrimSpace(remoteAddr)
+	}
+	return host
+}
+
+func isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
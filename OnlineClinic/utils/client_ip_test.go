@@ -0,0 +1,54 @@
+// utils/client_ip_test.go
+package utils
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	defer func(previous []netip.Prefix) { TrustedProxies = previous }(TrustedProxies)
+	TrustedProxies = []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.1/32"),
+	}
+
+	tests := []struct {
+		name      string
+		peer      string
+		forwarded []string
+		realIP    string
+		want      string
+	}{
+		{"direct client", "203.0.113.7:5000", nil, "", "203.0.113.7"},
+		{"direct client forging X-Forwarded-For", "203.0.113.7:5000", []string{"198.51.100.1"}, "", "203.0.113.7"},
+		{"direct client forging X-Real-IP", "203.0.113.7:5000", nil, "198.51.100.1", "203.0.113.7"},
+		{"behind proxy", "10.0.0.2:5000", []string{"203.0.113.7"}, "", "203.0.113.7"},
+		{"behind proxy with forged hop", "10.0.0.2:5000", []string{"198.51.100.1, 203.0.113.7"}, "", "203.0.113.7"},
+		{"behind two proxies", "10.0.0.2:5000", []string{"203.0.113.7, 192.0.2.1"}, "", "203.0.113.7"},
+		{"header split over lines", "10.0.0.2:5000", []string{"198.51.100.1", "203.0.113.7"}, "", "203.0.113.7"},
+		{"only proxies", "10.0.0.2:5000", []string{"10.0.0.3"}, "", "10.0.0.3"},
+		{"garbage hop", "10.0.0.2:5000", []string{"203.0.113.7, nonsense"}, "", "10.0.0.2"},
+		{"X-Real-IP behind proxy", "10.0.0.2:5000", nil, "203.0.113.7", "203.0.113.7"},
+		{"proxy without headers", "10.0.0.2:5000", nil, "", "10.0.0.2"},
+		{"IPv6 client behind proxy", "[::ffff:10.0.0.2]:5000", []string{"2001:db8::1"}, "", "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/login/patient", nil)
+			r.RemoteAddr = tt.peer
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			if got := ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
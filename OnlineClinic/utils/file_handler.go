@@ -1,9 +1,12 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
+	"onlineClinic/config"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,29 +15,47 @@ import (
 
 const (
 	MaxFileSize = 10 << 20 // 10 MB
-	UploadDir   = "./uploads"
 )
 
-func init() {
+// Errors for uploads that are refused; other errors of the Save functions
+// are failures of the server.
+var (
+	ErrFileTooLarge        = errors.New("file is too large")
+	ErrUnsupportedFileType = errors.New("unsupported file type")
+)
+
+// UploadDir returns the configured root directory for uploaded files.
+func UploadDir() string {
+	return config.Cfg.UploadDir
+}
+
+// EnsureUploadDirs creates the directory layout used for uploaded files.
+func EnsureUploadDirs() error {
 	dirs := []string{
-		filepath.Join(UploadDir, "profile", "doctors"),
-		filepath.Join(UploadDir, "profile", "patients"),
-		filepath.Join(UploadDir, "chat", "doctors"),
-		filepath.Join(UploadDir, "chat", "patients"),
+		filepath.Join(UploadDir(), "profile", "doctors"),
+		filepath.Join(UploadDir(), "profile", "patients"),
+		filepath.Join(UploadDir(), "chat", "doctors"),
+		filepath.Join(UploadDir(), "chat", "patients"),
 	}
 
 	for _, dir := range dirs {
 		if err := os.MkdirAll(dir, 0755); err != nil {
-			// log.Printf("Error creating directory %s: %v", dir, err)
+			return fmt.Errorf("failed to create upload directory %s: %v", dir, err)
 		}
 	}
+	// License documents are private and never served as static files.
+	dir := filepath.Join(config.Cfg.DocumentDir, "doctors")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create document directory %s: %v", dir, err)
+	}
+	return nil
 }
 
 func SaveFile(file multipart.File, header *multipart.FileHeader, userID int, fileType string, isDoctor bool) (string, error) {
 	// log.Printf("Processing file upload: %s, type: %s, size: %d, isDoctor: %v", header.Filename, fileType, header.Size, isDoctor)
 
 	if header.Size > MaxFileSize {
-		return "", fmt.Errorf("file size exceeds maximum limit of %d bytes", MaxFileSize)
+		return "", fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, MaxFileSize)
 	}
 
 	ext := strings.ToLower(filepath.Ext(header.Filename))
@@ -45,7 +66,7 @@ func SaveFile(file multipart.File, header *multipart.FileHeader, userID int, fil
 			".png":  true,
 		}
 		if !allowedTypes[ext] {
-			return "", fmt.Errorf("unsupported file type for profile photo: %s. Allowed types: jpg, jpeg, png", ext)
+			return "", fmt.Errorf("%w for profile photo: %s. Allowed types: jpg, jpeg, png", ErrUnsupportedFileType, ext)
 		}
 	} else {
 		allowedTypes := map[string]bool{
@@ -62,7 +83,7 @@ func SaveFile(file multipart.File, header *multipart.FileHeader, userID int, fil
 			".zip":  true,
 		}
 		if !allowedTypes[ext] {
-			return "", fmt.Errorf("unsupported file type: %s", ext)
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedFileType, ext)
 		}
 	}
 
@@ -71,7 +92,7 @@ func SaveFile(file multipart.File, header *multipart.FileHeader, userID int, fil
 		userType = "doctors"
 	}
 
-	uploadPath := filepath.Join(UploadDir, fileType, userType, fmt.Sprintf("%d", userID))
+	uploadPath := filepath.Join(UploadDir(), fileType, userType, fmt.Sprintf("%d", userID))
 	if err := os.MkdirAll(uploadPath, 0755); err != nil {
 		// log.Printf("Error creating directory %s: %v", uploadPath, err)
 		return "", fmt.Errorf("failed to create upload directory: %v", err)
@@ -111,7 +132,7 @@ func SaveFile(file multipart.File, header *multipart.FileHeader, userID int, fil
 }
 
 func GetFilePath(relativePath string) string {
-	return filepath.Join(UploadDir, relativePath)
+	return filepath.Join(UploadDir(), relativePath)
 }
 
 func DeleteFile(relativePath string) error {
@@ -127,7 +148,7 @@ func SaveChatFile(file multipart.File, header *multipart.FileHeader, userID int,
 	// log.Printf("Processing chat file upload: %s, size: %d, isDoctor: %v", header.Filename, header.Size, isDoctor)
 
 	if header.Size > MaxFileSize {
-		return "", fmt.Errorf("file size exceeds maximum limit of %d bytes", MaxFileSize)
+		return "", fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, MaxFileSize)
 	}
 
 	ext := strings.ToLower(filepath.Ext(header.Filename))
@@ -145,7 +166,7 @@ func SaveChatFile(file multipart.File, header *multipart.FileHeader, userID int,
 		".zip":  true,
 	}
 	if !allowedTypes[ext] {
-		return "", fmt.Errorf("unsupported file type: %s. Allowed types: jpg, jpeg, png, pdf, doc, docx, mp3, wav, mp4, avi", ext)
+		return "", fmt.Errorf("%w: %s. Allowed types: jpg, jpeg, png, pdf, doc, docx, mp3, wav, mp4, avi", ErrUnsupportedFileType, ext)
 	}
 
 	userType := "patients"
@@ -153,7 +174,7 @@ func SaveChatFile(file multipart.File, header *multipart.FileHeader, userID int,
 		userType = "doctors"
 	}
 
-	uploadPath := filepath.Join(UploadDir, "chat", userType, fmt.Sprintf("%d", userID))
+	uploadPath := filepath.Join(UploadDir(), "chat", userType, fmt.Sprintf("%d", userID))
 	if err := os.MkdirAll(uploadPath, 0755); err != nil {
 		// log.Printf("Error creating directory %s: %v", uploadPath, err)
 		return "", fmt.Errorf("failed to create upload directory: %v", err)
@@ -179,3 +200,61 @@ func SaveChatFile(file multipart.File, header *multipart.FileHeader, userID int,
 	// log.Printf("Chat file saved successfully. Relative path: %s", relativePath)
 	return relativePath, nil
 }
+
+// documentTypes are the content types accepted for doctors' license
+// documents, by the extension they must be uploaded with.
+var documentTypes = map[string]string{
+	".pdf":  "application/pdf",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+}
+
+// SaveDoctorDocument stores a license document of a doctor in the private
+// document directory. The content must match the extension. It returns the
+// path relative to that directory and the content type.
+func SaveDoctorDocument(file multipart.File, header *multipart.FileHeader, doctorID int) (string, string, error) {
+	if header.Size > MaxFileSize {
+		return "", "", fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, MaxFileSize)
+	}
+
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	contentType, ok := documentTypes[ext]
+	if !ok {
+		return "", "", fmt.Errorf("%w: %s. Allowed types: pdf, jpg, jpeg, png", ErrUnsupportedFileType, ext)
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", "", fmt.Errorf("failed to read file: %v", err)
+	}
+	if http.DetectContentType(head[:n]) != contentType {
+		return "", "", fmt.Errorf("%w: file content is not %s", ErrUnsupportedFileType, contentType)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", "", fmt.Errorf("failed to read file: %v", err)
+	}
+
+	dir := filepath.Join(config.Cfg.DocumentDir, "doctors", fmt.Sprintf("%d", doctorID))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", fmt.Errorf("failed to create document directory: %v", err)
+	}
+
+	filename := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
+	dst, err := os.OpenFile(filepath.Join(dir, filename), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create file: %v", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, file); err != nil {
+		return "", "", fmt.Errorf("failed to save file: %v", err)
+	}
+
+	return filepath.ToSlash(filepath.Join("doctors", fmt.Sprintf("%d", doctorID), filename)), contentType, nil
+}
+
+// DocumentPath returns the file of a document saved by SaveDoctorDocument.
+func DocumentPath(relativePath string) string {
+	return filepath.Join(config.Cfg.DocumentDir, filepath.FromSlash(relativePath))
+}
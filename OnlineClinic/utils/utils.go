@@ -42,16 +42,6 @@ func SanitizeFileName(filename string) string {
 	return re.ReplaceAllString(filename, "_")
 }
 
-func ValidatePhoneNumber(phone string) bool {
-	matched, _ := regexp.MatchString(`^09\d{9}$`, phone)
-	return matched
-}
-
-func ValidateNationalCode(code string) bool {
-	matched, _ := regexp.MatchString(`^\d{10}$`, code)
-	return matched
-}
-
 // Convert Gregorian date to Solar (Hijri) date
 func GregorianToSolar(date time.Time) string {
 	p := ptime.New(date)
@@ -167,10 +157,6 @@ func IsSolarDateValid(date string) bool {
 	return err == nil
 }
 
-func RespondWithError(w http.ResponseWriter, code int, message string) {
-	RespondWithJSON(w, code, map[string]string{"error": message})
-}
-
 func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, _ := json.Marshal(payload)
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,95 @@
+package validation
+
+import "unicode"
+
+// Age ranges of accounts, in years.
+const (
+	MinPatientAge = 0
+	MaxPatientAge = 130
+	MinDoctorAge  = 23
+	MaxDoctorAge  = 100
+)
+
+// mobilePrefixes are the first four digits of numbers Iranian mobile
+// operators hand out.
+var mobilePrefixes = map[string]bool{
+	// Hamrah-e Avval
+	"0910": true, "0911": true, "0912": true, "0913": true, "0914": true,
+	"0915": true, "0916": true, "0917": true, "0918": true, "0919": true,
+	"0990": true, "0991": true, "0992": true, "0993": true, "0994": true,
+	"0995": true, "0996": true,
+	// Irancell
+	"0900": true, "0901": true, "0902": true, "0903": true, "0904": true,
+	"0905": true, "0930": true, "0933": true, "0935": true, "0936": true,
+	"0937": true, "0938": true, "0939": true, "0941": true,
+	// Rightel
+	"0920": true, "0921": true, "0922": true, "0923": true,
+	// Other operators and MVNOs
+	"0931": true, "0932": true, "0934": true, "0998": true, "0999": true,
+}
+
+// Mobile reports whether phone is an 11-digit Iranian mobile number with a
+// known operator prefix.
+func Mobile(phone string) bool {
+	return len(phone) == 11 && digits(phone) && mobilePrefixes[phone[:4]]
+}
+
+// NationalCode reports whether code is a ten-digit Iranian national code
+// with a valid check digit. Codes of one repeated digit pass the checksum
+// but are never issued.
+func NationalCode(code string) bool {
+	if len(code) != 10 || !digits(code) {
+		return false
+	}
+
+	same := true
+	sum := 0
+	for i := 0; i < 9; i++ {
+		same = same && code[i] == code[0]
+		sum += int(code[i]-'0') * (10 - i)
+	}
+	if same && code[9] == code[0] {
+		return false
+	}
+
+	check := sum % 11
+	if check >= 2 {
+		check = 11 - check
+	}
+	return int(code[9]-'0') == check
+}
+
+// Name reports whether name is 2 to 50 characters of Persian or Latin
+// letters, with spaces, hyphens, apostrophes and zero-width non-joiners
+// between words.
+func Name(name string) bool {
+	runes := []rune(name)
+	if len(runes) < 2 || len(runes) > 50 {
+		return false
+	}
+	letters := 0
+	for _, r := range runes {
+		switch {
+		case unicode.IsLetter(r) && unicode.In(r, unicode.Latin, unicode.Arabic):
+			letters++
+		case r == ' ' || r == '-' || r == '\'' || r == '\u200c':
+		default:
+			return false
+		}
+	}
+	return letters >= 2
+}
+
+// Gender reports whether gender is one of the stored genders.
+func Gender(gender string) bool {
+	return gender == "man" || gender == "woman"
+}
+
+func digits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
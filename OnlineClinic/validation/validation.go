@@ -0,0 +1,100 @@
+// Package validation checks user input field by field. A Validator runs
+// every check of a request and collects one error per failing field, so
+// clients can show all problems at once.
+package validation
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FieldError is the first problem found with one field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors lists the failing fields of a request in the order they were
+// checked.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return "invalid input: " + strings.Join(msgs, "; ")
+}
+
+// Validator collects field errors. A field that already failed is not
+// checked again, so each field reports its first problem only.
+type Validator struct {
+	errs Errors
+}
+
+// Failed reports whether field already has an error.
+func (v *Validator) Failed(field string) bool {
+	for _, fe := range v.errs {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Check records message for field unless ok.
+func (v *Validator) Check(ok bool, field, message string) {
+	if !ok && !v.Failed(field) {
+		v.errs = append(v.errs, FieldError{Field: field, Message: message})
+	}
+}
+
+// Required records an error for field if value is blank.
+func (v *Validator) Required(field, value string) {
+	v.Check(strings.TrimSpace(value) != "", field, "is required")
+}
+
+// MaxLength records an error for field if value has more than max
+// characters.
+func (v *Validator) MaxLength(field, value string, max int) {
+	v.Check(len([]rune(value)) <= max, field, "must be at most "+strconv.Itoa(max)+" characters")
+}
+
+// NationalCode checks that a required field holds a valid national code.
+func (v *Validator) NationalCode(field, code string) {
+	v.Required(field, code)
+	v.Check(NationalCode(code), field, "is not a valid national code")
+}
+
+// Mobile checks that a required field holds an Iranian mobile number.
+func (v *Validator) Mobile(field, phone string) {
+	v.Required(field, phone)
+	v.Check(Mobile(phone), field, "must be an Iranian mobile number such as 09121234567")
+}
+
+// Name checks that a required field holds a person's name.
+func (v *Validator) Name(field, name string) {
+	v.Required(field, name)
+	v.Check(Name(name), field, "must be 2 to 50 Persian or Latin letters")
+}
+
+// Gender checks that a required field holds one of the genders.
+func (v *Validator) Gender(field, gender string) {
+	v.Required(field, gender)
+	v.Check(Gender(gender), field, "must be either 'man' or 'woman'")
+}
+
+// Age checks that an optional age lies within [min, max].
+func (v *Validator) Age(field string, age *int, min, max int) {
+	if age != nil {
+		v.Check(*age >= min && *age <= max, field, "must be between "+strconv.Itoa(min)+" and "+strconv.Itoa(max))
+	}
+}
+
+// Err returns the collected errors as Errors, or nil if every check passed.
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}